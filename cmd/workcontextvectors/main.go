@@ -0,0 +1,58 @@
+// Command workcontextvectors writes the Work Context conformance corpus.
+//
+//	go run ./cmd/workcontextvectors -o workcontextvectors/corpus_v1.json
+//
+// Without -o the corpus is written to standard output.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/codefly-dev/sdk-go/workcontextvectors"
+)
+
+func main() {
+	output := flag.String("o", "", "write the corpus to this file instead of standard output")
+	flag.Parse()
+	if err := run(*output); err != nil {
+		fmt.Fprintf(os.Stderr, "workcontextvectors: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(output string) error {
+	corpus, err := workcontextvectors.Generate()
+	if err != nil {
+		return err
+	}
+	encoded, err := corpus.Marshal()
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		_, err = os.Stdout.Write(encoded)
+		return err
+	}
+	// Write next to the target and rename, so an interrupted run never leaves
+	// a truncated corpus behind.
+	temporary, err := os.CreateTemp(filepath.Dir(output), ".workcontextvectors-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temporary.Name())
+	if _, err := temporary.Write(encoded); err != nil {
+		_ = temporary.Close()
+		return err
+	}
+	if err := temporary.Chmod(0o644); err != nil {
+		_ = temporary.Close()
+		return err
+	}
+	if err := temporary.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temporary.Name(), output)
+}
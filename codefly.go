@@ -81,6 +81,9 @@ func Context() context.Context {
 var (
 	environmentVariablesMu sync.RWMutex
 	environmentVariables   []string
+	// environmentReloaded is closed and replaced by every reload, waking the
+	// endpoint watchers waiting on it.
+	environmentReloaded = make(chan struct{})
 )
 
 func LoadEnvironmentVariables() error {
@@ -110,10 +113,20 @@ func LoadEnvironmentVariables() error {
 
 	environmentVariablesMu.Lock()
 	environmentVariables = snapshot
+	close(environmentReloaded)
+	environmentReloaded = make(chan struct{})
 	environmentVariablesMu.Unlock()
 	return nil
 }
 
+// environmentReloadSignal returns a channel closed by the next
+// LoadEnvironmentVariables.
+func environmentReloadSignal() <-chan struct{} {
+	environmentVariablesMu.RLock()
+	defer environmentVariablesMu.RUnlock()
+	return environmentReloaded
+}
+
 func codeflyEnvironmentVariables() []string {
 	environmentVariablesMu.RLock()
 	defer environmentVariablesMu.RUnlock()
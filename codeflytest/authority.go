@@ -0,0 +1,278 @@
+package codeflytest
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	basev0 "github.com/codefly-dev/core/generated/go/codefly/base/v0"
+	codefly "github.com/codefly-dev/sdk-go"
+)
+
+// DefaultIssuer is the issuer of an Authority without one.
+const DefaultIssuer = "https://authority.codefly.test/work-context"
+
+// AuthorityOptions configures NewAuthority. Every field is optional.
+type AuthorityOptions struct {
+	Issuer string
+	// KeyID names the first signing key; it defaults to "test-key-1".
+	KeyID string
+	// Clock drives minting and the verifiers the authority builds. It
+	// defaults to NewClock(time.Time{}).
+	Clock            *Clock
+	DelegationPolicy *codefly.WorkContextDelegationPolicy
+	LineageRecorder  codefly.WorkContextLineageRecorder
+}
+
+// Authority is an in-memory Work Context authority: it mints tokens with
+// deterministic keys and publishes its keys as a JWKS over HTTP.
+type Authority struct {
+	t         testing.TB
+	options   AuthorityOptions
+	clock     *Clock
+	mu        sync.Mutex
+	keyID     string
+	signer    *codefly.WorkContextSigner
+	published map[string]ed25519.PublicKey
+	server    *httptest.Server
+}
+
+// NewAuthority returns an authority with one signing key. Keys derive from
+// their key IDs, so the same IDs always produce the same keys.
+func NewAuthority(t testing.TB, options AuthorityOptions) *Authority {
+	t.Helper()
+	if options.Issuer == "" {
+		options.Issuer = DefaultIssuer
+	}
+	if options.KeyID == "" {
+		options.KeyID = "test-key-1"
+	}
+	if options.Clock == nil {
+		options.Clock = NewClock(time.Time{})
+	}
+	a := &Authority{t: t, options: options, clock: options.Clock, published: map[string]ed25519.PublicKey{}}
+	a.Rotate(options.KeyID)
+	return a
+}
+
+// Clock returns the authority's clock.
+func (a *Authority) Clock() *Clock {
+	return a.clock
+}
+
+// Issuer returns the issuer claim of minted tokens.
+func (a *Authority) Issuer() string {
+	return a.options.Issuer
+}
+
+// KeyID returns the current signing key ID.
+func (a *Authority) KeyID() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.keyID
+}
+
+// Rotate starts signing with keyID. Earlier keys stay published, as during a
+// real rotation, until Retire removes them. Like a real authority, the signer
+// only exchanges parents signed with its current key.
+func (a *Authority) Rotate(keyID string) {
+	a.t.Helper()
+	privateKey := TestPrivateKey(keyID)
+	signer, err := codefly.NewWorkContextSigner(codefly.WorkContextSignerOptions{
+		Issuer:           a.options.Issuer,
+		KeyID:            keyID,
+		PrivateKey:       privateKey,
+		Now:              a.clock.Now,
+		DelegationPolicy: a.options.DelegationPolicy,
+		LineageRecorder:  a.options.LineageRecorder,
+	})
+	if err != nil {
+		a.t.Fatalf("codeflytest: new Work Context signer: %v", err)
+	}
+	publicKey, _ := privateKey.Public().(ed25519.PublicKey)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.keyID, a.signer = keyID, signer
+	a.published[keyID] = publicKey
+}
+
+// Retire stops publishing keyID. Tokens signed with it no longer verify
+// against verifiers built afterwards or JWKS fetched afterwards.
+func (a *Authority) Retire(keyID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.published, keyID)
+}
+
+// Signer returns the current signer for tests that need the full API.
+func (a *Authority) Signer() *codefly.WorkContextSigner {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.signer
+}
+
+// PublicKeys returns the published keys.
+func (a *Authority) PublicKeys() map[string]ed25519.PublicKey {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	keys := make(map[string]ed25519.PublicKey, len(a.published))
+	for keyID, publicKey := range a.published {
+		keys[keyID] = publicKey
+	}
+	return keys
+}
+
+// JWKS returns the published keys as a JWKS document.
+func (a *Authority) JWKS() []byte {
+	a.t.Helper()
+	a.mu.Lock()
+	encoded, err := a.jwksLocked()
+	a.mu.Unlock()
+	if err != nil {
+		a.t.Fatalf("codeflytest: encode JWKS: %v", err)
+	}
+	return encoded
+}
+
+// jwksLocked encodes the published keys. It does not touch t, so the JWKS
+// server can call it off the test goroutine.
+func (a *Authority) jwksLocked() ([]byte, error) {
+	keyIDs := make([]string, 0, len(a.published))
+	for keyID := range a.published {
+		keyIDs = append(keyIDs, keyID)
+	}
+	sort.Strings(keyIDs)
+	type jwk struct {
+		KeyType string `json:"kty"`
+		Curve   string `json:"crv"`
+		Use     string `json:"use"`
+		Alg     string `json:"alg"`
+		KeyID   string `json:"kid"`
+		X       string `json:"x"`
+	}
+	document := struct {
+		Keys []jwk `json:"keys"`
+	}{Keys: []jwk{}}
+	for _, keyID := range keyIDs {
+		document.Keys = append(document.Keys, jwk{
+			KeyType: "OKP", Curve: "Ed25519", Use: "sig", Alg: "EdDSA",
+			KeyID: keyID, X: base64.RawURLEncoding.EncodeToString(a.published[keyID]),
+		})
+	}
+	return json.Marshal(document)
+}
+
+// JWKSServer starts, on first use, an HTTP server that always serves the
+// current JWKS. It is closed when the test ends.
+func (a *Authority) JWKSServer() *httptest.Server {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.server == nil {
+		a.server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+			a.mu.Lock()
+			encoded, err := a.jwksLocked()
+			a.mu.Unlock()
+			if err != nil {
+				http.Error(writer, fmt.Sprintf("encode JWKS: %v", err), http.StatusInternalServerError)
+				return
+			}
+			writer.Header().Set("Content-Type", "application/json")
+			_, _ = writer.Write(encoded)
+		}))
+		a.t.Cleanup(a.server.Close)
+	}
+	return a.server
+}
+
+// Verifier returns a verifier trusting the currently published keys and
+// driven by the authority clock.
+func (a *Authority) Verifier() *codefly.WorkContextVerifier {
+	a.t.Helper()
+	verifier, err := codefly.NewWorkContextVerifier(codefly.WorkContextVerifierOptions{
+		PublicKeys: a.PublicKeys(),
+		Now:        a.clock.Now,
+	})
+	if err != nil {
+		a.t.Fatalf("codeflytest: new Work Context verifier: %v", err)
+	}
+	return verifier
+}
+
+// JWKSVerifier returns a verifier that fetches keys from JWKSServer. Like a
+// production verifier it picks up Rotate on the first token signed with the
+// new key, and Retire once its cached key set expires.
+func (a *Authority) JWKSVerifier() *codefly.WorkContextJWKSVerifier {
+	a.t.Helper()
+	server := a.JWKSServer()
+	verifier, err := codefly.NewWorkContextJWKSVerifier(codefly.WorkContextJWKSVerifierOptions{
+		URL:        server.URL,
+		HTTPClient: server.Client(),
+		Now:        a.clock.Now,
+	})
+	if err != nil {
+		a.t.Fatalf("codeflytest: new Work Context JWKS verifier: %v", err)
+	}
+	a.t.Cleanup(func() { _ = verifier.Close() })
+	return verifier
+}
+
+// StartTask mints a root Work Context from TaskInput after applying the
+// optional edits.
+func (a *Authority) StartTask(edits ...func(*codefly.StartTaskInput)) (codefly.WorkContextToken, *basev0.WorkContextV1) {
+	a.t.Helper()
+	input := TaskInput()
+	for _, edit := range edits {
+		edit(&input)
+	}
+	token, claims, err := a.Signer().StartTask(input)
+	if err != nil {
+		a.t.Fatalf("codeflytest: start task: %v", err)
+	}
+	return token, claims
+}
+
+// StartChildSession mints a child session of parent.
+func (a *Authority) StartChildSession(
+	parent codefly.WorkContextToken,
+	input codefly.StartChildSessionInput,
+) (codefly.WorkContextToken, *basev0.WorkContextV1) {
+	a.t.Helper()
+	token, claims, err := a.Signer().StartChildSession(parent, input)
+	if err != nil {
+		a.t.Fatalf("codeflytest: start child session: %v", err)
+	}
+	return token, claims
+}
+
+// TaskInput returns a valid StartTaskInput for tests to adjust: one owner with
+// read and write on a repository, acting directly, for five minutes.
+func TaskInput() codefly.StartTaskInput {
+	return codefly.StartTaskInput{
+		Audience:              "test.service",
+		TenantID:              "tenant-test",
+		OwnerPrincipalID:      "principal-test",
+		TaskID:                "task-test",
+		SessionID:             "session-test",
+		AuthorizationRevision: 1,
+		ReplayPolicy:          codefly.WorkContextReplayIdempotent,
+		AuthorityScopes: []*basev0.WorkScopeV1{
+			{ResourceKind: "repository", Actions: []string{"read", "write"}},
+		},
+		TTL: codefly.WorkContextDefaultTTL,
+	}
+}
+
+// TestPrivateKey derives a deterministic Ed25519 key from keyID. It is for
+// tests only: anyone can derive it.
+func TestPrivateKey(keyID string) ed25519.PrivateKey {
+	seed := sha256.Sum256([]byte(fmt.Sprintf("codeflytest.work-context.key.v1\n%s", keyID)))
+	return ed25519.NewKeyFromSeed(seed[:])
+}
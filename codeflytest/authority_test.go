@@ -0,0 +1,68 @@
+package codeflytest
+
+import (
+	"testing"
+	"time"
+
+	basev0 "github.com/codefly-dev/core/generated/go/codefly/base/v0"
+	codefly "github.com/codefly-dev/sdk-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorityMintsTokensItsVerifiersAccept(t *testing.T) {
+	authority := NewAuthority(t, AuthorityOptions{})
+	token, claims := authority.StartTask(func(input *codefly.StartTaskInput) {
+		input.TaskID = "task-custom"
+	})
+	require.Equal(t, "task-custom", claims.TaskId)
+	require.Equal(t, DefaultIssuer, claims.Issuer)
+
+	verified, err := authority.Verifier().Verify(token, codefly.WorkContextExpectations{
+		Issuer: authority.Issuer(), Audience: "test.service",
+	})
+	require.NoError(t, err)
+	require.NoError(t, codefly.RequireWorkContextScope(verified, codefly.WorkContextScopeRequirement{
+		ResourceKind: "repository", Action: "write",
+	}))
+
+	_, err = authority.JWKSVerifier().Verify(t.Context(), token, codefly.WorkContextExpectations{})
+	require.NoError(t, err)
+
+	child, childClaims := authority.StartChildSession(token, codefly.StartChildSessionInput{
+		SessionID: "session-child",
+		Actor: &basev0.WorkActorV1{
+			PrincipalId: "agent-test", PrincipalKind: "agent", DelegationId: "delegation-test",
+			GrantedScopes: []*basev0.WorkScopeV1{{ResourceKind: "repository", Actions: []string{"read"}}},
+		},
+	})
+	require.Equal(t, "session-test", childClaims.GetParentSessionId())
+	_, err = authority.Verifier().Verify(child, codefly.WorkContextExpectations{})
+	require.NoError(t, err)
+
+	authority.Clock().Advance(codefly.WorkContextDefaultTTL + codefly.WorkContextClockSkew + time.Second)
+	_, err = authority.Verifier().Verify(token, codefly.WorkContextExpectations{})
+	require.Equal(t, codefly.WorkContextReasonExpired, codefly.WorkContextErrorReasonOf(err))
+}
+
+func TestAuthorityRotatesAndRetiresKeys(t *testing.T) {
+	authority := NewAuthority(t, AuthorityOptions{KeyID: "key-a"})
+	jwksVerifier := authority.JWKSVerifier()
+	first, _ := authority.StartTask()
+	_, err := jwksVerifier.Verify(t.Context(), first, codefly.WorkContextExpectations{})
+	require.NoError(t, err)
+
+	authority.Rotate("key-b")
+	require.Equal(t, "key-b", authority.KeyID())
+	second, _ := authority.StartTask()
+	_, err = jwksVerifier.Verify(t.Context(), second, codefly.WorkContextExpectations{})
+	require.NoError(t, err, "an unknown key ID refreshes the JWKS")
+	require.Len(t, authority.PublicKeys(), 2)
+
+	authority.Retire("key-a")
+	_, err = authority.Verifier().Verify(first, codefly.WorkContextExpectations{})
+	require.Equal(t, codefly.WorkContextReasonUnknownKey, codefly.WorkContextErrorReasonOf(err))
+	_, err = authority.Verifier().Verify(second, codefly.WorkContextExpectations{})
+	require.NoError(t, err)
+	require.JSONEq(t, string(NewAuthority(t, AuthorityOptions{KeyID: "key-b"}).JWKS()), string(authority.JWKS()),
+		"keys derive from their IDs")
+}
@@ -0,0 +1,49 @@
+package codeflytest
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultTime is the instant NewClock starts at when given a zero start. It
+// is fixed so tokens minted in tests are reproducible.
+var DefaultTime = time.Date(2026, 7, 23, 12, 34, 56, 0, time.UTC)
+
+// Clock is a manually advanced clock. Pass Clock.Now wherever the SDK accepts
+// a Now option; it is safe for concurrent use. Create one with NewClock: a
+// zero Clock starts at the zero time, not DefaultTime.
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewClock returns a clock stopped at start, or at DefaultTime when start is
+// zero.
+func NewClock(start time.Time) *Clock {
+	if start.IsZero() {
+		start = DefaultTime
+	}
+	return &Clock{now: start.UTC()}
+}
+
+// Now returns the current fake time.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d and returns the new time.
+func (c *Clock) Advance(d time.Duration) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	return c.now
+}
+
+// Set moves the clock to now, forwards or backwards.
+func (c *Clock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now.UTC()
+}
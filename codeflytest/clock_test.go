@@ -0,0 +1,20 @@
+package codeflytest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClockAdvancesOnlyWhenTold(t *testing.T) {
+	clock := NewClock(time.Time{})
+	require.Equal(t, DefaultTime, clock.Now())
+	require.Equal(t, DefaultTime.Add(time.Minute), clock.Advance(time.Minute))
+	require.Equal(t, DefaultTime.Add(time.Minute), clock.Now())
+
+	start := time.Date(2027, 1, 2, 3, 4, 5, 0, time.FixedZone("CET", 3600))
+	clock.Set(start)
+	require.Equal(t, start.UTC(), clock.Now())
+	require.Equal(t, time.UTC, NewClock(start).Now().Location())
+}
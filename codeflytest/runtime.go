@@ -0,0 +1,129 @@
+// Package codeflytest helps tests of services built on the Codefly SDK: a
+// fake runtime for the environment carriers, an in-memory Work Context
+// authority, a temporary workspace builder, and a fake clock.
+package codeflytest
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/codefly-dev/core/resources"
+	codefly "github.com/codefly-dev/sdk-go"
+)
+
+// Runtime plays the Codefly agent for one test: it injects the carriers the
+// SDK reads and restores the previous process environment when the test ends.
+// Like testing.T.Setenv, it cannot be used in parallel tests.
+type Runtime struct {
+	t       testing.TB
+	module  string
+	service string
+}
+
+// NewRuntime starts from an environment without any Codefly carriers and
+// identifies the process as module/service.
+func NewRuntime(t testing.TB, module, service string) *Runtime {
+	t.Helper()
+	// Registered first so it runs last, after every Setenv is undone: the
+	// SDK snapshot must not keep carriers from this test.
+	t.Cleanup(func() { _ = codefly.LoadEnvironmentVariables() })
+	for _, variable := range os.Environ() {
+		name, _, _ := strings.Cut(variable, "=")
+		if strings.HasPrefix(name, "CODEFLY__") || strings.HasPrefix(name, "CODEFLY_EXECUTION_CONTEXT_") ||
+			name == "CODEFLY_SCOPED_AUTH_SECRET" {
+			t.Setenv(name, "")
+			if err := os.Unsetenv(name); err != nil {
+				t.Fatalf("codeflytest: unset %s: %v", name, err)
+			}
+		}
+	}
+	r := &Runtime{t: t, module: module, service: service}
+	r.set(resources.ModulePrefix, module)
+	r.set(resources.ServicePrefix, service)
+	return r
+}
+
+// Environment selects the Codefly environment, such as "local".
+func (r *Runtime) Environment(name string) *Runtime {
+	r.set(resources.EnvironmentPrefix, name)
+	return r
+}
+
+// Workspace sets the runtime workspace identity.
+func (r *Runtime) Workspace(name string) *Runtime {
+	r.set(resources.WorkspacePrefix, name)
+	return r
+}
+
+// Fixture selects the runtime fixture.
+func (r *Runtime) Fixture(name string) *Runtime {
+	r.set(resources.FixturePrefix, name)
+	return r
+}
+
+// Version sets the service version reported by codefly.ServiceVersion.
+func (r *Runtime) Version(version string) *Runtime {
+	r.set(resources.VersionPrefix, version)
+	return r
+}
+
+// Endpoint injects the address of an endpoint of module/service. An empty
+// module or service means this runtime's own.
+func (r *Runtime) Endpoint(module, service, name, api, address string) *Runtime {
+	module, service = r.identity(module, service)
+	r.set(resources.EndpointAsEnvironmentVariableKey(&resources.EndpointInformation{
+		Module: module, Service: service, Name: name, API: api,
+	}), address)
+	return r
+}
+
+// Configuration injects one configuration value exported by module/service.
+func (r *Runtime) Configuration(module, service, name, key, value string) *Runtime {
+	module, service = r.identity(module, service)
+	r.set(resources.ServiceConfigurationKeyFromUnique(resources.ServiceUnique(module, service), name, key), value)
+	return r
+}
+
+// Secret injects one secret configuration value exported by module/service.
+func (r *Runtime) Secret(module, service, name, key, value string) *Runtime {
+	module, service = r.identity(module, service)
+	r.set(resources.ServiceSecretConfigurationKeyFromUnique(resources.ServiceUnique(module, service), name, key), value)
+	return r
+}
+
+// WorkspaceConfiguration injects one workspace configuration value.
+func (r *Runtime) WorkspaceConfiguration(name, key, value string) *Runtime {
+	r.set(workspaceKey(resources.WorkspaceConfigurationPrefix, name, key), value)
+	return r
+}
+
+// WorkspaceSecret injects one workspace secret value.
+func (r *Runtime) WorkspaceSecret(name, key, value string) *Runtime {
+	r.set(workspaceKey(resources.WorkspaceSecretConfigurationPrefix, name, key), value)
+	return r
+}
+
+func (r *Runtime) identity(module, service string) (string, string) {
+	if module == "" {
+		module = r.module
+	}
+	if service == "" {
+		service = r.service
+	}
+	return module, service
+}
+
+func (r *Runtime) set(name, value string) {
+	r.t.Helper()
+	r.t.Setenv(name, value)
+	if err := codefly.LoadEnvironmentVariables(); err != nil {
+		r.t.Fatalf("codeflytest: reload environment: %v", err)
+	}
+}
+
+// workspaceKey uses the normalized spelling current runtimes emit.
+func workspaceKey(prefix, name, key string) string {
+	normalize := func(value string) string { return strings.ReplaceAll(strings.ToUpper(value), "-", "_") }
+	return prefix + "__" + normalize(name) + "__" + normalize(key)
+}
@@ -0,0 +1,63 @@
+package codeflytest
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/codefly-dev/core/standards"
+	codefly "github.com/codefly-dev/sdk-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuntimeInjectsCarriersAndRestoresThem(t *testing.T) {
+	t.Setenv("CODEFLY__WORKSPACE_CONFIGURATION__LEAKED__VALUE", "from-outside")
+	ctx := context.Background()
+
+	t.Run("runtime", func(t *testing.T) {
+		NewRuntime(t, "platform", "warden").
+			Environment("production").
+			Workspace("workspace-test").
+			Fixture("smoke").
+			Version("1.2.3").
+			Endpoint("platform", "store", standards.HTTP, standards.HTTP, "http://localhost:4321").
+			Configuration("platform", "store", "postgres", "pool-size", "12").
+			Secret("platform", "store", "postgres", "read-only-connection", "postgresql://reader").
+			WorkspaceConfiguration("security", "public-setting", "public").
+			WorkspaceSecret("internal-auth", "token", "secret")
+
+		_, err := codefly.For(ctx).WorkspaceConfiguration("leaked", "value")
+		require.Error(t, err, "carriers from the surrounding process are hidden")
+
+		instance, err := codefly.For(ctx).Service("store").API(standards.HTTP).ResolveNetworkInstance()
+		require.NoError(t, err)
+		require.Equal(t, "http://localhost:4321", instance.Address)
+		value, err := codefly.For(ctx).Service("store").Configuration("postgres", "pool-size")
+		require.NoError(t, err)
+		require.Equal(t, "12", value)
+		value, err = codefly.For(ctx).Service("store").Secret("postgres", "read-only-connection")
+		require.NoError(t, err)
+		require.Equal(t, "postgresql://reader", value)
+		value, err = codefly.For(ctx).WorkspaceConfiguration("security", "public-setting")
+		require.NoError(t, err)
+		require.Equal(t, "public", value)
+		value, err = codefly.For(ctx).WorkspaceSecret("internal-auth", "token")
+		require.NoError(t, err)
+		require.Equal(t, "secret", value)
+
+		workspace, err := codefly.Workspace(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "workspace-test", workspace)
+		require.Equal(t, "production", codefly.Environment())
+		require.False(t, codefly.IsLocal())
+		require.True(t, codefly.WithFixture("smoke"))
+		require.Equal(t, "1.2.3", codefly.ServiceVersion())
+	})
+
+	require.Empty(t, os.Getenv("CODEFLY__ENVIRONMENT"))
+	value, err := codefly.For(ctx).WorkspaceConfiguration("leaked", "value")
+	require.NoError(t, err)
+	require.Equal(t, "from-outside", value)
+	_, err = codefly.For(ctx).WorkspaceConfiguration("security", "public-setting")
+	require.Error(t, err, "the SDK snapshot is reloaded after the runtime is torn down")
+}
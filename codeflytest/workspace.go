@@ -0,0 +1,158 @@
+package codeflytest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// Endpoint declares one endpoint of a service in a Workspace. API defaults to
+// the endpoint name when that name is a supported API, as in Codefly itself.
+type Endpoint struct {
+	Name       string
+	API        string
+	Visibility string
+}
+
+// Workspace builds a Codefly workspace in a temporary directory, for tests of
+// local fallbacks such as endpoint resolution from the deterministic native
+// map and configuration files.
+type Workspace struct {
+	t        testing.TB
+	root     string
+	name     string
+	services map[string][]string
+}
+
+// NewWorkspace creates an empty workspace with the modules layout.
+func NewWorkspace(t testing.TB, name string) *Workspace {
+	t.Helper()
+	w := &Workspace{t: t, root: t.TempDir(), name: name, services: map[string][]string{}}
+	w.writeWorkspace()
+	return w
+}
+
+// Root returns the workspace directory.
+func (w *Workspace) Root() string {
+	return w.root
+}
+
+// Name returns the workspace name.
+func (w *Workspace) Name() string {
+	return w.name
+}
+
+// ServiceDir returns the directory of module/service.
+func (w *Workspace) ServiceDir(module, service string) string {
+	return filepath.Join(w.root, "modules", module, "services", service)
+}
+
+// Service adds a service with its endpoints, creating its module if needed.
+func (w *Workspace) Service(module, service string, endpoints ...Endpoint) *Workspace {
+	w.t.Helper()
+	if _, known := w.services[module]; !known {
+		w.services[module] = nil
+		w.writeWorkspace()
+	}
+	w.services[module] = append(w.services[module], service)
+	var moduleFile strings.Builder
+	fmt.Fprintf(&moduleFile, "kind: module\nname: %s\nservices:\n", module)
+	for _, name := range w.services[module] {
+		fmt.Fprintf(&moduleFile, "  - name: %s\n", name)
+	}
+	w.write(filepath.Join(w.root, "modules", module, "module.codefly.yaml"), moduleFile.String(), 0o644)
+
+	var serviceFile strings.Builder
+	fmt.Fprintf(&serviceFile, `kind: service
+name: %s
+version: 0.0.0
+agent:
+  kind: codefly:service
+  name: go
+  version: 0.0.0
+  publisher: codefly.dev
+`, service)
+	if len(endpoints) > 0 {
+		serviceFile.WriteString("endpoints:\n")
+		for _, endpoint := range endpoints {
+			fmt.Fprintf(&serviceFile, "  - name: %s\n", endpoint.Name)
+			if endpoint.API != "" {
+				fmt.Fprintf(&serviceFile, "    api: %s\n", endpoint.API)
+			}
+			if endpoint.Visibility != "" {
+				fmt.Fprintf(&serviceFile, "    visibility: %s\n", endpoint.Visibility)
+			}
+		}
+	}
+	w.write(filepath.Join(w.ServiceDir(module, service), "service.codefly.yaml"), serviceFile.String(), 0o644)
+	return w
+}
+
+// Configuration writes the configuration file name of module/service for one
+// environment ("local" when empty). Secret values go to the .secret.env file
+// the SDK reads for Query.Secret.
+func (w *Workspace) Configuration(module, service, environment, name string, values map[string]string, secret bool) *Workspace {
+	w.t.Helper()
+	if environment == "" {
+		environment = "local"
+	}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var content strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&content, "%s=%s\n", strings.ToUpper(key), values[key])
+	}
+	file := name + ".env"
+	mode := os.FileMode(0o644)
+	if secret {
+		file, mode = name+".secret.env", 0o600
+	}
+	w.write(filepath.Join(w.ServiceDir(module, service), "configurations", environment, file), content.String(), mode)
+	return w
+}
+
+// Chdir makes the workspace the working directory for the rest of the test,
+// or dir inside it when given, so the SDK discovers it. Like testing.T.Chdir,
+// it cannot be used in parallel tests.
+func (w *Workspace) Chdir(dir ...string) {
+	w.t.Helper()
+	target := filepath.Join(append([]string{w.root}, dir...)...)
+	if err := os.MkdirAll(target, 0o755); err != nil {
+		w.t.Fatalf("codeflytest: create %s: %v", target, err)
+	}
+	w.t.Chdir(target)
+}
+
+func (w *Workspace) writeWorkspace() {
+	w.t.Helper()
+	modules := make([]string, 0, len(w.services))
+	for module := range w.services {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+	var content strings.Builder
+	fmt.Fprintf(&content, "name: %s\nlayout: modules\n", w.name)
+	if len(modules) > 0 {
+		content.WriteString("modules:\n")
+		for _, module := range modules {
+			fmt.Fprintf(&content, "  - name: %s\n", module)
+		}
+	}
+	w.write(filepath.Join(w.root, "workspace.codefly.yaml"), content.String(), 0o644)
+}
+
+func (w *Workspace) write(path, content string, mode os.FileMode) {
+	w.t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		w.t.Fatalf("codeflytest: create %s: %v", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(content), mode); err != nil {
+		w.t.Fatalf("codeflytest: write %s: %v", path, err)
+	}
+}
@@ -0,0 +1,38 @@
+package codeflytest
+
+import (
+	"context"
+	"testing"
+
+	basev0 "github.com/codefly-dev/core/generated/go/codefly/base/v0"
+	"github.com/codefly-dev/core/network"
+	codefly "github.com/codefly-dev/sdk-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkspaceResolvesLocalEndpointsAndConfiguration(t *testing.T) {
+	ctx := context.Background()
+	NewRuntime(t, "platform", "consumer")
+	workspace := NewWorkspace(t, "codeflytest-workspace").
+		Service("platform", "warden", Endpoint{Name: "rest"}, Endpoint{Name: "grpc"}).
+		Service("platform", "store").
+		Configuration("platform", "store", "", "api", map[string]string{"url": "http://store"}, false).
+		Configuration("platform", "store", "", "api", map[string]string{"token": "store-secret"}, true)
+	workspace.Chdir("modules", "platform", "services", "warden", "code")
+
+	instance, err := codefly.For(ctx).Service("warden").Endpoint("rest").ResolveNetworkInstance()
+	require.NoError(t, err)
+	expected := network.NativeFor(ctx, workspace.Name(), "platform", "warden", "", &basev0.Endpoint{Name: "rest", Api: "rest"})
+	require.Equal(t, expected.Address, instance.Address)
+
+	value, err := codefly.For(ctx).Service("store").Configuration("api", "url")
+	require.NoError(t, err)
+	require.Equal(t, "http://store", value)
+	value, err = codefly.For(ctx).Service("store").Secret("api", "token")
+	require.NoError(t, err)
+	require.Equal(t, "store-secret", value)
+
+	name, err := codefly.Workspace(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "codeflytest-workspace", name)
+}
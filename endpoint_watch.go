@@ -0,0 +1,137 @@
+package codefly
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/codefly-dev/core/resources"
+)
+
+const (
+	defaultEndpointWatchPollInterval = 2 * time.Second
+	defaultEndpointWatchDebounce     = 250 * time.Millisecond
+)
+
+// EndpointWatchOptions configures Query.Watch.
+type EndpointWatchOptions struct {
+	// PollInterval is how often the local workspace is read again, where
+	// ResolveNetworkInstance falls back to it. It defaults to two seconds; a
+	// negative interval disables polling, leaving environment reloads as the
+	// only trigger.
+	PollInterval time.Duration
+	// Debounce is how long reloads must stay quiet before the endpoint is
+	// resolved again, so a burst of reloads costs one resolution. A poll
+	// starts the same wait but never extends one already pending. It
+	// defaults to 250 milliseconds.
+	Debounce time.Duration
+}
+
+// EndpointUpdate is one resolution of a watched endpoint.
+type EndpointUpdate struct {
+	NetworkInstance *resources.NetworkInstance
+	// Err is why the endpoint cannot be resolved; NetworkInstance is nil.
+	Err error
+}
+
+// Watch resolves the query like ResolveNetworkInstance, sends the result, and
+// then sends a new one every time the result changes, so client factories can
+// reconnect when an address moves. Resolution runs again after every
+// LoadEnvironmentVariables and, where the local workspace is the fallback, at
+// every poll interval. Consecutive failures are sent once.
+//
+// The channel holds only the latest update: a slow reader skips intermediate
+// ones. It is closed when ctx is done. Later changes to the query do not
+// affect the watch.
+func (q *Query) Watch(ctx context.Context, options EndpointWatchOptions) (<-chan EndpointUpdate, error) {
+	if ctx == nil {
+		return nil, errors.New("endpoint watch requires a context")
+	}
+	if options.Debounce < 0 {
+		return nil, errors.New("endpoint watch debounce must not be negative")
+	}
+	pollInterval := options.PollInterval
+	if pollInterval == 0 {
+		pollInterval = defaultEndpointWatchPollInterval
+	}
+	debounce := options.Debounce
+	if debounce == 0 {
+		debounce = defaultEndpointWatchDebounce
+	}
+	watched := *q
+	watched.Normalize()
+	updates := make(chan EndpointUpdate, 1)
+	// Subscribe before the first resolution so that no reload is missed.
+	reloaded := environmentReloadSignal()
+	last := watched.resolveUpdate()
+	updates <- last
+	go watched.watch(ctx, updates, last, reloaded, pollInterval, debounce)
+	return updates, nil
+}
+
+func (q *Query) watch(
+	ctx context.Context,
+	updates chan EndpointUpdate,
+	last EndpointUpdate,
+	reloaded <-chan struct{},
+	pollInterval, debounce time.Duration,
+) {
+	defer close(updates)
+	var poll <-chan time.Time
+	if pollInterval > 0 {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+	settle := time.NewTimer(debounce)
+	settle.Stop()
+	defer settle.Stop()
+	pending := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-reloaded:
+			reloaded = environmentReloadSignal()
+			settle.Reset(debounce)
+			pending = true
+		case <-poll:
+			// A poll never pushes back a pending resolution, or polls faster
+			// than the debounce would starve it.
+			if !pending && (Environment() == "" || IsLocal()) {
+				settle.Reset(debounce)
+				pending = true
+			}
+		case <-settle.C:
+			pending = false
+			update := q.resolveUpdate()
+			if sameEndpointUpdate(last, update) {
+				continue
+			}
+			last = update
+			// Only this goroutine sends, so replacing an unread update
+			// cannot block.
+			select {
+			case <-updates:
+			default:
+			}
+			updates <- update
+		}
+	}
+}
+
+func (q *Query) resolveUpdate() EndpointUpdate {
+	instance, err := q.ResolveNetworkInstance()
+	if err != nil {
+		return EndpointUpdate{Err: err}
+	}
+	return EndpointUpdate{NetworkInstance: instance}
+}
+
+func sameEndpointUpdate(a, b EndpointUpdate) bool {
+	if a.Err != nil || b.Err != nil {
+		return a.Err != nil && b.Err != nil
+	}
+	x, y := a.NetworkInstance, b.NetworkInstance
+	return x.Address == y.Address && x.Host == y.Host && x.Hostname == y.Hostname && x.Port == y.Port
+}
@@ -0,0 +1,160 @@
+package codefly_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	basev0 "github.com/codefly-dev/core/generated/go/codefly/base/v0"
+	"github.com/codefly-dev/core/network"
+	"github.com/codefly-dev/core/standards"
+	codefly "github.com/codefly-dev/sdk-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func nextEndpointUpdate(t *testing.T, updates <-chan codefly.EndpointUpdate) codefly.EndpointUpdate {
+	t.Helper()
+	select {
+	case update, ok := <-updates:
+		if !ok {
+			t.Fatal("endpoint watch closed")
+		}
+		return update
+	case <-time.After(5 * time.Second):
+		t.Fatal("no endpoint update")
+		return codefly.EndpointUpdate{}
+	}
+}
+
+func requireNoEndpointUpdate(t *testing.T, updates <-chan codefly.EndpointUpdate) {
+	t.Helper()
+	select {
+	case update := <-updates:
+		t.Fatalf("unexpected endpoint update %+v", update)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestWatchFollowsEnvironmentReloads(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	t.Setenv("CODEFLY__ENVIRONMENT", "production")
+	setRuntimeEndpoint(t, "billing", "invoices", "rest", standards.REST, "http://invoices-a.internal:8000")
+	requireNoError(t, codefly.LoadEnvironmentVariables())
+	t.Cleanup(func() { _ = codefly.LoadEnvironmentVariables() })
+
+	updates, err := codefly.For(ctx).Module("billing").Service("invoices").API(standards.REST).
+		Watch(ctx, codefly.EndpointWatchOptions{PollInterval: -1, Debounce: time.Millisecond})
+	assert.NoError(t, err)
+	initial := nextEndpointUpdate(t, updates)
+	assert.NoError(t, initial.Err)
+	assert.Equal(t, "http://invoices-a.internal:8000", initial.NetworkInstance.Address)
+
+	// A burst of reloads yields the final address once.
+	setRuntimeEndpoint(t, "billing", "invoices", "rest", standards.REST, "http://invoices-b.internal:8000")
+	requireNoError(t, codefly.LoadEnvironmentVariables())
+	setRuntimeEndpoint(t, "billing", "invoices", "rest", standards.REST, "http://invoices-c.internal:8000")
+	requireNoError(t, codefly.LoadEnvironmentVariables())
+	moved := nextEndpointUpdate(t, updates)
+	assert.NoError(t, moved.Err)
+	assert.Equal(t, "http://invoices-c.internal:8000", moved.NetworkInstance.Address)
+
+	// Reloads that do not change the endpoint are not reported.
+	requireNoError(t, codefly.LoadEnvironmentVariables())
+	requireNoEndpointUpdate(t, updates)
+
+	requireNoError(t, os.Unsetenv("CODEFLY__ENDPOINT__BILLING__INVOICES__REST__REST"))
+	requireNoError(t, codefly.LoadEnvironmentVariables())
+	gone := nextEndpointUpdate(t, updates)
+	assert.Error(t, gone.Err)
+	assert.Nil(t, gone.NetworkInstance)
+
+	cancel()
+	select {
+	case _, ok := <-updates:
+		assert.False(t, ok)
+	case <-time.After(5 * time.Second):
+		t.Fatal("endpoint watch not closed")
+	}
+}
+
+func TestWatchPollsTheLocalWorkspace(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	root := t.TempDir()
+	t.Setenv("CODEFLY__ENVIRONMENT", "")
+	requireNoError(t, codefly.LoadEnvironmentVariables())
+
+	workspaceFile := filepath.Join(root, "workspace.codefly.yaml")
+	writeFile(t, workspaceFile, `name: sdk-watch-a
+layout: modules
+modules:
+  - name: platform
+`)
+	writeFile(t, filepath.Join(root, "modules", "platform", "module.codefly.yaml"), `kind: module
+name: platform
+services:
+  - name: warden
+`)
+	writeFile(t, filepath.Join(root, "modules", "platform", "services", "warden", "service.codefly.yaml"), `kind: service
+name: warden
+version: 0.0.0
+endpoints:
+  - name: rest
+`)
+	t.Chdir(root)
+
+	updates, err := codefly.For(ctx).Module("platform").Service("warden").Endpoint("rest").
+		Watch(ctx, codefly.EndpointWatchOptions{PollInterval: 5 * time.Millisecond, Debounce: time.Millisecond})
+	assert.NoError(t, err)
+	initial := nextEndpointUpdate(t, updates)
+	assert.NoError(t, initial.Err)
+	expected := network.NativeFor(ctx, "sdk-watch-a", "platform", "warden", "",
+		&basev0.Endpoint{Name: "rest", Api: standards.REST})
+	assert.Equal(t, expected.Address, initial.NetworkInstance.Address)
+	requireNoEndpointUpdate(t, updates)
+
+	writeFile(t, workspaceFile, `name: sdk-watch-b
+layout: modules
+modules:
+  - name: platform
+`)
+	renamed := nextEndpointUpdate(t, updates)
+	assert.NoError(t, renamed.Err)
+	expected = network.NativeFor(ctx, "sdk-watch-b", "platform", "warden", "",
+		&basev0.Endpoint{Name: "rest", Api: standards.REST})
+	assert.Equal(t, expected.Address, renamed.NetworkInstance.Address)
+}
+
+func TestWatchPollsFasterThanTheDebounce(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	t.Setenv("CODEFLY__ENVIRONMENT", "local")
+	setRuntimeEndpoint(t, "billing", "invoices", "rest", standards.REST, "http://invoices-a.internal:8000")
+	requireNoError(t, codefly.LoadEnvironmentVariables())
+	t.Cleanup(func() { _ = codefly.LoadEnvironmentVariables() })
+
+	updates, err := codefly.For(ctx).Module("billing").Service("invoices").API(standards.REST).
+		Watch(ctx, codefly.EndpointWatchOptions{PollInterval: 5 * time.Millisecond, Debounce: 50 * time.Millisecond})
+	assert.NoError(t, err)
+	initial := nextEndpointUpdate(t, updates)
+	assert.Equal(t, "http://invoices-a.internal:8000", initial.NetworkInstance.Address)
+
+	setRuntimeEndpoint(t, "billing", "invoices", "rest", standards.REST, "http://invoices-b.internal:8000")
+	requireNoError(t, codefly.LoadEnvironmentVariables())
+	moved := nextEndpointUpdate(t, updates)
+	assert.NoError(t, moved.Err)
+	assert.Equal(t, "http://invoices-b.internal:8000", moved.NetworkInstance.Address)
+}
+
+func TestWatchRejectsNegativeDebounce(t *testing.T) {
+	_, err := codefly.For(context.Background()).Watch(context.Background(), codefly.EndpointWatchOptions{Debounce: -time.Second})
+	assert.Error(t, err)
+}
+
+func TestWatchRejectsNilContext(t *testing.T) {
+	_, err := codefly.For(context.Background()).Watch(nil, codefly.EndpointWatchOptions{})
+	assert.ErrorContains(t, err, "endpoint watch requires a context")
+}
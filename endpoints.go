@@ -0,0 +1,359 @@
+package codefly
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/codefly-dev/core/resources"
+	"github.com/codefly-dev/core/wool"
+)
+
+// EndpointSource says where a ResolvedEndpoint was found.
+type EndpointSource string
+
+const (
+	// EndpointSourceRuntime is an endpoint injected by the Codefly runtime.
+	EndpointSourceRuntime EndpointSource = "runtime"
+	// EndpointSourceWorkspace is an endpoint declared in the local workspace
+	// and addressed through its deterministic native map.
+	EndpointSourceWorkspace EndpointSource = "workspace"
+)
+
+// ResolvedEndpoint is one endpoint matched by Query.ResolveAll.
+type ResolvedEndpoint struct {
+	Module  string
+	Service string
+	Name    string
+	API     string
+	// Visibility is empty for runtime endpoints: the carrier does not
+	// record it.
+	Visibility string
+	Source     EndpointSource
+	// NetworkInstance is nil for workspace endpoints the local native map
+	// cannot address, such as external ones.
+	NetworkInstance *resources.NetworkInstance
+}
+
+// ResolveAll returns every endpoint matching the query, where
+// ResolveNetworkInstance requires exactly one. An empty module, service,
+// endpoint name, or API matches any; For pre-selects the running service, so
+// clear Module and Service to list the whole workspace. Unlike
+// ResolveNetworkInstance, API alone does not also select the endpoint of
+// that name: For(ctx).API("rest") lists every REST endpoint.
+//
+// Runtime-injected endpoints come first and win over the workspace
+// declaration of the same endpoint. The local workspace contributes under the
+// same conditions as in ResolveNetworkInstance. Endpoints are sorted by
+// module, service, name, and API.
+func (q *Query) ResolveAll() ([]ResolvedEndpoint, error) {
+	endpoints := q.resolveAllRuntime()
+	var localErr error
+	if Environment() == "" || IsLocal() {
+		var local []ResolvedEndpoint
+		local, localErr = q.resolveAllLocal()
+		endpoints = mergeResolvedEndpoints(endpoints, local)
+	}
+	if len(endpoints) == 0 && localErr != nil {
+		return nil, fmt.Errorf("no runtime endpoint matches; local endpoints unavailable (%w)", localErr)
+	}
+	sortResolvedEndpoints(endpoints)
+	return endpoints, nil
+}
+
+// mergeResolvedEndpoints appends the local endpoints that runtime does not
+// already provide.
+func mergeResolvedEndpoints(runtime, local []ResolvedEndpoint) []ResolvedEndpoint {
+	seen := make(map[string]bool, len(runtime))
+	for _, endpoint := range runtime {
+		seen[endpoint.key()] = true
+	}
+	for _, endpoint := range local {
+		if !seen[endpoint.key()] {
+			seen[endpoint.key()] = true
+			runtime = append(runtime, endpoint)
+		}
+	}
+	return runtime
+}
+
+func sortResolvedEndpoints(endpoints []ResolvedEndpoint) {
+	sort.Slice(endpoints, func(i, j int) bool {
+		return endpoints[i].key() < endpoints[j].key()
+	})
+}
+
+func (e ResolvedEndpoint) key() string {
+	return strings.Join([]string{e.Module, e.Service, e.Name, e.API}, "/")
+}
+
+func (q *Query) matches(module, service, name, api string) bool {
+	return (q.module == "" || resources.Match(module, q.module)) &&
+		(q.service == "" || resources.Match(service, q.service)) &&
+		(q.endpointName == "" || resources.Match(name, q.endpointName)) &&
+		(q.endpointApi == "" || resources.Match(api, q.endpointApi))
+}
+
+func (q *Query) resolveAllRuntime() []ResolvedEndpoint {
+	w := wool.Get(q.ctx).In("ResolveAll")
+	variables := codeflyEnvironmentVariables()
+	var endpoints []ResolvedEndpoint
+	for _, variable := range variables {
+		name, _, _ := strings.Cut(variable, "=")
+		info, ok := parseEndpointEnvironmentVariableKey(name)
+		if !ok || !q.matches(info.Module, info.Service, info.Name, info.API) {
+			continue
+		}
+		instance, err := resources.FindNetworkInstanceInEnvironmentVariables(q.ctx, info, variables)
+		if err != nil {
+			w.Warn("Skipping unreadable endpoint carrier", wool.Field("key", name), wool.Field("error", err))
+			continue
+		}
+		endpoints = append(endpoints, ResolvedEndpoint{
+			Module:          info.Module,
+			Service:         info.Service,
+			Name:            info.Name,
+			API:             info.API,
+			Source:          EndpointSourceRuntime,
+			NetworkInstance: instance,
+		})
+	}
+	return endpoints
+}
+
+// parseEndpointEnvironmentVariableKey recovers the endpoint a runtime carrier
+// key names. Carrier keys are upper case; Codefly names are lower case. Only
+// keys that core would build again from the parsed endpoint are accepted.
+func parseEndpointEnvironmentVariableKey(key string) (*resources.EndpointInformation, bool) {
+	rest, ok := strings.CutPrefix(key, resources.EndpointPrefix+"__")
+	if !ok {
+		return nil, false
+	}
+	parts := strings.Split(rest, "__")
+	if len(parts) != 4 {
+		return nil, false
+	}
+	for _, part := range parts {
+		if part == "" {
+			return nil, false
+		}
+	}
+	info := &resources.EndpointInformation{
+		Module:  strings.ToLower(parts[0]),
+		Service: strings.ToLower(parts[1]),
+		Name:    strings.ToLower(parts[2]),
+		API:     strings.ToLower(parts[3]),
+	}
+	if resources.EndpointAsEnvironmentVariableKey(info) != key {
+		return nil, false
+	}
+	return info, true
+}
+
+// resolveAllLocal lists the matching endpoints declared in the enclosing
+// workspace. Modules and services that fail to load are skipped when the
+// query does not name them.
+func (q *Query) resolveAllLocal() ([]ResolvedEndpoint, error) {
+	workspace, err := resources.FindWorkspaceUp(q.ctx)
+	if err != nil {
+		return nil, err
+	}
+	if workspace == nil {
+		return nil, errors.New("workspace not found")
+	}
+	w := wool.Get(q.ctx).In("ResolveAll")
+	moduleNames := []string{q.module}
+	if q.module == "" {
+		moduleNames = nil
+		for _, reference := range workspace.Modules {
+			moduleNames = append(moduleNames, reference.Name)
+		}
+	}
+	var endpoints []ResolvedEndpoint
+	for _, moduleName := range moduleNames {
+		module, err := workspace.LoadModuleFromName(q.ctx, moduleName)
+		if err != nil {
+			if q.module != "" {
+				return nil, err
+			}
+			w.Warn("Skipping module", wool.Field("module", moduleName), wool.Field("error", err))
+			continue
+		}
+		serviceNames := []string{q.service}
+		if q.service == "" {
+			serviceNames = nil
+			for _, reference := range module.ServiceReferences {
+				serviceNames = append(serviceNames, reference.Name)
+			}
+		}
+		for _, serviceName := range serviceNames {
+			service, err := module.LoadServiceFromName(q.ctx, serviceName)
+			if err != nil {
+				if q.module != "" && q.service != "" {
+					return nil, err
+				}
+				w.Warn("Skipping service", wool.Field("service", serviceName), wool.Field("error", err))
+				continue
+			}
+			for _, endpoint := range service.Endpoints {
+				api := localEndpointAPI(endpoint)
+				if !q.matches(moduleName, serviceName, endpoint.Name, api) {
+					continue
+				}
+				resolved := ResolvedEndpoint{
+					Module:     moduleName,
+					Service:    serviceName,
+					Name:       endpoint.Name,
+					API:        api,
+					Visibility: endpoint.Visibility,
+					Source:     EndpointSourceWorkspace,
+				}
+				resolved.NetworkInstance, _ = q.localNetworkInstance(workspace.Name, moduleName, serviceName, endpoint)
+				endpoints = append(endpoints, resolved)
+			}
+		}
+	}
+	return endpoints, nil
+}
+
+// EndpointInventory is every endpoint a service can reach, as listed by
+// Endpoints. It prints as a table with WriteTable and marshals to JSON.
+type EndpointInventory []ResolvedEndpoint
+
+// Endpoints lists every endpoint the running service can reach: all
+// runtime-injected endpoints and, where ResolveNetworkInstance would fall back
+// to the local workspace, the endpoints of the service's declared
+// dependencies. A dependency that names endpoints contributes only those.
+// Runtime endpoints win over the workspace declaration of the same endpoint.
+//
+// The running service is the one For selects. Dependencies that cannot be
+// loaded are skipped with a warning; an error is returned only when nothing
+// at all can be listed.
+func Endpoints(ctx context.Context) (EndpointInventory, error) {
+	current := For(ctx)
+	endpoints := For(ctx).Module("").Service("").resolveAllRuntime()
+	var localErr error
+	if Environment() == "" || IsLocal() {
+		var local []ResolvedEndpoint
+		local, localErr = current.resolveDependencyEndpoints()
+		endpoints = mergeResolvedEndpoints(endpoints, local)
+	}
+	if len(endpoints) == 0 && localErr != nil {
+		return nil, fmt.Errorf("no runtime endpoint injected; local dependencies unavailable (%w)", localErr)
+	}
+	sortResolvedEndpoints(endpoints)
+	return endpoints, nil
+}
+
+// resolveDependencyEndpoints lists the workspace endpoints of the declared
+// dependencies of the query's service.
+func (q *Query) resolveDependencyEndpoints() ([]ResolvedEndpoint, error) {
+	if strings.TrimSpace(q.module) == "" || strings.TrimSpace(q.service) == "" {
+		return nil, errors.New("module and service are required to list local dependencies")
+	}
+	workspace, err := resources.FindWorkspaceUp(q.ctx)
+	if err != nil {
+		return nil, err
+	}
+	if workspace == nil {
+		return nil, errors.New("workspace not found")
+	}
+	module, err := workspace.LoadModuleFromName(q.ctx, q.module)
+	if err != nil {
+		return nil, err
+	}
+	service, err := module.LoadServiceFromName(q.ctx, q.service)
+	if err != nil {
+		return nil, err
+	}
+	w := wool.Get(q.ctx).In("Endpoints")
+	var endpoints []ResolvedEndpoint
+	for _, dependency := range service.ServiceDependencies {
+		dependencyModule := dependency.Module
+		if dependencyModule == "" {
+			dependencyModule = q.module
+		}
+		names := []string{""}
+		if len(dependency.Endpoints) > 0 {
+			names = nil
+			for _, reference := range dependency.Endpoints {
+				names = append(names, reference.Name)
+			}
+		}
+		for _, name := range names {
+			dependencyQuery := &Query{
+				ctx:          q.ctx,
+				module:       dependencyModule,
+				service:      dependency.Name,
+				endpointName: name,
+				namingScope:  q.namingScope,
+			}
+			local, err := dependencyQuery.resolveAllLocal()
+			if err != nil {
+				w.Warn("Skipping dependency",
+					wool.Field("dependency", dependencyModule+"/"+dependency.Name), wool.Field("error", err))
+				break
+			}
+			endpoints = mergeResolvedEndpoints(endpoints, local)
+		}
+	}
+	return endpoints, nil
+}
+
+// WriteTable prints the inventory as aligned columns, with "-" for unknown
+// values.
+func (inventory EndpointInventory) WriteTable(out io.Writer) error {
+	table := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(table, "MODULE\tSERVICE\tENDPOINT\tAPI\tVISIBILITY\tADDRESS\tSOURCE")
+	for _, endpoint := range inventory {
+		address := ""
+		if endpoint.NetworkInstance != nil {
+			address = endpoint.NetworkInstance.Address
+		}
+		_, _ = fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			orDash(endpoint.Module), orDash(endpoint.Service), orDash(endpoint.Name), orDash(endpoint.API),
+			orDash(endpoint.Visibility), orDash(address), endpoint.Source)
+	}
+	return table.Flush()
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// MarshalJSON flattens the network instance into the endpoint, so the output
+// does not depend on how core encodes it.
+func (e ResolvedEndpoint) MarshalJSON() ([]byte, error) {
+	view := struct {
+		Module     string         `json:"module"`
+		Service    string         `json:"service"`
+		Name       string         `json:"name"`
+		API        string         `json:"api"`
+		Visibility string         `json:"visibility,omitempty"`
+		Source     EndpointSource `json:"source"`
+		Address    string         `json:"address,omitempty"`
+		Hostname   string         `json:"hostname,omitempty"`
+		Port       uint16         `json:"port,omitempty"`
+	}{
+		Module:     e.Module,
+		Service:    e.Service,
+		Name:       e.Name,
+		API:        e.API,
+		Visibility: e.Visibility,
+		Source:     e.Source,
+	}
+	if e.NetworkInstance != nil {
+		view.Address = e.NetworkInstance.Address
+		view.Hostname = e.NetworkInstance.Hostname
+		view.Port = e.NetworkInstance.Port
+	}
+	return json.Marshal(view)
+}
@@ -0,0 +1,284 @@
+package codefly_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	basev0 "github.com/codefly-dev/core/generated/go/codefly/base/v0"
+	"github.com/codefly-dev/core/network"
+	"github.com/codefly-dev/core/resources"
+	"github.com/codefly-dev/core/standards"
+	codefly "github.com/codefly-dev/sdk-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func setRuntimeEndpoint(t *testing.T, module, service, name, api, address string) {
+	t.Helper()
+	t.Setenv(resources.EndpointAsEnvironmentVariableKey(&resources.EndpointInformation{
+		Module:  module,
+		Service: service,
+		Name:    name,
+		API:     api,
+	}), address)
+}
+
+func TestResolveAllListsEveryMatchingRuntimeEndpoint(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("CODEFLY__ENVIRONMENT", "production")
+	t.Setenv("CODEFLY__MODULE", "billing")
+	t.Setenv("CODEFLY__SERVICE", "invoices")
+	setRuntimeEndpoint(t, "billing", "invoices", "admin", standards.REST, "http://admin.internal:9000")
+	setRuntimeEndpoint(t, "billing", "invoices", "public", standards.REST, "http://public.internal:8000")
+	setRuntimeEndpoint(t, "billing", "invoices", "grpc", standards.GRPC, "http://grpc.internal:9090")
+	setRuntimeEndpoint(t, "billing", "ledger", "postgres", standards.TCP, "tcp://store.internal:5432")
+	t.Setenv("CODEFLY__ENDPOINT__MALFORMED", "http://ignored:1")
+	requireNoError(t, codefly.LoadEnvironmentVariables())
+	t.Cleanup(func() { _ = codefly.LoadEnvironmentVariables() })
+
+	endpoints, err := codefly.For(ctx).API(standards.REST).ResolveAll()
+	assert.NoError(t, err)
+	if assert.Len(t, endpoints, 2) {
+		assert.Equal(t, "admin", endpoints[0].Name)
+		assert.Equal(t, "public", endpoints[1].Name)
+		assert.Equal(t, codefly.ResolvedEndpoint{
+			Module:  "billing",
+			Service: "invoices",
+			Name:    "public",
+			API:     standards.REST,
+			Source:  codefly.EndpointSourceRuntime,
+			NetworkInstance: &resources.NetworkInstance{
+				Hostname: "public.internal",
+				Port:     8000,
+				Host:     "public.internal:8000",
+				Address:  "http://public.internal:8000",
+			},
+		}, endpoints[1])
+	}
+
+	endpoints, err = codefly.For(ctx).ResolveAll()
+	assert.NoError(t, err)
+	assert.Len(t, endpoints, 3)
+
+	endpoints, err = codefly.For(ctx).Service("").ResolveAll()
+	assert.NoError(t, err)
+	var names []string
+	for _, endpoint := range endpoints {
+		names = append(names, endpoint.Module+"/"+endpoint.Service+"/"+endpoint.Name)
+	}
+	assert.Equal(t, []string{
+		"billing/invoices/admin",
+		"billing/invoices/grpc",
+		"billing/invoices/public",
+		"billing/ledger/postgres",
+	}, names)
+
+	endpoints, err = codefly.For(ctx).Service("missing").ResolveAll()
+	assert.NoError(t, err)
+	assert.Empty(t, endpoints)
+}
+
+func TestResolveAllMergesRuntimeAndLocalWorkspaceEndpoints(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	t.Setenv("CODEFLY__ENVIRONMENT", "")
+	setRuntimeEndpoint(t, "platform", "warden", "rest", standards.REST, "http://warden.runtime:7000")
+	requireNoError(t, codefly.LoadEnvironmentVariables())
+	t.Cleanup(func() { _ = codefly.LoadEnvironmentVariables() })
+
+	writeFile(t, filepath.Join(root, "workspace.codefly.yaml"), `name: sdk-endpoints-test
+layout: modules
+modules:
+  - name: platform
+`)
+	writeFile(t, filepath.Join(root, "modules", "platform", "module.codefly.yaml"), `kind: module
+name: platform
+services:
+  - name: warden
+  - name: ledger
+`)
+	writeFile(t, filepath.Join(root, "modules", "platform", "services", "warden", "service.codefly.yaml"), `kind: service
+name: warden
+version: 0.0.0
+endpoints:
+  - name: rest
+  - name: grpc
+    visibility: public
+  - name: webhook
+    api: rest
+    visibility: external
+`)
+	writeFile(t, filepath.Join(root, "modules", "platform", "services", "ledger", "service.codefly.yaml"), `kind: service
+name: ledger
+version: 0.0.0
+endpoints:
+  - name: rest
+`)
+	t.Chdir(root)
+
+	_, err := codefly.For(ctx).Module("platform").Service("warden").ResolveNetworkInstance()
+	assert.ErrorContains(t, err, "multiple endpoints match")
+
+	endpoints, err := codefly.For(ctx).Module("platform").Service("warden").ResolveAll()
+	assert.NoError(t, err)
+	if assert.Len(t, endpoints, 3) {
+		grpc, rest, webhook := endpoints[0], endpoints[1], endpoints[2]
+
+		expected := network.NativeFor(ctx, "sdk-endpoints-test", "platform", "warden", "",
+			&basev0.Endpoint{Name: "grpc", Api: standards.GRPC, Visibility: resources.VisibilityPublic})
+		assert.Equal(t, "grpc", grpc.Name)
+		assert.Equal(t, standards.GRPC, grpc.API)
+		assert.Equal(t, resources.VisibilityPublic, grpc.Visibility)
+		assert.Equal(t, codefly.EndpointSourceWorkspace, grpc.Source)
+		if assert.NotNil(t, grpc.NetworkInstance) {
+			assert.Equal(t, expected.Address, grpc.NetworkInstance.Address)
+		}
+
+		// The runtime carrier wins over the workspace declaration.
+		assert.Equal(t, "rest", rest.Name)
+		assert.Equal(t, codefly.EndpointSourceRuntime, rest.Source)
+		assert.Equal(t, "http://warden.runtime:7000", rest.NetworkInstance.Address)
+
+		assert.Equal(t, "webhook", webhook.Name)
+		assert.Equal(t, standards.REST, webhook.API)
+		assert.Equal(t, resources.VisibilityExternal, webhook.Visibility)
+		assert.Nil(t, webhook.NetworkInstance)
+	}
+
+	endpoints, err = codefly.For(ctx).Module("").Service("").Endpoint("rest").ResolveAll()
+	assert.NoError(t, err)
+	if assert.Len(t, endpoints, 2) {
+		assert.Equal(t, "ledger", endpoints[0].Service)
+		assert.Equal(t, codefly.EndpointSourceWorkspace, endpoints[0].Source)
+		assert.Equal(t, "warden", endpoints[1].Service)
+	}
+
+	_, err = codefly.For(ctx).Module("platform").Service("missing").ResolveAll()
+	assert.Error(t, err)
+}
+
+func TestEndpointsListsRuntimeEndpointsAndLocalDependencies(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	t.Setenv("CODEFLY__ENVIRONMENT", "")
+	t.Setenv("CODEFLY__MODULE", "platform")
+	t.Setenv("CODEFLY__SERVICE", "gateway")
+	setRuntimeEndpoint(t, "billing", "ledger", "grpc", standards.GRPC, "http://ledger.runtime:9090")
+	setRuntimeEndpoint(t, "observability", "collector", "otlp", standards.GRPC, "http://collector.runtime:4317")
+	requireNoError(t, codefly.LoadEnvironmentVariables())
+	t.Cleanup(func() { _ = codefly.LoadEnvironmentVariables() })
+
+	writeFile(t, filepath.Join(root, "workspace.codefly.yaml"), `name: sdk-inventory-test
+layout: modules
+modules:
+  - name: platform
+  - name: billing
+`)
+	writeFile(t, filepath.Join(root, "modules", "platform", "module.codefly.yaml"), `kind: module
+name: platform
+services:
+  - name: gateway
+  - name: warden
+`)
+	writeFile(t, filepath.Join(root, "modules", "platform", "services", "gateway", "service.codefly.yaml"), `kind: service
+name: gateway
+version: 0.0.0
+endpoints:
+  - name: rest
+dependencies:
+  - name: warden
+    endpoints:
+      - name: rest
+  - name: ledger
+    module: billing
+  - name: missing
+`)
+	writeFile(t, filepath.Join(root, "modules", "platform", "services", "warden", "service.codefly.yaml"), `kind: service
+name: warden
+version: 0.0.0
+endpoints:
+  - name: rest
+  - name: grpc
+`)
+	writeFile(t, filepath.Join(root, "modules", "billing", "module.codefly.yaml"), `kind: module
+name: billing
+services:
+  - name: ledger
+`)
+	writeFile(t, filepath.Join(root, "modules", "billing", "services", "ledger", "service.codefly.yaml"), `kind: service
+name: ledger
+version: 0.0.0
+endpoints:
+  - name: grpc
+  - name: rest
+    visibility: public
+`)
+	t.Chdir(root)
+
+	inventory, err := codefly.Endpoints(ctx)
+	assert.NoError(t, err)
+	found := make(map[string]codefly.ResolvedEndpoint)
+	for _, endpoint := range inventory {
+		found[endpoint.Module+"/"+endpoint.Service+"/"+endpoint.Name] = endpoint
+	}
+	// Only the declared warden endpoint, and not the gateway's own.
+	assert.Contains(t, found, "platform/warden/rest")
+	assert.NotContains(t, found, "platform/warden/grpc")
+	assert.NotContains(t, found, "platform/gateway/rest")
+
+	assert.Equal(t, codefly.EndpointSourceRuntime, found["billing/ledger/grpc"].Source)
+	assert.Equal(t, "http://ledger.runtime:9090", found["billing/ledger/grpc"].NetworkInstance.Address)
+	rest := found["billing/ledger/rest"]
+	assert.Equal(t, codefly.EndpointSourceWorkspace, rest.Source)
+	assert.Equal(t, resources.VisibilityPublic, rest.Visibility)
+	expected := network.NativeFor(ctx, "sdk-inventory-test", "billing", "ledger", "",
+		&basev0.Endpoint{Name: "rest", Api: standards.REST, Visibility: resources.VisibilityPublic})
+	if assert.NotNil(t, rest.NetworkInstance) {
+		assert.Equal(t, expected.Address, rest.NetworkInstance.Address)
+	}
+	assert.Equal(t, codefly.EndpointSourceRuntime, found["observability/collector/otlp"].Source)
+}
+
+func TestEndpointInventoryPrintsAsTableAndJSON(t *testing.T) {
+	inventory := codefly.EndpointInventory{
+		{
+			Module:  "billing",
+			Service: "ledger",
+			Name:    "grpc",
+			API:     standards.GRPC,
+			Source:  codefly.EndpointSourceRuntime,
+			NetworkInstance: &resources.NetworkInstance{
+				Hostname: "ledger.runtime",
+				Port:     9090,
+				Host:     "ledger.runtime:9090",
+				Address:  "http://ledger.runtime:9090",
+			},
+		},
+		{
+			Module:     "platform",
+			Service:    "warden",
+			Name:       "webhook",
+			API:        standards.REST,
+			Visibility: resources.VisibilityExternal,
+			Source:     codefly.EndpointSourceWorkspace,
+		},
+	}
+
+	var table bytes.Buffer
+	requireNoError(t, inventory.WriteTable(&table))
+	assert.Equal(t, `MODULE    SERVICE  ENDPOINT  API   VISIBILITY  ADDRESS                     SOURCE
+billing   ledger   grpc      grpc  -           http://ledger.runtime:9090  runtime
+platform  warden   webhook   rest  external    -                           workspace
+`, table.String())
+
+	encoded, err := json.Marshal(inventory)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"module": "billing", "service": "ledger", "name": "grpc", "api": "grpc", "source": "runtime",
+		 "address": "http://ledger.runtime:9090", "hostname": "ledger.runtime", "port": 9090},
+		{"module": "platform", "service": "warden", "name": "webhook", "api": "rest",
+		 "visibility": "external", "source": "workspace"}
+	]`, string(encoded))
+}
@@ -0,0 +1,149 @@
+package codefly
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ExecutionContextCarrier is the header set of a message, in the style of an
+// OpenTelemetry TextMapCarrier. Unlike a TextMapCarrier it returns every value
+// of a key, so extraction can reject duplicates. Values should match keys
+// without regard to case, so a second carrier cannot hide behind other
+// casing; the carriers in this package do. http.Header implements it but
+// canonicalizes keys, so it only matches headers added through its methods
+// and not raw map entries with other casing.
+type ExecutionContextCarrier interface {
+	Values(key string) []string
+	Add(key, value string)
+}
+
+// InjectExecutionContext writes one execution context into a message's
+// headers, for queues and other transports without a dedicated carrier. Like
+// WithGRPCExecutionContext, it rejects headers that already carry either
+// value.
+func InjectExecutionContext(carrier ExecutionContextCarrier, execution ExecutionContext) error {
+	if isNilExecutionContextCarrier(carrier) {
+		return workContextFailure(
+			WorkContextReasonInvalidArgument,
+			fmt.Errorf("%w: nil message carrier", ErrWorkContextInvalid),
+		)
+	}
+	validated, err := NewExecutionContext(execution.workContext, execution.operationID)
+	if err != nil {
+		return err
+	}
+	if len(carrier.Values(WorkContextHeaderName)) != 0 {
+		return workContextFailure(
+			WorkContextReasonInvalidArgument,
+			fmt.Errorf("%w: outgoing message Work Context already set", ErrWorkContextInvalid),
+		)
+	}
+	if len(carrier.Values(operationIDHeaderName)) != 0 {
+		return workContextFailure(
+			WorkContextReasonInvalidArgument,
+			fmt.Errorf("%w: outgoing message operation ID already set", ErrWorkContextInvalid),
+		)
+	}
+	carrier.Add(WorkContextHeaderName, validated.workContext.encoded)
+	carrier.Add(operationIDHeaderName, validated.operationID)
+	return nil
+}
+
+// isNilExecutionContextCarrier reports whether carrier is nil or a nil
+// carrier of this package that cannot be written to.
+func isNilExecutionContextCarrier(carrier ExecutionContextCarrier) bool {
+	switch carrier := carrier.(type) {
+	case nil:
+		return true
+	case MapExecutionContextCarrier:
+		return carrier == nil
+	case *MessageHeadersCarrier:
+		return carrier == nil
+	}
+	return false
+}
+
+// ExtractExecutionContext reads an opaque execution context from a message's
+// headers with the same cardinality and shape rules as
+// GRPCExecutionContextFromIncoming. It does not verify Work Context trust.
+func ExtractExecutionContext(carrier ExecutionContextCarrier) (ExecutionContext, error) {
+	if carrier == nil {
+		return ExecutionContext{}, workContextFailure(
+			WorkContextReasonInvalidArgument,
+			fmt.Errorf("%w: nil message carrier", ErrWorkContextInvalid),
+		)
+	}
+	return executionContextFromCarrierValues(
+		"message",
+		carrier.Values(WorkContextHeaderName),
+		carrier.Values(operationIDHeaderName),
+	)
+}
+
+// ExtractExecutionContextIfPresent is the optional form of
+// ExtractExecutionContext: no carrier returns present=false, while a partial
+// or duplicate carrier is still an error.
+func ExtractExecutionContextIfPresent(
+	carrier ExecutionContextCarrier,
+) (execution ExecutionContext, present bool, err error) {
+	if carrier != nil &&
+		len(carrier.Values(WorkContextHeaderName)) == 0 &&
+		len(carrier.Values(operationIDHeaderName)) == 0 {
+		return ExecutionContext{}, false, nil
+	}
+	execution, err = ExtractExecutionContext(carrier)
+	if err != nil {
+		return ExecutionContext{}, false, err
+	}
+	return execution, true, nil
+}
+
+// MapExecutionContextCarrier adapts string-valued message attributes, such
+// as those of SQS, Pub/Sub, or NATS-style maps. A map holds one value per
+// key, but keys differing only in case still count as duplicates.
+type MapExecutionContextCarrier map[string]string
+
+func (c MapExecutionContextCarrier) Values(key string) []string {
+	var values []string
+	for name, value := range c {
+		if strings.EqualFold(name, key) {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func (c MapExecutionContextCarrier) Add(key, value string) {
+	c[key] = value
+}
+
+// MessageHeader is one key/value header of a message, as Kafka clients
+// represent record headers.
+type MessageHeader struct {
+	Key   string
+	Value []byte
+}
+
+// MessageHeadersCarrier adapts ordered key/value headers that may repeat a
+// key. Add appends, so use a pointer to the slice being produced.
+type MessageHeadersCarrier []MessageHeader
+
+func (c *MessageHeadersCarrier) Values(key string) []string {
+	if c == nil {
+		return nil
+	}
+	var values []string
+	for _, header := range *c {
+		if strings.EqualFold(header.Key, key) {
+			values = append(values, string(header.Value))
+		}
+	}
+	return values
+}
+
+func (c *MessageHeadersCarrier) Add(key, value string) {
+	if c == nil {
+		return
+	}
+	*c = append(*c, MessageHeader{Key: key, Value: []byte(value)})
+}
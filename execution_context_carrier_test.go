@@ -0,0 +1,107 @@
+package codefly
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// inMemoryBroker stands in for a queue: messages cross it as headers and a
+// body, with no shared Go values between producer and consumer.
+type inMemoryBroker struct {
+	messages chan inMemoryMessage
+}
+
+type inMemoryMessage struct {
+	headers MessageHeadersCarrier
+	body    []byte
+}
+
+func (b *inMemoryBroker) publish(headers MessageHeadersCarrier, body []byte) {
+	copied := make(MessageHeadersCarrier, len(headers))
+	for index, header := range headers {
+		copied[index] = MessageHeader{Key: header.Key, Value: append([]byte(nil), header.Value...)}
+	}
+	b.messages <- inMemoryMessage{headers: copied, body: append([]byte(nil), body...)}
+}
+
+func TestExecutionContextCrossesMessageBroker(t *testing.T) {
+	broker := &inMemoryBroker{messages: make(chan inMemoryMessage, 1)}
+	execution, err := NewExecutionContext(opaqueTestWorkContext(t), "operation-019f8fc1")
+	require.NoError(t, err)
+
+	headers := MessageHeadersCarrier{{Key: "content-type", Value: []byte("application/json")}}
+	require.NoError(t, InjectExecutionContext(&headers, execution))
+	broker.publish(headers, []byte(`{"task":"task-1"}`))
+
+	message := <-broker.messages
+	received, err := ExtractExecutionContext(&message.headers)
+	require.NoError(t, err)
+	require.Equal(t, execution.WorkContext().Encoded(), received.WorkContext().Encoded())
+	require.Equal(t, execution.OperationID(), received.OperationID())
+	require.Equal(t, []string{"application/json"}, message.headers.Values("Content-Type"))
+}
+
+func TestExecutionContextCarriersEnforceCardinality(t *testing.T) {
+	execution, err := NewExecutionContext(opaqueTestWorkContext(t), "operation-1")
+	require.NoError(t, err)
+	token := execution.WorkContext().Encoded()
+
+	attributes := MapExecutionContextCarrier{}
+	require.NoError(t, InjectExecutionContext(attributes, execution))
+	received, err := ExtractExecutionContext(attributes)
+	require.NoError(t, err)
+	require.Equal(t, "operation-1", received.OperationID())
+	err = InjectExecutionContext(attributes, execution)
+	require.ErrorContains(t, err, "outgoing message Work Context already set")
+	require.Equal(t, WorkContextReasonInvalidArgument, WorkContextErrorReasonOf(err))
+
+	attributes["X-Codefly-Operation-Id"] = "operation-2"
+	_, err = ExtractExecutionContext(attributes)
+	require.ErrorContains(t, err, "message operation ID requires exactly one value")
+	require.Equal(t, WorkContextReasonMalformed, WorkContextErrorReasonOf(err))
+
+	headers := MessageHeadersCarrier{
+		{Key: WorkContextHeaderName, Value: []byte(token)},
+		{Key: "X-CODEFLY-WORK-CONTEXT", Value: []byte(token)},
+		{Key: operationIDHeaderName, Value: []byte("operation-1")},
+	}
+	_, err = ExtractExecutionContext(&headers)
+	require.ErrorContains(t, err, "message Work Context requires exactly one value")
+
+	headers = MessageHeadersCarrier{{Key: WorkContextHeaderName, Value: []byte(token)}}
+	_, present, err := ExtractExecutionContextIfPresent(&headers)
+	require.Equal(t, WorkContextReasonMissing, WorkContextErrorReasonOf(err))
+	require.False(t, present)
+	headers.Add(operationIDHeaderName, "operation 1")
+	_, err = ExtractExecutionContext(&headers)
+	require.ErrorContains(t, err, "operation ID contains unsupported characters")
+
+	_, present, err = ExtractExecutionContextIfPresent(&MessageHeadersCarrier{})
+	require.NoError(t, err)
+	require.False(t, present)
+	var none *MessageHeadersCarrier
+	_, present, err = ExtractExecutionContextIfPresent(none)
+	require.NoError(t, err)
+	require.False(t, present)
+	_, err = ExtractExecutionContext(nil)
+	require.Equal(t, WorkContextReasonInvalidArgument, WorkContextErrorReasonOf(err))
+	require.Error(t, InjectExecutionContext(nil, execution))
+	err = InjectExecutionContext(none, execution)
+	require.Equal(t, WorkContextReasonInvalidArgument, WorkContextErrorReasonOf(err))
+	err = InjectExecutionContext(MapExecutionContextCarrier(nil), execution)
+	require.Equal(t, WorkContextReasonInvalidArgument, WorkContextErrorReasonOf(err))
+	require.NotPanics(t, func() { none.Add(operationIDHeaderName, "operation-1") })
+	require.Error(t, InjectExecutionContext(MapExecutionContextCarrier{}, ExecutionContext{}))
+}
+
+func TestHTTPHeaderIsAnExecutionContextCarrier(t *testing.T) {
+	execution, err := NewExecutionContext(opaqueTestWorkContext(t), "operation-1")
+	require.NoError(t, err)
+	headers := http.Header{}
+	require.NoError(t, InjectExecutionContext(headers, execution))
+	received, err := HTTPExecutionContextFromHeaders(headers)
+	require.NoError(t, err)
+	require.Equal(t, "operation-1", received.OperationID())
+}
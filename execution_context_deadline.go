@@ -0,0 +1,186 @@
+package codefly
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrWorkContextExpiresBeforeDeadline is the sentinel matched by every
+// *WorkContextDeadlineError. WorkContextErrorReasonOf classifies it as
+// WorkContextReasonExpired.
+var ErrWorkContextExpiresBeforeDeadline = errors.New("Codefly Work Context expires before the call deadline")
+
+// WorkContextDeadlineError reports an outgoing call refused before it was
+// sent because its Work Context would expire before the call's deadline.
+type WorkContextDeadlineError struct {
+	// ExpiresAt is when the Work Context that would have been sent expires,
+	// after any renewal.
+	ExpiresAt time.Time
+	// Deadline is the deadline of the call's context, zero when it has none
+	// and the Work Context has already expired.
+	Deadline time.Time
+}
+
+func (e *WorkContextDeadlineError) Error() string {
+	message := fmt.Sprintf("%s: expires at %s", ErrWorkContextExpiresBeforeDeadline, e.ExpiresAt.UTC().Format(time.RFC3339))
+	if !e.Deadline.IsZero() {
+		message += ", deadline is " + e.Deadline.UTC().Format(time.RFC3339Nano)
+	}
+	return message
+}
+
+func (e *WorkContextDeadlineError) Unwrap() error {
+	return ErrWorkContextExpiresBeforeDeadline
+}
+
+// WorkContextDeadlinePolicy selects what ExecutionContextDeadlines does with a
+// call whose deadline outlives its Work Context.
+type WorkContextDeadlinePolicy int
+
+const (
+	// WorkContextDeadlineShorten moves the call's deadline to the Work
+	// Context's expiry, so it ends with a deadline error the caller already
+	// handles. A call without a deadline gets one.
+	WorkContextDeadlineShorten WorkContextDeadlinePolicy = iota
+	// WorkContextDeadlineRenew obtains a fresh Work Context from the token
+	// source and sends that instead. The call fails with a
+	// *WorkContextDeadlineError when the renewal does not cover the deadline
+	// either.
+	WorkContextDeadlineRenew
+	// WorkContextDeadlineFailFast fails the call with a
+	// *WorkContextDeadlineError.
+	WorkContextDeadlineFailFast
+)
+
+// ExecutionContextDeadlinesOptions configures NewExecutionContextDeadlines.
+type ExecutionContextDeadlinesOptions struct {
+	Policy WorkContextDeadlinePolicy
+	// TokenSource renews Work Contexts. It is required by
+	// WorkContextDeadlineRenew and ignored otherwise. This process trusts its
+	// own token source, so renewals are not verified before they are sent.
+	TokenSource WorkContextTokenSource
+	// Margin treats a Work Context as expiring that much earlier, to cover
+	// transit time and a client clock behind the server's.
+	Margin time.Duration
+	Now    func() time.Time
+}
+
+// ExecutionContextDeadlines aligns outgoing calls with the expiry of the Work
+// Context they carry, before anything is sent. Calls without an execution
+// context, or whose Work Context expiry cannot be read, are sent unchanged;
+// the server judges them.
+type ExecutionContextDeadlines struct {
+	policy      WorkContextDeadlinePolicy
+	tokenSource WorkContextTokenSource
+	margin      time.Duration
+	now         func() time.Time
+}
+
+// NewExecutionContextDeadlines validates options and returns gRPC and HTTP
+// client middleware.
+func NewExecutionContextDeadlines(options ExecutionContextDeadlinesOptions) (*ExecutionContextDeadlines, error) {
+	switch options.Policy {
+	case WorkContextDeadlineShorten, WorkContextDeadlineFailFast:
+	case WorkContextDeadlineRenew:
+		if options.TokenSource == nil {
+			return nil, errors.New("Codefly Work Context deadline renewal requires a token source")
+		}
+	default:
+		return nil, fmt.Errorf("unknown Codefly Work Context deadline policy %d", options.Policy)
+	}
+	if options.Margin < 0 {
+		return nil, errors.New("Codefly Work Context deadline margin must not be negative")
+	}
+	now := options.Now
+	if now == nil {
+		now = time.Now
+	}
+	return &ExecutionContextDeadlines{
+		policy:      options.Policy,
+		tokenSource: options.TokenSource,
+		margin:      options.Margin,
+		now:         now,
+	}, nil
+}
+
+// align returns the context and execution context to send the call with. The
+// returned cancel releases a shortened deadline and must be called once the
+// call is over; renewed reports whether execution was replaced.
+func (d *ExecutionContextDeadlines) align(
+	ctx context.Context,
+	execution ExecutionContext,
+) (aligned context.Context, sent ExecutionContext, cancel context.CancelFunc, renewed bool, err error) {
+	expiresAt, err := workContextTokenExpiry(execution.workContext)
+	if err != nil {
+		return ctx, execution, func() {}, false, nil
+	}
+	usable := expiresAt.Add(-d.margin)
+	deadline, hasDeadline := ctx.Deadline()
+	live := d.now().Before(usable)
+	switch {
+	case !live:
+	case !hasDeadline:
+		// Only shortening can align a call without a deadline: the other
+		// policies cannot know how long it will run.
+		if d.policy != WorkContextDeadlineShorten {
+			return ctx, execution, func() {}, false, nil
+		}
+	case !deadline.After(usable):
+		return ctx, execution, func() {}, false, nil
+	}
+	failure := &WorkContextDeadlineError{ExpiresAt: expiresAt}
+	if hasDeadline {
+		failure.Deadline = deadline
+	}
+	switch d.policy {
+	case WorkContextDeadlineShorten:
+		if !live {
+			return nil, ExecutionContext{}, nil, false, workContextFailure(WorkContextReasonExpired, failure)
+		}
+		aligned, cancel = context.WithDeadline(ctx, usable)
+		return aligned, execution, cancel, false, nil
+	case WorkContextDeadlineRenew:
+		token, err := d.tokenSource.RenewWorkContext(ctx, execution.workContext)
+		if err != nil {
+			return nil, ExecutionContext{}, nil, false, workContextFailure(
+				WorkContextReasonExpired,
+				fmt.Errorf("%w: renew Work Context for deadline: %w", ErrWorkContextInvalid, err),
+			)
+		}
+		sent, err = NewExecutionContext(token, execution.operationID)
+		if err != nil {
+			return nil, ExecutionContext{}, nil, false, err
+		}
+		renewedExpiry, err := workContextTokenExpiry(token)
+		if err != nil {
+			return nil, ExecutionContext{}, nil, false, workContextFailure(WorkContextReasonMalformed, err)
+		}
+		renewedUsable := renewedExpiry.Add(-d.margin)
+		if !d.now().Before(renewedUsable) || (hasDeadline && deadline.After(renewedUsable)) {
+			failure.ExpiresAt = renewedExpiry
+			return nil, ExecutionContext{}, nil, false, workContextFailure(WorkContextReasonExpired, failure)
+		}
+		return ctx, sent, func() {}, true, nil
+	default:
+		return nil, ExecutionContext{}, nil, false, workContextFailure(WorkContextReasonExpired, failure)
+	}
+}
+
+// workContextTokenExpiry reads the expiry of a token this process is about to
+// send. It is not a trust decision, so the signature is not checked.
+func workContextTokenExpiry(token WorkContextToken) (time.Time, error) {
+	payload, _, err := decodeWorkContextToken(token.encoded)
+	if err != nil {
+		return time.Time{}, err
+	}
+	claims, _, err := unmarshalWorkContext(payload)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if claims.GetExpiresAtUnix() == 0 {
+		return time.Time{}, errors.New("Codefly Work Context has no expiry")
+	}
+	return time.Unix(claims.GetExpiresAtUnix(), 0), nil
+}
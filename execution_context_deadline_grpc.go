@@ -0,0 +1,88 @@
+package codefly
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// UnaryClientInterceptor aligns each call carrying an execution context
+// attached by WithGRPCExecutionContext. A refused call returns the
+// *WorkContextDeadlineError, not a gRPC status, since nothing was sent.
+func (d *ExecutionContextDeadlines) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		request, reply any,
+		connection *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		options ...grpc.CallOption,
+	) error {
+		ctx, cancel, err := d.alignOutgoing(ctx)
+		if err != nil {
+			return err
+		}
+		defer cancel()
+		return invoker(ctx, method, request, reply, connection, options...)
+	}
+}
+
+// StreamClientInterceptor is the streaming form of UnaryClientInterceptor. A
+// shortened deadline bounds the whole stream.
+func (d *ExecutionContextDeadlines) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(
+		ctx context.Context,
+		description *grpc.StreamDesc,
+		connection *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		options ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		ctx, cancel, err := d.alignOutgoing(ctx)
+		if err != nil {
+			return nil, err
+		}
+		stream, err := streamer(ctx, description, connection, method, options...)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		return &deadlineClientStream{ClientStream: stream, cancel: cancel, serverStreams: description.ServerStreams}, nil
+	}
+}
+
+func (d *ExecutionContextDeadlines) alignOutgoing(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	execution, present, err := grpcExecutionContextFromOutgoingIfPresent(ctx)
+	if err != nil || !present {
+		// A malformed carrier is the server's to reject.
+		return ctx, func() {}, nil
+	}
+	aligned, sent, cancel, renewed, err := d.align(ctx, execution)
+	if err != nil {
+		return nil, nil, err
+	}
+	if renewed {
+		values, _ := metadata.FromOutgoingContext(aligned)
+		values = values.Copy()
+		values.Set(workContextGRPCMetadataName, sent.workContext.encoded)
+		aligned = metadata.NewOutgoingContext(aligned, values)
+	}
+	return aligned, cancel, nil
+}
+
+// deadlineClientStream releases a shortened deadline once the stream is over,
+// which gRPC signals through RecvMsg.
+type deadlineClientStream struct {
+	grpc.ClientStream
+	cancel        context.CancelFunc
+	serverStreams bool
+}
+
+func (s *deadlineClientStream) RecvMsg(message any) error {
+	err := s.ClientStream.RecvMsg(message)
+	if err != nil || !s.serverStreams {
+		s.cancel()
+	}
+	return err
+}
@@ -0,0 +1,100 @@
+package codefly
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+type deadlineTestClientStream struct {
+	grpc.ClientStream
+	received int
+}
+
+func (s *deadlineTestClientStream) RecvMsg(any) error {
+	s.received++
+	if s.received > 1 {
+		return io.EOF
+	}
+	return nil
+}
+
+func TestExecutionContextDeadlinesUnaryInterceptorRenewsOutgoingMetadata(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	execution := deadlineTestExecution(t, now.Add(-4*time.Minute), 5*time.Minute)
+	fresh := deadlineTestExecution(t, now, 15*time.Minute).WorkContext()
+	interceptor := newExecutionContextDeadlinesForTest(t, now, ExecutionContextDeadlinesOptions{
+		Policy: WorkContextDeadlineRenew,
+		TokenSource: WorkContextTokenSourceFunc(func(context.Context, WorkContextToken) (WorkContextToken, error) {
+			return fresh, nil
+		}),
+	}).UnaryClientInterceptor()
+
+	ctx, cancel := context.WithDeadline(metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "request-1"), now.Add(10*time.Minute))
+	defer cancel()
+	ctx, err := WithGRPCExecutionContext(ctx, execution)
+	require.NoError(t, err)
+	err = interceptor(ctx, "/warden.v1.Tasks/Start", nil, nil, nil,
+		func(ctx context.Context, _ string, _, _ any, _ *grpc.ClientConn, _ ...grpc.CallOption) error {
+			sent, present, err := grpcExecutionContextFromOutgoingIfPresent(ctx)
+			require.NoError(t, err)
+			require.True(t, present)
+			require.Equal(t, fresh, sent.WorkContext())
+			values, _ := metadata.FromOutgoingContext(ctx)
+			require.Equal(t, []string{"request-1"}, values.Get("x-request-id"))
+			return nil
+		})
+	require.NoError(t, err)
+}
+
+func TestExecutionContextDeadlinesUnaryInterceptorFailsBeforeSending(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	interceptor := newExecutionContextDeadlinesForTest(t, now, ExecutionContextDeadlinesOptions{
+		Policy: WorkContextDeadlineFailFast,
+	}).UnaryClientInterceptor()
+	ctx, cancel := context.WithDeadline(context.Background(), now.Add(time.Hour))
+	defer cancel()
+	ctx, err := WithGRPCExecutionContext(ctx, deadlineTestExecution(t, now, 5*time.Minute))
+	require.NoError(t, err)
+	invoked := false
+	invoker := func(context.Context, string, any, any, *grpc.ClientConn, ...grpc.CallOption) error {
+		invoked = true
+		return nil
+	}
+	err = interceptor(ctx, "/warden.v1.Tasks/Start", nil, nil, nil, invoker)
+	require.ErrorIs(t, err, ErrWorkContextExpiresBeforeDeadline)
+	require.False(t, invoked)
+
+	require.NoError(t, interceptor(context.Background(), "/warden.v1.Tasks/Start", nil, nil, nil, invoker))
+	require.True(t, invoked, "calls without an execution context are sent unchanged")
+}
+
+func TestExecutionContextDeadlinesStreamInterceptorBoundsTheStream(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	interceptor := newExecutionContextDeadlinesForTest(t, now, ExecutionContextDeadlinesOptions{
+		Policy: WorkContextDeadlineShorten,
+	}).StreamClientInterceptor()
+	ctx, err := WithGRPCExecutionContext(context.Background(), deadlineTestExecution(t, now, 5*time.Minute))
+	require.NoError(t, err)
+
+	var streamContext context.Context
+	stream, err := interceptor(ctx, &grpc.StreamDesc{ServerStreams: true}, nil, "/warden.v1.Tasks/Watch",
+		func(ctx context.Context, _ *grpc.StreamDesc, _ *grpc.ClientConn, _ string, _ ...grpc.CallOption) (grpc.ClientStream, error) {
+			streamContext = ctx
+			return &deadlineTestClientStream{}, nil
+		})
+	require.NoError(t, err)
+	deadline, ok := streamContext.Deadline()
+	require.True(t, ok)
+	require.Equal(t, now.Add(5*time.Minute), deadline)
+
+	require.NoError(t, stream.RecvMsg(nil))
+	require.NoError(t, streamContext.Err())
+	require.ErrorIs(t, stream.RecvMsg(nil), io.EOF)
+	require.ErrorIs(t, streamContext.Err(), context.Canceled, "the deadline is released when the stream ends")
+}
@@ -0,0 +1,65 @@
+package codefly
+
+import (
+	"context"
+	"io"
+	"net/http"
+)
+
+// HTTPTransport aligns each request carrying an execution context attached
+// by AttachExecutionContext. A shortened deadline lasts until the response
+// body is closed. A nil next uses http.DefaultTransport.
+func (d *ExecutionContextDeadlines) HTTPTransport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return deadlineRoundTripper{deadlines: d, next: next}
+}
+
+type deadlineRoundTripper struct {
+	deadlines *ExecutionContextDeadlines
+	next      http.RoundTripper
+}
+
+func (r deadlineRoundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
+	execution, present, err := HTTPExecutionContextFromHeadersIfPresent(request.Header)
+	if err != nil || !present {
+		return r.next.RoundTrip(request)
+	}
+	ctx, sent, cancel, renewed, err := r.deadlines.align(request.Context(), execution)
+	if err != nil {
+		if request.Body != nil {
+			// A RoundTripper must close the body, even on errors.
+			_ = request.Body.Close()
+		}
+		return nil, err
+	}
+	// RoundTrippers must not modify the caller's request.
+	aligned := request.WithContext(ctx)
+	if renewed {
+		aligned = request.Clone(ctx)
+		aligned.Header.Set(WorkContextHeaderName, sent.workContext.encoded)
+	}
+	response, err := r.next.RoundTrip(aligned)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if ctx != request.Context() {
+		response.Body = &deadlineResponseBody{ReadCloser: response.Body, cancel: cancel}
+	}
+	return response, nil
+}
+
+// deadlineResponseBody releases a shortened deadline when the caller is done
+// with the response.
+type deadlineResponseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *deadlineResponseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,90 @@
+package codefly
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type deadlineTestBody struct {
+	io.Reader
+	closed bool
+}
+
+func (b *deadlineTestBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestExecutionContextDeadlinesHTTPTransportShortensUntilBodyClose(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	var sent *http.Request
+	transport := newExecutionContextDeadlinesForTest(t, now, ExecutionContextDeadlinesOptions{
+		Policy: WorkContextDeadlineShorten,
+	}).HTTPTransport(telemetryTestRoundTripper(func(request *http.Request) (*http.Response, error) {
+		sent = request
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("ok")), Request: request}, nil
+	}))
+
+	request, err := http.NewRequest(http.MethodGet, "http://warden.internal/tasks", nil)
+	require.NoError(t, err)
+	require.NoError(t, AttachExecutionContext(request, deadlineTestExecution(t, now, 5*time.Minute)))
+	response, err := transport.RoundTrip(request)
+	require.NoError(t, err)
+	deadline, ok := sent.Context().Deadline()
+	require.True(t, ok)
+	require.Equal(t, now.Add(5*time.Minute), deadline)
+	_, ok = request.Context().Deadline()
+	require.False(t, ok, "the caller's request is not modified")
+
+	body, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	require.Equal(t, "ok", string(body))
+	require.NoError(t, sent.Context().Err())
+	require.NoError(t, response.Body.Close())
+	require.ErrorIs(t, sent.Context().Err(), context.Canceled)
+}
+
+func TestExecutionContextDeadlinesHTTPTransportRenewsOrFailsBeforeSending(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	execution := deadlineTestExecution(t, now.Add(-4*time.Minute), 5*time.Minute)
+	fresh := deadlineTestExecution(t, now, 15*time.Minute).WorkContext()
+	var sent *http.Request
+	next := telemetryTestRoundTripper(func(request *http.Request) (*http.Response, error) {
+		sent = request
+		return &http.Response{StatusCode: http.StatusNoContent, Body: http.NoBody, Request: request}, nil
+	})
+	ctx, cancel := context.WithDeadline(context.Background(), now.Add(10*time.Minute))
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://warden.internal/tasks", nil)
+	require.NoError(t, err)
+	require.NoError(t, AttachExecutionContext(request, execution))
+
+	renewing := newExecutionContextDeadlinesForTest(t, now, ExecutionContextDeadlinesOptions{
+		Policy: WorkContextDeadlineRenew,
+		TokenSource: WorkContextTokenSourceFunc(func(context.Context, WorkContextToken) (WorkContextToken, error) {
+			return fresh, nil
+		}),
+	}).HTTPTransport(next)
+	response, err := renewing.RoundTrip(request)
+	require.NoError(t, err)
+	require.NoError(t, response.Body.Close())
+	require.Equal(t, fresh.Encoded(), sent.Header.Get(WorkContextHeaderName))
+	require.Equal(t, execution.WorkContext().Encoded(), request.Header.Get(WorkContextHeaderName))
+
+	sent = nil
+	body := &deadlineTestBody{Reader: strings.NewReader("payload")}
+	request.Body = body
+	failing := newExecutionContextDeadlinesForTest(t, now, ExecutionContextDeadlinesOptions{
+		Policy: WorkContextDeadlineFailFast,
+	}).HTTPTransport(next)
+	_, err = failing.RoundTrip(request)
+	requireWorkContextDeadlineError(t, err, now.Add(time.Minute), now.Add(10*time.Minute))
+	require.Nil(t, sent, "nothing is sent")
+	require.True(t, body.closed)
+}
@@ -0,0 +1,163 @@
+package codefly
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// deadlineTestExecution returns an execution context whose Work Context
+// was minted at now and lives for ttl.
+func deadlineTestExecution(t *testing.T, now time.Time, ttl time.Duration) ExecutionContext {
+	t.Helper()
+	input := workContextTestInput()
+	input.TTL = ttl
+	token, _, err := workContextTestSigner(t, now).StartTask(input)
+	require.NoError(t, err)
+	execution, err := NewExecutionContext(token, "operation-1")
+	require.NoError(t, err)
+	return execution
+}
+
+func newExecutionContextDeadlinesForTest(
+	t *testing.T,
+	now time.Time,
+	options ExecutionContextDeadlinesOptions,
+) *ExecutionContextDeadlines {
+	t.Helper()
+	options.Now = func() time.Time { return now }
+	deadlines, err := NewExecutionContextDeadlines(options)
+	require.NoError(t, err)
+	return deadlines
+}
+
+func requireWorkContextDeadlineError(t *testing.T, err error, expiresAt, deadline time.Time) {
+	t.Helper()
+	var typed *WorkContextDeadlineError
+	require.ErrorAs(t, err, &typed)
+	require.ErrorIs(t, err, ErrWorkContextExpiresBeforeDeadline)
+	require.Equal(t, WorkContextReasonExpired, WorkContextErrorReasonOf(err))
+	require.True(t, expiresAt.Equal(typed.ExpiresAt), "expires at %s, want %s", typed.ExpiresAt, expiresAt)
+	require.True(t, deadline.Equal(typed.Deadline), "deadline %s, want %s", typed.Deadline, deadline)
+}
+
+func TestExecutionContextDeadlinesShortenToExpiry(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	execution := deadlineTestExecution(t, now, 5*time.Minute)
+	deadlines := newExecutionContextDeadlinesForTest(t, now, ExecutionContextDeadlinesOptions{
+		Policy: WorkContextDeadlineShorten,
+		Margin: 2 * time.Second,
+	})
+	usable := now.Add(5*time.Minute - 2*time.Second)
+
+	long, cancelLong := context.WithDeadline(context.Background(), now.Add(time.Hour))
+	defer cancelLong()
+	for _, ctx := range []context.Context{long, context.Background()} {
+		aligned, sent, cancel, renewed, err := deadlines.align(ctx, execution)
+		require.NoError(t, err)
+		deadline, ok := aligned.Deadline()
+		require.True(t, ok)
+		require.Equal(t, usable, deadline)
+		require.Equal(t, execution, sent)
+		require.False(t, renewed)
+		cancel()
+		require.ErrorIs(t, aligned.Err(), context.Canceled)
+	}
+
+	short, cancelShort := context.WithDeadline(context.Background(), now.Add(time.Minute))
+	defer cancelShort()
+	aligned, _, cancel, _, err := deadlines.align(short, execution)
+	require.NoError(t, err)
+	require.Equal(t, short, aligned, "a deadline within the Work Context lifetime is kept")
+	cancel()
+	require.NoError(t, short.Err())
+
+	expired := deadlineTestExecution(t, now.Add(-10*time.Minute), 5*time.Minute)
+	_, _, _, _, err = deadlines.align(context.Background(), expired)
+	requireWorkContextDeadlineError(t, err, now.Add(-5*time.Minute), time.Time{})
+}
+
+func TestExecutionContextDeadlinesFailFast(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	execution := deadlineTestExecution(t, now, 5*time.Minute)
+	deadlines := newExecutionContextDeadlinesForTest(t, now, ExecutionContextDeadlinesOptions{
+		Policy: WorkContextDeadlineFailFast,
+	})
+
+	long, cancel := context.WithDeadline(context.Background(), now.Add(time.Hour))
+	defer cancel()
+	_, _, _, _, err := deadlines.align(long, execution)
+	requireWorkContextDeadlineError(t, err, now.Add(5*time.Minute), now.Add(time.Hour))
+	require.EqualError(t, err, "Codefly Work Context expires before the call deadline: expires at "+
+		now.Add(5*time.Minute).UTC().Format(time.RFC3339)+", deadline is "+now.Add(time.Hour).UTC().Format(time.RFC3339Nano))
+
+	aligned, _, _, _, err := deadlines.align(context.Background(), execution)
+	require.NoError(t, err, "a call without a deadline is not judged while the Work Context is live")
+	require.Equal(t, context.Background(), aligned)
+}
+
+func TestExecutionContextDeadlinesRenewBeforeSending(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	execution := deadlineTestExecution(t, now.Add(-4*time.Minute), 5*time.Minute)
+	fresh := deadlineTestExecution(t, now, 15*time.Minute).WorkContext()
+	var renewals []WorkContextToken
+	renewWith := func(token WorkContextToken, err error) *ExecutionContextDeadlines {
+		return newExecutionContextDeadlinesForTest(t, now, ExecutionContextDeadlinesOptions{
+			Policy: WorkContextDeadlineRenew,
+			TokenSource: WorkContextTokenSourceFunc(func(_ context.Context, current WorkContextToken) (WorkContextToken, error) {
+				renewals = append(renewals, current)
+				return token, err
+			}),
+		})
+	}
+
+	ctx, cancel := context.WithDeadline(context.Background(), now.Add(10*time.Minute))
+	defer cancel()
+	aligned, sent, _, renewed, err := renewWith(fresh, nil).align(ctx, execution)
+	require.NoError(t, err)
+	require.True(t, renewed)
+	require.Equal(t, ctx, aligned)
+	require.Equal(t, fresh, sent.WorkContext())
+	require.Equal(t, "operation-1", sent.OperationID(), "renewal keeps the operation")
+	require.Equal(t, []WorkContextToken{execution.WorkContext()}, renewals)
+
+	short := deadlineTestExecution(t, now, 5*time.Minute).WorkContext()
+	_, _, _, _, err = renewWith(short, nil).align(ctx, execution)
+	requireWorkContextDeadlineError(t, err, now.Add(5*time.Minute), now.Add(10*time.Minute))
+
+	authorityDown := errors.New("authority unavailable")
+	_, _, _, _, err = renewWith(WorkContextToken{}, authorityDown).align(ctx, execution)
+	require.ErrorIs(t, err, authorityDown)
+	require.Equal(t, WorkContextReasonExpired, WorkContextErrorReasonOf(err))
+
+	renewals = nil
+	_, sent, _, renewed, err = renewWith(fresh, nil).align(context.Background(), execution)
+	require.NoError(t, err)
+	require.False(t, renewed)
+	require.Equal(t, execution, sent)
+	require.Empty(t, renewals, "a live Work Context without a deadline is not renewed")
+}
+
+func TestExecutionContextDeadlinesSendUnreadableTokensUnchanged(t *testing.T) {
+	execution, err := NewExecutionContext(opaqueTestWorkContext(t), "operation-1")
+	require.NoError(t, err)
+	deadlines := newExecutionContextDeadlinesForTest(t, time.Now(), ExecutionContextDeadlinesOptions{
+		Policy: WorkContextDeadlineFailFast,
+	})
+	aligned, sent, _, _, err := deadlines.align(context.Background(), execution)
+	require.NoError(t, err)
+	require.Equal(t, context.Background(), aligned)
+	require.Equal(t, execution, sent)
+}
+
+func TestNewExecutionContextDeadlinesValidatesOptions(t *testing.T) {
+	_, err := NewExecutionContextDeadlines(ExecutionContextDeadlinesOptions{Policy: WorkContextDeadlineRenew})
+	require.ErrorContains(t, err, "requires a token source")
+	_, err = NewExecutionContextDeadlines(ExecutionContextDeadlinesOptions{Policy: WorkContextDeadlinePolicy(9)})
+	require.ErrorContains(t, err, "unknown Codefly Work Context deadline policy 9")
+	_, err = NewExecutionContextDeadlines(ExecutionContextDeadlinesOptions{Margin: -time.Second})
+	require.ErrorContains(t, err, "must not be negative")
+}
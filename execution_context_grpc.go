@@ -10,7 +10,7 @@ import (
 
 const (
 	workContextGRPCMetadataName = WorkContextHeaderName
-	operationIDGRPCMetadataName = "x-codefly-operation-id"
+	operationIDGRPCMetadataName = operationIDHeaderName
 	maxOperationIDBytes         = 128
 )
 
@@ -60,7 +60,10 @@ func WithGRPCExecutionContext(
 	execution ExecutionContext,
 ) (context.Context, error) {
 	if ctx == nil {
-		return nil, fmt.Errorf("%w: nil gRPC context", ErrWorkContextInvalid)
+		return nil, workContextFailure(
+			WorkContextReasonInvalidArgument,
+			fmt.Errorf("%w: nil gRPC context", ErrWorkContextInvalid),
+		)
 	}
 	validated, err := NewExecutionContext(execution.workContext, execution.operationID)
 	if err != nil {
@@ -87,31 +90,63 @@ func WithGRPCExecutionContext(
 // does not verify Work Context trust.
 func GRPCExecutionContextFromIncoming(ctx context.Context) (ExecutionContext, error) {
 	if ctx == nil {
-		return ExecutionContext{}, fmt.Errorf("%w: nil gRPC context", ErrWorkContextInvalid)
+		return ExecutionContext{}, workContextFailure(
+			WorkContextReasonInvalidArgument,
+			fmt.Errorf("%w: nil gRPC context", ErrWorkContextInvalid),
+		)
 	}
 	values, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
-		return ExecutionContext{}, fmt.Errorf("%w: missing incoming gRPC metadata", ErrWorkContextInvalid)
+		return ExecutionContext{}, workContextFailure(
+			WorkContextReasonMissing,
+			fmt.Errorf("%w: missing incoming gRPC metadata", ErrWorkContextInvalid),
+		)
 	}
-	workContexts := values.Get(workContextGRPCMetadataName)
+	return executionContextFromCarrierValues(
+		"incoming gRPC",
+		values.Get(workContextGRPCMetadataName),
+		values.Get(operationIDGRPCMetadataName),
+	)
+}
+
+// executionContextFromCarrierValues applies the rules every transport shares:
+// exactly one value per carrier, a well-formed token, and a canonical
+// operation ID.
+func executionContextFromCarrierValues(
+	carrier string,
+	workContexts []string,
+	operationIDs []string,
+) (ExecutionContext, error) {
 	if len(workContexts) != 1 {
-		return ExecutionContext{}, fmt.Errorf(
-			"%w: incoming gRPC Work Context requires exactly one value",
-			ErrWorkContextInvalid,
+		return ExecutionContext{}, workContextFailure(
+			carrierCardinalityReason(len(workContexts)),
+			fmt.Errorf("%w: %s Work Context requires exactly one value", ErrWorkContextInvalid, carrier),
 		)
 	}
-	operationIDs := values.Get(operationIDGRPCMetadataName)
 	if len(operationIDs) != 1 {
-		return ExecutionContext{}, fmt.Errorf(
-			"%w: incoming gRPC operation ID requires exactly one value",
-			ErrWorkContextInvalid,
+		return ExecutionContext{}, workContextFailure(
+			carrierCardinalityReason(len(operationIDs)),
+			fmt.Errorf("%w: %s operation ID requires exactly one value", ErrWorkContextInvalid, carrier),
 		)
 	}
 	workContext, err := ParseWorkContextToken(workContexts[0])
 	if err != nil {
 		return ExecutionContext{}, err
 	}
-	return NewExecutionContext(workContext, operationIDs[0])
+	execution, err := NewExecutionContext(workContext, operationIDs[0])
+	if err != nil {
+		return ExecutionContext{}, workContextFailure(WorkContextReasonMalformed, err)
+	}
+	return execution, nil
+}
+
+// carrierCardinalityReason separates an absent carrier, which a client
+// fixes by authenticating, from a duplicated one, which it must not retry.
+func carrierCardinalityReason(values int) WorkContextErrorReason {
+	if values == 0 {
+		return WorkContextReasonMissing
+	}
+	return WorkContextReasonMalformed
 }
 
 // GRPCExecutionContextFromIncomingIfPresent supports compatibility boundaries
@@ -121,7 +156,10 @@ func GRPCExecutionContextFromIncomingIfPresent(
 	ctx context.Context,
 ) (execution ExecutionContext, present bool, err error) {
 	if ctx == nil {
-		return ExecutionContext{}, false, fmt.Errorf("%w: nil gRPC context", ErrWorkContextInvalid)
+		return ExecutionContext{}, false, workContextFailure(
+			WorkContextReasonInvalidArgument,
+			fmt.Errorf("%w: nil gRPC context", ErrWorkContextInvalid),
+		)
 	}
 	values, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
@@ -139,6 +177,24 @@ func GRPCExecutionContextFromIncomingIfPresent(
 	return execution, true, nil
 }
 
+// grpcExecutionContextFromOutgoingIfPresent reads back the execution context
+// WithGRPCExecutionContext attached, for client interceptors.
+func grpcExecutionContextFromOutgoingIfPresent(
+	ctx context.Context,
+) (execution ExecutionContext, present bool, err error) {
+	values, _ := metadata.FromOutgoingContext(ctx)
+	workContexts := values.Get(workContextGRPCMetadataName)
+	operationIDs := values.Get(operationIDGRPCMetadataName)
+	if len(workContexts) == 0 && len(operationIDs) == 0 {
+		return ExecutionContext{}, false, nil
+	}
+	execution, err = executionContextFromCarrierValues("outgoing gRPC", workContexts, operationIDs)
+	if err != nil {
+		return ExecutionContext{}, false, err
+	}
+	return execution, true, nil
+}
+
 func validateOperationID(operationID string) error {
 	if operationID == "" {
 		return fmt.Errorf("%w: operation ID is required", ErrWorkContextInvalid)
@@ -0,0 +1,75 @@
+package codefly
+
+import (
+	"fmt"
+	"net/http"
+)
+
+const operationIDHeaderName = "x-codefly-operation-id"
+
+// AttachExecutionContext installs one execution context on an outgoing HTTP
+// request. Like WithGRPCExecutionContext, it rejects a request that already
+// carries either value rather than overwriting it.
+func AttachExecutionContext(request *http.Request, execution ExecutionContext) error {
+	if request == nil {
+		return workContextFailure(
+			WorkContextReasonInvalidArgument,
+			fmt.Errorf("%w: nil HTTP request", ErrWorkContextInvalid),
+		)
+	}
+	validated, err := NewExecutionContext(execution.workContext, execution.operationID)
+	if err != nil {
+		return err
+	}
+	if request.Header == nil {
+		request.Header = make(http.Header)
+	}
+	if len(request.Header.Values(WorkContextHeaderName)) != 0 {
+		return workContextFailure(
+			WorkContextReasonInvalidArgument,
+			fmt.Errorf("%w: outgoing HTTP Work Context already set", ErrWorkContextInvalid),
+		)
+	}
+	if len(request.Header.Values(operationIDHeaderName)) != 0 {
+		return workContextFailure(
+			WorkContextReasonInvalidArgument,
+			fmt.Errorf("%w: outgoing HTTP operation ID already set", ErrWorkContextInvalid),
+		)
+	}
+	request.Header.Set(WorkContextHeaderName, validated.workContext.encoded)
+	request.Header.Set(operationIDHeaderName, validated.operationID)
+	return nil
+}
+
+// HTTPExecutionContextFromHeaders extracts an opaque execution context from
+// HTTP headers with the same cardinality and shape rules as
+// GRPCExecutionContextFromIncoming. It does not verify Work Context trust.
+func HTTPExecutionContextFromHeaders(headers http.Header) (ExecutionContext, error) {
+	if headers == nil {
+		return ExecutionContext{}, workContextFailure(
+			WorkContextReasonMissing,
+			fmt.Errorf("%w: missing HTTP headers", ErrWorkContextInvalid),
+		)
+	}
+	return executionContextFromCarrierValues(
+		"HTTP",
+		headers.Values(WorkContextHeaderName),
+		headers.Values(operationIDHeaderName),
+	)
+}
+
+// HTTPExecutionContextFromHeadersIfPresent is the optional form of
+// HTTPExecutionContextFromHeaders: no carrier returns present=false, while a
+// partial or duplicate carrier is still an error.
+func HTTPExecutionContextFromHeadersIfPresent(
+	headers http.Header,
+) (execution ExecutionContext, present bool, err error) {
+	if len(headers.Values(WorkContextHeaderName)) == 0 && len(headers.Values(operationIDHeaderName)) == 0 {
+		return ExecutionContext{}, false, nil
+	}
+	execution, err = HTTPExecutionContextFromHeaders(headers)
+	if err != nil {
+		return ExecutionContext{}, false, err
+	}
+	return execution, true, nil
+}
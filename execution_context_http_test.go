@@ -0,0 +1,61 @@
+package codefly
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPExecutionContextRoundTrip(t *testing.T) {
+	execution, err := NewExecutionContext(opaqueTestWorkContext(t), "operation-019f8fc1")
+	require.NoError(t, err)
+	request, err := http.NewRequest(http.MethodPost, "http://warden.test/v1/tasks", nil)
+	require.NoError(t, err)
+	request.Header.Set("Authorization", "Bearer gateway-token")
+
+	require.NoError(t, AttachExecutionContext(request, execution))
+	require.Equal(t, "Bearer gateway-token", request.Header.Get("Authorization"))
+	received, err := HTTPExecutionContextFromHeaders(request.Header)
+	require.NoError(t, err)
+	require.Equal(t, execution.WorkContext().Encoded(), received.WorkContext().Encoded())
+	require.Equal(t, execution.OperationID(), received.OperationID())
+
+	err = AttachExecutionContext(request, execution)
+	require.ErrorContains(t, err, "outgoing HTTP Work Context already set")
+	require.Equal(t, WorkContextReasonInvalidArgument, WorkContextErrorReasonOf(err))
+	err = AttachExecutionContext(nil, execution)
+	require.Equal(t, WorkContextReasonInvalidArgument, WorkContextErrorReasonOf(err))
+	err = AttachExecutionContext(&http.Request{}, ExecutionContext{})
+	require.ErrorIs(t, err, ErrWorkContextInvalid)
+}
+
+func TestHTTPExecutionContextRejectsPartialOrDuplicateCarriers(t *testing.T) {
+	token := opaqueTestWorkContext(t).Encoded()
+
+	_, present, err := HTTPExecutionContextFromHeadersIfPresent(http.Header{"Accept": {"*/*"}})
+	require.NoError(t, err)
+	require.False(t, present)
+	_, present, err = HTTPExecutionContextFromHeadersIfPresent(nil)
+	require.NoError(t, err)
+	require.False(t, present)
+
+	headers := http.Header{}
+	headers.Set(WorkContextHeaderName, token)
+	_, present, err = HTTPExecutionContextFromHeadersIfPresent(headers)
+	require.ErrorContains(t, err, "HTTP operation ID requires exactly one value")
+	require.Equal(t, WorkContextReasonMissing, WorkContextErrorReasonOf(err))
+	require.False(t, present)
+
+	headers.Add(operationIDHeaderName, "operation-1")
+	headers.Add(operationIDHeaderName, "operation-2")
+	_, err = HTTPExecutionContextFromHeaders(headers)
+	require.Equal(t, WorkContextReasonMalformed, WorkContextErrorReasonOf(err))
+
+	headers.Set(operationIDHeaderName, " operation-1")
+	_, err = HTTPExecutionContextFromHeaders(headers)
+	require.ErrorContains(t, err, "operation ID is not canonical")
+
+	_, err = HTTPExecutionContextFromHeaders(nil)
+	require.Equal(t, WorkContextReasonMissing, WorkContextErrorReasonOf(err))
+}
@@ -0,0 +1,269 @@
+package codefly
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	// ExecutionContextPersistedVersion is the version written by the
+	// MarshalExecutionContext functions. Readers reject other versions.
+	ExecutionContextPersistedVersion = 1
+
+	executionContextSealKeyBytes  = 32
+	maxPersistedExecutionContext  = 2 * WorkContextMaxTokenBytes
+	executionContextSealAADPrefix = "codefly.execution-context.sealed.v1\n"
+)
+
+// Field numbers of the protobuf encoding, equivalent to:
+//
+//	message PersistedExecutionContext {
+//	  uint32 version = 1;
+//	  string operation_id = 2;
+//	  string work_context = 3;
+//	  string seal_key_id = 4;
+//	  bytes sealed_work_context = 5;
+//	}
+const (
+	persistedExecutionContextVersionField     protowire.Number = 1
+	persistedExecutionContextOperationIDField protowire.Number = 2
+	persistedExecutionContextWorkContextField protowire.Number = 3
+	persistedExecutionContextSealKeyIDField   protowire.Number = 4
+	persistedExecutionContextSealedField      protowire.Number = 5
+)
+
+// persistedExecutionContext is the stored form shared by both encodings.
+// The operation ID stays readable so job stores can index it; the token is in
+// WorkContext or, sealed, in SealedWorkContext.
+type persistedExecutionContext struct {
+	Version           uint32 `json:"version"`
+	OperationID       string `json:"operation_id"`
+	WorkContext       string `json:"work_context,omitempty"`
+	SealKeyID         string `json:"seal_key_id,omitempty"`
+	SealedWorkContext []byte `json:"sealed_work_context,omitempty"`
+}
+
+// ExecutionContextSealer encrypts the Work Context of persisted execution
+// contexts with local AES-256-GCM keys, so a job record at rest does not hold
+// a usable bearer token.
+type ExecutionContextSealer struct {
+	keyID string
+	aeads map[string]cipher.AEAD
+}
+
+// ExecutionContextSealerOptions configures NewExecutionContextSealer.
+type ExecutionContextSealerOptions struct {
+	// Keys are 32-byte keys by key ID. Every key opens; KeyID seals, so a
+	// rotation adds the new key, switches KeyID, and removes the old key once
+	// records sealed with it are gone.
+	Keys  map[string][]byte
+	KeyID string
+}
+
+func NewExecutionContextSealer(options ExecutionContextSealerOptions) (*ExecutionContextSealer, error) {
+	if _, ok := options.Keys[options.KeyID]; !ok {
+		return nil, fmt.Errorf("%w: seal key %q is not configured", ErrWorkContextInvalid, options.KeyID)
+	}
+	aeads := make(map[string]cipher.AEAD, len(options.Keys))
+	for keyID, key := range options.Keys {
+		if err := validateBounded("seal key_id", keyID, workContextMaxKindBytes, true); err != nil {
+			return nil, err
+		}
+		if len(key) != executionContextSealKeyBytes {
+			return nil, fmt.Errorf("%w: seal key %q must be %d bytes", ErrWorkContextInvalid, keyID, executionContextSealKeyBytes)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		aeads[keyID] = aead
+	}
+	return &ExecutionContextSealer{keyID: options.KeyID, aeads: aeads}, nil
+}
+
+// executionContextSealAAD binds a sealed token to its record, so a token
+// cannot be moved into a record with another operation ID or key ID.
+func executionContextSealAAD(persisted persistedExecutionContext) []byte {
+	return fmt.Appendf(nil, "%s%d\n%s\n%s", executionContextSealAADPrefix, persisted.Version, persisted.SealKeyID, persisted.OperationID)
+}
+
+func (s *ExecutionContextSealer) seal(persisted *persistedExecutionContext) error {
+	aead := s.aeads[s.keyID]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(persisted.WorkContext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("seal Codefly execution context: %w", err)
+	}
+	persisted.SealKeyID = s.keyID
+	persisted.SealedWorkContext = aead.Seal(nonce, nonce, []byte(persisted.WorkContext), executionContextSealAAD(*persisted))
+	persisted.WorkContext = ""
+	return nil
+}
+
+func (s *ExecutionContextSealer) open(persisted *persistedExecutionContext) error {
+	aead, ok := s.aeads[persisted.SealKeyID]
+	if !ok {
+		return fmt.Errorf("%w: unknown seal key %q", ErrWorkContextInvalid, persisted.SealKeyID)
+	}
+	if len(persisted.SealedWorkContext) < aead.NonceSize() {
+		return fmt.Errorf("%w: sealed Work Context is truncated", ErrWorkContextInvalid)
+	}
+	nonce, ciphertext := persisted.SealedWorkContext[:aead.NonceSize()], persisted.SealedWorkContext[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, executionContextSealAAD(*persisted))
+	if err != nil {
+		return fmt.Errorf("%w: sealed Work Context does not open", ErrWorkContextInvalid)
+	}
+	persisted.WorkContext = string(plaintext)
+	return nil
+}
+
+func persistExecutionContext(execution ExecutionContext, sealer *ExecutionContextSealer) (persistedExecutionContext, error) {
+	validated, err := NewExecutionContext(execution.workContext, execution.operationID)
+	if err != nil {
+		return persistedExecutionContext{}, err
+	}
+	persisted := persistedExecutionContext{
+		Version:     ExecutionContextPersistedVersion,
+		OperationID: validated.operationID,
+		WorkContext: validated.workContext.encoded,
+	}
+	if sealer != nil {
+		if err := sealer.seal(&persisted); err != nil {
+			return persistedExecutionContext{}, err
+		}
+	}
+	return persisted, nil
+}
+
+// restore validates a decoded record. A sealed record needs the sealer; a
+// clear one is accepted with or without it, so sealing can be turned on
+// while clear records are still queued.
+func (persisted persistedExecutionContext) restore(sealer *ExecutionContextSealer) (ExecutionContext, error) {
+	if persisted.Version != ExecutionContextPersistedVersion {
+		return ExecutionContext{}, fmt.Errorf("%w: unsupported persisted execution context version %d", ErrWorkContextInvalid, persisted.Version)
+	}
+	sealed := persisted.SealKeyID != "" || len(persisted.SealedWorkContext) != 0
+	switch {
+	case sealed && persisted.WorkContext != "":
+		return ExecutionContext{}, fmt.Errorf("%w: persisted execution context is both sealed and clear", ErrWorkContextInvalid)
+	case sealed && sealer == nil:
+		return ExecutionContext{}, fmt.Errorf("%w: persisted execution context is sealed", ErrWorkContextInvalid)
+	case sealed:
+		if err := sealer.open(&persisted); err != nil {
+			return ExecutionContext{}, err
+		}
+	}
+	token, err := ParseWorkContextToken(persisted.WorkContext)
+	if err != nil {
+		return ExecutionContext{}, err
+	}
+	return NewExecutionContext(token, persisted.OperationID)
+}
+
+// MarshalExecutionContextJSON encodes an execution context for a job record.
+// With a nil sealer the token is stored in clear, like
+// WorkContextToken.Encoded.
+func MarshalExecutionContextJSON(execution ExecutionContext, sealer *ExecutionContextSealer) ([]byte, error) {
+	persisted, err := persistExecutionContext(execution, sealer)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(persisted)
+}
+
+// UnmarshalExecutionContextJSON decodes MarshalExecutionContextJSON output.
+// It validates the shape only; ResumeExecutionContext establishes trust.
+func UnmarshalExecutionContextJSON(data []byte, sealer *ExecutionContextSealer) (ExecutionContext, error) {
+	if len(data) > maxPersistedExecutionContext {
+		return ExecutionContext{}, fmt.Errorf("%w: persisted execution context exceeds %d bytes", ErrWorkContextInvalid, maxPersistedExecutionContext)
+	}
+	var persisted persistedExecutionContext
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&persisted); err != nil {
+		return ExecutionContext{}, fmt.Errorf("%w: decode persisted execution context: %v", ErrWorkContextInvalid, err)
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return ExecutionContext{}, fmt.Errorf("%w: trailing data after persisted execution context", ErrWorkContextInvalid)
+	}
+	return persisted.restore(sealer)
+}
+
+// MarshalExecutionContextProto encodes an execution context as the
+// PersistedExecutionContext protobuf message, for binary job records.
+func MarshalExecutionContextProto(execution ExecutionContext, sealer *ExecutionContextSealer) ([]byte, error) {
+	persisted, err := persistExecutionContext(execution, sealer)
+	if err != nil {
+		return nil, err
+	}
+	encoded := protowire.AppendTag(nil, persistedExecutionContextVersionField, protowire.VarintType)
+	encoded = protowire.AppendVarint(encoded, uint64(persisted.Version))
+	encoded = protowire.AppendTag(encoded, persistedExecutionContextOperationIDField, protowire.BytesType)
+	encoded = protowire.AppendString(encoded, persisted.OperationID)
+	if persisted.WorkContext != "" {
+		encoded = protowire.AppendTag(encoded, persistedExecutionContextWorkContextField, protowire.BytesType)
+		encoded = protowire.AppendString(encoded, persisted.WorkContext)
+	}
+	if persisted.SealKeyID != "" {
+		encoded = protowire.AppendTag(encoded, persistedExecutionContextSealKeyIDField, protowire.BytesType)
+		encoded = protowire.AppendString(encoded, persisted.SealKeyID)
+		encoded = protowire.AppendTag(encoded, persistedExecutionContextSealedField, protowire.BytesType)
+		encoded = protowire.AppendBytes(encoded, persisted.SealedWorkContext)
+	}
+	return encoded, nil
+}
+
+// UnmarshalExecutionContextProto decodes MarshalExecutionContextProto
+// output. Unknown or repeated fields are rejected rather than skipped.
+func UnmarshalExecutionContextProto(data []byte, sealer *ExecutionContextSealer) (ExecutionContext, error) {
+	if len(data) > maxPersistedExecutionContext {
+		return ExecutionContext{}, fmt.Errorf("%w: persisted execution context exceeds %d bytes", ErrWorkContextInvalid, maxPersistedExecutionContext)
+	}
+	var persisted persistedExecutionContext
+	seen := make(map[protowire.Number]bool)
+	for len(data) > 0 {
+		number, wireType, length := protowire.ConsumeTag(data)
+		if length < 0 {
+			return ExecutionContext{}, fmt.Errorf("%w: decode persisted execution context: %v", ErrWorkContextInvalid, protowire.ParseError(length))
+		}
+		data = data[length:]
+		if seen[number] {
+			return ExecutionContext{}, fmt.Errorf("%w: persisted execution context repeats field %d", ErrWorkContextInvalid, number)
+		}
+		seen[number] = true
+		switch {
+		case number == persistedExecutionContextVersionField && wireType == protowire.VarintType:
+			var version uint64
+			version, length = protowire.ConsumeVarint(data)
+			persisted.Version = uint32(min(version, 1<<32-1))
+		case number == persistedExecutionContextOperationIDField && wireType == protowire.BytesType:
+			persisted.OperationID, length = protowire.ConsumeString(data)
+		case number == persistedExecutionContextWorkContextField && wireType == protowire.BytesType:
+			persisted.WorkContext, length = protowire.ConsumeString(data)
+		case number == persistedExecutionContextSealKeyIDField && wireType == protowire.BytesType:
+			persisted.SealKeyID, length = protowire.ConsumeString(data)
+		case number == persistedExecutionContextSealedField && wireType == protowire.BytesType:
+			var sealed []byte
+			sealed, length = protowire.ConsumeBytes(data)
+			persisted.SealedWorkContext = bytes.Clone(sealed)
+		default:
+			return ExecutionContext{}, fmt.Errorf("%w: persisted execution context has unknown field %d", ErrWorkContextInvalid, number)
+		}
+		if length < 0 {
+			return ExecutionContext{}, fmt.Errorf("%w: decode persisted execution context: %v", ErrWorkContextInvalid, protowire.ParseError(length))
+		}
+		data = data[length:]
+	}
+	return persisted.restore(sealer)
+}
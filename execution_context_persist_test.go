@@ -0,0 +1,127 @@
+package codefly
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func executionContextTestSealer(t *testing.T, keyID string, keys ...string) *ExecutionContextSealer {
+	t.Helper()
+	options := ExecutionContextSealerOptions{Keys: map[string][]byte{}, KeyID: keyID}
+	for _, id := range append(keys, keyID) {
+		options.Keys[id] = bytes.Repeat([]byte(id[len(id)-1:]), executionContextSealKeyBytes)
+	}
+	sealer, err := NewExecutionContextSealer(options)
+	require.NoError(t, err)
+	return sealer
+}
+
+func TestPersistedExecutionContextRoundTrips(t *testing.T) {
+	execution, err := NewExecutionContext(opaqueTestWorkContext(t), "operation-019f8fc1")
+	require.NoError(t, err)
+	sealer := executionContextTestSealer(t, "seal-1")
+
+	for _, test := range []struct {
+		name      string
+		marshal   func(ExecutionContext, *ExecutionContextSealer) ([]byte, error)
+		unmarshal func([]byte, *ExecutionContextSealer) (ExecutionContext, error)
+	}{
+		{"json", MarshalExecutionContextJSON, UnmarshalExecutionContextJSON},
+		{"proto", MarshalExecutionContextProto, UnmarshalExecutionContextProto},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			clear, err := test.marshal(execution, nil)
+			require.NoError(t, err)
+			require.Contains(t, string(clear), execution.WorkContext().Encoded())
+			restored, err := test.unmarshal(clear, sealer)
+			require.NoError(t, err, "clear records are still accepted once sealing is on")
+			require.Equal(t, execution, restored)
+
+			sealed, err := test.marshal(execution, sealer)
+			require.NoError(t, err)
+			require.NotContains(t, string(sealed), execution.WorkContext().Encoded())
+			require.Contains(t, string(sealed), "operation-019f8fc1", "the operation ID stays indexable")
+			restored, err = test.unmarshal(sealed, sealer)
+			require.NoError(t, err)
+			require.Equal(t, execution, restored)
+
+			_, err = test.unmarshal(sealed, nil)
+			require.ErrorContains(t, err, "is sealed")
+			_, err = test.unmarshal(sealed, executionContextTestSealer(t, "seal-2"))
+			require.ErrorContains(t, err, `unknown seal key "seal-1"`)
+			rotated := executionContextTestSealer(t, "seal-2", "seal-1")
+			restored, err = test.unmarshal(sealed, rotated)
+			require.NoError(t, err, "old keys still open after a rotation")
+			require.Equal(t, execution, restored)
+		})
+	}
+}
+
+func TestPersistedExecutionContextJSONRejectsTampering(t *testing.T) {
+	execution, err := NewExecutionContext(opaqueTestWorkContext(t), "operation-1")
+	require.NoError(t, err)
+	sealer := executionContextTestSealer(t, "seal-1")
+	sealed, err := MarshalExecutionContextJSON(execution, sealer)
+	require.NoError(t, err)
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(sealed, &record))
+	record["operation_id"] = "operation-2"
+	moved, err := json.Marshal(record)
+	require.NoError(t, err)
+	_, err = UnmarshalExecutionContextJSON(moved, sealer)
+	require.ErrorContains(t, err, "does not open", "a sealed token is bound to its operation ID")
+
+	for _, data := range []string{
+		`{"version":2,"operation_id":"operation-1","work_context":"` + execution.WorkContext().Encoded() + `"}`,
+		`{"version":1,"operation_id":"operation-1","work_context":"` + execution.WorkContext().Encoded() + `","extra":true}`,
+		`{"version":1,"operation_id":"operation-1","work_context":"` + execution.WorkContext().Encoded() + `"} {}`,
+		`{"version":1,"operation_id":"operation-1","work_context":"x","seal_key_id":"seal-1","sealed_work_context":"AAAA"}`,
+		`{"version":1,"operation_id":"operation 1","work_context":"` + execution.WorkContext().Encoded() + `"}`,
+		`{"version":1,"operation_id":"operation-1"}`,
+		strings.Repeat(" ", maxPersistedExecutionContext+1),
+	} {
+		_, err := UnmarshalExecutionContextJSON([]byte(data), sealer)
+		require.ErrorIs(t, err, ErrWorkContextInvalid, data[:min(len(data), 80)])
+	}
+}
+
+func TestPersistedExecutionContextProtoRejectsUnknownOrRepeatedFields(t *testing.T) {
+	execution, err := NewExecutionContext(opaqueTestWorkContext(t), "operation-1")
+	require.NoError(t, err)
+	encoded, err := MarshalExecutionContextProto(execution, nil)
+	require.NoError(t, err)
+
+	unknown := protowire.AppendTag(bytes.Clone(encoded), 9, protowire.VarintType)
+	unknown = protowire.AppendVarint(unknown, 1)
+	_, err = UnmarshalExecutionContextProto(unknown, nil)
+	require.ErrorContains(t, err, "unknown field 9")
+
+	repeated := protowire.AppendTag(bytes.Clone(encoded), persistedExecutionContextOperationIDField, protowire.BytesType)
+	repeated = protowire.AppendString(repeated, "operation-2")
+	_, err = UnmarshalExecutionContextProto(repeated, nil)
+	require.ErrorContains(t, err, "repeats field 2")
+
+	_, err = UnmarshalExecutionContextProto(encoded[:len(encoded)-1], nil)
+	require.ErrorIs(t, err, ErrWorkContextInvalid)
+	_, err = UnmarshalExecutionContextProto(nil, nil)
+	require.ErrorContains(t, err, "unsupported persisted execution context version 0")
+}
+
+func TestNewExecutionContextSealerValidatesKeys(t *testing.T) {
+	_, err := NewExecutionContextSealer(ExecutionContextSealerOptions{KeyID: "seal-1"})
+	require.ErrorContains(t, err, `seal key "seal-1" is not configured`)
+	_, err = NewExecutionContextSealer(ExecutionContextSealerOptions{
+		Keys: map[string][]byte{"seal-1": make([]byte, 16)}, KeyID: "seal-1",
+	})
+	require.ErrorContains(t, err, "must be 32 bytes")
+	_, err = NewExecutionContextSealer(ExecutionContextSealerOptions{
+		Keys: map[string][]byte{"": make([]byte, 32)}, KeyID: "",
+	})
+	require.ErrorContains(t, err, "seal key_id is required")
+}
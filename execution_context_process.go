@@ -0,0 +1,207 @@
+package codefly
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// The environment names where the execution context is, never what it
+	// is: /proc/<pid>/environ is readable far more widely than a 0600 file.
+	executionContextFDEnvironmentName   = "CODEFLY_EXECUTION_CONTEXT_FD"
+	executionContextFileEnvironmentName = "CODEFLY_EXECUTION_CONTEXT_FILE"
+	executionContextFileDescription     = "Codefly execution context file"
+)
+
+// ExecutionContextHandoff selects how AttachExecutionContextToCommand passes
+// an execution context to a child process.
+type ExecutionContextHandoff int
+
+const (
+	// ExecutionContextHandoffDescriptor passes an inherited descriptor to a
+	// temporary file that is already unlinked, so the context never has a
+	// path another process could open. It is not available on Windows.
+	ExecutionContextHandoffDescriptor ExecutionContextHandoff = iota
+	// ExecutionContextHandoffFile passes the path of a 0600 temporary file,
+	// for children started through wrappers that close inherited
+	// descriptors.
+	ExecutionContextHandoffFile
+)
+
+// AttachExecutionContextToCommand arranges for cmd's child to receive
+// execution through ExecutionContextFromProcess. It must be called before
+// cmd.Start, and release must be called once the child has exited or failed
+// to start. Any execution context this process itself received is not passed
+// on.
+func AttachExecutionContextToCommand(
+	cmd *exec.Cmd,
+	execution ExecutionContext,
+	handoff ExecutionContextHandoff,
+) (release func() error, err error) {
+	if cmd == nil {
+		return nil, workContextFailure(
+			WorkContextReasonInvalidArgument,
+			fmt.Errorf("%w: nil command", ErrWorkContextInvalid),
+		)
+	}
+	if cmd.Process != nil {
+		return nil, errors.New("Codefly execution context must be attached before the command starts")
+	}
+	if handoff == ExecutionContextHandoffDescriptor && runtime.GOOS == "windows" {
+		return nil, errors.New("Codefly execution context descriptor handoff is not supported on Windows")
+	}
+	if handoff != ExecutionContextHandoffDescriptor && handoff != ExecutionContextHandoffFile {
+		return nil, fmt.Errorf("unknown Codefly execution context handoff %d", handoff)
+	}
+	payload, err := MarshalExecutionContextJSON(execution, nil)
+	if err != nil {
+		return nil, err
+	}
+	// CreateTemp creates the file with mode 0600.
+	file, err := os.CreateTemp("", "codefly-execution-context-*")
+	if err != nil {
+		return nil, fmt.Errorf("create %s: %w", executionContextFileDescription, err)
+	}
+	removeFile := func() error {
+		if err := os.Remove(file.Name()); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	if _, err := file.Write(payload); err != nil {
+		_ = file.Close()
+		_ = removeFile()
+		return nil, fmt.Errorf("write %s: %w", executionContextFileDescription, err)
+	}
+
+	environment := cmd.Env
+	if environment == nil {
+		environment = os.Environ()
+	}
+	environment = withoutExecutionContextEnvironment(environment)
+	if handoff == ExecutionContextHandoffFile {
+		if err := file.Close(); err != nil {
+			_ = removeFile()
+			return nil, fmt.Errorf("write %s: %w", executionContextFileDescription, err)
+		}
+		cmd.Env = append(environment, executionContextFileEnvironmentName+"="+file.Name())
+		return removeFile, nil
+	}
+	// The child only consumes the context, so it inherits a read-only
+	// descriptor rather than the one the payload was written through.
+	if err := file.Close(); err != nil {
+		_ = removeFile()
+		return nil, fmt.Errorf("write %s: %w", executionContextFileDescription, err)
+	}
+	readOnly, err := os.Open(file.Name())
+	if err != nil {
+		_ = removeFile()
+		return nil, fmt.Errorf("reopen %s: %w", executionContextFileDescription, err)
+	}
+	if err := removeFile(); err != nil {
+		_ = readOnly.Close()
+		return nil, fmt.Errorf("unlink %s: %w", executionContextFileDescription, err)
+	}
+	// Descriptors 0-2 are stdio; ExtraFiles[i] becomes descriptor 3+i.
+	descriptor := 3 + len(cmd.ExtraFiles)
+	cmd.ExtraFiles = append(cmd.ExtraFiles, readOnly)
+	cmd.Env = append(environment, executionContextFDEnvironmentName+"="+strconv.Itoa(descriptor))
+	return readOnly.Close, nil
+}
+
+func withoutExecutionContextEnvironment(environment []string) []string {
+	filtered := make([]string, 0, len(environment)+1)
+	for _, variable := range environment {
+		name, _, _ := strings.Cut(variable, "=")
+		if name == executionContextFDEnvironmentName || name == executionContextFileEnvironmentName {
+			continue
+		}
+		filtered = append(filtered, variable)
+	}
+	return filtered
+}
+
+var processExecutionContext struct {
+	once      sync.Once
+	execution ExecutionContext
+	err       error
+}
+
+// ExecutionContextFromProcess returns the execution context a parent passed
+// with AttachExecutionContextToCommand, validated like
+// GRPCExecutionContextFromIncoming. It does not verify Work Context trust.
+//
+// The first call consumes the handoff: the descriptor is closed, the file
+// removed, and the environment pointer unset, so grandchildren do not inherit
+// it. Later calls return the same result.
+func ExecutionContextFromProcess() (ExecutionContext, error) {
+	processExecutionContext.once.Do(func() {
+		processExecutionContext.execution, processExecutionContext.err = consumeProcessExecutionContext()
+	})
+	return processExecutionContext.execution, processExecutionContext.err
+}
+
+func consumeProcessExecutionContext() (ExecutionContext, error) {
+	descriptor, fromDescriptor := os.LookupEnv(executionContextFDEnvironmentName)
+	path, fromFile := os.LookupEnv(executionContextFileEnvironmentName)
+	_ = os.Unsetenv(executionContextFDEnvironmentName)
+	_ = os.Unsetenv(executionContextFileEnvironmentName)
+	switch {
+	case fromDescriptor && fromFile:
+		return ExecutionContext{}, workContextFailure(
+			WorkContextReasonMalformed,
+			fmt.Errorf("%w: process received more than one execution context", ErrWorkContextInvalid),
+		)
+	case fromDescriptor:
+		payload, err := readExecutionContextDescriptor(descriptor)
+		if err != nil {
+			return ExecutionContext{}, workContextFailure(WorkContextReasonMalformed, err)
+		}
+		return UnmarshalExecutionContextJSON(payload, nil)
+	case fromFile:
+		payload, _, err := readSafeFile(path, executionContextFileDescription, maxPersistedExecutionContext, 0o077)
+		if err != nil {
+			return ExecutionContext{}, workContextFailure(WorkContextReasonMalformed, err)
+		}
+		// The parent removes it too; removing it now only shortens its life.
+		_ = os.Remove(path)
+		return UnmarshalExecutionContextJSON(payload, nil)
+	default:
+		return ExecutionContext{}, workContextFailure(
+			WorkContextReasonMissing,
+			fmt.Errorf("%w: process received no execution context", ErrWorkContextInvalid),
+		)
+	}
+}
+
+// readExecutionContextDescriptor reads and closes the inherited descriptor
+// named by value. inheritedExecutionContextFile vets it first: a stale value
+// may name a descriptor this process uses for something else.
+func readExecutionContextDescriptor(value string) ([]byte, error) {
+	descriptor, err := strconv.Atoi(value)
+	if err != nil || descriptor < 3 || strconv.Itoa(descriptor) != value {
+		return nil, fmt.Errorf("%w: invalid execution context descriptor %q", ErrWorkContextInvalid, value)
+	}
+	file, err := inheritedExecutionContextFile(descriptor)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	// The parent's descriptor shares the file offset, so read from the start
+	// explicitly.
+	payload, err := io.ReadAll(io.NewSectionReader(file, 0, maxPersistedExecutionContext+1))
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", executionContextFileDescription, err)
+	}
+	if len(payload) > maxPersistedExecutionContext {
+		return nil, fmt.Errorf("%s exceeds %d bytes", executionContextFileDescription, maxPersistedExecutionContext)
+	}
+	return payload, nil
+}
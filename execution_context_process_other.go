@@ -0,0 +1,12 @@
+//go:build !unix
+
+package codefly
+
+import (
+	"errors"
+	"os"
+)
+
+func inheritedExecutionContextFile(int) (*os.File, error) {
+	return nil, errors.New("Codefly execution context descriptor handoff is not supported on this platform")
+}
@@ -0,0 +1,164 @@
+package codefly
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const executionContextChildEnvironmentName = "CODEFLY_TEST_EXECUTION_CONTEXT_CHILD"
+
+// TestExecutionContextProcessChild runs only as the child of
+// TestExecutionContextReachesChildProcess.
+func TestExecutionContextProcessChild(t *testing.T) {
+	if os.Getenv(executionContextChildEnvironmentName) != "1" {
+		t.Skip("runs as a child process only")
+	}
+	execution, err := ExecutionContextFromProcess()
+	if err != nil {
+		fmt.Printf("error=%v\n", err)
+		os.Exit(0)
+	}
+	again, err := ExecutionContextFromProcess()
+	fmt.Printf("operation=%s token=%s again=%t\n", execution.OperationID(), execution.WorkContext().Encoded(),
+		err == nil && again == execution)
+	fmt.Printf("environment=%t\n", os.Getenv(executionContextFDEnvironmentName)+os.Getenv(executionContextFileEnvironmentName) != "")
+	os.Exit(0)
+}
+
+func TestExecutionContextReachesChildProcess(t *testing.T) {
+	execution, err := NewExecutionContext(opaqueTestWorkContext(t), "operation-019f8fc1")
+	require.NoError(t, err)
+	handoffs := []ExecutionContextHandoff{ExecutionContextHandoffFile}
+	if runtime.GOOS != "windows" {
+		handoffs = append(handoffs, ExecutionContextHandoffDescriptor)
+	}
+	for _, handoff := range handoffs {
+		t.Run(strconv.Itoa(int(handoff)), func(t *testing.T) {
+			t.Setenv(executionContextFDEnvironmentName, "9")
+			cmd := exec.Command(os.Args[0], "-test.run=^TestExecutionContextProcessChild$")
+			cmd.Env = append(os.Environ(), executionContextChildEnvironmentName+"=1")
+			release, err := AttachExecutionContextToCommand(cmd, execution, handoff)
+			require.NoError(t, err)
+			for _, variable := range cmd.Env {
+				require.NotContains(t, variable, execution.WorkContext().Encoded(), "the token never enters the environment")
+				require.NotEqual(t, executionContextFDEnvironmentName+"=9", variable, "an inherited pointer is not passed on")
+			}
+			var path string
+			for _, variable := range cmd.Env {
+				if value, ok := strings.CutPrefix(variable, executionContextFileEnvironmentName+"="); ok {
+					path = value
+					info, err := os.Stat(path)
+					require.NoError(t, err)
+					require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+				}
+			}
+
+			output, err := cmd.Output()
+			require.NoError(t, release())
+			require.NoError(t, err)
+			require.Contains(t, string(output), fmt.Sprintf("operation=operation-019f8fc1 token=%s again=true\n", execution.WorkContext().Encoded()))
+			require.Contains(t, string(output), "environment=false")
+			if path != "" {
+				_, err := os.Stat(path)
+				require.ErrorIs(t, err, os.ErrNotExist)
+			}
+		})
+	}
+}
+
+func TestAttachExecutionContextToCommandValidatesArguments(t *testing.T) {
+	execution, err := NewExecutionContext(opaqueTestWorkContext(t), "operation-1")
+	require.NoError(t, err)
+	_, err = AttachExecutionContextToCommand(nil, execution, ExecutionContextHandoffFile)
+	require.Equal(t, WorkContextReasonInvalidArgument, WorkContextErrorReasonOf(err))
+	_, err = AttachExecutionContextToCommand(exec.Command("true"), ExecutionContext{}, ExecutionContextHandoffFile)
+	require.ErrorIs(t, err, ErrWorkContextInvalid)
+	_, err = AttachExecutionContextToCommand(exec.Command("true"), execution, ExecutionContextHandoff(7))
+	require.ErrorContains(t, err, "unknown Codefly execution context handoff 7")
+}
+
+func TestAttachExecutionContextToCommandPassesReadOnlyDescriptor(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("descriptor handoff is not supported on Windows")
+	}
+	execution, err := NewExecutionContext(opaqueTestWorkContext(t), "operation-1")
+	require.NoError(t, err)
+	cmd := exec.Command("true")
+	release, err := AttachExecutionContextToCommand(cmd, execution, ExecutionContextHandoffDescriptor)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, release()) }()
+	require.Len(t, cmd.ExtraFiles, 1)
+	_, err = cmd.ExtraFiles[0].Write([]byte("{}"))
+	require.Error(t, err, "the child must not be able to rewrite its context")
+}
+
+func TestConsumeProcessExecutionContextRejectsUnsafeHandoffs(t *testing.T) {
+	t.Setenv(executionContextFDEnvironmentName, "")
+	t.Setenv(executionContextFileEnvironmentName, "")
+	require.NoError(t, os.Unsetenv(executionContextFDEnvironmentName))
+	require.NoError(t, os.Unsetenv(executionContextFileEnvironmentName))
+	_, err := consumeProcessExecutionContext()
+	require.Equal(t, WorkContextReasonMissing, WorkContextErrorReasonOf(err))
+
+	execution, err := NewExecutionContext(opaqueTestWorkContext(t), "operation-1")
+	require.NoError(t, err)
+	payload, err := MarshalExecutionContextJSON(execution, nil)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "execution-context.json")
+	require.NoError(t, os.WriteFile(path, payload, 0o644))
+	t.Setenv(executionContextFileEnvironmentName, path)
+	_, err = consumeProcessExecutionContext()
+	require.ErrorContains(t, err, "must not be accessible by group or world")
+	_, set := os.LookupEnv(executionContextFileEnvironmentName)
+	require.False(t, set, "the pointer is consumed even when it is rejected")
+
+	require.NoError(t, os.Chmod(path, 0o600))
+	t.Setenv(executionContextFileEnvironmentName, path)
+	received, err := consumeProcessExecutionContext()
+	require.NoError(t, err)
+	require.Equal(t, execution, received)
+
+	t.Setenv(executionContextFileEnvironmentName, path)
+	t.Setenv(executionContextFDEnvironmentName, "3")
+	_, err = consumeProcessExecutionContext()
+	require.ErrorContains(t, err, "more than one execution context")
+
+	for _, value := range []string{"abc", "1", "03", "-4"} {
+		t.Setenv(executionContextFDEnvironmentName, value)
+		_, err = consumeProcessExecutionContext()
+		require.ErrorContains(t, err, "invalid execution context descriptor", value)
+	}
+}
+
+func TestConsumeProcessExecutionContextLeavesForeignDescriptorsOpen(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("descriptor handoff is not supported on Windows")
+	}
+	reader, writer, err := os.Pipe()
+	require.NoError(t, err)
+	defer reader.Close()
+	defer writer.Close()
+	t.Setenv(executionContextFDEnvironmentName, strconv.Itoa(int(reader.Fd())))
+	_, err = consumeProcessExecutionContext()
+	require.ErrorContains(t, err, "must be a regular file")
+
+	linked, err := os.CreateTemp(t.TempDir(), "linked-*")
+	require.NoError(t, err)
+	defer linked.Close()
+	t.Setenv(executionContextFDEnvironmentName, strconv.Itoa(int(linked.Fd())))
+	_, err = consumeProcessExecutionContext()
+	require.ErrorContains(t, err, "must refer to an unlinked file")
+
+	_, err = writer.Write([]byte("still open"))
+	require.NoError(t, err)
+	_, err = linked.Write([]byte("still open"))
+	require.NoError(t, err)
+}
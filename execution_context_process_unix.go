@@ -0,0 +1,28 @@
+//go:build unix
+
+package codefly
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// inheritedExecutionContextFile adopts descriptor only if it is what
+// AttachExecutionContextToCommand passes: an unlinked regular file that
+// grants nothing to group or world. Anything else is left open and untouched.
+func inheritedExecutionContextFile(descriptor int) (*os.File, error) {
+	var info syscall.Stat_t
+	if err := syscall.Fstat(descriptor, &info); err != nil {
+		return nil, fmt.Errorf("stat %s: %w", executionContextFileDescription, err)
+	}
+	switch {
+	case info.Mode&syscall.S_IFMT != syscall.S_IFREG:
+		return nil, fmt.Errorf("%s must be a regular file", executionContextFileDescription)
+	case info.Nlink != 0:
+		return nil, fmt.Errorf("%s descriptor must refer to an unlinked file", executionContextFileDescription)
+	case info.Mode&0o077 != 0:
+		return nil, fmt.Errorf("%s must not be accessible by group or world", executionContextFileDescription)
+	}
+	return os.NewFile(uintptr(descriptor), executionContextFileDescription), nil
+}
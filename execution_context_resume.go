@@ -0,0 +1,112 @@
+package codefly
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	basev0 "github.com/codefly-dev/core/generated/go/codefly/base/v0"
+)
+
+// WorkContextTokenSource obtains a fresh Work Context for one that expired or
+// is about to, typically by exchanging it at the authority.
+type WorkContextTokenSource interface {
+	RenewWorkContext(ctx context.Context, current WorkContextToken) (WorkContextToken, error)
+}
+
+// WorkContextTokenSourceFunc adapts a function to WorkContextTokenSource.
+type WorkContextTokenSourceFunc func(ctx context.Context, current WorkContextToken) (WorkContextToken, error)
+
+func (f WorkContextTokenSourceFunc) RenewWorkContext(ctx context.Context, current WorkContextToken) (WorkContextToken, error) {
+	return f(ctx, current)
+}
+
+// ResumeExecutionContextOptions configures ResumeExecutionContext.
+type ResumeExecutionContextOptions struct {
+	// Verify establishes trust in a token, typically a
+	// WorkContextJWKSVerifier's Verify with the expectations of the job. It
+	// must check the signature before it reports WorkContextReasonExpired,
+	// as the verifiers in this package do: the claims of an expired token
+	// anchor the comparison with its renewal.
+	Verify func(ctx context.Context, token WorkContextToken) (*basev0.WorkContextV1, error)
+	// TokenSource renews a token that expired while the job waited. Without
+	// one, an expired token fails the resume.
+	TokenSource WorkContextTokenSource
+}
+
+// ResumeExecutionContext re-establishes trust in a persisted execution
+// context before a background job acts on it. The token is verified again;
+// an expired one is renewed through the token source and the renewal must
+// continue the same tenant, owner, and task. The operation ID is kept, so the
+// resumed job stays idempotent with its first attempt.
+func ResumeExecutionContext(
+	ctx context.Context,
+	execution ExecutionContext,
+	options ResumeExecutionContextOptions,
+) (ExecutionContext, *basev0.WorkContextV1, error) {
+	if ctx == nil || options.Verify == nil {
+		return ExecutionContext{}, nil, workContextFailure(
+			WorkContextReasonInvalidArgument,
+			fmt.Errorf("%w: resume requires a context and a verify function", ErrWorkContextInvalid),
+		)
+	}
+	validated, err := NewExecutionContext(execution.workContext, execution.operationID)
+	if err != nil {
+		return ExecutionContext{}, nil, err
+	}
+	claims, err := options.Verify(ctx, validated.workContext)
+	if err == nil {
+		return validated, claims, nil
+	}
+	if WorkContextErrorReasonOf(err) != WorkContextReasonExpired || options.TokenSource == nil {
+		return ExecutionContext{}, nil, err
+	}
+	// Verify reports an expired reason only after the signature verified, as
+	// its contract requires, so these claims are authentic even though they
+	// are no longer valid.
+	expired, err := authenticatedWorkContextClaims(validated.workContext)
+	if err != nil {
+		return ExecutionContext{}, nil, workContextFailure(WorkContextReasonMalformed, err)
+	}
+	renewed, err := options.TokenSource.RenewWorkContext(ctx, validated.workContext)
+	if err != nil {
+		return ExecutionContext{}, nil, workContextFailure(
+			WorkContextReasonExpired,
+			fmt.Errorf("%w: renew expired Work Context: %w", ErrWorkContextInvalid, err),
+		)
+	}
+	claims, err = options.Verify(ctx, renewed)
+	if err != nil {
+		return ExecutionContext{}, nil, err
+	}
+	if claims.GetTenantId() != expired.GetTenantId() ||
+		claims.GetOwnerPrincipalId() != expired.GetOwnerPrincipalId() ||
+		claims.GetTaskId() != expired.GetTaskId() {
+		return ExecutionContext{}, nil, workContextFailure(
+			WorkContextReasonClaimsMismatch,
+			fmt.Errorf("%w: renewed Work Context belongs to another task", ErrWorkContextInvalid),
+		)
+	}
+	resumed, err := NewExecutionContext(renewed, validated.operationID)
+	if err != nil {
+		return ExecutionContext{}, nil, err
+	}
+	return resumed, claims, nil
+}
+
+// authenticatedWorkContextClaims decodes the claims of a token whose signature
+// a verifier has already checked.
+func authenticatedWorkContextClaims(token WorkContextToken) (*basev0.WorkContextV1, error) {
+	payload, _, err := decodeWorkContextToken(token.encoded)
+	if err != nil {
+		return nil, err
+	}
+	claims, _, err := unmarshalWorkContext(payload)
+	if err != nil {
+		return nil, err
+	}
+	if claims.GetTaskId() == "" {
+		return nil, errors.New("Codefly Work Context has no task")
+	}
+	return claims, nil
+}
@@ -0,0 +1,105 @@
+package codefly
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	basev0 "github.com/codefly-dev/core/generated/go/codefly/base/v0"
+	"github.com/stretchr/testify/require"
+)
+
+func resumeTestVerify(verifier *WorkContextVerifier) func(context.Context, WorkContextToken) (*basev0.WorkContextV1, error) {
+	return func(_ context.Context, token WorkContextToken) (*basev0.WorkContextV1, error) {
+		return verifier.Verify(token, WorkContextExpectations{})
+	}
+}
+
+func TestResumeExecutionContextVerifiesPersistedToken(t *testing.T) {
+	token, _, err := workContextTestSigner(t, workContextTestTime).StartTask(workContextTestInput())
+	require.NoError(t, err)
+	execution, err := NewExecutionContext(token, "operation-1")
+	require.NoError(t, err)
+	persisted, err := MarshalExecutionContextJSON(execution, nil)
+	require.NoError(t, err)
+	restored, err := UnmarshalExecutionContextJSON(persisted, nil)
+	require.NoError(t, err)
+
+	resumed, claims, err := ResumeExecutionContext(context.Background(), restored, ResumeExecutionContextOptions{
+		Verify: resumeTestVerify(workContextTestVerifier(t, workContextTestTime.Add(time.Minute))),
+	})
+	require.NoError(t, err)
+	require.Equal(t, execution, resumed)
+	require.Equal(t, "task-roadmap", claims.GetTaskId())
+
+	_, _, err = ResumeExecutionContext(context.Background(), restored, ResumeExecutionContextOptions{})
+	require.Equal(t, WorkContextReasonInvalidArgument, WorkContextErrorReasonOf(err))
+}
+
+func TestResumeExecutionContextRenewsExpiredToken(t *testing.T) {
+	token, _, err := workContextTestSigner(t, workContextTestTime).StartTask(workContextTestInput())
+	require.NoError(t, err)
+	execution, err := NewExecutionContext(token, "operation-1")
+	require.NoError(t, err)
+	later := workContextTestTime.Add(time.Hour)
+	verify := resumeTestVerify(workContextTestVerifier(t, later))
+
+	_, _, err = ResumeExecutionContext(context.Background(), execution, ResumeExecutionContextOptions{Verify: verify})
+	require.Equal(t, WorkContextReasonExpired, WorkContextErrorReasonOf(err), "an expired token is never used silently")
+
+	renewals := 0
+	source := WorkContextTokenSourceFunc(func(_ context.Context, current WorkContextToken) (WorkContextToken, error) {
+		renewals++
+		require.Equal(t, token, current)
+		renewed, _, err := workContextTestSigner(t, later).StartTask(workContextTestInput())
+		return renewed, err
+	})
+	resumed, claims, err := ResumeExecutionContext(context.Background(), execution, ResumeExecutionContextOptions{
+		Verify: verify, TokenSource: source,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, renewals)
+	require.Equal(t, "operation-1", resumed.OperationID(), "the operation ID survives renewal")
+	require.NotEqual(t, token, resumed.WorkContext())
+	require.Equal(t, later.Unix(), claims.GetIssuedAtUnix())
+
+	otherTask := WorkContextTokenSourceFunc(func(context.Context, WorkContextToken) (WorkContextToken, error) {
+		input := workContextTestInput()
+		input.TaskID = "task-other"
+		renewed, _, err := workContextTestSigner(t, later).StartTask(input)
+		return renewed, err
+	})
+	_, _, err = ResumeExecutionContext(context.Background(), execution, ResumeExecutionContextOptions{
+		Verify: verify, TokenSource: otherTask,
+	})
+	require.Equal(t, WorkContextReasonClaimsMismatch, WorkContextErrorReasonOf(err))
+
+	failing := WorkContextTokenSourceFunc(func(context.Context, WorkContextToken) (WorkContextToken, error) {
+		return WorkContextToken{}, errors.New("authority unreachable")
+	})
+	_, _, err = ResumeExecutionContext(context.Background(), execution, ResumeExecutionContextOptions{
+		Verify: verify, TokenSource: failing,
+	})
+	require.ErrorContains(t, err, "authority unreachable")
+	require.Equal(t, WorkContextReasonExpired, WorkContextErrorReasonOf(err))
+}
+
+func TestResumeExecutionContextDoesNotRenewOtherFailures(t *testing.T) {
+	token, _, err := workContextTestSigner(t, workContextTestTime).StartTask(workContextTestInput())
+	require.NoError(t, err)
+	execution, err := NewExecutionContext(token, "operation-1")
+	require.NoError(t, err)
+	source := WorkContextTokenSourceFunc(func(context.Context, WorkContextToken) (WorkContextToken, error) {
+		t.Fatal("only expired tokens are renewed")
+		return WorkContextToken{}, nil
+	})
+	verifier := workContextTestVerifier(t, workContextTestTime)
+	_, _, err = ResumeExecutionContext(context.Background(), execution, ResumeExecutionContextOptions{
+		Verify: func(_ context.Context, token WorkContextToken) (*basev0.WorkContextV1, error) {
+			return verifier.Verify(token, WorkContextExpectations{Audience: "other.service"})
+		},
+		TokenSource: source,
+	})
+	require.Equal(t, WorkContextReasonAudienceMismatch, WorkContextErrorReasonOf(err))
+}
@@ -0,0 +1,229 @@
+package codefly
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	basev0 "github.com/codefly-dev/core/generated/go/codefly/base/v0"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ExecutionContextAttribute is a span attribute ExecutionContextTelemetry
+// may record. Only these identifiers can be exported: tokens, nonces, scopes,
+// principals, and actor chains never are.
+type ExecutionContextAttribute string
+
+const (
+	ExecutionContextAttributeOperationID     ExecutionContextAttribute = "codefly.operation_id"
+	ExecutionContextAttributeTenantID        ExecutionContextAttribute = "codefly.work_context.tenant_id"
+	ExecutionContextAttributeTaskID          ExecutionContextAttribute = "codefly.work_context.task_id"
+	ExecutionContextAttributeSessionID       ExecutionContextAttribute = "codefly.work_context.session_id"
+	ExecutionContextAttributeParentSessionID ExecutionContextAttribute = "codefly.work_context.parent_session_id"
+
+	// ExecutionContextBaggageOperationID is the W3C baggage member that
+	// mirrors the operation ID when MirrorOperationIDToBaggage is set.
+	ExecutionContextBaggageOperationID = "codefly.operation_id"
+
+	maxTelemetryDelegationSessions = 10_000
+)
+
+// ExecutionContextTelemetryOptions configures NewExecutionContextTelemetry.
+type ExecutionContextTelemetryOptions struct {
+	// Claims returns the verified claims of an execution context, typically
+	// a caching verifier's Verify. Without it, or when it fails, only the
+	// operation ID is recorded: identifiers from an unverified token would
+	// let any caller write into the traces of another tenant.
+	Claims func(ctx context.Context, execution ExecutionContext) (*basev0.WorkContextV1, error)
+	// Attributes selects what is recorded. Nil records every
+	// ExecutionContextAttribute; an empty, non-nil slice records none.
+	Attributes []ExecutionContextAttribute
+	// MirrorOperationIDToBaggage adds the operation ID to the W3C baggage of
+	// the request context, so hops that do not carry a Codefly execution
+	// context still see it. Baggage crosses every hop the propagator does,
+	// including to third parties.
+	MirrorOperationIDToBaggage bool
+	// LinkDelegations links the first span of a child session to the latest
+	// span of the session that delegated to it, when the two are in
+	// different traces. Only sessions this process has seen can be linked.
+	LinkDelegations bool
+}
+
+// ExecutionContextTelemetry records execution contexts on OpenTelemetry
+// spans. It annotates the span already in the request context, so install it
+// inside the interceptor or handler that starts spans, such as otelgrpc or
+// otelhttp. It never fails a request.
+type ExecutionContextTelemetry struct {
+	claims     func(ctx context.Context, execution ExecutionContext) (*basev0.WorkContextV1, error)
+	attributes map[ExecutionContextAttribute]bool
+	baggage    bool
+	sessions   *telemetryDelegationSessions
+}
+
+// NewExecutionContextTelemetry validates options and returns gRPC and HTTP
+// middleware for servers and clients.
+func NewExecutionContextTelemetry(options ExecutionContextTelemetryOptions) (*ExecutionContextTelemetry, error) {
+	selected := options.Attributes
+	if selected == nil {
+		selected = []ExecutionContextAttribute{
+			ExecutionContextAttributeOperationID,
+			ExecutionContextAttributeTenantID,
+			ExecutionContextAttributeTaskID,
+			ExecutionContextAttributeSessionID,
+			ExecutionContextAttributeParentSessionID,
+		}
+	}
+	attributes := make(map[ExecutionContextAttribute]bool, len(selected))
+	for _, attribute := range selected {
+		switch attribute {
+		case ExecutionContextAttributeOperationID, ExecutionContextAttributeTenantID,
+			ExecutionContextAttributeTaskID, ExecutionContextAttributeSessionID,
+			ExecutionContextAttributeParentSessionID:
+			attributes[attribute] = true
+		default:
+			return nil, fmt.Errorf("unknown Codefly execution context attribute %q", attribute)
+		}
+	}
+	telemetry := &ExecutionContextTelemetry{
+		claims:     options.Claims,
+		attributes: attributes,
+		baggage:    options.MirrorOperationIDToBaggage,
+	}
+	if options.LinkDelegations {
+		telemetry.sessions = &telemetryDelegationSessions{spans: make(map[telemetryDelegationSession]trace.SpanContext)}
+	}
+	return telemetry, nil
+}
+
+// Annotate records execution on the span in ctx and returns ctx with the
+// operation ID in its baggage when that is enabled. The middleware calls it
+// for every request; call it directly for spans it does not see, such as
+// those of message consumers.
+func (t *ExecutionContextTelemetry) Annotate(ctx context.Context, execution ExecutionContext) context.Context {
+	span := trace.SpanFromContext(ctx)
+	if span.IsRecording() {
+		var claims *basev0.WorkContextV1
+		if t.claims != nil && t.needsClaims() {
+			// Telemetry never fails a request; the handler's own
+			// verification reports a bad token.
+			claims, _ = t.claims(ctx, execution)
+		}
+		span.SetAttributes(t.spanAttributes(execution, claims)...)
+		if t.sessions != nil && claims.GetSessionId() != "" {
+			t.linkDelegation(span, claims)
+		}
+	}
+	if t.baggage {
+		ctx = withOperationIDBaggage(ctx, execution.operationID)
+	}
+	return ctx
+}
+
+func (t *ExecutionContextTelemetry) needsClaims() bool {
+	return t.sessions != nil || t.attributes[ExecutionContextAttributeTenantID] ||
+		t.attributes[ExecutionContextAttributeTaskID] || t.attributes[ExecutionContextAttributeSessionID] ||
+		t.attributes[ExecutionContextAttributeParentSessionID]
+}
+
+func (t *ExecutionContextTelemetry) spanAttributes(
+	execution ExecutionContext,
+	claims *basev0.WorkContextV1,
+) []attribute.KeyValue {
+	values := []struct {
+		attribute ExecutionContextAttribute
+		value     string
+	}{
+		{ExecutionContextAttributeOperationID, execution.operationID},
+		{ExecutionContextAttributeTenantID, claims.GetTenantId()},
+		{ExecutionContextAttributeTaskID, claims.GetTaskId()},
+		{ExecutionContextAttributeSessionID, claims.GetSessionId()},
+		{ExecutionContextAttributeParentSessionID, claims.GetParentSessionId()},
+	}
+	attributes := make([]attribute.KeyValue, 0, len(values))
+	for _, value := range values {
+		if value.value != "" && t.attributes[value.attribute] {
+			attributes = append(attributes, attribute.String(string(value.attribute), value.value))
+		}
+	}
+	return attributes
+}
+
+// linkDelegation remembers span as the latest of its session and, when the
+// session was delegated from one whose span is in another trace, links the
+// two.
+func (t *ExecutionContextTelemetry) linkDelegation(span trace.Span, claims *basev0.WorkContextV1) {
+	current := span.SpanContext()
+	if !current.IsValid() {
+		return
+	}
+	parent, ok := t.sessions.get(claims.GetTenantId(), claims.GetParentSessionId())
+	first := t.sessions.record(claims.GetTenantId(), claims.GetSessionId(), current)
+	if !first || !ok || parent.TraceID() == current.TraceID() {
+		return
+	}
+	var attributes []attribute.KeyValue
+	if t.attributes[ExecutionContextAttributeParentSessionID] {
+		attributes = append(attributes, attribute.String(
+			string(ExecutionContextAttributeParentSessionID), claims.GetParentSessionId(),
+		))
+	}
+	span.AddLink(trace.Link{SpanContext: parent, Attributes: attributes})
+}
+
+func withOperationIDBaggage(ctx context.Context, operationID string) context.Context {
+	member, err := baggage.NewMemberRaw(ExecutionContextBaggageOperationID, operationID)
+	if err != nil {
+		return ctx
+	}
+	bag, err := baggage.FromContext(ctx).SetMember(member)
+	if err != nil {
+		return ctx
+	}
+	return baggage.ContextWithBaggage(ctx, bag)
+}
+
+// telemetryDelegationSession identifies a session within its tenant; session
+// IDs are only unique per tenant.
+type telemetryDelegationSession struct {
+	tenantID  string
+	sessionID string
+}
+
+// telemetryDelegationSessions maps recently seen sessions to their latest
+// span, evicting the oldest session beyond maxTelemetryDelegationSessions.
+type telemetryDelegationSessions struct {
+	mu    sync.Mutex
+	spans map[telemetryDelegationSession]trace.SpanContext
+	order []telemetryDelegationSession
+}
+
+func (s *telemetryDelegationSessions) get(tenantID, sessionID string) (trace.SpanContext, bool) {
+	if sessionID == "" {
+		return trace.SpanContext{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	span, ok := s.spans[telemetryDelegationSession{tenantID: tenantID, sessionID: sessionID}]
+	return span, ok
+}
+
+// record stores span as the latest of sessionID in tenantID and reports
+// whether the session is new.
+func (s *telemetryDelegationSessions) record(tenantID, sessionID string, span trace.SpanContext) bool {
+	key := telemetryDelegationSession{tenantID: tenantID, sessionID: sessionID}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, known := s.spans[key]
+	s.spans[key] = span
+	if known {
+		return false
+	}
+	s.order = append(s.order, key)
+	if len(s.order) > maxTelemetryDelegationSessions {
+		delete(s.spans, s.order[0])
+		s.order = s.order[1:]
+	}
+	return true
+}
@@ -0,0 +1,84 @@
+package codefly
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// UnaryServerInterceptor annotates the span of each call that carries an
+// execution context.
+func (t *ExecutionContextTelemetry) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, request any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		return handler(t.annotateIncoming(ctx), request)
+	}
+}
+
+// StreamServerInterceptor annotates the span of each stream that carries an
+// execution context.
+func (t *ExecutionContextTelemetry) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(server any, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := t.annotateIncoming(stream.Context())
+		if ctx == stream.Context() {
+			return handler(server, stream)
+		}
+		return handler(server, &telemetryServerStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+// UnaryClientInterceptor annotates the caller's span with the execution
+// context attached by WithGRPCExecutionContext. Install it before the
+// interceptor or stats handler that propagates baggage.
+func (t *ExecutionContextTelemetry) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		request, reply any,
+		connection *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		options ...grpc.CallOption,
+	) error {
+		return invoker(t.annotateOutgoing(ctx), method, request, reply, connection, options...)
+	}
+}
+
+// StreamClientInterceptor is the streaming form of UnaryClientInterceptor.
+func (t *ExecutionContextTelemetry) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(
+		ctx context.Context,
+		description *grpc.StreamDesc,
+		connection *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		options ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		return streamer(t.annotateOutgoing(ctx), description, connection, method, options...)
+	}
+}
+
+// annotateIncoming leaves calls without a well-formed execution context to
+// the handler, which decides whether one is required.
+func (t *ExecutionContextTelemetry) annotateIncoming(ctx context.Context) context.Context {
+	execution, present, err := GRPCExecutionContextFromIncomingIfPresent(ctx)
+	if err != nil || !present {
+		return ctx
+	}
+	return t.Annotate(ctx, execution)
+}
+
+func (t *ExecutionContextTelemetry) annotateOutgoing(ctx context.Context) context.Context {
+	execution, present, err := grpcExecutionContextFromOutgoingIfPresent(ctx)
+	if err != nil || !present {
+		return ctx
+	}
+	return t.Annotate(ctx, execution)
+}
+
+type telemetryServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *telemetryServerStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,104 @@
+package codefly
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+type telemetryTestServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s telemetryTestServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestExecutionContextTelemetryServerInterceptorsAnnotateIncomingCalls(t *testing.T) {
+	root, _, claims := telemetryTestExecutions(t)
+	tracer, recorder := telemetryTestTracer(t)
+	telemetry := newExecutionContextTelemetryForTest(t, ExecutionContextTelemetryOptions{
+		Claims:                     claims,
+		MirrorOperationIDToBaggage: true,
+	})
+	incoming := func(pairs ...string) context.Context {
+		ctx, _ := tracer.Start(metadata.NewIncomingContext(context.Background(), metadata.Pairs(pairs...)), "server")
+		return ctx
+	}
+	carriers := []string{
+		workContextGRPCMetadataName, root.WorkContext().Encoded(),
+		operationIDGRPCMetadataName, root.OperationID(),
+	}
+
+	var handled context.Context
+	_, err := telemetry.UnaryServerInterceptor()(incoming(carriers...), nil, &grpc.UnaryServerInfo{},
+		func(ctx context.Context, _ any) (any, error) {
+			handled = ctx
+			return nil, nil
+		})
+	require.NoError(t, err)
+	unary := handled
+	require.Equal(t, "operation-root", baggage.FromContext(unary).Member(ExecutionContextBaggageOperationID).Value())
+
+	stream := telemetryTestServerStream{ctx: incoming(carriers...)}
+	err = telemetry.StreamServerInterceptor()(nil, stream, &grpc.StreamServerInfo{},
+		func(_ any, stream grpc.ServerStream) error {
+			handled = stream.Context()
+			return nil
+		})
+	require.NoError(t, err)
+	require.Equal(t, "operation-root", baggage.FromContext(handled).Member(ExecutionContextBaggageOperationID).Value())
+
+	partial := incoming(operationIDGRPCMetadataName, "operation-partial")
+	_, err = telemetry.UnaryServerInterceptor()(partial, nil, &grpc.UnaryServerInfo{},
+		func(ctx context.Context, _ any) (any, error) {
+			require.Equal(t, partial, ctx, "malformed carriers are left to the handler")
+			return nil, nil
+		})
+	require.NoError(t, err)
+
+	for _, ctx := range []context.Context{unary, handled, partial} {
+		trace.SpanFromContext(ctx).End()
+	}
+	ended := recorder.Ended()
+	require.Len(t, ended, 3)
+	require.Equal(t, "session-root", telemetryTestAttributes(ended[0])["codefly.work_context.session_id"])
+	require.Equal(t, "session-root", telemetryTestAttributes(ended[1])["codefly.work_context.session_id"])
+	require.Empty(t, telemetryTestAttributes(ended[2]))
+}
+
+func TestExecutionContextTelemetryClientInterceptorsAnnotateOutgoingCalls(t *testing.T) {
+	_, child, claims := telemetryTestExecutions(t)
+	tracer, recorder := telemetryTestTracer(t)
+	telemetry := newExecutionContextTelemetryForTest(t, ExecutionContextTelemetryOptions{
+		Claims:                     claims,
+		MirrorOperationIDToBaggage: true,
+	})
+	ctx, span := tracer.Start(context.Background(), "client")
+	outgoing, err := WithGRPCExecutionContext(ctx, child)
+	require.NoError(t, err)
+
+	err = telemetry.UnaryClientInterceptor()(outgoing, "/warden.v1.Tasks/Start", nil, nil, nil,
+		func(ctx context.Context, _ string, _, _ any, _ *grpc.ClientConn, _ ...grpc.CallOption) error {
+			require.Equal(t, "operation-child", baggage.FromContext(ctx).Member(ExecutionContextBaggageOperationID).Value())
+			return nil
+		})
+	require.NoError(t, err)
+	_, err = telemetry.StreamClientInterceptor()(ctx, &grpc.StreamDesc{}, nil, "/warden.v1.Tasks/Watch",
+		func(ctx context.Context, _ *grpc.StreamDesc, _ *grpc.ClientConn, _ string, _ ...grpc.CallOption) (grpc.ClientStream, error) {
+			require.Empty(t, baggage.FromContext(ctx).Members(), "calls without an execution context are unchanged")
+			return nil, nil
+		})
+	require.NoError(t, err)
+	span.End()
+
+	attributes := telemetryTestAttributes(recorder.Ended()[0])
+	require.Equal(t, "operation-child", attributes["codefly.operation_id"])
+	require.Equal(t, "session-root", attributes["codefly.work_context.parent_session_id"])
+}
@@ -0,0 +1,44 @@
+package codefly
+
+import (
+	"net/http"
+)
+
+// HTTPHandler annotates the span of each request to next that carries an
+// execution context.
+func (t *ExecutionContextTelemetry) HTTPHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		execution, present, err := HTTPExecutionContextFromHeadersIfPresent(request.Header)
+		if err != nil || !present {
+			next.ServeHTTP(writer, request)
+			return
+		}
+		next.ServeHTTP(writer, request.WithContext(t.Annotate(request.Context(), execution)))
+	})
+}
+
+// HTTPTransport annotates the caller's span with the execution context
+// attached by AttachExecutionContext. Wrap the transport that propagates
+// baggage, such as otelhttp's, so it sees the mirrored operation ID. A nil
+// next uses http.DefaultTransport.
+func (t *ExecutionContextTelemetry) HTTPTransport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return telemetryRoundTripper{telemetry: t, next: next}
+}
+
+type telemetryRoundTripper struct {
+	telemetry *ExecutionContextTelemetry
+	next      http.RoundTripper
+}
+
+func (r telemetryRoundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
+	execution, present, err := HTTPExecutionContextFromHeadersIfPresent(request.Header)
+	if err != nil || !present {
+		return r.next.RoundTrip(request)
+	}
+	// RoundTrippers must not modify the request, but may send a shallow
+	// copy with another context.
+	return r.next.RoundTrip(request.WithContext(r.telemetry.Annotate(request.Context(), execution)))
+}
@@ -0,0 +1,72 @@
+package codefly
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type telemetryTestRoundTripper func(*http.Request) (*http.Response, error)
+
+func (f telemetryTestRoundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
+	return f(request)
+}
+
+func TestExecutionContextTelemetryHTTPHandlerAnnotatesRequests(t *testing.T) {
+	root, _, claims := telemetryTestExecutions(t)
+	tracer, recorder := telemetryTestTracer(t)
+	handler := newExecutionContextTelemetryForTest(t, ExecutionContextTelemetryOptions{
+		Claims:                     claims,
+		MirrorOperationIDToBaggage: true,
+	}).HTTPHandler(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		_, _ = writer.Write([]byte(baggage.FromContext(request.Context()).Member(ExecutionContextBaggageOperationID).Value()))
+		trace.SpanFromContext(request.Context()).End()
+	}))
+	serve := func(request *http.Request) string {
+		ctx, _ := tracer.Start(request.Context(), "server")
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request.WithContext(ctx))
+		return response.Body.String()
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/tasks", nil)
+	require.NoError(t, AttachExecutionContext(request, root))
+	require.Equal(t, "operation-root", serve(request))
+	require.Equal(t, "task-roadmap", telemetryTestAttributes(recorder.Ended()[0])["codefly.work_context.task_id"])
+
+	partial := httptest.NewRequest(http.MethodPost, "/tasks", nil)
+	partial.Header.Set(operationIDHeaderName, "operation-partial")
+	require.Empty(t, serve(partial), "malformed carriers are left to the handler")
+	require.Empty(t, telemetryTestAttributes(recorder.Ended()[1]))
+}
+
+func TestExecutionContextTelemetryHTTPTransportAnnotatesRequests(t *testing.T) {
+	_, child, claims := telemetryTestExecutions(t)
+	tracer, recorder := telemetryTestTracer(t)
+	var sent *http.Request
+	transport := newExecutionContextTelemetryForTest(t, ExecutionContextTelemetryOptions{
+		Claims:                     claims,
+		MirrorOperationIDToBaggage: true,
+	}).HTTPTransport(telemetryTestRoundTripper(func(request *http.Request) (*http.Response, error) {
+		sent = request
+		return &http.Response{StatusCode: http.StatusNoContent, Body: http.NoBody, Request: request}, nil
+	}))
+
+	ctx, span := tracer.Start(context.Background(), "client")
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://warden.internal/tasks", nil)
+	require.NoError(t, err)
+	require.NoError(t, AttachExecutionContext(request, child))
+	response, err := transport.RoundTrip(request)
+	require.NoError(t, err)
+	require.NoError(t, response.Body.Close())
+	span.End()
+
+	require.Equal(t, "operation-child", baggage.FromContext(sent.Context()).Member(ExecutionContextBaggageOperationID).Value())
+	require.Empty(t, baggage.FromContext(request.Context()).Members(), "the caller's request is not modified")
+	require.Equal(t, "session-child", telemetryTestAttributes(recorder.Ended()[0])["codefly.work_context.session_id"])
+}
@@ -0,0 +1,204 @@
+package codefly
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	basev0 "github.com/codefly-dev/core/generated/go/codefly/base/v0"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func telemetryTestTracer(t *testing.T) (trace.Tracer, *tracetest.SpanRecorder) {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	t.Cleanup(func() { _ = provider.Shutdown(context.Background()) })
+	return provider.Tracer("codefly-test"), recorder
+}
+
+// telemetryTestExecutions returns a root session's execution context, one of
+// a child session it delegated to, and a Claims function standing in for a
+// verifier.
+func telemetryTestExecutions(t *testing.T) (
+	root ExecutionContext,
+	child ExecutionContext,
+	claims func(context.Context, ExecutionContext) (*basev0.WorkContextV1, error),
+) {
+	t.Helper()
+	signer := workContextTestSigner(t, workContextTestTime)
+	rootToken, rootClaims, err := signer.StartTask(workContextTestInput())
+	require.NoError(t, err)
+	childToken, childClaims, err := signer.StartChildSession(rootToken, StartChildSessionInput{
+		SessionID: "session-child",
+		Actor: &basev0.WorkActorV1{
+			PrincipalId:   "agent-reviewer",
+			PrincipalKind: "agent",
+			DelegationId:  "delegation-1",
+			GrantedScopes: []*basev0.WorkScopeV1{
+				{ResourceKind: "repository", Actions: []string{"read"}, ResourceIds: []string{"repo-warden"}},
+			},
+		},
+	})
+	require.NoError(t, err)
+	root, err = NewExecutionContext(rootToken, "operation-root")
+	require.NoError(t, err)
+	child, err = NewExecutionContext(childToken, "operation-child")
+	require.NoError(t, err)
+	verified := map[string]*basev0.WorkContextV1{rootToken.Encoded(): rootClaims, childToken.Encoded(): childClaims}
+	return root, child, func(_ context.Context, execution ExecutionContext) (*basev0.WorkContextV1, error) {
+		if claims, ok := verified[execution.WorkContext().Encoded()]; ok {
+			return claims, nil
+		}
+		return nil, workContextFailure(WorkContextReasonBadSignature, ErrWorkContextInvalid)
+	}
+}
+
+func newExecutionContextTelemetryForTest(
+	t *testing.T,
+	options ExecutionContextTelemetryOptions,
+) *ExecutionContextTelemetry {
+	t.Helper()
+	telemetry, err := NewExecutionContextTelemetry(options)
+	require.NoError(t, err)
+	return telemetry
+}
+
+func telemetryTestAttributes(span sdktrace.ReadOnlySpan) map[string]string {
+	attributes := make(map[string]string)
+	for _, value := range span.Attributes() {
+		attributes[string(value.Key)] = value.Value.Emit()
+	}
+	return attributes
+}
+
+func TestExecutionContextTelemetryRecordsOnlySelectedVerifiedIdentifiers(t *testing.T) {
+	_, child, claims := telemetryTestExecutions(t)
+	forged, err := NewExecutionContext(opaqueTestWorkContext(t), "operation-forged")
+	require.NoError(t, err)
+	tracer, recorder := telemetryTestTracer(t)
+	annotate := func(options ExecutionContextTelemetryOptions, execution ExecutionContext) map[string]string {
+		ctx, span := tracer.Start(context.Background(), "call")
+		newExecutionContextTelemetryForTest(t, options).Annotate(ctx, execution)
+		span.End()
+		ended := recorder.Ended()
+		return telemetryTestAttributes(ended[len(ended)-1])
+	}
+
+	require.Equal(t, map[string]string{
+		"codefly.operation_id":                   "operation-child",
+		"codefly.work_context.tenant_id":         "tenant-codefly",
+		"codefly.work_context.task_id":           "task-roadmap",
+		"codefly.work_context.session_id":        "session-child",
+		"codefly.work_context.parent_session_id": "session-root",
+	}, annotate(ExecutionContextTelemetryOptions{Claims: claims}, child))
+
+	require.Equal(t, map[string]string{
+		"codefly.operation_id":         "operation-child",
+		"codefly.work_context.task_id": "task-roadmap",
+	}, annotate(ExecutionContextTelemetryOptions{
+		Claims:     claims,
+		Attributes: []ExecutionContextAttribute{ExecutionContextAttributeOperationID, ExecutionContextAttributeTaskID},
+	}, child))
+
+	require.Empty(t, annotate(ExecutionContextTelemetryOptions{
+		Claims:     claims,
+		Attributes: []ExecutionContextAttribute{},
+	}, child))
+
+	require.Equal(t, map[string]string{"codefly.operation_id": "operation-child"},
+		annotate(ExecutionContextTelemetryOptions{}, child), "without Claims nothing is read from the token")
+	require.Equal(t, map[string]string{"codefly.operation_id": "operation-forged"},
+		annotate(ExecutionContextTelemetryOptions{Claims: claims}, forged), "unverified claims are not recorded")
+}
+
+func TestNewExecutionContextTelemetryRejectsUnknownAttributes(t *testing.T) {
+	_, err := NewExecutionContextTelemetry(ExecutionContextTelemetryOptions{
+		Attributes: []ExecutionContextAttribute{"codefly.work_context.owner_principal_id"},
+	})
+	require.ErrorContains(t, err, `unknown Codefly execution context attribute "codefly.work_context.owner_principal_id"`)
+}
+
+func TestExecutionContextTelemetryMirrorsOperationIDToBaggage(t *testing.T) {
+	root, _, _ := telemetryTestExecutions(t)
+	existing, err := baggage.NewMemberRaw("tenant-hint", "blue")
+	require.NoError(t, err)
+	bag, err := baggage.New(existing)
+	require.NoError(t, err)
+	ctx := baggage.ContextWithBaggage(context.Background(), bag)
+
+	mirrored := newExecutionContextTelemetryForTest(t, ExecutionContextTelemetryOptions{
+		MirrorOperationIDToBaggage: true,
+	}).Annotate(ctx, root)
+	require.Equal(t, "operation-root", baggage.FromContext(mirrored).Member(ExecutionContextBaggageOperationID).Value())
+	require.Equal(t, "blue", baggage.FromContext(mirrored).Member("tenant-hint").Value())
+
+	unchanged := newExecutionContextTelemetryForTest(t, ExecutionContextTelemetryOptions{}).Annotate(ctx, root)
+	require.Equal(t, ctx, unchanged)
+}
+
+func TestExecutionContextTelemetryLinksDelegatedSessions(t *testing.T) {
+	root, child, claims := telemetryTestExecutions(t)
+	tracer, recorder := telemetryTestTracer(t)
+	telemetry := newExecutionContextTelemetryForTest(t, ExecutionContextTelemetryOptions{
+		Claims:          claims,
+		LinkDelegations: true,
+	})
+	annotated := func(ctx context.Context, execution ExecutionContext) sdktrace.ReadOnlySpan {
+		ctx, span := tracer.Start(ctx, "work")
+		telemetry.Annotate(ctx, execution)
+		span.End()
+		ended := recorder.Ended()
+		return ended[len(ended)-1]
+	}
+
+	delegating := annotated(context.Background(), root)
+	require.Empty(t, delegating.Links())
+	first := annotated(context.Background(), child)
+	require.Len(t, first.Links(), 1)
+	require.Equal(t, delegating.SpanContext(), first.Links()[0].SpanContext)
+	require.Equal(t,
+		[]attribute.KeyValue{attribute.String("codefly.work_context.parent_session_id", "session-root")},
+		first.Links()[0].Attributes,
+	)
+	require.Empty(t, annotated(context.Background(), child).Links(), "only the first span of a session is linked")
+
+	// A child session first seen in its parent's trace is already connected.
+	telemetry = newExecutionContextTelemetryForTest(t, ExecutionContextTelemetryOptions{
+		Claims:          claims,
+		LinkDelegations: true,
+	})
+	ctx, span := tracer.Start(context.Background(), "delegate")
+	telemetry.Annotate(ctx, root)
+	require.Empty(t, annotated(ctx, child).Links())
+	span.End()
+}
+
+func TestTelemetryDelegationSessionsEvictOldest(t *testing.T) {
+	sessions := &telemetryDelegationSessions{spans: make(map[telemetryDelegationSession]trace.SpanContext)}
+	span := trace.NewSpanContext(trace.SpanContextConfig{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{1}})
+	for index := range maxTelemetryDelegationSessions + 1 {
+		require.True(t, sessions.record("tenant-codefly", fmt.Sprintf("session-%d", index), span))
+	}
+	require.Len(t, sessions.spans, maxTelemetryDelegationSessions)
+	_, ok := sessions.get("tenant-codefly", "session-0")
+	require.False(t, ok)
+	oldest := sessions.order[0]
+	require.False(t, sessions.record(oldest.tenantID, oldest.sessionID, span), "a known session is not new")
+	_, ok = sessions.get("tenant-codefly", "")
+	require.False(t, ok)
+}
+
+func TestTelemetryDelegationSessionsAreScopedByTenant(t *testing.T) {
+	sessions := &telemetryDelegationSessions{spans: make(map[telemetryDelegationSession]trace.SpanContext)}
+	span := trace.NewSpanContext(trace.SpanContextConfig{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{1}})
+	require.True(t, sessions.record("tenant-codefly", "session-root", span))
+	_, ok := sessions.get("tenant-other", "session-root")
+	require.False(t, ok, "a session ID seen in another tenant is not a parent")
+	require.True(t, sessions.record("tenant-other", "session-root", span))
+}
@@ -151,10 +151,7 @@ func (q *Query) resolveLocalNetworkInstance() (*resources.NetworkInstance, error
 	}
 	var selected *resources.Endpoint
 	for _, endpoint := range service.Endpoints {
-		api := endpoint.API
-		if api == "" && standards.IsSupportedAPI(endpoint.Name) == nil {
-			api = endpoint.Name
-		}
+		api := localEndpointAPI(endpoint)
 		if q.endpointName != "" && !resources.Match(endpoint.Name, q.endpointName) {
 			continue
 		}
@@ -163,7 +160,7 @@ func (q *Query) resolveLocalNetworkInstance() (*resources.NetworkInstance, error
 		}
 		if selected != nil {
 			return nil, fmt.Errorf(
-				"multiple endpoints match %s/%s name=%q api=%q",
+				"multiple endpoints match %s/%s name=%q api=%q (ResolveAll lists them)",
 				q.module,
 				q.service,
 				q.endpointName,
@@ -181,26 +178,41 @@ func (q *Query) resolveLocalNetworkInstance() (*resources.NetworkInstance, error
 			q.endpointApi,
 		)
 	}
-	if selected.Visibility == resources.VisibilityExternal {
-		return nil, errors.New("external endpoint cannot be resolved from the local native map")
+	return q.localNetworkInstance(workspace.Name, q.module, q.service, selected)
+}
+
+// localEndpointAPI defaults an endpoint without an explicit API to its name
+// when the name is itself a supported API, as in `endpoints: [{name: rest}]`.
+func localEndpointAPI(endpoint *resources.Endpoint) string {
+	if endpoint.API == "" && standards.IsSupportedAPI(endpoint.Name) == nil {
+		return endpoint.Name
 	}
-	api := selected.API
-	if api == "" && standards.IsSupportedAPI(selected.Name) == nil {
-		api = selected.Name
+	return endpoint.API
+}
+
+// localNetworkInstance addresses a workspace endpoint through the
+// deterministic native map that `codefly run` uses.
+func (q *Query) localNetworkInstance(
+	workspace, module, service string,
+	endpoint *resources.Endpoint,
+) (*resources.NetworkInstance, error) {
+	if endpoint.Visibility == resources.VisibilityExternal {
+		return nil, errors.New("external endpoint cannot be resolved from the local native map")
 	}
+	api := localEndpointAPI(endpoint)
 	if standards.IsSupportedAPI(api) != nil {
 		return nil, fmt.Errorf("endpoint API %q is not supported by the local native map", api)
 	}
 	native := network.NativeFor(
 		q.ctx,
-		workspace.Name,
-		q.module,
-		q.service,
+		workspace,
+		module,
+		service,
 		q.namingScope,
 		&basev0.Endpoint{
-			Name:       selected.Name,
+			Name:       endpoint.Name,
 			Api:        api,
-			Visibility: selected.Visibility,
+			Visibility: endpoint.Visibility,
 		},
 	)
 	if native.Port > uint32(^uint16(0)) {
@@ -0,0 +1,283 @@
+package codefly
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	basev0 "github.com/codefly-dev/core/generated/go/codefly/base/v0"
+)
+
+const (
+	// IdempotencyReplayHeaderName marks a response served from the idempotency
+	// store instead of the handler, as an HTTP header or gRPC header metadata.
+	IdempotencyReplayHeaderName = "x-codefly-idempotent-replay"
+
+	defaultIdempotencyTTL          = 24 * time.Hour
+	defaultIdempotencyLease        = 5 * time.Minute
+	defaultIdempotencyPollInterval = 25 * time.Millisecond
+	defaultIdempotencyMaxBodyBytes = 1 << 20
+	maxIdempotencyEntries          = 100_000
+)
+
+var (
+	// ErrIdempotencyInProgress reports a duplicate that arrived while the
+	// first attempt of its operation was still running and
+	// RejectInFlightDuplicates is set.
+	ErrIdempotencyInProgress = errors.New("Codefly operation is already in progress")
+	// ErrIdempotencyMismatch reports an operation ID reused for a request
+	// that differs from the one it was first recorded with.
+	ErrIdempotencyMismatch = errors.New("Codefly operation ID reused with a different request")
+	// ErrIdempotencyStoreUnavailable reports a store that could not claim or
+	// look up an operation. Nothing was executed.
+	ErrIdempotencyStoreUnavailable = errors.New("Codefly idempotency store is unavailable")
+	// ErrIdempotencyResponseNotStored reports a retry of an operation that
+	// completed with a response too large to store. The operation is not run
+	// again, and its response cannot be replayed.
+	ErrIdempotencyResponseNotStored = errors.New("Codefly operation completed but its response was not stored")
+)
+
+// IdempotencyKey identifies one logical operation: the same operation ID sent
+// by another principal or to another method is another operation.
+type IdempotencyKey struct {
+	Principal   string `json:"principal"`
+	Method      string `json:"method"`
+	OperationID string `json:"operation_id"`
+}
+
+// IdempotencyResponse is a stored result. HTTP responses use Code, Header,
+// and Body; gRPC responses keep the encoded message or status in Body.
+type IdempotencyResponse struct {
+	Code   int         `json:"code"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+	// Omitted marks a response too large to store, kept so that retries
+	// fail with ErrIdempotencyResponseNotStored instead of running the
+	// operation again. Header and Body are empty.
+	Omitted bool `json:"omitted,omitempty"`
+}
+
+// IdempotencyRecord is the stored state of one key. A record without a
+// Response is pending: Claim identifies the attempt running it, and
+// ExpiresAt is the end of its lease rather than of its retention.
+type IdempotencyRecord struct {
+	Fingerprint string               `json:"fingerprint"`
+	Claim       string               `json:"claim"`
+	Response    *IdempotencyResponse `json:"response,omitempty"`
+	ExpiresAt   time.Time            `json:"expires_at"`
+}
+
+func (r IdempotencyRecord) live(now time.Time) bool {
+	return now.Before(r.ExpiresAt)
+}
+
+// IdempotencyStore keeps idempotency records. Implementations must make
+// ClaimIdempotencyKey atomic: of two concurrent claims of a key without a live
+// record, exactly one succeeds.
+type IdempotencyStore interface {
+	// ClaimIdempotencyKey stores pending when key has no live record at now
+	// and returns it with claimed=true. Otherwise it returns the live record.
+	ClaimIdempotencyKey(key IdempotencyKey, pending IdempotencyRecord, now time.Time) (record IdempotencyRecord, claimed bool, err error)
+	// CompleteIdempotencyKey replaces the pending record held by claim with
+	// its response, retained until expiresAt.
+	CompleteIdempotencyKey(key IdempotencyKey, claim string, response IdempotencyResponse, expiresAt time.Time) error
+	// ReleaseIdempotencyKey deletes the pending record held by claim, so the
+	// next attempt executes again.
+	ReleaseIdempotencyKey(key IdempotencyKey, claim string) error
+}
+
+// IdempotencyOptions configures NewIdempotency.
+type IdempotencyOptions struct {
+	Store IdempotencyStore
+	// Principal returns the authenticated principal an operation belongs to,
+	// typically WorkContextIdempotencyPrincipal of the verified Work Context.
+	// It is required: keying by an unauthenticated value would let one
+	// caller read another's stored responses.
+	Principal func(ctx context.Context, execution ExecutionContext) (string, error)
+	// TTL is how long a completed response is replayed. It defaults to 24h.
+	TTL time.Duration
+	// Lease bounds how long a pending attempt holds its operation, so one
+	// lost with its process does not block retries forever. It defaults to
+	// 5m and must exceed the slowest handler.
+	Lease time.Duration
+	// RejectInFlightDuplicates answers a duplicate of a running operation
+	// with ErrIdempotencyInProgress. By default the duplicate waits for the
+	// first attempt and receives its response.
+	RejectInFlightDuplicates bool
+	// PollInterval is how often a waiting duplicate checks the store. It
+	// defaults to 25ms.
+	PollInterval time.Duration
+	// RequireExecutionContext rejects requests without an execution context
+	// instead of running them without deduplication.
+	RequireExecutionContext bool
+	// MaxBodyBytes bounds the HTTP request body that is fingerprinted and
+	// the response that is stored. It defaults to 1 MiB; larger responses
+	// are delivered but not stored, and their retries fail with
+	// ErrIdempotencyResponseNotStored.
+	MaxBodyBytes int
+	Now          func() time.Time
+}
+
+// Idempotency deduplicates requests by their execution context operation ID.
+// The first attempt of an operation runs the handler; retries receive its
+// stored response. Server-side failures (HTTP 5xx, and gRPC codes a client may
+// retry) are not stored, so their retries run the handler again.
+type Idempotency struct {
+	store                    IdempotencyStore
+	principal                func(ctx context.Context, execution ExecutionContext) (string, error)
+	ttl                      time.Duration
+	lease                    time.Duration
+	rejectInFlightDuplicates bool
+	pollInterval             time.Duration
+	requireExecutionContext  bool
+	maxBodyBytes             int
+	now                      func() time.Time
+}
+
+// NewIdempotency validates options and returns server middleware for gRPC
+// and HTTP.
+func NewIdempotency(options IdempotencyOptions) (*Idempotency, error) {
+	if options.Store == nil {
+		return nil, errors.New("Codefly idempotency store is required")
+	}
+	if options.Principal == nil {
+		return nil, errors.New("Codefly idempotency principal function is required")
+	}
+	ttl := options.TTL
+	if ttl == 0 {
+		ttl = defaultIdempotencyTTL
+	}
+	lease := options.Lease
+	if lease == 0 {
+		lease = defaultIdempotencyLease
+	}
+	pollInterval := options.PollInterval
+	if pollInterval == 0 {
+		pollInterval = defaultIdempotencyPollInterval
+	}
+	maxBodyBytes := options.MaxBodyBytes
+	if maxBodyBytes == 0 {
+		maxBodyBytes = defaultIdempotencyMaxBodyBytes
+	}
+	if ttl < 0 || lease < 0 || pollInterval < 0 || maxBodyBytes < 0 {
+		return nil, errors.New("Codefly idempotency durations and limits must be positive")
+	}
+	now := options.Now
+	if now == nil {
+		now = time.Now
+	}
+	return &Idempotency{
+		store: options.Store, principal: options.Principal, ttl: ttl, lease: lease,
+		rejectInFlightDuplicates: options.RejectInFlightDuplicates, pollInterval: pollInterval,
+		requireExecutionContext: options.RequireExecutionContext, maxBodyBytes: maxBodyBytes, now: now,
+	}, nil
+}
+
+// WorkContextIdempotencyPrincipal names the principal of verified claims for
+// IdempotencyOptions.Principal: the tenant, the owner, and the acting
+// principal when the owner delegated. Each part is length-prefixed, as in
+// "8:tenant-1/11:principal-1", because IDs may themselves contain "/": joined
+// plainly, owner "user/alice" and owner "user" acting through "alice" would
+// share stored responses.
+func WorkContextIdempotencyPrincipal(claims *basev0.WorkContextV1) string {
+	parts := []string{claims.GetTenantId(), claims.GetOwnerPrincipalId()}
+	if actors := claims.GetActorChain(); len(actors) > 0 {
+		parts = append(parts, actors[len(actors)-1].GetPrincipalId())
+	}
+	for index, part := range parts {
+		parts[index] = strconv.Itoa(len(part)) + ":" + part
+	}
+	return strings.Join(parts, "/")
+}
+
+// execute runs one attempt of key. It returns a stored response to replay, or
+// nil after run has executed the handler. run returns the response to store,
+// or nil when the result must not be stored.
+func (i *Idempotency) execute(
+	ctx context.Context,
+	key IdempotencyKey,
+	fingerprint string,
+	run func() *IdempotencyResponse,
+) (*IdempotencyResponse, error) {
+	claim := newIdempotencyClaim()
+	for {
+		now := i.now()
+		record, claimed, err := i.store.ClaimIdempotencyKey(key, IdempotencyRecord{
+			Fingerprint: fingerprint,
+			Claim:       claim,
+			ExpiresAt:   now.Add(i.lease),
+		}, now)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrIdempotencyStoreUnavailable, err)
+		}
+		if claimed {
+			i.run(key, claim, run)
+			return nil, nil
+		}
+		if record.Fingerprint != fingerprint {
+			return nil, fmt.Errorf("%w: operation %q", ErrIdempotencyMismatch, key.OperationID)
+		}
+		if record.Response != nil && record.Response.Omitted {
+			return nil, fmt.Errorf("%w: operation %q", ErrIdempotencyResponseNotStored, key.OperationID)
+		}
+		if record.Response != nil {
+			return record.Response, nil
+		}
+		if i.rejectInFlightDuplicates {
+			return nil, fmt.Errorf("%w: operation %q", ErrIdempotencyInProgress, key.OperationID)
+		}
+		timer := time.NewTimer(i.pollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// run executes a claimed attempt. A panicking handler releases its claim so
+// a retry is not blocked until the lease expires.
+func (i *Idempotency) run(key IdempotencyKey, claim string, run func() *IdempotencyResponse) {
+	completed := false
+	defer func() {
+		if !completed {
+			// The handler's result stands whether or not the release
+			// succeeds; at worst a retry waits for the lease to expire.
+			_ = i.store.ReleaseIdempotencyKey(key, claim)
+		}
+	}()
+	response := run()
+	if response == nil {
+		return
+	}
+	// The response was produced, so it is delivered even if it cannot be
+	// stored; a failed completion leaves the claim to be released above.
+	completed = i.store.CompleteIdempotencyKey(key, claim, *response, i.now().Add(i.ttl)) == nil
+}
+
+// idempotencyFingerprint hashes length-prefixed parts, so no two different
+// part lists share a fingerprint.
+func idempotencyFingerprint(parts ...[]byte) string {
+	hash := sha256.New()
+	for _, part := range parts {
+		_, _ = fmt.Fprintf(hash, "%d:", len(part))
+		_, _ = hash.Write(part)
+	}
+	return base64.RawURLEncoding.EncodeToString(hash.Sum(nil))
+}
+
+func newIdempotencyClaim() string {
+	var claim [16]byte
+	// As in operationIDGenerator.next, crypto/rand.Read never returns an
+	// error.
+	_, _ = rand.Read(claim[:])
+	return base64.RawURLEncoding.EncodeToString(claim[:])
+}
@@ -0,0 +1,145 @@
+package codefly
+
+import (
+	"context"
+	"errors"
+
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// UnaryServerInterceptor deduplicates unary calls by their incoming
+// execution context. Calls without one run unchanged unless
+// RequireExecutionContext is set. Responses are stored as protobuf messages;
+// response header and trailer metadata are not replayed. Streaming calls are
+// not deduplicated.
+func (i *Idempotency) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, request any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		execution, present, err := GRPCExecutionContextFromIncomingIfPresent(ctx)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		if !present {
+			if i.requireExecutionContext {
+				return nil, status.Error(codes.InvalidArgument, "Codefly execution context is required")
+			}
+			return handler(ctx, request)
+		}
+		message, ok := request.(proto.Message)
+		if !ok {
+			// Only protobuf requests have a canonical encoding to
+			// fingerprint.
+			return handler(ctx, request)
+		}
+		principal, err := i.principal(ctx, execution)
+		if err != nil {
+			return nil, status.Error(grpcWorkContextCode(err), err.Error())
+		}
+		encoded, err := proto.MarshalOptions{Deterministic: true}.Marshal(message)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "fingerprint request: %v", err)
+		}
+		key := IdempotencyKey{Principal: principal, Method: info.FullMethod, OperationID: execution.OperationID()}
+
+		var response any
+		var handlerErr error
+		replay, err := i.execute(ctx, key, idempotencyFingerprint([]byte(info.FullMethod), encoded), func() *IdempotencyResponse {
+			response, handlerErr = handler(ctx, request)
+			return grpcIdempotencyResponse(response, handlerErr)
+		})
+		if err != nil {
+			return nil, grpcIdempotencyError(err)
+		}
+		if replay == nil {
+			return response, handlerErr
+		}
+		// The header is informational; a replay is still correct when the
+		// transport no longer accepts headers.
+		_ = grpc.SetHeader(ctx, metadata.Pairs(IdempotencyReplayHeaderName, "true"))
+		return grpcIdempotencyReplay(replay)
+	}
+}
+
+// grpcIdempotencyResponse encodes a handler result for storage, or returns
+// nil for results a retry should recompute.
+func grpcIdempotencyResponse(response any, err error) *IdempotencyResponse {
+	if err != nil {
+		result := status.Convert(err)
+		switch result.Code() {
+		case codes.Canceled, codes.Unknown, codes.DeadlineExceeded, codes.ResourceExhausted,
+			codes.Aborted, codes.Internal, codes.Unavailable, codes.DataLoss:
+			return nil
+		}
+		body, marshalErr := proto.Marshal(result.Proto())
+		if marshalErr != nil {
+			return nil
+		}
+		return &IdempotencyResponse{Code: int(result.Code()), Body: body}
+	}
+	message, ok := response.(proto.Message)
+	if !ok {
+		return nil
+	}
+	// Any keeps the message type, which the interceptor cannot know when it
+	// replays without calling the handler.
+	wrapped, err := anypb.New(message)
+	if err != nil {
+		return nil
+	}
+	body, err := proto.Marshal(wrapped)
+	if err != nil {
+		return nil
+	}
+	return &IdempotencyResponse{Code: int(codes.OK), Body: body}
+}
+
+func grpcIdempotencyReplay(replay *IdempotencyResponse) (any, error) {
+	if codes.Code(replay.Code) != codes.OK {
+		var result spb.Status
+		if err := proto.Unmarshal(replay.Body, &result); err != nil {
+			return nil, status.Errorf(codes.Internal, "decode stored status: %v", err)
+		}
+		return nil, status.FromProto(&result).Err()
+	}
+	var wrapped anypb.Any
+	if err := proto.Unmarshal(replay.Body, &wrapped); err != nil {
+		return nil, status.Errorf(codes.Internal, "decode stored response: %v", err)
+	}
+	message, err := wrapped.UnmarshalNew()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "decode stored response: %v", err)
+	}
+	return message, nil
+}
+
+func grpcIdempotencyError(err error) error {
+	switch {
+	case errors.Is(err, ErrIdempotencyInProgress):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, ErrIdempotencyMismatch), errors.Is(err, ErrIdempotencyResponseNotStored):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	default:
+		return status.Error(codes.Unavailable, err.Error())
+	}
+}
+
+// grpcWorkContextCode maps a Work Context failure to the status a client acts
+// on: authenticate again, give up, or retry later.
+func grpcWorkContextCode(err error) codes.Code {
+	reason := WorkContextErrorReasonOf(err)
+	switch {
+	case reason == WorkContextReasonScopeDenied, reason == WorkContextReasonDelegationDenied:
+		return codes.PermissionDenied
+	case reason.Retryable():
+		return codes.Unavailable
+	default:
+		return codes.Unauthenticated
+	}
+}
@@ -0,0 +1,110 @@
+package codefly
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func idempotencyGRPCContext(t *testing.T, operationID string) context.Context {
+	t.Helper()
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		workContextGRPCMetadataName, opaqueTestWorkContext(t).Encoded(),
+		operationIDGRPCMetadataName, operationID,
+	))
+}
+
+func TestIdempotencyUnaryInterceptorReplaysResponsesAndStatuses(t *testing.T) {
+	interceptor := newIdempotencyForTest(t, nil).UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/warden.v1.Tasks/Start"}
+	calls := 0
+	handler := func(_ context.Context, request any) (any, error) {
+		calls++
+		value, _ := request.(*wrapperspb.StringValue)
+		if value.GetValue() == "denied" {
+			result, err := status.New(codes.PermissionDenied, "not yours").WithDetails(&errdetails.ErrorInfo{Reason: "OWNER"})
+			require.NoError(t, err)
+			return nil, result.Err()
+		}
+		return wrapperspb.String("started " + value.GetValue()), nil
+	}
+
+	for range 2 {
+		response, err := interceptor(idempotencyGRPCContext(t, "operation-1"), wrapperspb.String("task-1"), info, handler)
+		require.NoError(t, err)
+		require.True(t, proto.Equal(wrapperspb.String("started task-1"), response.(proto.Message)))
+	}
+	require.Equal(t, 1, calls)
+
+	_, err := interceptor(idempotencyGRPCContext(t, "operation-1"), wrapperspb.String("task-2"), info, handler)
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	for range 2 {
+		_, err = interceptor(idempotencyGRPCContext(t, "operation-2"), wrapperspb.String("denied"), info, handler)
+		require.Equal(t, codes.PermissionDenied, status.Code(err))
+		require.Len(t, status.Convert(err).Details(), 1, "status details are replayed")
+	}
+	require.Equal(t, 2, calls)
+
+	_, err = interceptor(context.Background(), wrapperspb.String("task-1"), info, handler)
+	require.NoError(t, err, "calls without an execution context run unchanged")
+	require.Equal(t, 3, calls)
+}
+
+func TestIdempotencyUnaryInterceptorRetriesServerFailures(t *testing.T) {
+	interceptor := newIdempotencyForTest(t, nil).UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/warden.v1.Tasks/Start"}
+	calls := 0
+	handler := func(context.Context, any) (any, error) {
+		calls++
+		if calls == 1 {
+			return nil, status.Error(codes.Unavailable, "database down")
+		}
+		return wrapperspb.String("started"), nil
+	}
+	_, err := interceptor(idempotencyGRPCContext(t, "operation-1"), wrapperspb.String("task-1"), info, handler)
+	require.Equal(t, codes.Unavailable, status.Code(err))
+	_, err = interceptor(idempotencyGRPCContext(t, "operation-1"), wrapperspb.String("task-1"), info, handler)
+	require.NoError(t, err)
+	require.Equal(t, 2, calls)
+}
+
+func TestIdempotencyUnaryInterceptorRejectsBadCarriersAndPrincipals(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/warden.v1.Tasks/Start"}
+	handler := func(context.Context, any) (any, error) { return wrapperspb.String("ok"), nil }
+
+	required := newIdempotencyForTest(t, func(options *IdempotencyOptions) {
+		options.RequireExecutionContext = true
+	}).UnaryServerInterceptor()
+	_, err := required(context.Background(), wrapperspb.String("task-1"), info, handler)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	partial := metadata.NewIncomingContext(context.Background(), metadata.Pairs(operationIDGRPCMetadataName, "operation-1"))
+	_, err = required(partial, wrapperspb.String("task-1"), info, handler)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	for _, test := range []struct {
+		err  error
+		code codes.Code
+	}{
+		{workContextFailure(WorkContextReasonExpired, ErrWorkContextInvalid), codes.Unauthenticated},
+		{workContextFailure(WorkContextReasonScopeDenied, ErrWorkContextDenied), codes.PermissionDenied},
+		{&WorkContextDelegationError{Reason: WorkContextDelegationDepth}, codes.PermissionDenied},
+		{workContextFailure(WorkContextReasonKeysUnavailable, ErrWorkContextJWKSUnavailable), codes.Unavailable},
+		{errors.New("no peer"), codes.Unauthenticated},
+	} {
+		interceptor := newIdempotencyForTest(t, func(options *IdempotencyOptions) {
+			options.Principal = func(context.Context, ExecutionContext) (string, error) { return "", test.err }
+		}).UnaryServerInterceptor()
+		_, err = interceptor(idempotencyGRPCContext(t, "operation-1"), wrapperspb.String("task-1"), info, handler)
+		require.Equal(t, test.code, status.Code(err), test.err.Error())
+	}
+}
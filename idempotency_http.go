@@ -0,0 +1,150 @@
+package codefly
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// HTTPHandler deduplicates requests to next by their execution context
+// headers. Requests without one reach next unchanged unless
+// RequireExecutionContext is set. The request body is read up front to
+// fingerprint it, so it is limited to MaxBodyBytes.
+func (i *Idempotency) HTTPHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		execution, present, err := HTTPExecutionContextFromHeadersIfPresent(request.Header)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !present {
+			if i.requireExecutionContext {
+				http.Error(writer, "Codefly execution context is required", http.StatusBadRequest)
+				return
+			}
+			next.ServeHTTP(writer, request)
+			return
+		}
+		principal, err := i.principal(request.Context(), execution)
+		if err != nil {
+			http.Error(writer, err.Error(), httpWorkContextStatus(err))
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(request.Body, int64(i.maxBodyBytes)+1))
+		if err != nil {
+			http.Error(writer, fmt.Sprintf("read request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		if len(body) > i.maxBodyBytes {
+			http.Error(writer, fmt.Sprintf("request body exceeds %d bytes", i.maxBodyBytes), http.StatusRequestEntityTooLarge)
+			return
+		}
+		request.Body = io.NopCloser(bytes.NewReader(body))
+		method := request.Method + " " + request.URL.Path
+		key := IdempotencyKey{Principal: principal, Method: method, OperationID: execution.OperationID()}
+		fingerprint := idempotencyFingerprint([]byte(method), []byte(request.URL.RawQuery), body)
+
+		replay, err := i.execute(request.Context(), key, fingerprint, func() *IdempotencyResponse {
+			recorder := &idempotencyResponseRecorder{ResponseWriter: writer, limit: i.maxBodyBytes}
+			next.ServeHTTP(recorder, request)
+			return recorder.response()
+		})
+		if err != nil {
+			http.Error(writer, err.Error(), httpIdempotencyStatus(err))
+			return
+		}
+		if replay == nil {
+			return
+		}
+		for name, values := range replay.Header {
+			writer.Header()[name] = append([]string(nil), values...)
+		}
+		writer.Header().Set(IdempotencyReplayHeaderName, "true")
+		writer.WriteHeader(replay.Code)
+		_, _ = writer.Write(replay.Body)
+	})
+}
+
+// idempotencyResponseRecorder passes a response through to the client while
+// keeping a copy to store.
+type idempotencyResponseRecorder struct {
+	http.ResponseWriter
+	limit    int
+	code     int
+	header   http.Header
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (r *idempotencyResponseRecorder) WriteHeader(code int) {
+	if r.code == 0 {
+		r.code = code
+		r.header = r.ResponseWriter.Header().Clone()
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *idempotencyResponseRecorder) Write(data []byte) (int, error) {
+	if r.code == 0 {
+		r.WriteHeader(http.StatusOK)
+	}
+	if !r.overflow {
+		if r.body.Len()+len(data) > r.limit {
+			r.overflow = true
+			r.body = bytes.Buffer{}
+		} else {
+			r.body.Write(data)
+		}
+	}
+	return r.ResponseWriter.Write(data)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *idempotencyResponseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// response returns the recorded response, or nil for a server-side failure a
+// retry should recompute. A response too large to keep is stored omitted.
+func (r *idempotencyResponseRecorder) response() *IdempotencyResponse {
+	if r.code == 0 {
+		// A handler that writes nothing sends an implicit 200.
+		r.code, r.header = http.StatusOK, r.ResponseWriter.Header().Clone()
+	}
+	if r.code >= http.StatusInternalServerError {
+		return nil
+	}
+	if r.overflow {
+		return &IdempotencyResponse{Code: r.code, Omitted: true}
+	}
+	return &IdempotencyResponse{Code: r.code, Header: r.header, Body: bytes.Clone(r.body.Bytes())}
+}
+
+func httpIdempotencyStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrIdempotencyInProgress), errors.Is(err, ErrIdempotencyResponseNotStored):
+		return http.StatusConflict
+	case errors.Is(err, ErrIdempotencyMismatch):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusServiceUnavailable
+	}
+}
+
+// httpWorkContextStatus is the HTTP form of grpcWorkContextCode.
+func httpWorkContextStatus(err error) int {
+	reason := WorkContextErrorReasonOf(err)
+	switch {
+	case reason == WorkContextReasonScopeDenied, reason == WorkContextReasonDelegationDenied:
+		return http.StatusForbidden
+	case reason.Retryable():
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusUnauthorized
+	}
+}
@@ -0,0 +1,114 @@
+package codefly
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func idempotencyHTTPRequest(t *testing.T, operationID, body string) *http.Request {
+	t.Helper()
+	request := httptest.NewRequest(http.MethodPost, "/v1/tasks?dry_run=false", strings.NewReader(body))
+	execution, err := NewExecutionContext(opaqueTestWorkContext(t), operationID)
+	require.NoError(t, err)
+	require.NoError(t, AttachExecutionContext(request, execution))
+	return request
+}
+
+func TestIdempotencyHTTPHandlerReplaysResponses(t *testing.T) {
+	calls := 0
+	handler := newIdempotencyForTest(t, nil).HTTPHandler(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		calls++
+		body, err := io.ReadAll(request.Body)
+		require.NoError(t, err)
+		writer.Header().Set("Location", "/v1/tasks/1")
+		writer.WriteHeader(http.StatusCreated)
+		_, _ = fmt.Fprintf(writer, "created %s", body)
+	}))
+
+	for attempt := range 2 {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, idempotencyHTTPRequest(t, "operation-1", "task-1"))
+		require.Equal(t, http.StatusCreated, recorder.Code)
+		require.Equal(t, "created task-1", recorder.Body.String())
+		require.Equal(t, "/v1/tasks/1", recorder.Header().Get("Location"))
+		require.Equal(t, attempt == 1, recorder.Header().Get(IdempotencyReplayHeaderName) == "true")
+	}
+	require.Equal(t, 1, calls)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, idempotencyHTTPRequest(t, "operation-1", "task-2"))
+	require.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/v1/tasks", strings.NewReader("task-1")))
+	require.Equal(t, http.StatusCreated, recorder.Code, "requests without an execution context run unchanged")
+	require.Equal(t, 2, calls)
+}
+
+func TestIdempotencyHTTPHandlerDoesNotReplayFailuresOrLargeResponses(t *testing.T) {
+	calls := 0
+	handler := newIdempotencyForTest(t, func(options *IdempotencyOptions) {
+		options.MaxBodyBytes = 16
+	}).HTTPHandler(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		calls++
+		switch request.URL.Query().Get("mode") {
+		case "fail":
+			http.Error(writer, "database down", http.StatusServiceUnavailable)
+		case "large":
+			_, _ = writer.Write([]byte(strings.Repeat("x", 17)))
+		default:
+			writer.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	serve := func(mode string) *httptest.ResponseRecorder {
+		request := idempotencyHTTPRequest(t, "operation-"+mode, "")
+		request.URL.RawQuery = "mode=" + mode
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		require.Empty(t, recorder.Header().Get(IdempotencyReplayHeaderName))
+		return recorder
+	}
+	for range 2 {
+		require.Equal(t, http.StatusServiceUnavailable, serve("fail").Code)
+	}
+	require.Equal(t, 2, calls, "failures run again")
+
+	require.Equal(t, strings.Repeat("x", 17), serve("large").Body.String())
+	// The large response is delivered once; a retry must not repeat its
+	// side effects.
+	retried := serve("large")
+	require.Equal(t, http.StatusConflict, retried.Code)
+	require.Contains(t, retried.Body.String(), "response was not stored")
+	require.Equal(t, 3, calls)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, idempotencyHTTPRequest(t, "operation-body", strings.Repeat("x", 17)))
+	require.Equal(t, http.StatusRequestEntityTooLarge, recorder.Code)
+	require.Equal(t, 3, calls)
+}
+
+func TestIdempotencyHTTPHandlerRejectsBadCarriersAndPrincipals(t *testing.T) {
+	next := http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) { writer.WriteHeader(http.StatusOK) })
+	required := newIdempotencyForTest(t, func(options *IdempotencyOptions) {
+		options.RequireExecutionContext = true
+	}).HTTPHandler(next)
+	recorder := httptest.NewRecorder()
+	required.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/v1/tasks", nil))
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	denied := newIdempotencyForTest(t, func(options *IdempotencyOptions) {
+		options.Principal = func(_ context.Context, _ ExecutionContext) (string, error) {
+			return "", workContextFailure(WorkContextReasonScopeDenied, ErrWorkContextDenied)
+		}
+	}).HTTPHandler(next)
+	recorder = httptest.NewRecorder()
+	denied.ServeHTTP(recorder, idempotencyHTTPRequest(t, "operation-1", ""))
+	require.Equal(t, http.StatusForbidden, recorder.Code)
+}
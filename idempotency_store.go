@@ -0,0 +1,419 @@
+package codefly
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxIdempotencyFileBytes   = 1 << 30
+	maxIdempotencyRecordBytes = 4 << 20
+	// An idempotency file is compacted once it is this much larger than its
+	// live records, and at least minIdempotencyCompactionBytes.
+	idempotencyCompactionRatio    = 2
+	minIdempotencyCompactionBytes = 4 << 20
+)
+
+// idempotencyRecords is the bookkeeping shared by the stores.
+type idempotencyRecords map[IdempotencyKey]IdempotencyRecord
+
+// claim checks whether pending may claim key in a store holding at most
+// capacity records.
+func (r idempotencyRecords) claim(
+	key IdempotencyKey,
+	pending IdempotencyRecord,
+	now time.Time,
+	capacity int,
+) (IdempotencyRecord, bool, error) {
+	if existing, ok := r[key]; ok && existing.live(now) {
+		return existing, false, nil
+	}
+	if pending.Claim == "" || pending.Response != nil || !pending.live(now) {
+		return IdempotencyRecord{}, false, errors.New("Codefly idempotency claim requires a pending record with a live lease")
+	}
+	if _, ok := r[key]; !ok && len(r) >= capacity {
+		r.sweep(now)
+		if len(r) >= capacity {
+			// Evict a hundredth at once, so that claims made at capacity do
+			// not each pay for a scan.
+			r.evict(max(1, capacity/100))
+		}
+		if len(r) >= capacity {
+			return IdempotencyRecord{}, false, fmt.Errorf("Codefly idempotency store exceeds %d pending entries", capacity)
+		}
+	}
+	return pending, true, nil
+}
+
+// evict drops the count completed records closest to expiry. Their operations run
+// again if retried, which is better than refusing every new operation.
+// Pending records are kept: their attempts are still running.
+func (r idempotencyRecords) evict(count int) {
+	completed := make([]IdempotencyKey, 0, len(r))
+	for key, record := range r {
+		if record.Response != nil {
+			completed = append(completed, key)
+		}
+	}
+	slices.SortFunc(completed, func(a, b IdempotencyKey) int {
+		return r[a].ExpiresAt.Compare(r[b].ExpiresAt)
+	})
+	for _, key := range completed[:min(len(completed), count)] {
+		delete(r, key)
+	}
+}
+
+func (r idempotencyRecords) pending(key IdempotencyKey, claim string) error {
+	if existing, ok := r[key]; !ok || existing.Claim != claim || existing.Response != nil {
+		return fmt.Errorf("Codefly idempotency claim of operation %q is no longer held", key.OperationID)
+	}
+	return nil
+}
+
+func (r idempotencyRecords) sweep(now time.Time) {
+	for key, record := range r {
+		if !record.live(now) {
+			delete(r, key)
+		}
+	}
+}
+
+// IdempotencyMemoryStore keeps idempotency records in memory, for a single
+// replica or tests. It holds at most 100,000 live records; when full, the
+// completed records closest to expiry are evicted to make room.
+type IdempotencyMemoryStore struct {
+	mu       sync.Mutex
+	records  idempotencyRecords
+	capacity int
+}
+
+// NewIdempotencyMemoryStore returns an empty in-memory store.
+func NewIdempotencyMemoryStore() *IdempotencyMemoryStore {
+	return &IdempotencyMemoryStore{records: make(idempotencyRecords), capacity: maxIdempotencyEntries}
+}
+
+func (s *IdempotencyMemoryStore) ClaimIdempotencyKey(
+	key IdempotencyKey,
+	pending IdempotencyRecord,
+	now time.Time,
+) (IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, claimed, err := s.records.claim(key, pending, now, s.capacity)
+	if claimed {
+		s.records[key] = record
+	}
+	return record, claimed, err
+}
+
+func (s *IdempotencyMemoryStore) CompleteIdempotencyKey(
+	key IdempotencyKey,
+	claim string,
+	response IdempotencyResponse,
+	expiresAt time.Time,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.records.pending(key, claim); err != nil {
+		return err
+	}
+	record := s.records[key]
+	record.Response, record.ExpiresAt = &response, expiresAt
+	s.records[key] = record
+	return nil
+}
+
+func (s *IdempotencyMemoryStore) ReleaseIdempotencyKey(key IdempotencyKey, claim string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.records.pending(key, claim); err != nil {
+		return err
+	}
+	delete(s.records, key)
+	return nil
+}
+
+// idempotencyFileEntry is one line of an idempotency file: the new state of a
+// key, or its deletion.
+type idempotencyFileEntry struct {
+	Key      IdempotencyKey     `json:"key"`
+	Record   *IdempotencyRecord `json:"record,omitempty"`
+	Released bool               `json:"released,omitempty"`
+}
+
+// IdempotencyFile is an embedded idempotency store: an append-only JSON-lines
+// file indexed in memory, so a restarted replica still replays the responses
+// of its earlier life. Expired records are dropped when the file is opened and
+// whenever it is compacted. It evicts records like IdempotencyMemoryStore. A
+// file must have a single writer process at a time.
+type IdempotencyFile struct {
+	mu       sync.Mutex
+	path     string
+	file     *os.File
+	size     int64
+	records  idempotencyRecords
+	sizes    map[IdempotencyKey]int64
+	capacity int
+}
+
+// OpenIdempotencyFile opens or creates an idempotency file, with the same
+// file-safety rules as OpenWorkContextLineageFile. Records expired at now are
+// discarded.
+func OpenIdempotencyFile(path string, now time.Time) (*IdempotencyFile, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return nil, errors.New("Codefly idempotency file path is required")
+	}
+	if _, err := statSafeFile(path, "Codefly idempotency file", maxIdempotencyFileBytes, 0o077); err != nil &&
+		!errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open Codefly idempotency file: %w", err)
+	}
+	info, err := statSafeFile(path, "Codefly idempotency file", maxIdempotencyFileBytes, 0o077)
+	if err == nil {
+		var opened os.FileInfo
+		if opened, err = file.Stat(); err == nil && !os.SameFile(info, opened) {
+			err = errors.New("Codefly idempotency file changed while it was opened")
+		}
+	}
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	store := &IdempotencyFile{
+		path:     path,
+		file:     file,
+		records:  make(idempotencyRecords),
+		sizes:    make(map[IdempotencyKey]int64),
+		capacity: maxIdempotencyEntries,
+	}
+	if err := store.load(now); err != nil {
+		_ = store.file.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+func (s *IdempotencyFile) load(now time.Time) error {
+	reader := bufio.NewReaderSize(s.file, maxIdempotencyRecordBytes)
+	var offset int64
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := reader.ReadSlice('\n')
+		if errors.Is(err, bufio.ErrBufferFull) {
+			return fmt.Errorf("Codefly idempotency file line %d exceeds %d bytes", lineNumber, reader.Size())
+		}
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				// An entry is only complete once its newline is written.
+				if truncateErr := s.file.Truncate(offset); truncateErr != nil {
+					return fmt.Errorf("discard partial Codefly idempotency entry: %w", truncateErr)
+				}
+			}
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read Codefly idempotency file: %w", err)
+		}
+		offset += int64(len(line))
+		var entry idempotencyFileEntry
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&entry); err != nil {
+			return fmt.Errorf("invalid Codefly idempotency entry at line %d: %w", lineNumber, err)
+		}
+		s.apply(entry, int64(len(line)))
+	}
+	if _, err := s.file.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("seek Codefly idempotency file: %w", err)
+	}
+	s.size = offset
+	s.sweep(now)
+	return s.compact()
+}
+
+func (s *IdempotencyFile) apply(entry idempotencyFileEntry, size int64) {
+	if entry.Released || entry.Record == nil {
+		delete(s.records, entry.Key)
+		delete(s.sizes, entry.Key)
+		return
+	}
+	s.records[entry.Key] = *entry.Record
+	s.sizes[entry.Key] = size
+}
+
+func (s *IdempotencyFile) ClaimIdempotencyKey(
+	key IdempotencyKey,
+	pending IdempotencyRecord,
+	now time.Time,
+) (IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	held := len(s.records)
+	record, claimed, err := s.records.claim(key, pending, now, s.capacity)
+	if len(s.records) < held {
+		// The claim swept or evicted records; rewrite the file without them
+		// so that they do not come back when it is reopened. A failed
+		// compaction only postpones that.
+		s.forgetRemoved()
+		_ = s.compact()
+	}
+	if !claimed || err != nil {
+		return record, false, err
+	}
+	if err := s.append(idempotencyFileEntry{Key: key, Record: &record}, now); err != nil {
+		return IdempotencyRecord{}, false, err
+	}
+	return record, true, nil
+}
+
+func (s *IdempotencyFile) CompleteIdempotencyKey(
+	key IdempotencyKey,
+	claim string,
+	response IdempotencyResponse,
+	expiresAt time.Time,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.records.pending(key, claim); err != nil {
+		return err
+	}
+	record := s.records[key]
+	record.Response, record.ExpiresAt = &response, expiresAt
+	return s.append(idempotencyFileEntry{Key: key, Record: &record}, time.Time{})
+}
+
+func (s *IdempotencyFile) ReleaseIdempotencyKey(key IdempotencyKey, claim string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.records.pending(key, claim); err != nil {
+		return err
+	}
+	return s.append(idempotencyFileEntry{Key: key, Released: true}, time.Time{})
+}
+
+// append writes entry and applies it. A non-zero now also drops expired
+// records when the file is due for compaction.
+func (s *IdempotencyFile) append(entry idempotencyFileEntry, now time.Time) error {
+	if s.file == nil {
+		return errors.New("Codefly idempotency file is closed")
+	}
+	encoded, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("encode Codefly idempotency entry: %w", err)
+	}
+	encoded = append(encoded, '\n')
+	if len(encoded) > maxIdempotencyRecordBytes {
+		return fmt.Errorf("Codefly idempotency entry exceeds %d bytes", maxIdempotencyRecordBytes)
+	}
+	if s.size+int64(len(encoded)) > maxIdempotencyFileBytes {
+		return fmt.Errorf("Codefly idempotency file exceeds %d bytes", maxIdempotencyFileBytes)
+	}
+	if _, err := s.file.Write(encoded); err != nil {
+		if truncateErr := s.file.Truncate(s.size); truncateErr == nil {
+			_, _ = s.file.Seek(s.size, io.SeekStart)
+		}
+		return fmt.Errorf("append Codefly idempotency entry: %w", err)
+	}
+	s.size += int64(len(encoded))
+	s.apply(entry, int64(len(encoded)))
+	if now.IsZero() || s.size < minIdempotencyCompactionBytes || s.size < idempotencyCompactionRatio*s.liveBytes() {
+		return nil
+	}
+	s.sweep(now)
+	// The entry is already durable in the current file; a failed compaction
+	// only postpones reclaiming space.
+	_ = s.compact()
+	return nil
+}
+
+func (s *IdempotencyFile) sweep(now time.Time) {
+	s.records.sweep(now)
+	s.forgetRemoved()
+}
+
+// forgetRemoved drops the sizes of records no longer held.
+func (s *IdempotencyFile) forgetRemoved() {
+	for key := range s.sizes {
+		if _, ok := s.records[key]; !ok {
+			delete(s.sizes, key)
+		}
+	}
+}
+
+func (s *IdempotencyFile) liveBytes() int64 {
+	var live int64
+	for _, size := range s.sizes {
+		live += size
+	}
+	return live
+}
+
+// compact rewrites the live records to a new file and renames it over the
+// old one, so a crash leaves either file intact.
+func (s *IdempotencyFile) compact() error {
+	if s.size == s.liveBytes() {
+		return nil
+	}
+	temporary, err := os.CreateTemp(filepath.Dir(s.path), ".codefly-idempotency-*")
+	if err != nil {
+		return fmt.Errorf("compact Codefly idempotency file: %w", err)
+	}
+	writer := bufio.NewWriter(temporary)
+	sizes := make(map[IdempotencyKey]int64, len(s.records))
+	var size int64
+	for key, record := range s.records {
+		encoded, err := json.Marshal(idempotencyFileEntry{Key: key, Record: &record})
+		if err == nil {
+			encoded = append(encoded, '\n')
+			_, err = writer.Write(encoded)
+		}
+		if err != nil {
+			_ = temporary.Close()
+			_ = os.Remove(temporary.Name())
+			return fmt.Errorf("compact Codefly idempotency file: %w", err)
+		}
+		sizes[key] = int64(len(encoded))
+		size += int64(len(encoded))
+	}
+	err = writer.Flush()
+	if err == nil {
+		err = temporary.Sync()
+	}
+	if err == nil {
+		err = os.Rename(temporary.Name(), s.path)
+	}
+	if err != nil {
+		_ = temporary.Close()
+		_ = os.Remove(temporary.Name())
+		return fmt.Errorf("compact Codefly idempotency file: %w", err)
+	}
+	_ = s.file.Close()
+	s.file, s.size, s.sizes = temporary, size, sizes
+	return nil
+}
+
+// Close flushes the file to stable storage and closes it.
+func (s *IdempotencyFile) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	syncErr := s.file.Sync()
+	closeErr := s.file.Close()
+	s.file = nil
+	return errors.Join(syncErr, closeErr)
+}
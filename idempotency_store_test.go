@@ -0,0 +1,205 @@
+package codefly
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func idempotencyTestKey(operationID string) IdempotencyKey {
+	return IdempotencyKey{Principal: "tenant-1/principal-1", Method: "/warden.v1.Tasks/Start", OperationID: operationID}
+}
+
+func idempotencyTestPending(claim string, now time.Time) IdempotencyRecord {
+	return IdempotencyRecord{Fingerprint: "fingerprint-1", Claim: claim, ExpiresAt: now.Add(time.Minute)}
+}
+
+func testIdempotencyStoreLifecycle(t *testing.T, store IdempotencyStore) {
+	t.Helper()
+	now := workContextTestTime
+	key := idempotencyTestKey("operation-1")
+
+	record, claimed, err := store.ClaimIdempotencyKey(key, idempotencyTestPending("claim-a", now), now)
+	require.NoError(t, err)
+	require.True(t, claimed)
+	require.Equal(t, "claim-a", record.Claim)
+
+	record, claimed, err = store.ClaimIdempotencyKey(key, idempotencyTestPending("claim-b", now), now)
+	require.NoError(t, err)
+	require.False(t, claimed)
+	require.Equal(t, "claim-a", record.Claim)
+	require.Nil(t, record.Response)
+
+	require.ErrorContains(t, store.CompleteIdempotencyKey(key, "claim-b", IdempotencyResponse{}, now.Add(time.Hour)), "no longer held")
+	require.NoError(t, store.CompleteIdempotencyKey(key, "claim-a", IdempotencyResponse{Code: 201, Body: []byte("done")}, now.Add(time.Hour)))
+	require.Error(t, store.ReleaseIdempotencyKey(key, "claim-a"), "a completed record cannot be released")
+
+	record, claimed, err = store.ClaimIdempotencyKey(key, idempotencyTestPending("claim-c", now), now.Add(30*time.Minute))
+	require.NoError(t, err)
+	require.False(t, claimed)
+	require.Equal(t, &IdempotencyResponse{Code: 201, Body: []byte("done")}, record.Response)
+
+	later := now.Add(2 * time.Hour)
+	_, claimed, err = store.ClaimIdempotencyKey(key, idempotencyTestPending("claim-d", later), later)
+	require.NoError(t, err)
+	require.True(t, claimed, "an expired response is claimed again")
+	require.NoError(t, store.ReleaseIdempotencyKey(key, "claim-d"))
+	_, claimed, err = store.ClaimIdempotencyKey(key, idempotencyTestPending("claim-e", later), later)
+	require.NoError(t, err)
+	require.True(t, claimed, "a released claim frees the key")
+
+	_, _, err = store.ClaimIdempotencyKey(idempotencyTestKey("operation-2"), IdempotencyRecord{Claim: "claim-f"}, now)
+	require.Error(t, err, "a claim needs a live lease")
+}
+
+func TestIdempotencyMemoryStore(t *testing.T) {
+	testIdempotencyStoreLifecycle(t, NewIdempotencyMemoryStore())
+}
+
+func TestIdempotencyMemoryStoreClaimsAtomically(t *testing.T) {
+	store := NewIdempotencyMemoryStore()
+	now := workContextTestTime
+	var claims sync.Map
+	var wg sync.WaitGroup
+	for index := range 32 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, claimed, err := store.ClaimIdempotencyKey(idempotencyTestKey("operation-1"), idempotencyTestPending(string(rune('a'+index)), now), now)
+			if err == nil && claimed {
+				claims.Store(index, true)
+			}
+		}()
+	}
+	wg.Wait()
+	count := 0
+	claims.Range(func(_, _ any) bool { count++; return true })
+	require.Equal(t, 1, count)
+}
+
+func TestIdempotencyFileStore(t *testing.T) {
+	store, err := OpenIdempotencyFile(filepath.Join(t.TempDir(), "idempotency.jsonl"), workContextTestTime)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, store.Close()) })
+	testIdempotencyStoreLifecycle(t, store)
+}
+
+func TestIdempotencyFileStoreSurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "idempotency.jsonl")
+	now := workContextTestTime
+	store, err := OpenIdempotencyFile(path, now)
+	require.NoError(t, err)
+	for _, operationID := range []string{"operation-kept", "operation-expiring", "operation-released"} {
+		_, claimed, err := store.ClaimIdempotencyKey(idempotencyTestKey(operationID), idempotencyTestPending("claim", now), now)
+		require.NoError(t, err)
+		require.True(t, claimed)
+	}
+	require.NoError(t, store.CompleteIdempotencyKey(idempotencyTestKey("operation-kept"), "claim",
+		IdempotencyResponse{Code: 200, Header: map[string][]string{"Content-Type": {"application/json"}}, Body: []byte(`{}`)},
+		now.Add(24*time.Hour)))
+	require.NoError(t, store.ReleaseIdempotencyKey(idempotencyTestKey("operation-released"), "claim"))
+	require.NoError(t, store.Close())
+
+	// A crash during a write leaves a partial final line behind.
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"key":{"principal":"`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	reopened, err := OpenIdempotencyFile(path, now.Add(time.Hour))
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, reopened.Close()) })
+	require.Len(t, reopened.records, 1, "expired and released records are dropped on open")
+	record, claimed, err := reopened.ClaimIdempotencyKey(idempotencyTestKey("operation-kept"), idempotencyTestPending("other", now.Add(time.Hour)), now.Add(time.Hour))
+	require.NoError(t, err)
+	require.False(t, claimed)
+	require.Equal(t, "application/json", record.Response.Header.Get("Content-Type"))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	require.Equal(t, reopened.liveBytes(), info.Size(), "the file is compacted to its live records")
+}
+
+// idempotencyTestCapacity keeps stores small enough to fill quickly.
+const idempotencyTestCapacity = 200
+
+func fillIdempotencyStore(t *testing.T, store IdempotencyStore, now time.Time, complete bool) {
+	t.Helper()
+	for index := range idempotencyTestCapacity {
+		key := idempotencyTestKey("filled-" + strconv.Itoa(index))
+		// Expiries one second apart order the records for eviction.
+		pending := idempotencyTestPending("claim", now)
+		pending.ExpiresAt = now.Add(time.Hour + time.Duration(index)*time.Second)
+		_, claimed, err := store.ClaimIdempotencyKey(key, pending, now)
+		require.NoError(t, err)
+		require.True(t, claimed)
+		if complete {
+			require.NoError(t, store.CompleteIdempotencyKey(key, "claim", IdempotencyResponse{Code: 200}, pending.ExpiresAt))
+		}
+	}
+}
+
+func TestIdempotencyMemoryStoreEvictsCompletedRecordsWhenFull(t *testing.T) {
+	now := workContextTestTime
+	store := NewIdempotencyMemoryStore()
+	store.capacity = idempotencyTestCapacity
+	fillIdempotencyStore(t, store, now, true)
+
+	_, claimed, err := store.ClaimIdempotencyKey(idempotencyTestKey("operation-new"), idempotencyTestPending("claim", now), now)
+	require.NoError(t, err)
+	require.True(t, claimed)
+	require.Len(t, store.records, idempotencyTestCapacity-1, "a hundredth of the records is evicted")
+	require.NotContains(t, store.records, idempotencyTestKey("filled-0"), "the records closest to expiry are evicted")
+	require.NotContains(t, store.records, idempotencyTestKey("filled-1"))
+	require.Contains(t, store.records, idempotencyTestKey("filled-2"))
+
+	pending := NewIdempotencyMemoryStore()
+	pending.capacity = idempotencyTestCapacity
+	fillIdempotencyStore(t, pending, now, false)
+	_, _, err = pending.ClaimIdempotencyKey(idempotencyTestKey("operation-new"), idempotencyTestPending("claim", now), now)
+	require.ErrorContains(t, err, "pending entries", "running attempts are never evicted")
+}
+
+func TestIdempotencyFileStoreEvictsCompletedRecordsWhenFull(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "idempotency.jsonl")
+	now := workContextTestTime
+	store, err := OpenIdempotencyFile(path, now)
+	require.NoError(t, err)
+	store.capacity = idempotencyTestCapacity
+	fillIdempotencyStore(t, store, now, true)
+
+	_, claimed, err := store.ClaimIdempotencyKey(idempotencyTestKey("operation-new"), idempotencyTestPending("claim", now), now)
+	require.NoError(t, err)
+	require.True(t, claimed)
+	require.NoError(t, store.Close())
+
+	reopened, err := OpenIdempotencyFile(path, now)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, reopened.Close()) })
+	require.Len(t, reopened.records, idempotencyTestCapacity-1, "evicted records stay evicted")
+	require.NotContains(t, reopened.records, idempotencyTestKey("filled-0"))
+	require.Contains(t, reopened.records, idempotencyTestKey("operation-new"))
+}
+
+func TestIdempotencyFileStoreRejectsUnsafeFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "idempotency.jsonl")
+	require.NoError(t, os.WriteFile(path, nil, 0o644))
+	_, err := OpenIdempotencyFile(path, workContextTestTime)
+	require.Error(t, err)
+
+	path = filepath.Join(dir, "malformed.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte("not json\n"), 0o600))
+	_, err = OpenIdempotencyFile(path, workContextTestTime)
+	require.ErrorContains(t, err, "invalid Codefly idempotency entry at line 1")
+
+	_, err = OpenIdempotencyFile(" ", workContextTestTime)
+	require.Error(t, err)
+}
@@ -0,0 +1,152 @@
+package codefly
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	basev0 "github.com/codefly-dev/core/generated/go/codefly/base/v0"
+	"github.com/stretchr/testify/require"
+)
+
+func newIdempotencyForTest(t *testing.T, edit func(*IdempotencyOptions)) *Idempotency {
+	t.Helper()
+	options := IdempotencyOptions{
+		Store: NewIdempotencyMemoryStore(),
+		Principal: func(_ context.Context, execution ExecutionContext) (string, error) {
+			return "tenant-1/principal-1", nil
+		},
+		PollInterval: time.Millisecond,
+	}
+	if edit != nil {
+		edit(&options)
+	}
+	idempotency, err := NewIdempotency(options)
+	require.NoError(t, err)
+	return idempotency
+}
+
+func TestNewIdempotencyValidatesOptions(t *testing.T) {
+	principal := func(context.Context, ExecutionContext) (string, error) { return "", nil }
+	_, err := NewIdempotency(IdempotencyOptions{Principal: principal})
+	require.ErrorContains(t, err, "store is required")
+	_, err = NewIdempotency(IdempotencyOptions{Store: NewIdempotencyMemoryStore()})
+	require.ErrorContains(t, err, "principal function is required")
+	_, err = NewIdempotency(IdempotencyOptions{Store: NewIdempotencyMemoryStore(), Principal: principal, Lease: -time.Second})
+	require.Error(t, err)
+
+	idempotency, err := NewIdempotency(IdempotencyOptions{Store: NewIdempotencyMemoryStore(), Principal: principal})
+	require.NoError(t, err)
+	require.Equal(t, defaultIdempotencyTTL, idempotency.ttl)
+	require.Equal(t, defaultIdempotencyLease, idempotency.lease)
+	require.Equal(t, defaultIdempotencyMaxBodyBytes, idempotency.maxBodyBytes)
+}
+
+func TestWorkContextIdempotencyPrincipalIncludesActingPrincipal(t *testing.T) {
+	claims := &basev0.WorkContextV1{TenantId: "tenant-1", OwnerPrincipalId: "principal-1"}
+	require.Equal(t, "8:tenant-1/11:principal-1", WorkContextIdempotencyPrincipal(claims))
+	claims.ActorChain = []*basev0.WorkActorV1{{PrincipalId: "agent-1"}, {PrincipalId: "tool-1"}}
+	require.Equal(t, "8:tenant-1/11:principal-1/6:tool-1", WorkContextIdempotencyPrincipal(claims))
+}
+
+func TestWorkContextIdempotencyPrincipalDoesNotCollide(t *testing.T) {
+	distinct := []*basev0.WorkContextV1{
+		{TenantId: "t", OwnerPrincipalId: "user/alice"},
+		{TenantId: "t", OwnerPrincipalId: "user", ActorChain: []*basev0.WorkActorV1{{PrincipalId: "alice"}}},
+		{TenantId: "t/user", OwnerPrincipalId: "alice"},
+		{TenantId: "t", OwnerPrincipalId: "user/1:a"},
+		{TenantId: "t", OwnerPrincipalId: "user", ActorChain: []*basev0.WorkActorV1{{PrincipalId: "a"}}},
+	}
+	seen := make(map[string]int)
+	for index, claims := range distinct {
+		principal := WorkContextIdempotencyPrincipal(claims)
+		previous, collides := seen[principal]
+		require.False(t, collides, "claims %d and %d share principal %q", previous, index, principal)
+		seen[principal] = index
+	}
+}
+
+func TestIdempotencyDuplicatesWaitForTheFirstAttempt(t *testing.T) {
+	idempotency := newIdempotencyForTest(t, nil)
+	key := idempotencyTestKey("operation-1")
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	var runs sync.WaitGroup
+	runs.Add(1)
+	go func() {
+		defer runs.Done()
+		replay, err := idempotency.execute(context.Background(), key, "fingerprint", func() *IdempotencyResponse {
+			close(started)
+			<-finish
+			return &IdempotencyResponse{Code: 201}
+		})
+		require.NoError(t, err)
+		require.Nil(t, replay)
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := idempotency.execute(ctx, key, "fingerprint", func() *IdempotencyResponse {
+		t.Fatal("a duplicate must not run the handler")
+		return nil
+	})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	close(finish)
+	replay, err := idempotency.execute(context.Background(), key, "fingerprint", func() *IdempotencyResponse {
+		t.Fatal("a duplicate must not run the handler")
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 201, replay.Code)
+	runs.Wait()
+
+	_, err = idempotency.execute(context.Background(), key, "other-fingerprint", nil)
+	require.ErrorIs(t, err, ErrIdempotencyMismatch)
+}
+
+func TestIdempotencyCanRejectInFlightDuplicates(t *testing.T) {
+	idempotency := newIdempotencyForTest(t, func(options *IdempotencyOptions) {
+		options.RejectInFlightDuplicates = true
+	})
+	key := idempotencyTestKey("operation-1")
+	_, err := idempotency.execute(context.Background(), key, "fingerprint", func() *IdempotencyResponse {
+		_, err := idempotency.execute(context.Background(), key, "fingerprint", nil)
+		require.ErrorIs(t, err, ErrIdempotencyInProgress)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestIdempotencyReleasesUnstoredAndPanickingAttempts(t *testing.T) {
+	idempotency := newIdempotencyForTest(t, nil)
+	key := idempotencyTestKey("operation-1")
+	runs := 0
+	for range 2 {
+		_, err := idempotency.execute(context.Background(), key, "fingerprint", func() *IdempotencyResponse {
+			runs++
+			return nil
+		})
+		require.NoError(t, err)
+	}
+	require.Equal(t, 2, runs, "an unstored result runs again")
+
+	require.Panics(t, func() {
+		_, _ = idempotency.execute(context.Background(), key, "fingerprint", func() *IdempotencyResponse {
+			panic("handler bug")
+		})
+	})
+	_, err := idempotency.execute(context.Background(), key, "fingerprint", func() *IdempotencyResponse {
+		runs++
+		return &IdempotencyResponse{Code: 200}
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, runs, "a panic releases the claim")
+}
+
+func TestIdempotencyFingerprintSeparatesParts(t *testing.T) {
+	require.Equal(t, idempotencyFingerprint([]byte("a"), []byte("b")), idempotencyFingerprint([]byte("a"), []byte("b")))
+	require.NotEqual(t, idempotencyFingerprint([]byte("ab"), nil), idempotencyFingerprint([]byte("a"), []byte("b")))
+}
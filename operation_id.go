@@ -0,0 +1,163 @@
+package codefly
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// operationIDLength is the Crockford base32 length of a 48-bit
+	// millisecond timestamp followed by 80 bits of entropy, as in ULID.
+	operationIDLength        = 26
+	operationIDTimeLength    = 10
+	operationIDEntropyBytes  = 10
+	operationIDAlphabet      = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+	maxOperationIDMillis     = 1<<48 - 1
+	maxOperationStepBytes    = 256
+	operationIDDerivationTag = "codefly.operation-id.v1"
+)
+
+// ErrOperationIDUntimed reports an operation ID that does not carry a
+// creation time: one chosen by the caller rather than NewOperationID, or
+// derived from such an ID.
+var ErrOperationIDUntimed = errors.New("Codefly operation ID carries no creation time")
+
+var defaultOperationIDGenerator = &operationIDGenerator{now: time.Now}
+
+// NewOperationID returns a new ULID-style operation ID: 26 Crockford base32
+// characters, a millisecond timestamp followed by random entropy. IDs sort by
+// creation time, and IDs created by one process are strictly increasing even
+// within a millisecond.
+func NewOperationID() string {
+	return defaultOperationIDGenerator.next()
+}
+
+// DeriveOperationID returns the operation ID of one step of parent. The same
+// parent and step always give the same ID, so a retried fan-out reaches
+// downstream services with the IDs of the first attempt, and their
+// idempotency still applies. The derived ID keeps the creation time of a
+// timed parent and is itself a valid parent.
+func DeriveOperationID(parent, step string) (string, error) {
+	if err := validateOperationID(parent); err != nil {
+		return "", err
+	}
+	if err := validateBounded("operation step", step, maxOperationStepBytes, true); err != nil {
+		return "", err
+	}
+	var millis uint64
+	if created, err := OperationIDTime(parent); err == nil {
+		millis = uint64(created.UnixMilli())
+	}
+	// Operation IDs cannot contain a newline, so no other parent/step pair
+	// hashes the same input.
+	digest := sha256.Sum256([]byte(operationIDDerivationTag + "\n" + parent + "\n" + step))
+	var entropy [operationIDEntropyBytes]byte
+	copy(entropy[:], digest[:])
+	return encodeOperationID(millis, entropy), nil
+}
+
+// OperationIDTime returns the creation time carried by an ID from
+// NewOperationID or DeriveOperationID, for logs. Any other valid operation ID
+// returns ErrOperationIDUntimed.
+func OperationIDTime(operationID string) (time.Time, error) {
+	if err := validateOperationID(operationID); err != nil {
+		return time.Time{}, err
+	}
+	millis, ok := decodeOperationIDTime(operationID)
+	if !ok || millis == 0 {
+		return time.Time{}, ErrOperationIDUntimed
+	}
+	return time.UnixMilli(int64(millis)).UTC(), nil
+}
+
+type operationIDGenerator struct {
+	now func() time.Time
+
+	mu          sync.Mutex
+	lastMillis  uint64
+	lastEntropy [operationIDEntropyBytes]byte
+}
+
+func (g *operationIDGenerator) next() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	millis := uint64(max(g.now().UnixMilli(), 1))
+	if millis <= g.lastMillis && incrementOperationIDEntropy(&g.lastEntropy) {
+		// A clock that stalls or steps back keeps the previous timestamp so
+		// the order of IDs is never inverted.
+		return encodeOperationID(g.lastMillis, g.lastEntropy)
+	}
+	if millis <= g.lastMillis {
+		// 2^80 IDs in one millisecond exhausted its entropy.
+		millis = g.lastMillis + 1
+	}
+	// crypto/rand.Read never returns an error; it aborts the process when
+	// the system source fails.
+	_, _ = rand.Read(g.lastEntropy[:])
+	g.lastMillis = millis
+	return encodeOperationID(millis, g.lastEntropy)
+}
+
+// incrementOperationIDEntropy adds one to entropy as a big-endian integer and
+// reports whether it did so without overflowing.
+func incrementOperationIDEntropy(entropy *[operationIDEntropyBytes]byte) bool {
+	for index := len(entropy) - 1; index >= 0; index-- {
+		entropy[index]++
+		if entropy[index] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func encodeOperationID(millis uint64, entropy [operationIDEntropyBytes]byte) string {
+	millis = min(millis, maxOperationIDMillis)
+	var encoded [operationIDLength]byte
+	for index := operationIDTimeLength - 1; index >= 0; index-- {
+		encoded[index] = operationIDAlphabet[millis&31]
+		millis >>= 5
+	}
+	// 80 bits of entropy are exactly 16 base32 characters.
+	var buffer uint64
+	bits := 0
+	position := operationIDTimeLength
+	for _, value := range entropy {
+		buffer = buffer<<8 | uint64(value)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			encoded[position] = operationIDAlphabet[(buffer>>bits)&31]
+			position++
+		}
+	}
+	return string(encoded[:])
+}
+
+func decodeOperationIDTime(operationID string) (uint64, bool) {
+	if len(operationID) != operationIDLength {
+		return 0, false
+	}
+	for _, character := range operationID[operationIDTimeLength:] {
+		if !strings.ContainsRune(operationIDAlphabet, character) {
+			return 0, false
+		}
+	}
+	// The first character carries only 3 of the 50 timestamp bits, so
+	// anything above '7' is not a timestamp.
+	if operationID[0] > '7' {
+		return 0, false
+	}
+	var millis uint64
+	for index := range operationIDTimeLength {
+		value := strings.IndexByte(operationIDAlphabet, operationID[index])
+		if value < 0 {
+			return 0, false
+		}
+		millis = millis<<5 | uint64(value)
+	}
+	return millis, true
+}
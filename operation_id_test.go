@@ -0,0 +1,109 @@
+package codefly
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewOperationIDIsSortableAndTimed(t *testing.T) {
+	before := time.Now().UTC().Truncate(time.Millisecond)
+	ids := make([]string, 1000)
+	for index := range ids {
+		ids[index] = NewOperationID()
+		require.Len(t, ids[index], operationIDLength)
+		require.NoError(t, validateOperationID(ids[index]))
+	}
+	after := time.Now().UTC()
+	require.True(t, sort.StringsAreSorted(ids), "IDs from one process are strictly increasing")
+	for index := 1; index < len(ids); index++ {
+		require.NotEqual(t, ids[index-1], ids[index])
+	}
+
+	created, err := OperationIDTime(ids[0])
+	require.NoError(t, err)
+	require.False(t, created.Before(before))
+	require.False(t, created.After(after))
+	require.Equal(t, time.UTC, created.Location())
+}
+
+func TestOperationIDGeneratorNeverInvertsOrder(t *testing.T) {
+	now := workContextTestTime
+	generator := &operationIDGenerator{now: func() time.Time { return now }}
+	first := generator.next()
+	second := generator.next()
+	require.Less(t, first, second, "same millisecond increments the entropy")
+	require.Equal(t, first[:operationIDTimeLength], second[:operationIDTimeLength])
+
+	now = now.Add(-time.Hour)
+	third := generator.next()
+	require.Less(t, second, third, "a clock stepping back keeps the previous timestamp")
+	created, err := OperationIDTime(third)
+	require.NoError(t, err)
+	require.Equal(t, workContextTestTime, created)
+
+	for index := range generator.lastEntropy {
+		generator.lastEntropy[index] = 0xff
+	}
+	fourth := generator.next()
+	require.Less(t, third, fourth, "exhausted entropy moves to the next millisecond")
+	created, err = OperationIDTime(fourth)
+	require.NoError(t, err)
+	require.Equal(t, workContextTestTime.Add(time.Millisecond), created)
+}
+
+func TestDeriveOperationIDIsDeterministic(t *testing.T) {
+	parent := encodeOperationID(uint64(workContextTestTime.UnixMilli()), [operationIDEntropyBytes]byte{1, 2, 3})
+	first, err := DeriveOperationID(parent, "charge-card")
+	require.NoError(t, err)
+	again, err := DeriveOperationID(parent, "charge-card")
+	require.NoError(t, err)
+	require.Equal(t, first, again)
+	require.Len(t, first, operationIDLength)
+
+	other, err := DeriveOperationID(parent, "send-receipt")
+	require.NoError(t, err)
+	require.NotEqual(t, first, other)
+	created, err := OperationIDTime(first)
+	require.NoError(t, err)
+	require.Equal(t, workContextTestTime, created, "derived IDs keep the parent creation time")
+
+	grandchild, err := DeriveOperationID(first, "retry-1")
+	require.NoError(t, err)
+	require.NotEqual(t, first, grandchild)
+	require.NoError(t, validateOperationID(grandchild))
+}
+
+func TestDeriveOperationIDFromCallerChosenParent(t *testing.T) {
+	derived, err := DeriveOperationID("operation-019f8fc1", "step-1")
+	require.NoError(t, err)
+	require.NoError(t, validateOperationID(derived))
+	_, err = OperationIDTime(derived)
+	require.ErrorIs(t, err, ErrOperationIDUntimed)
+
+	_, err = DeriveOperationID("", "step-1")
+	require.ErrorIs(t, err, ErrWorkContextInvalid)
+	_, err = DeriveOperationID("operation-1", " ")
+	require.ErrorContains(t, err, "operation step is required")
+	_, err = DeriveOperationID("operation-1", strings.Repeat("x", maxOperationStepBytes+1))
+	require.ErrorContains(t, err, "operation step exceeds")
+}
+
+func TestOperationIDTimeRejectsUntimedIDs(t *testing.T) {
+	for _, operationID := range []string{
+		"operation-1",
+		"operation-019f8fc1-0000-00", // 26 bytes outside the alphabet
+		"8ZZZZZZZZZ0000000000000000", // timestamp overflows 48 bits
+		"0000000000ABCDEFGHJKMNPQRS", // zero timestamp
+		"01K0000000ABCDEFGHJKMNPQRi", // lowercase
+		"01K0000000ABCDEFGHJKMNPQRU", // U is not Crockford base32
+	} {
+		_, err := OperationIDTime(operationID)
+		require.ErrorIs(t, err, ErrOperationIDUntimed, operationID)
+	}
+	_, err := OperationIDTime("operation id")
+	require.ErrorIs(t, err, ErrWorkContextInvalid)
+}
@@ -26,24 +26,8 @@ func LoadRuntimeEnvironmentFile(path string) error {
 	if path == "" {
 		return errors.New("Codefly runtime environment file path is required")
 	}
-	info, err := os.Lstat(path)
-	if err != nil {
-		return fmt.Errorf("stat Codefly runtime environment file: %w", err)
-	}
-	if info.Mode()&os.ModeSymlink != 0 {
-		return errors.New("Codefly runtime environment file must not be a symbolic link")
-	}
-	if !info.Mode().IsRegular() {
-		return errors.New("Codefly runtime environment file must be a regular file")
-	}
-	if info.Size() > maxRuntimeEnvironmentFileBytes {
-		return fmt.Errorf(
-			"Codefly runtime environment file exceeds %d bytes",
-			maxRuntimeEnvironmentFileBytes,
-		)
-	}
-	if info.Mode().Perm()&0o077 != 0 {
-		return errors.New("Codefly runtime environment file must not be accessible by group or world")
+	if _, err := statSafeFile(path, "Codefly runtime environment file", maxRuntimeEnvironmentFileBytes, 0o077); err != nil {
+		return err
 	}
 
 	file, err := os.Open(path)
@@ -83,6 +67,63 @@ func LoadRuntimeEnvironmentFile(path string) error {
 	return nil
 }
 
+// statSafeFile applies the rules for files that carry secrets or trust
+// material: the path must name a regular file rather than a symbolic link, stay
+// within maxBytes, and grant none of the forbidden permission bits.
+func statSafeFile(path, description string, maxBytes int64, forbidden os.FileMode) (os.FileInfo, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, fmt.Errorf("stat %s: %w", description, err)
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		return nil, fmt.Errorf("%s must not be a symbolic link", description)
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s must be a regular file", description)
+	}
+	if info.Size() > maxBytes {
+		return nil, fmt.Errorf("%s exceeds %d bytes", description, maxBytes)
+	}
+	if info.Mode().Perm()&forbidden != 0 {
+		access := "accessible"
+		if forbidden&0o044 == 0 {
+			access = "writable"
+		}
+		return nil, fmt.Errorf("%s must not be %s by group or world", description, access)
+	}
+	return info, nil
+}
+
+// readSafeFile reads a file that passed statSafeFile. The opened file must be
+// the one that was checked, so a path swapped between the two steps is
+// rejected instead of read.
+func readSafeFile(path, description string, maxBytes int64, forbidden os.FileMode) ([]byte, os.FileInfo, error) {
+	info, err := statSafeFile(path, description, maxBytes, forbidden)
+	if err != nil {
+		return nil, nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open %s: %w", description, err)
+	}
+	defer file.Close()
+	opened, err := file.Stat()
+	if err != nil {
+		return nil, nil, fmt.Errorf("stat %s: %w", description, err)
+	}
+	if !os.SameFile(info, opened) {
+		return nil, nil, fmt.Errorf("%s changed while it was opened", description)
+	}
+	payload, err := io.ReadAll(io.LimitReader(file, maxBytes+1))
+	if err != nil {
+		return nil, nil, fmt.Errorf("read %s: %w", description, err)
+	}
+	if int64(len(payload)) > maxBytes {
+		return nil, nil, fmt.Errorf("%s exceeds %d bytes", description, maxBytes)
+	}
+	return payload, opened, nil
+}
+
 func validRuntimeEnvironmentName(name string) bool {
 	if !strings.HasPrefix(name, "CODEFLY__") || len(name) == len("CODEFLY__") {
 		return false
@@ -2,14 +2,17 @@ package codefly
 
 import (
 	"bytes"
+	"context"
 	"crypto/ed25519"
 	"crypto/rand"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -68,8 +71,11 @@ func (t WorkContextToken) empty() bool {
 // ParseWorkContextToken validates only the bounded two-segment wire shape. It
 // does not establish trust; call WorkContextVerifier.Verify before using claims.
 func ParseWorkContextToken(encoded string) (WorkContextToken, error) {
+	if encoded == "" {
+		return WorkContextToken{}, workContextFailure(WorkContextReasonMissing, fmt.Errorf("%w: empty token", ErrWorkContextInvalid))
+	}
 	if err := validateTokenShape(encoded); err != nil {
-		return WorkContextToken{}, err
+		return WorkContextToken{}, workContextFailure(WorkContextReasonMalformed, err)
 	}
 	return WorkContextToken{encoded: encoded}, nil
 }
@@ -90,7 +96,10 @@ func AttachWorkContext(request *http.Request, token WorkContextToken) error {
 // WorkContextFromHeaders extracts an opaque token. It does not verify it.
 func WorkContextFromHeaders(headers http.Header) (WorkContextToken, error) {
 	if headers == nil {
-		return WorkContextToken{}, fmt.Errorf("%w: missing HTTP headers", ErrWorkContextInvalid)
+		return WorkContextToken{}, workContextFailure(
+			WorkContextReasonMissing,
+			fmt.Errorf("%w: missing HTTP headers", ErrWorkContextInvalid),
+		)
 	}
 	return ParseWorkContextToken(headers.Get(WorkContextHeaderName))
 }
@@ -104,6 +113,8 @@ type WorkContextSigner struct {
 	privateKey ed25519.PrivateKey
 	now        func() time.Time
 	nonce      func() (string, error)
+	delegation *workContextDelegationPolicy
+	lineage    WorkContextLineageRecorder
 }
 
 type WorkContextSignerOptions struct {
@@ -112,6 +123,12 @@ type WorkContextSignerOptions struct {
 	PrivateKey ed25519.PrivateKey
 	Now        func() time.Time
 	Nonce      func() (string, error)
+	// DelegationPolicy constrains StartChildSession. Nil keeps the structural
+	// checks only.
+	DelegationPolicy *WorkContextDelegationPolicy
+	// LineageRecorder, when set, records every minted Work Context. See
+	// OpenWorkContextLineageFile for the embedded store.
+	LineageRecorder WorkContextLineageRecorder
 }
 
 func NewWorkContextSigner(options WorkContextSignerOptions) (*WorkContextSigner, error) {
@@ -132,12 +149,18 @@ func NewWorkContextSigner(options WorkContextSignerOptions) (*WorkContextSigner,
 	if nonce == nil {
 		nonce = randomWorkContextNonce
 	}
+	delegation, err := newWorkContextDelegationPolicy(options.DelegationPolicy)
+	if err != nil {
+		return nil, err
+	}
 	return &WorkContextSigner{
 		issuer:     options.Issuer,
 		keyID:      options.KeyID,
 		privateKey: append(ed25519.PrivateKey(nil), options.PrivateKey...),
 		now:        now,
 		nonce:      nonce,
+		delegation: delegation,
+		lineage:    options.LineageRecorder,
 	}, nil
 }
 
@@ -157,6 +180,9 @@ type StartTaskInput struct {
 	ProjectID             string
 	TTL                   time.Duration
 	NotBefore             time.Time
+	// CertificateThumbprint binds the capability to one mTLS client
+	// certificate. Use WorkContextCertificateThumbprint to compute it.
+	CertificateThumbprint string
 }
 
 // StartTask constructs and signs an immutable Task/root-Session context.
@@ -207,53 +233,86 @@ func (s *WorkContextSigner) StartTask(input StartTaskInput) (WorkContextToken, *
 	if input.ProjectID != "" {
 		context.ProjectId = stringPointer(input.ProjectID)
 	}
-	return s.sign(context)
+	return s.sign(context, input.CertificateThumbprint)
 }
 
 // StartRootSessionInput exchanges a valid capability for another root Session
 // under the same Task. Identity, owner, scopes, actors, and attribution cannot
-// be changed by the caller.
+// be changed by the caller. An empty CertificateThumbprint keeps the parent's
+// certificate binding.
 type StartRootSessionInput struct {
-	SessionID    string
-	Audience     string
-	ReplayPolicy string
-	TTL          time.Duration
+	SessionID             string
+	Audience              string
+	ReplayPolicy          string
+	TTL                   time.Duration
+	CertificateThumbprint string
+	// ParentCertificate is the mTLS client certificate of the caller that
+	// presented parent, from PeerCertificateFromGRPC or PeerCertificateFromHTTP.
+	// Rebinding a certificate-bound parent requires it to match the parent's
+	// binding; otherwise a stolen token could be exchanged for one bound to
+	// the thief's certificate.
+	ParentCertificate *x509.Certificate
 }
 
 func (s *WorkContextSigner) StartSession(parent WorkContextToken, input StartRootSessionInput) (WorkContextToken, *basev0.WorkContextV1, error) {
-	verified, err := s.verifyOwn(parent)
+	verified, binding, err := s.verifyOwn(parent)
+	if err != nil {
+		return WorkContextToken{}, nil, err
+	}
+	binding, err = rebindWorkContext(binding, input.CertificateThumbprint, input.ParentCertificate)
 	if err != nil {
 		return WorkContextToken{}, nil, err
 	}
 	next := cloneContext(verified)
 	next.SessionId = input.SessionID
 	next.ParentSessionId = nil
-	return s.exchange(next, input.Audience, input.ReplayPolicy, input.TTL)
+	return s.exchange(next, input.Audience, input.ReplayPolicy, input.TTL, binding)
 }
 
 // StartChildSessionInput appends exactly one verified Actor and creates a child
-// Session. The new actor's scopes must attenuate the parent's effective scope.
+// Session. The new actor's scopes must attenuate the parent's effective scope,
+// and the signer's DelegationPolicy, if any, must allow the step.
+// CertificateThumbprint rebinds the child to the new actor's certificate; when
+// empty the parent's binding is inherited.
 type StartChildSessionInput struct {
-	SessionID    string
-	Audience     string
-	Actor        *basev0.WorkActorV1
-	ReplayPolicy string
-	TTL          time.Duration
+	SessionID             string
+	Audience              string
+	Actor                 *basev0.WorkActorV1
+	ReplayPolicy          string
+	TTL                   time.Duration
+	CertificateThumbprint string
+	// ParentCertificate has the same meaning as on StartRootSessionInput.
+	ParentCertificate *x509.Certificate
 }
 
 func (s *WorkContextSigner) StartChildSession(parent WorkContextToken, input StartChildSessionInput) (WorkContextToken, *basev0.WorkContextV1, error) {
-	verified, err := s.verifyOwn(parent)
+	verified, binding, err := s.verifyOwn(parent)
 	if err != nil {
 		return WorkContextToken{}, nil, err
 	}
+	binding, err = rebindWorkContext(binding, input.CertificateThumbprint, input.ParentCertificate)
+	if err != nil {
+		return WorkContextToken{}, nil, err
+	}
+	ttl := input.TTL
+	if s.delegation != nil && input.Actor != nil {
+		audience := input.Audience
+		if audience == "" {
+			audience = verified.Audience
+		}
+		ttl, err = s.delegation.authorize(verified, audience, input.Actor, ttl, s.now().UTC().Truncate(time.Second))
+		if err != nil {
+			return WorkContextToken{}, nil, err
+		}
+	}
 	next := cloneContext(verified)
 	next.ParentSessionId = stringPointer(verified.SessionId)
 	next.SessionId = input.SessionID
 	next.ActorChain = append(next.ActorChain, cloneActor(input.Actor))
-	return s.exchange(next, input.Audience, input.ReplayPolicy, input.TTL)
+	return s.exchange(next, input.Audience, input.ReplayPolicy, ttl, binding)
 }
 
-func (s *WorkContextSigner) exchange(context *basev0.WorkContextV1, audience, replayPolicy string, ttl time.Duration) (WorkContextToken, *basev0.WorkContextV1, error) {
+func (s *WorkContextSigner) exchange(context *basev0.WorkContextV1, audience, replayPolicy string, ttl time.Duration, binding string) (WorkContextToken, *basev0.WorkContextV1, error) {
 	now := s.now().UTC().Truncate(time.Second)
 	if ttl == 0 {
 		ttl = WorkContextDefaultTTL
@@ -278,31 +337,58 @@ func (s *WorkContextSigner) exchange(context *basev0.WorkContextV1, audience, re
 	context.ExpiresAtUnix = now.Add(ttl).Unix()
 	context.Nonce = nonce
 	context.ReplayPolicy = replayPolicy
-	return s.sign(context)
+	return s.sign(context, binding)
 }
 
-func (s *WorkContextSigner) verifyOwn(token WorkContextToken) (*basev0.WorkContextV1, error) {
+// verifyOwn returns the parent's certificate binding alongside its claims. It
+// does not check proof of possession: a derived token that keeps the binding
+// is as useless without the certificate as its parent, and rebindWorkContext
+// demands the certificate before the binding can change.
+func (s *WorkContextSigner) verifyOwn(token WorkContextToken) (*basev0.WorkContextV1, string, error) {
 	publicKey, ok := s.privateKey.Public().(ed25519.PublicKey)
 	if !ok {
-		return nil, fmt.Errorf("%w: signer has no Ed25519 public key", ErrWorkContextInvalid)
+		return nil, "", fmt.Errorf("%w: signer has no Ed25519 public key", ErrWorkContextInvalid)
 	}
 	verifier, err := NewWorkContextVerifier(WorkContextVerifierOptions{
 		PublicKeys: map[string]ed25519.PublicKey{s.keyID: publicKey},
 		Now:        s.now,
 	})
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	return verifier.verify(token, WorkContextExpectations{Issuer: s.issuer})
+}
+
+// rebindWorkContext returns the binding of a token derived from a parent bound
+// to binding. Moving a bound parent to another certificate requires proof that
+// the caller holds the parent's certificate.
+func rebindWorkContext(binding, requested string, parentCertificate *x509.Certificate) (string, error) {
+	if requested == "" || requested == binding {
+		return binding, nil
+	}
+	if binding != "" {
+		if err := matchWorkContextCertificateBinding(binding, WorkContextExpectations{PeerCertificate: parentCertificate}); err != nil {
+			return "", workContextFailure(
+				WorkContextReasonCertificateBinding,
+				fmt.Errorf("rebind certificate-bound Work Context: %w", err),
+			)
+		}
 	}
-	return verifier.Verify(token, WorkContextExpectations{Issuer: s.issuer})
+	return requested, nil
 }
 
-func (s *WorkContextSigner) sign(context *basev0.WorkContextV1) (WorkContextToken, *basev0.WorkContextV1, error) {
+func (s *WorkContextSigner) sign(context *basev0.WorkContextV1, binding string) (WorkContextToken, *basev0.WorkContextV1, error) {
 	canonical := cloneContext(context)
 	canonicalizeWorkContext(canonical)
 	if err := validateWorkContext(canonical); err != nil {
 		return WorkContextToken{}, nil, err
 	}
-	payload, err := marshalWorkContext(canonical)
+	if binding != "" {
+		if err := validateWorkContextCertificateThumbprint(binding); err != nil {
+			return WorkContextToken{}, nil, err
+		}
+	}
+	payload, err := marshalWorkContext(canonical, binding)
 	if err != nil {
 		return WorkContextToken{}, nil, err
 	}
@@ -312,22 +398,52 @@ func (s *WorkContextSigner) sign(context *basev0.WorkContextV1) (WorkContextToke
 	if len(encoded) > WorkContextMaxTokenBytes {
 		return WorkContextToken{}, nil, fmt.Errorf("%w: token exceeds %d bytes", ErrWorkContextInvalid, WorkContextMaxTokenBytes)
 	}
+	if s.lineage != nil {
+		if err := s.lineage.RecordWorkContext(newWorkContextLineageRecord(canonical)); err != nil {
+			return WorkContextToken{}, nil, fmt.Errorf("record Work Context lineage: %w", err)
+		}
+	}
 	return WorkContextToken{encoded: encoded}, canonical, nil
 }
 
 type WorkContextVerifier struct {
-	publicKeys map[string]ed25519.PublicKey
-	now        func() time.Time
-	clockSkew  time.Duration
+	publicKeys  map[string]ed25519.PublicKey
+	now         func() time.Time
+	clockSkew   time.Duration
+	workspace   string
+	cache       *workContextVerificationCache
+	revocations WorkContextRevocationChecker
 }
 
 type WorkContextVerifierOptions struct {
 	PublicKeys map[string]ed25519.PublicKey
 	Now        func() time.Time
 	ClockSkew  time.Duration
+	// ExpectRuntimeWorkspace pins every verification to this process's
+	// Codefly workspace, so tokens minted for another workspace are rejected
+	// without per-call expectations.
+	ExpectRuntimeWorkspace bool
+	// CacheSize bounds an optional LRU of authenticated tokens for hot paths
+	// that see the same token repeatedly. Zero disables caching.
+	CacheSize int
+	// Revocations is consulted on every verification after the signature and
+	// claims are checked; cached authentication never skips it.
+	Revocations WorkContextRevocationChecker
 }
 
 func NewWorkContextVerifier(options WorkContextVerifierOptions) (*WorkContextVerifier, error) {
+	workspace := ""
+	if options.ExpectRuntimeWorkspace {
+		var err error
+		workspace, err = runtimeWorkContextWorkspace()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return newWorkContextVerifier(options, workspace)
+}
+
+func newWorkContextVerifier(options WorkContextVerifierOptions, workspace string) (*WorkContextVerifier, error) {
 	if len(options.PublicKeys) == 0 {
 		return nil, fmt.Errorf("%w: no public verification keys", ErrWorkContextInvalid)
 	}
@@ -352,7 +468,28 @@ func NewWorkContextVerifier(options WorkContextVerifierOptions) (*WorkContextVer
 	if clockSkew < 0 || clockSkew > WorkContextClockSkew {
 		return nil, fmt.Errorf("%w: clock skew must be between zero and %s", ErrWorkContextInvalid, WorkContextClockSkew)
 	}
-	return &WorkContextVerifier{publicKeys: keys, now: now, clockSkew: clockSkew}, nil
+	cache, err := newWorkContextVerificationCache(options.CacheSize)
+	if err != nil {
+		return nil, err
+	}
+	return &WorkContextVerifier{
+		publicKeys: keys, now: now, clockSkew: clockSkew, workspace: workspace, cache: cache,
+		revocations: options.Revocations,
+	}, nil
+}
+
+// runtimeWorkContextWorkspace resolves the workspace pinned by
+// ExpectRuntimeWorkspace. It is resolved once at construction: the runtime
+// identity of a process does not change while it serves requests.
+func runtimeWorkContextWorkspace() (string, error) {
+	workspace, err := Workspace(context.Background())
+	if err != nil {
+		return "", fmt.Errorf("%w: expected runtime workspace: %v", ErrWorkContextInvalid, err)
+	}
+	if err := validateBounded("runtime workspace", workspace, workContextMaxIDBytes, true); err != nil {
+		return "", err
+	}
+	return workspace, nil
 }
 
 type WorkContextExpectations struct {
@@ -364,6 +501,23 @@ type WorkContextExpectations struct {
 	SessionID             string
 	ParentSessionID       *string
 	AuthorizationRevision *uint64
+
+	// Audiences accepts any one of several audiences, for a service reachable
+	// under more than one name. It applies in addition to Audience.
+	Audiences []string
+	// WorkspaceID requires the token's workspace_id; a token without one is
+	// rejected.
+	WorkspaceID string
+	// ProjectIDs requires the token's project_id to be one of the values.
+	ProjectIDs []string
+	// AttributionTeamID requires the team among attribution_team_ids.
+	AttributionTeamID string
+
+	// PeerCertificate is the client certificate of the connection that
+	// presented the token. A certificate-bound token is rejected unless it
+	// matches; RequireCertificateBinding also rejects unbound tokens.
+	PeerCertificate           *x509.Certificate
+	RequireCertificateBinding bool
 }
 
 // WorkContextScopeRequirement identifies one exact capability a verified Work
@@ -389,7 +543,7 @@ func RequireWorkContextScope(
 	requirement WorkContextScopeRequirement,
 ) error {
 	if err := validateWorkContext(claims); err != nil {
-		return err
+		return workContextFailure(WorkContextReasonMalformed, err)
 	}
 	if err := validateBounded(
 		"required resource_kind",
@@ -397,7 +551,7 @@ func RequireWorkContextScope(
 		workContextMaxKindBytes,
 		true,
 	); err != nil {
-		return err
+		return workContextFailure(WorkContextReasonInvalidArgument, err)
 	}
 	if err := validateBounded(
 		"required action",
@@ -405,7 +559,7 @@ func RequireWorkContextScope(
 		workContextMaxKindBytes,
 		true,
 	); err != nil {
-		return err
+		return workContextFailure(WorkContextReasonInvalidArgument, err)
 	}
 	if err := validateBounded(
 		"required resource_id",
@@ -413,7 +567,7 @@ func RequireWorkContextScope(
 		workContextMaxIDBytes,
 		requirement.RequireExplicitResource,
 	); err != nil {
-		return err
+		return workContextFailure(WorkContextReasonInvalidArgument, err)
 	}
 
 	effective := claims.GetAuthorityScopes()
@@ -434,50 +588,119 @@ func RequireWorkContextScope(
 			return nil
 		}
 	}
-	return fmt.Errorf(
+	return workContextFailure(WorkContextReasonScopeDenied, fmt.Errorf(
 		"%w: %s:%s:%s",
 		ErrWorkContextDenied,
 		requirement.ResourceKind,
 		requirement.Action,
 		requirement.ResourceID,
-	)
+	))
 }
 
 func (v *WorkContextVerifier) Verify(token WorkContextToken, expected WorkContextExpectations) (*basev0.WorkContextV1, error) {
+	context, binding, err := v.verify(token, expected)
+	if err != nil {
+		return nil, err
+	}
+	if err := matchWorkContextCertificateBinding(binding, expected); err != nil {
+		return nil, workContextFailure(WorkContextReasonCertificateBinding, err)
+	}
+	return context, nil
+}
+
+// verify establishes signature, time, and claim trust and returns the token's
+// certificate binding without checking it against a peer.
+func (v *WorkContextVerifier) verify(token WorkContextToken, expected WorkContextExpectations) (*basev0.WorkContextV1, string, error) {
 	if v == nil {
-		return nil, fmt.Errorf("%w: nil verifier", ErrWorkContextInvalid)
+		return nil, "", workContextFailure(
+			WorkContextReasonInvalidArgument,
+			fmt.Errorf("%w: nil verifier", ErrWorkContextInvalid),
+		)
+	}
+	context, binding, err := v.authenticate(token)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := v.validateTime(context); err != nil {
+		return nil, "", err
+	}
+	if err := matchWorkContext(context, expected); err != nil {
+		return nil, "", err
+	}
+	if v.workspace != "" && context.GetWorkspaceId() != v.workspace {
+		return nil, "", workContextFailure(
+			WorkContextReasonClaimsMismatch,
+			fmt.Errorf("%w: runtime workspace mismatch", ErrWorkContextInvalid),
+		)
+	}
+	if v.revocations != nil {
+		if err := v.revocations.CheckWorkContextRevocation(context); err != nil {
+			reason := WorkContextReasonRevocationUnavailable
+			if errors.Is(err, ErrWorkContextRevoked) {
+				reason = WorkContextReasonRevoked
+			}
+			return nil, "", workContextFailure(reason, err)
+		}
+	}
+	return context, binding, nil
+}
+
+// authenticate performs the signature and structural checks that depend only
+// on the token bytes and key set, which makes its result safe to cache.
+func (v *WorkContextVerifier) authenticate(token WorkContextToken) (*basev0.WorkContextV1, string, error) {
+	if v.cache != nil {
+		if context, binding, ok := v.cache.get(token.encoded, v.now().UTC()); ok {
+			return context, binding, nil
+		}
 	}
 	payload, signature, err := decodeWorkContextToken(token.encoded)
 	if err != nil {
-		return nil, err
+		return nil, "", workContextFailure(WorkContextReasonMalformed, err)
 	}
 	probe := struct {
 		KeyID string `json:"key_id"`
 	}{}
 	if err := json.Unmarshal(payload, &probe); err != nil {
-		return nil, fmt.Errorf("%w: decode key id: %v", ErrWorkContextInvalid, err)
+		return nil, "", workContextFailure(
+			WorkContextReasonMalformed,
+			fmt.Errorf("%w: decode key id: %v", ErrWorkContextInvalid, err),
+		)
+	}
+	if err := validateBounded("key_id", probe.KeyID, workContextMaxKindBytes, true); err != nil {
+		return nil, "", workContextFailure(WorkContextReasonMalformed, err)
 	}
 	publicKey, ok := v.publicKeys[probe.KeyID]
 	if !ok {
-		return nil, fmt.Errorf("%w: unknown key id %q", ErrWorkContextInvalid, probe.KeyID)
+		return nil, "", workContextFailure(
+			WorkContextReasonUnknownKey,
+			fmt.Errorf("%w: unknown key id %q", ErrWorkContextInvalid, probe.KeyID),
+		)
 	}
 	if !ed25519.Verify(publicKey, payload, signature) {
-		return nil, fmt.Errorf("%w: signature verification failed", ErrWorkContextInvalid)
+		return nil, "", workContextFailure(
+			WorkContextReasonBadSignature,
+			fmt.Errorf("%w: signature verification failed", ErrWorkContextInvalid),
+		)
 	}
-	context, err := unmarshalWorkContext(payload)
+	context, binding, err := unmarshalWorkContext(payload)
 	if err != nil {
-		return nil, err
+		return nil, "", workContextFailure(WorkContextReasonMalformed, err)
 	}
 	if err := validateWorkContext(context); err != nil {
-		return nil, err
+		return nil, "", workContextFailure(WorkContextReasonMalformed, err)
 	}
-	if err := v.validateTime(context); err != nil {
-		return nil, err
+	if binding != "" {
+		if err := validateWorkContextCertificateThumbprint(binding); err != nil {
+			return nil, "", workContextFailure(WorkContextReasonMalformed, err)
+		}
 	}
-	if err := matchWorkContext(context, expected); err != nil {
-		return nil, err
+	if v.cache != nil {
+		expiresAt := time.Unix(context.ExpiresAtUnix, 0).Add(v.clockSkew)
+		if !v.now().UTC().After(expiresAt) {
+			v.cache.add(token.encoded, context, binding, expiresAt)
+		}
 	}
-	return context, nil
+	return context, binding, nil
 }
 
 func (v *WorkContextVerifier) validateTime(context *basev0.WorkContextV1) error {
@@ -486,18 +709,31 @@ func (v *WorkContextVerifier) validateTime(context *basev0.WorkContextV1) error
 	issuedAt := time.Unix(context.IssuedAtUnix, 0)
 	expiresAt := time.Unix(context.ExpiresAtUnix, 0)
 	if now.Before(notBefore.Add(-v.clockSkew)) {
-		return fmt.Errorf("%w: token is not active yet", ErrWorkContextInvalid)
+		return workContextFailure(
+			WorkContextReasonNotYetValid,
+			fmt.Errorf("%w: token is not active yet", ErrWorkContextInvalid),
+		)
 	}
 	if issuedAt.After(now.Add(v.clockSkew)) {
-		return fmt.Errorf("%w: token was issued in the future", ErrWorkContextInvalid)
+		return workContextFailure(
+			WorkContextReasonNotYetValid,
+			fmt.Errorf("%w: token was issued in the future", ErrWorkContextInvalid),
+		)
 	}
 	if now.After(expiresAt.Add(v.clockSkew)) {
-		return fmt.Errorf("%w: token expired", ErrWorkContextInvalid)
+		return workContextFailure(WorkContextReasonExpired, fmt.Errorf("%w: token expired", ErrWorkContextInvalid))
 	}
 	return nil
 }
 
 func matchWorkContext(context *basev0.WorkContextV1, expected WorkContextExpectations) error {
+	mismatch := func(name string) error {
+		reason := WorkContextReasonClaimsMismatch
+		if name == "audience" {
+			reason = WorkContextReasonAudienceMismatch
+		}
+		return workContextFailure(reason, fmt.Errorf("%w: %s mismatch", ErrWorkContextInvalid, name))
+	}
 	checks := []struct {
 		name string
 		got  string
@@ -509,17 +745,29 @@ func matchWorkContext(context *basev0.WorkContextV1, expected WorkContextExpecta
 		{"owner", context.OwnerPrincipalId, expected.OwnerPrincipalID},
 		{"task", context.TaskId, expected.TaskID},
 		{"session", context.SessionId, expected.SessionID},
+		{"workspace", context.GetWorkspaceId(), expected.WorkspaceID},
 	}
 	for _, check := range checks {
 		if check.want != "" && check.got != check.want {
-			return fmt.Errorf("%w: %s mismatch", ErrWorkContextInvalid, check.name)
+			return mismatch(check.name)
 		}
 	}
 	if expected.ParentSessionID != nil && context.GetParentSessionId() != *expected.ParentSessionID {
-		return fmt.Errorf("%w: parent session mismatch", ErrWorkContextInvalid)
+		return mismatch("parent session")
 	}
 	if expected.AuthorizationRevision != nil && context.AuthorizationRevision != *expected.AuthorizationRevision {
-		return fmt.Errorf("%w: authorization revision mismatch", ErrWorkContextInvalid)
+		return mismatch("authorization revision")
+	}
+	if len(expected.Audiences) > 0 && !slices.Contains(expected.Audiences, context.Audience) {
+		return mismatch("audience")
+	}
+	if len(expected.ProjectIDs) > 0 &&
+		(context.ProjectId == nil || !slices.Contains(expected.ProjectIDs, context.GetProjectId())) {
+		return mismatch("project")
+	}
+	if expected.AttributionTeamID != "" &&
+		!sortedStringsContain(context.AttributionTeamIds, expected.AttributionTeamID) {
+		return mismatch("attribution team")
 	}
 	return nil
 }
@@ -548,6 +796,13 @@ type workContextPayload struct {
 	AttributionTeamIDs    []string           `json:"attribution_team_ids"`
 	WorkspaceID           *string            `json:"workspace_id,omitempty"`
 	ProjectID             *string            `json:"project_id,omitempty"`
+	Confirmation          *workContextCNF    `json:"cnf,omitempty"`
+}
+
+// workContextCNF is the RFC 8705 confirmation claim binding a token to the
+// SHA-256 thumbprint of one mTLS client certificate.
+type workContextCNF struct {
+	CertificateThumbprint string `json:"x5t#S256"`
 }
 
 type workContextScope struct {
@@ -563,8 +818,11 @@ type workContextActor struct {
 	GrantedScopes []workContextScope `json:"granted_scopes"`
 }
 
-func marshalWorkContext(context *basev0.WorkContextV1) ([]byte, error) {
+func marshalWorkContext(context *basev0.WorkContextV1, binding string) ([]byte, error) {
 	payload := payloadFromContext(context)
+	if binding != "" {
+		payload.Confirmation = &workContextCNF{CertificateThumbprint: binding}
+	}
 	encoded, err := json.Marshal(payload)
 	if err != nil {
 		return nil, fmt.Errorf("%w: encode payload: %v", ErrWorkContextInvalid, err)
@@ -572,25 +830,34 @@ func marshalWorkContext(context *basev0.WorkContextV1) ([]byte, error) {
 	return encoded, nil
 }
 
-func unmarshalWorkContext(encoded []byte) (*basev0.WorkContextV1, error) {
+func unmarshalWorkContext(encoded []byte) (*basev0.WorkContextV1, string, error) {
 	var payload workContextPayload
 	decoder := json.NewDecoder(bytes.NewReader(encoded))
 	decoder.DisallowUnknownFields()
 	if err := decoder.Decode(&payload); err != nil {
-		return nil, fmt.Errorf("%w: decode payload: %v", ErrWorkContextInvalid, err)
+		return nil, "", fmt.Errorf("%w: decode payload: %v", ErrWorkContextInvalid, err)
 	}
 	var trailing any
 	if err := decoder.Decode(&trailing); !errors.Is(err, io.EOF) {
 		if err == nil {
-			return nil, fmt.Errorf("%w: trailing JSON value", ErrWorkContextInvalid)
+			return nil, "", fmt.Errorf("%w: trailing JSON value", ErrWorkContextInvalid)
 		}
-		return nil, fmt.Errorf("%w: trailing JSON: %v", ErrWorkContextInvalid, err)
+		return nil, "", fmt.Errorf("%w: trailing JSON: %v", ErrWorkContextInvalid, err)
 	}
 	revision, err := strconv.ParseUint(payload.AuthorizationRevision, 10, 64)
 	if err != nil {
-		return nil, fmt.Errorf("%w: authorization_revision must be uint64 decimal", ErrWorkContextInvalid)
+		return nil, "", fmt.Errorf("%w: authorization_revision must be uint64 decimal", ErrWorkContextInvalid)
+	}
+	binding := ""
+	if payload.Confirmation != nil {
+		// A present cnf must carry a binding; an empty one would silently
+		// downgrade a bound token to a bearer token.
+		if payload.Confirmation.CertificateThumbprint == "" {
+			return nil, "", fmt.Errorf("%w: cnf has no certificate thumbprint", ErrWorkContextInvalid)
+		}
+		binding = payload.Confirmation.CertificateThumbprint
 	}
-	return contextFromPayload(payload, revision), nil
+	return contextFromPayload(payload, revision), binding, nil
 }
 
 func payloadFromContext(context *basev0.WorkContextV1) workContextPayload {
@@ -0,0 +1,118 @@
+package codefly
+
+import (
+	"container/list"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"fmt"
+	"sync"
+	"time"
+
+	basev0 "github.com/codefly-dev/core/generated/go/codefly/base/v0"
+)
+
+const maxWorkContextVerificationCacheEntries = 1 << 20
+
+// workContextVerificationCache is a bounded LRU of authenticated claims. It
+// memoizes only the expectation- and time-independent work (decode, signature,
+// strict JSON, structural validation); time, expectations, and certificate
+// binding are evaluated on every hit. Keys are SHA-256 digests, so the cache
+// never retains bearer tokens.
+type workContextVerificationCache struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[[sha256.Size]byte]*list.Element
+	order    *list.List
+}
+
+type workContextVerificationCacheEntry struct {
+	key       [sha256.Size]byte
+	claims    *basev0.WorkContextV1
+	binding   string
+	expiresAt time.Time
+}
+
+func newWorkContextVerificationCache(capacity int) (*workContextVerificationCache, error) {
+	if capacity < 0 || capacity > maxWorkContextVerificationCacheEntries {
+		return nil, fmt.Errorf(
+			"%w: verification cache size must be between 0 and %d",
+			ErrWorkContextInvalid,
+			maxWorkContextVerificationCacheEntries,
+		)
+	}
+	if capacity == 0 {
+		return nil, nil
+	}
+	return &workContextVerificationCache{
+		capacity: capacity,
+		entries:  make(map[[sha256.Size]byte]*list.Element, capacity),
+		order:    list.New(),
+	}, nil
+}
+
+// get returns a private copy of the cached claims so a caller mutating its
+// result cannot poison later verifications.
+func (c *workContextVerificationCache) get(encoded string, now time.Time) (*basev0.WorkContextV1, string, bool) {
+	key := sha256.Sum256([]byte(encoded))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, "", false
+	}
+	entry := element.Value.(*workContextVerificationCacheEntry)
+	if now.After(entry.expiresAt) {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		return nil, "", false
+	}
+	c.order.MoveToFront(element)
+	return cloneContext(entry.claims), entry.binding, true
+}
+
+func (c *workContextVerificationCache) add(
+	encoded string,
+	claims *basev0.WorkContextV1,
+	binding string,
+	expiresAt time.Time,
+) {
+	key := sha256.Sum256([]byte(encoded))
+	entry := &workContextVerificationCacheEntry{
+		key: key, claims: cloneContext(claims), binding: binding, expiresAt: expiresAt,
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*workContextVerificationCacheEntry).key)
+	}
+}
+
+func (c *workContextVerificationCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// samePublicKeys reports whether two key sets are byte-identical. A JWKS
+// refresh that returns the same keys keeps its verification cache; any change
+// invalidates it.
+func samePublicKeys(left, right map[string]ed25519.PublicKey) bool {
+	if len(left) != len(right) {
+		return false
+	}
+	for keyID, key := range left {
+		other, ok := right[keyID]
+		if !ok || !key.Equal(other) {
+			return false
+		}
+	}
+	return true
+}
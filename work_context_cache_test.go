@@ -0,0 +1,162 @@
+package codefly
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkContextVerificationCacheRevalidatesEveryHit(t *testing.T) {
+	token, _, err := workContextTestSigner(t, workContextTestTime).StartTask(workContextTestInput())
+	require.NoError(t, err)
+	publicKey, _ := workContextTestKeys()
+	var mu sync.RWMutex
+	now := workContextTestTime
+	verifier, err := NewWorkContextVerifier(WorkContextVerifierOptions{
+		PublicKeys: map[string]ed25519.PublicKey{"work-context-test-2026-07": publicKey},
+		Now: func() time.Time {
+			mu.RLock()
+			defer mu.RUnlock()
+			return now
+		},
+		CacheSize: 2,
+	})
+	require.NoError(t, err)
+
+	claims, err := verifier.Verify(token, WorkContextExpectations{Audience: "warden.evidence"})
+	require.NoError(t, err)
+	require.Equal(t, 1, verifier.cache.len())
+	claims.TenantId = "tenant-mutated"
+
+	cached, err := verifier.Verify(token, WorkContextExpectations{Audience: "warden.evidence"})
+	require.NoError(t, err)
+	require.Equal(t, "tenant-codefly", cached.TenantId, "callers must not poison cached claims")
+	_, err = verifier.Verify(token, WorkContextExpectations{Audience: "other-service"})
+	require.ErrorContains(t, err, "audience mismatch")
+
+	mu.Lock()
+	now = now.Add(7 * time.Minute)
+	mu.Unlock()
+	_, err = verifier.Verify(token, WorkContextExpectations{})
+	require.ErrorIs(t, err, ErrWorkContextInvalid)
+	require.Zero(t, verifier.cache.len(), "expired entries are evicted")
+}
+
+func TestWorkContextVerificationCacheIsBounded(t *testing.T) {
+	signer := workContextTestSigner(t, workContextTestTime)
+	publicKey, _ := workContextTestKeys()
+	verifier, err := NewWorkContextVerifier(WorkContextVerifierOptions{
+		PublicKeys: map[string]ed25519.PublicKey{"work-context-test-2026-07": publicKey},
+		Now:        func() time.Time { return workContextTestTime },
+		CacheSize:  2,
+	})
+	require.NoError(t, err)
+	for index := range 5 {
+		input := workContextTestInput()
+		input.SessionID = fmt.Sprintf("session-%d", index)
+		token, _, startErr := signer.StartTask(input)
+		require.NoError(t, startErr)
+		_, err = verifier.Verify(token, WorkContextExpectations{})
+		require.NoError(t, err)
+	}
+	require.Equal(t, 2, verifier.cache.len())
+
+	_, err = NewWorkContextVerifier(WorkContextVerifierOptions{
+		PublicKeys: map[string]ed25519.PublicKey{"work-context-test-2026-07": publicKey},
+		CacheSize:  maxWorkContextVerificationCacheEntries + 1,
+	})
+	require.ErrorIs(t, err, ErrWorkContextInvalid)
+}
+
+func TestWorkContextJWKSVerificationCacheFollowsKeySet(t *testing.T) {
+	firstPublic, firstPrivate := workContextJWKSKey(1)
+	secondPublic, _ := workContextJWKSKey(2)
+	var rotated atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		keys := map[string]ed25519.PublicKey{"key-1": firstPublic}
+		if rotated.Load() {
+			keys = map[string]ed25519.PublicKey{"key-2": secondPublic}
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		_, _ = writer.Write(workContextJWKSJSON(t, keys))
+	}))
+	t.Cleanup(server.Close)
+	var mu sync.RWMutex
+	now := workContextTestTime
+	verifier, err := NewWorkContextJWKSVerifier(WorkContextJWKSVerifierOptions{
+		URL: server.URL,
+		Now: func() time.Time {
+			mu.RLock()
+			defer mu.RUnlock()
+			return now
+		},
+		CacheTTL:  time.Second,
+		CacheSize: 16,
+	})
+	require.NoError(t, err)
+	token := workContextJWKSToken(t, "key-1", firstPrivate)
+	_, err = verifier.Verify(t.Context(), token, WorkContextExpectations{})
+	require.NoError(t, err)
+	cache := verifier.verifier.cache
+
+	advance := func() {
+		mu.Lock()
+		now = now.Add(2 * time.Second)
+		mu.Unlock()
+	}
+	advance()
+	_, err = verifier.Verify(t.Context(), token, WorkContextExpectations{})
+	require.NoError(t, err)
+	require.Same(t, cache, verifier.verifier.cache, "an identical key set keeps the cache")
+
+	rotated.Store(true)
+	advance()
+	_, err = verifier.Verify(t.Context(), token, WorkContextExpectations{})
+	require.ErrorIs(t, err, ErrWorkContextInvalid, "a removed key must not be served from the cache")
+	require.NotSame(t, cache, verifier.verifier.cache)
+}
+
+func BenchmarkWorkContextVerify(b *testing.B) {
+	benchmarkWorkContextVerify(b, 0)
+}
+
+func BenchmarkWorkContextVerifyCached(b *testing.B) {
+	benchmarkWorkContextVerify(b, 1024)
+}
+
+func benchmarkWorkContextVerify(b *testing.B, cacheSize int) {
+	publicKey, privateKey := workContextTestKeys()
+	signer, err := NewWorkContextSigner(WorkContextSignerOptions{
+		Issuer:     "https://accounts.codefly.dev/work-context",
+		KeyID:      "work-context-test-2026-07",
+		PrivateKey: privateKey,
+		Now:        func() time.Time { return workContextTestTime },
+	})
+	require.NoError(b, err)
+	token, _, err := signer.StartTask(workContextTestInput())
+	require.NoError(b, err)
+	verifier, err := NewWorkContextVerifier(WorkContextVerifierOptions{
+		PublicKeys: map[string]ed25519.PublicKey{"work-context-test-2026-07": publicKey},
+		Now:        func() time.Time { return workContextTestTime },
+		CacheSize:  cacheSize,
+	})
+	require.NoError(b, err)
+	expected := WorkContextExpectations{
+		Issuer: "https://accounts.codefly.dev/work-context", Audience: "warden.evidence",
+	}
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, verifyErr := verifier.Verify(token, expected); verifyErr != nil {
+				b.Fatal(verifyErr)
+			}
+		}
+	})
+}
@@ -0,0 +1,114 @@
+package codefly
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// WorkContextCertificateThumbprint returns the RFC 8705 x5t#S256 thumbprint of
+// a client certificate: the unpadded base64url SHA-256 of its DER encoding.
+// Authorities pass it as CertificateThumbprint to bind a Work Context to the
+// mTLS identity of the workload that will present it.
+func WorkContextCertificateThumbprint(certificate *x509.Certificate) (string, error) {
+	if certificate == nil || len(certificate.Raw) == 0 {
+		return "", fmt.Errorf("%w: certificate is required for a thumbprint", ErrWorkContextInvalid)
+	}
+	digest := sha256.Sum256(certificate.Raw)
+	return base64.RawURLEncoding.EncodeToString(digest[:]), nil
+}
+
+// PeerCertificateFromGRPC returns the client leaf certificate of the
+// incoming gRPC connection. Pass it as WorkContextExpectations.PeerCertificate.
+func PeerCertificateFromGRPC(ctx context.Context) (*x509.Certificate, error) {
+	if ctx == nil {
+		return nil, workContextFailure(
+			WorkContextReasonInvalidArgument,
+			fmt.Errorf("%w: nil gRPC context", ErrWorkContextInvalid),
+		)
+	}
+	remote, ok := peer.FromContext(ctx)
+	if !ok || remote.AuthInfo == nil {
+		return nil, workContextFailure(
+			WorkContextReasonCertificateBinding,
+			fmt.Errorf("%w: gRPC peer has no transport security", ErrWorkContextInvalid),
+		)
+	}
+	info, ok := remote.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return nil, workContextFailure(
+			WorkContextReasonCertificateBinding,
+			fmt.Errorf("%w: gRPC peer is not using TLS", ErrWorkContextInvalid),
+		)
+	}
+	if len(info.State.PeerCertificates) == 0 {
+		return nil, workContextFailure(
+			WorkContextReasonCertificateBinding,
+			fmt.Errorf("%w: gRPC peer presented no client certificate", ErrWorkContextInvalid),
+		)
+	}
+	return info.State.PeerCertificates[0], nil
+}
+
+// PeerCertificateFromHTTP returns the client leaf certificate of the TLS
+// connection that carried an HTTP request.
+func PeerCertificateFromHTTP(request *http.Request) (*x509.Certificate, error) {
+	if request == nil {
+		return nil, workContextFailure(
+			WorkContextReasonInvalidArgument,
+			fmt.Errorf("%w: nil HTTP request", ErrWorkContextInvalid),
+		)
+	}
+	if request.TLS == nil {
+		return nil, workContextFailure(
+			WorkContextReasonCertificateBinding,
+			fmt.Errorf("%w: HTTP request is not using TLS", ErrWorkContextInvalid),
+		)
+	}
+	if len(request.TLS.PeerCertificates) == 0 {
+		return nil, workContextFailure(
+			WorkContextReasonCertificateBinding,
+			fmt.Errorf("%w: HTTP peer presented no client certificate", ErrWorkContextInvalid),
+		)
+	}
+	return request.TLS.PeerCertificates[0], nil
+}
+
+// matchWorkContextCertificateBinding enforces proof of possession. A bound
+// token presented without its certificate is a stolen-token signal, so the
+// absence of a peer certificate fails closed rather than skipping the check.
+func matchWorkContextCertificateBinding(binding string, expected WorkContextExpectations) error {
+	if binding == "" {
+		if expected.RequireCertificateBinding {
+			return fmt.Errorf("%w: token is not certificate-bound", ErrWorkContextInvalid)
+		}
+		return nil
+	}
+	if expected.PeerCertificate == nil {
+		return fmt.Errorf("%w: certificate-bound token presented without a peer certificate", ErrWorkContextInvalid)
+	}
+	thumbprint, err := WorkContextCertificateThumbprint(expected.PeerCertificate)
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare([]byte(thumbprint), []byte(binding)) != 1 {
+		return fmt.Errorf("%w: certificate binding mismatch", ErrWorkContextInvalid)
+	}
+	return nil
+}
+
+func validateWorkContextCertificateThumbprint(thumbprint string) error {
+	decoded, err := base64.RawURLEncoding.DecodeString(thumbprint)
+	if err != nil || len(decoded) != sha256.Size ||
+		base64.RawURLEncoding.EncodeToString(decoded) != thumbprint {
+		return fmt.Errorf("%w: certificate thumbprint must be a base64url SHA-256 digest", ErrWorkContextInvalid)
+	}
+	return nil
+}
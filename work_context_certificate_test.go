@@ -0,0 +1,246 @@
+package codefly
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	basev0 "github.com/codefly-dev/core/generated/go/codefly/base/v0"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestWorkContextCertificateBindingRejectsStolenTokens(t *testing.T) {
+	owner := workContextTestCertificate(t, "owner")
+	thief := workContextTestCertificate(t, "thief")
+	signer := workContextTestSigner(t, workContextTestTime)
+	verifier := workContextTestVerifier(t, workContextTestTime)
+
+	input := workContextTestInput()
+	input.CertificateThumbprint = workContextTestThumbprint(t, owner)
+	token, _, err := signer.StartTask(input)
+	require.NoError(t, err)
+
+	_, err = verifier.Verify(token, WorkContextExpectations{PeerCertificate: owner.Leaf})
+	require.NoError(t, err)
+	_, err = verifier.Verify(token, WorkContextExpectations{PeerCertificate: thief.Leaf})
+	require.ErrorIs(t, err, ErrWorkContextInvalid)
+	require.Contains(t, err.Error(), "certificate binding mismatch")
+	_, err = verifier.Verify(token, WorkContextExpectations{})
+	require.ErrorIs(t, err, ErrWorkContextInvalid, "a bound token must never degrade to a bearer token")
+
+	unbound, _, err := signer.StartTask(workContextTestInput())
+	require.NoError(t, err)
+	_, err = verifier.Verify(unbound, WorkContextExpectations{PeerCertificate: owner.Leaf})
+	require.NoError(t, err)
+	_, err = verifier.Verify(unbound, WorkContextExpectations{
+		PeerCertificate: owner.Leaf, RequireCertificateBinding: true,
+	})
+	require.ErrorIs(t, err, ErrWorkContextInvalid)
+
+	inherited, _, err := signer.StartSession(token, StartRootSessionInput{SessionID: "session-next"})
+	require.NoError(t, err)
+	_, err = verifier.Verify(inherited, WorkContextExpectations{PeerCertificate: thief.Leaf})
+	require.ErrorIs(t, err, ErrWorkContextInvalid, "derived sessions inherit the parent's binding")
+
+	tool := workContextTestCertificate(t, "tool")
+	toolChild := StartChildSessionInput{
+		SessionID: "session-tool",
+		Actor: &basev0.WorkActorV1{
+			PrincipalId: "tool-codefly-editor", PrincipalKind: "tool", DelegationId: "delegation-2",
+			GrantedScopes: []*basev0.WorkScopeV1{{ResourceKind: "evidence", Actions: []string{"append"}}},
+		},
+		CertificateThumbprint: workContextTestThumbprint(t, tool),
+	}
+	// Rebinding a bound parent requires the parent's certificate, so a stolen
+	// token cannot be moved onto the thief's certificate.
+	_, _, err = signer.StartChildSession(token, toolChild)
+	require.ErrorIs(t, err, ErrWorkContextInvalid)
+	require.Equal(t, WorkContextReasonCertificateBinding, WorkContextErrorReasonOf(err))
+	_, _, err = signer.StartSession(token, StartRootSessionInput{
+		SessionID:             "session-stolen",
+		CertificateThumbprint: workContextTestThumbprint(t, thief),
+		ParentCertificate:     thief.Leaf,
+	})
+	require.ErrorIs(t, err, ErrWorkContextInvalid)
+	require.Equal(t, WorkContextReasonCertificateBinding, WorkContextErrorReasonOf(err))
+
+	toolChild.ParentCertificate = owner.Leaf
+	rebound, _, err := signer.StartChildSession(token, toolChild)
+	require.NoError(t, err)
+	_, err = verifier.Verify(rebound, WorkContextExpectations{PeerCertificate: tool.Leaf})
+	require.NoError(t, err)
+	_, err = verifier.Verify(rebound, WorkContextExpectations{PeerCertificate: owner.Leaf})
+	require.ErrorIs(t, err, ErrWorkContextInvalid)
+
+	input.CertificateThumbprint = "not-a-thumbprint"
+	_, _, err = signer.StartTask(input)
+	require.ErrorIs(t, err, ErrWorkContextInvalid)
+}
+
+func TestWorkContextCertificateBindingOverGRPCMutualTLS(t *testing.T) {
+	server := workContextTestCertificate(t, "localhost")
+	owner := workContextTestCertificate(t, "owner")
+	thief := workContextTestCertificate(t, "thief")
+	input := workContextTestInput()
+	input.CertificateThumbprint = workContextTestThumbprint(t, owner)
+	token, _, err := workContextTestSigner(t, workContextTestTime).StartTask(input)
+	require.NoError(t, err)
+	verifier := workContextTestVerifier(t, workContextTestTime)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	grpcServer := grpc.NewServer(
+		grpc.Creds(credentials.NewTLS(&tls.Config{
+			Certificates: []tls.Certificate{server},
+			ClientAuth:   tls.RequireAnyClientCert,
+			MinVersion:   tls.VersionTLS13,
+		})),
+		grpc.UnaryInterceptor(func(
+			ctx context.Context,
+			request any,
+			_ *grpc.UnaryServerInfo,
+			handler grpc.UnaryHandler,
+		) (any, error) {
+			execution, err := GRPCExecutionContextFromIncoming(ctx)
+			if err != nil {
+				return nil, status.Error(codes.Unauthenticated, err.Error())
+			}
+			certificate, err := PeerCertificateFromGRPC(ctx)
+			if err != nil {
+				return nil, status.Error(codes.Unauthenticated, err.Error())
+			}
+			if _, err := verifier.Verify(execution.WorkContext(), WorkContextExpectations{
+				PeerCertificate: certificate,
+			}); err != nil {
+				return nil, status.Error(codes.Unauthenticated, err.Error())
+			}
+			return handler(ctx, request)
+		}),
+	)
+	healthpb.RegisterHealthServer(grpcServer, health.NewServer())
+	go func() { _ = grpcServer.Serve(listener) }()
+	t.Cleanup(grpcServer.Stop)
+
+	call := func(client tls.Certificate) error {
+		roots := x509.NewCertPool()
+		roots.AddCert(server.Leaf)
+		connection, err := grpc.NewClient(
+			listener.Addr().String(),
+			grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+				Certificates: []tls.Certificate{client},
+				RootCAs:      roots,
+				ServerName:   "localhost",
+				MinVersion:   tls.VersionTLS13,
+			})),
+		)
+		require.NoError(t, err)
+		defer connection.Close()
+		execution, err := NewExecutionContext(token, "operation-mtls")
+		require.NoError(t, err)
+		ctx, err := WithGRPCExecutionContext(t.Context(), execution)
+		require.NoError(t, err)
+		_, err = healthpb.NewHealthClient(connection).Check(ctx, &healthpb.HealthCheckRequest{})
+		return err
+	}
+	require.NoError(t, call(owner))
+	err = call(thief)
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+	require.Contains(t, err.Error(), "certificate binding mismatch")
+}
+
+func TestWorkContextCertificateBindingOverHTTPMutualTLS(t *testing.T) {
+	owner := workContextTestCertificate(t, "owner")
+	thief := workContextTestCertificate(t, "thief")
+	input := workContextTestInput()
+	input.CertificateThumbprint = workContextTestThumbprint(t, owner)
+	token, _, err := workContextTestSigner(t, workContextTestTime).StartTask(input)
+	require.NoError(t, err)
+	verifier := workContextTestVerifier(t, workContextTestTime)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		presented, err := WorkContextFromHeaders(request.Header)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		certificate, err := PeerCertificateFromHTTP(request)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if _, err := verifier.Verify(presented, WorkContextExpectations{PeerCertificate: certificate}); err != nil {
+			http.Error(writer, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		writer.WriteHeader(http.StatusNoContent)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert, MinVersion: tls.VersionTLS13}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	call := func(client tls.Certificate) int {
+		transport := server.Client().Transport.(*http.Transport).Clone()
+		transport.TLSClientConfig.Certificates = []tls.Certificate{client}
+		request, err := http.NewRequestWithContext(t.Context(), http.MethodPost, server.URL, nil)
+		require.NoError(t, err)
+		require.NoError(t, AttachWorkContext(request, token))
+		response, err := (&http.Client{Transport: transport}).Do(request)
+		require.NoError(t, err)
+		defer response.Body.Close()
+		return response.StatusCode
+	}
+	require.Equal(t, http.StatusNoContent, call(owner))
+	require.Equal(t, http.StatusUnauthorized, call(thief))
+
+	_, err = PeerCertificateFromHTTP(httptest.NewRequest(http.MethodGet, "/", nil))
+	require.ErrorIs(t, err, ErrWorkContextInvalid)
+}
+
+// workContextTestCertificate generates a throwaway self-signed certificate
+// usable as both an mTLS client identity and a "localhost" server identity.
+func workContextTestCertificate(t *testing.T, commonName string) tls.Certificate {
+	t.Helper()
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName},
+		DNSNames:              []string{commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, privateKey.Public(), privateKey)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: privateKey, Leaf: leaf}
+}
+
+func workContextTestThumbprint(t *testing.T, certificate tls.Certificate) string {
+	t.Helper()
+	thumbprint, err := WorkContextCertificateThumbprint(certificate.Leaf)
+	require.NoError(t, err)
+	return thumbprint
+}
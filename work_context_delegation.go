@@ -0,0 +1,186 @@
+package codefly
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	basev0 "github.com/codefly-dev/core/generated/go/codefly/base/v0"
+)
+
+// ErrWorkContextDelegationDenied is the sentinel matched by every
+// *WorkContextDelegationError.
+var ErrWorkContextDelegationDenied = errors.New("Codefly Work Context delegation denied")
+
+// WorkContextDelegationReason names the policy rule a child session violated.
+type WorkContextDelegationReason string
+
+const (
+	WorkContextDelegationPrincipalKind WorkContextDelegationReason = "principal_kind"
+	WorkContextDelegationDepth         WorkContextDelegationReason = "depth"
+	WorkContextDelegationTTL           WorkContextDelegationReason = "ttl"
+	WorkContextDelegationCycle         WorkContextDelegationReason = "cycle"
+)
+
+// WorkContextDelegationError reports a StartChildSession request refused by
+// the signer's delegation policy. The request itself was well formed, so it
+// does not match ErrWorkContextInvalid.
+type WorkContextDelegationError struct {
+	Reason        WorkContextDelegationReason
+	Audience      string
+	TenantID      string
+	PrincipalID   string
+	PrincipalKind string
+	Detail        string
+}
+
+func (e *WorkContextDelegationError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrWorkContextDelegationDenied, e.Detail)
+}
+
+func (e *WorkContextDelegationError) Unwrap() error {
+	return ErrWorkContextDelegationDenied
+}
+
+// WorkContextDelegationPolicy constrains which actors StartChildSession may
+// append. Every rule is optional; the zero policy only adds the cycle check and
+// keeps children within their parent's lifetime.
+type WorkContextDelegationPolicy struct {
+	// AllowedPrincipalKinds lists the actor principal kinds each child audience
+	// accepts. Audiences without an entry use DefaultAllowedPrincipalKinds,
+	// and a nil default leaves them unrestricted.
+	AllowedPrincipalKinds        map[string][]string
+	DefaultAllowedPrincipalKinds []string
+	// MaxActorDepth caps the actor chain per tenant. Tenants without an entry
+	// use DefaultMaxActorDepth, and zero falls back to WorkContextMaxActorDepth.
+	MaxActorDepth        map[string]int
+	DefaultMaxActorDepth int
+	// MaxTTL caps child session lifetimes per actor principal kind. Explicit
+	// TTLs above the cap are refused; a zero TTL is clamped to it.
+	MaxTTL map[string]time.Duration
+}
+
+type workContextDelegationPolicy struct {
+	allowedKinds        map[string][]string
+	defaultAllowedKinds []string
+	maxDepth            map[string]int
+	defaultMaxDepth     int
+	maxTTL              map[string]time.Duration
+}
+
+func newWorkContextDelegationPolicy(policy *WorkContextDelegationPolicy) (*workContextDelegationPolicy, error) {
+	if policy == nil {
+		return nil, nil
+	}
+	compiled := &workContextDelegationPolicy{
+		allowedKinds:    make(map[string][]string, len(policy.AllowedPrincipalKinds)),
+		maxDepth:        make(map[string]int, len(policy.MaxActorDepth)),
+		maxTTL:          make(map[string]time.Duration, len(policy.MaxTTL)),
+		defaultMaxDepth: policy.DefaultMaxActorDepth,
+	}
+	if compiled.defaultMaxDepth == 0 {
+		compiled.defaultMaxDepth = WorkContextMaxActorDepth
+	}
+	if compiled.defaultMaxDepth < 1 || compiled.defaultMaxDepth > WorkContextMaxActorDepth {
+		return nil, fmt.Errorf("%w: delegation depth must be between 1 and %d", ErrWorkContextInvalid, WorkContextMaxActorDepth)
+	}
+	if policy.DefaultAllowedPrincipalKinds != nil {
+		compiled.defaultAllowedKinds = append([]string{}, policy.DefaultAllowedPrincipalKinds...)
+	}
+	for audience, kinds := range policy.AllowedPrincipalKinds {
+		if err := validateBounded("delegation audience", audience, workContextMaxIDBytes, true); err != nil {
+			return nil, err
+		}
+		compiled.allowedKinds[audience] = append([]string{}, kinds...)
+	}
+	for tenant, depth := range policy.MaxActorDepth {
+		if err := validateBounded("delegation tenant_id", tenant, workContextMaxIDBytes, true); err != nil {
+			return nil, err
+		}
+		if depth < 1 || depth > WorkContextMaxActorDepth {
+			return nil, fmt.Errorf("%w: delegation depth must be between 1 and %d", ErrWorkContextInvalid, WorkContextMaxActorDepth)
+		}
+		compiled.maxDepth[tenant] = depth
+	}
+	for kind, ttl := range policy.MaxTTL {
+		if err := validateBounded("delegation principal_kind", kind, workContextMaxKindBytes, true); err != nil {
+			return nil, err
+		}
+		if ttl < time.Second || ttl > WorkContextMaxTTL {
+			return nil, fmt.Errorf("%w: delegation TTL must be between 1s and %s", ErrWorkContextInvalid, WorkContextMaxTTL)
+		}
+		compiled.maxTTL[kind] = ttl
+	}
+	return compiled, nil
+}
+
+// authorize checks one delegation step and returns the TTL the child session
+// must be minted with.
+func (p *workContextDelegationPolicy) authorize(
+	parent *basev0.WorkContextV1,
+	audience string,
+	actor *basev0.WorkActorV1,
+	ttl time.Duration,
+	now time.Time,
+) (time.Duration, error) {
+	denied := func(reason WorkContextDelegationReason, format string, arguments ...any) error {
+		return &WorkContextDelegationError{
+			Reason:        reason,
+			Audience:      audience,
+			TenantID:      parent.GetTenantId(),
+			PrincipalID:   actor.GetPrincipalId(),
+			PrincipalKind: actor.GetPrincipalKind(),
+			Detail:        fmt.Sprintf(format, arguments...),
+		}
+	}
+	allowed, restricted := p.allowedKinds[audience]
+	if !restricted && p.defaultAllowedKinds != nil {
+		allowed, restricted = p.defaultAllowedKinds, true
+	}
+	if restricted && !slices.Contains(allowed, actor.GetPrincipalKind()) {
+		return 0, denied(
+			WorkContextDelegationPrincipalKind,
+			"principal kind %q may not act for audience %q", actor.GetPrincipalKind(), audience,
+		)
+	}
+
+	maxDepth, ok := p.maxDepth[parent.GetTenantId()]
+	if !ok {
+		maxDepth = p.defaultMaxDepth
+	}
+	if depth := len(parent.GetActorChain()) + 1; depth > maxDepth {
+		return 0, denied(
+			WorkContextDelegationDepth,
+			"actor chain depth %d exceeds %d for tenant %q", depth, maxDepth, parent.GetTenantId(),
+		)
+	}
+
+	if actor.GetPrincipalId() == parent.GetOwnerPrincipalId() {
+		return 0, denied(WorkContextDelegationCycle, "principal %q owns the task", actor.GetPrincipalId())
+	}
+	for _, previous := range parent.GetActorChain() {
+		if previous.GetPrincipalId() == actor.GetPrincipalId() {
+			return 0, denied(WorkContextDelegationCycle, "principal %q already acts in this chain", actor.GetPrincipalId())
+		}
+	}
+
+	remaining := time.Unix(parent.GetExpiresAtUnix(), 0).Sub(now)
+	limit := remaining
+	if kindLimit, ok := p.maxTTL[actor.GetPrincipalKind()]; ok {
+		limit = min(limit, kindLimit)
+	}
+	if limit < time.Second {
+		return 0, denied(WorkContextDelegationTTL, "parent session expires in %s", remaining.Truncate(time.Second))
+	}
+	if ttl == 0 {
+		return min(WorkContextDefaultTTL, limit), nil
+	}
+	if ttl > limit {
+		return 0, denied(
+			WorkContextDelegationTTL,
+			"TTL %s exceeds %s for principal kind %q", ttl, limit, actor.GetPrincipalKind(),
+		)
+	}
+	return ttl, nil
+}
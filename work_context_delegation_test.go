@@ -0,0 +1,150 @@
+package codefly
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	basev0 "github.com/codefly-dev/core/generated/go/codefly/base/v0"
+	"github.com/stretchr/testify/require"
+)
+
+func workContextDelegationSigner(t *testing.T, now *time.Time, policy *WorkContextDelegationPolicy) *WorkContextSigner {
+	t.Helper()
+	_, privateKey := workContextTestKeys()
+	signer, err := NewWorkContextSigner(WorkContextSignerOptions{
+		Issuer:           "https://accounts.codefly.dev/work-context",
+		KeyID:            "work-context-test-2026-07",
+		PrivateKey:       privateKey,
+		Now:              func() time.Time { return *now },
+		DelegationPolicy: policy,
+	})
+	require.NoError(t, err)
+	return signer
+}
+
+func workContextDelegationActor(principalID, kind string) *basev0.WorkActorV1 {
+	return &basev0.WorkActorV1{
+		PrincipalId:   principalID,
+		PrincipalKind: kind,
+		DelegationId:  "delegation-" + principalID,
+		GrantedScopes: []*basev0.WorkScopeV1{
+			{ResourceKind: "repository", Actions: []string{"read"}, ResourceIds: []string{"repo-warden"}},
+		},
+	}
+}
+
+func TestWorkContextDelegationPolicyReturnsTypedDenials(t *testing.T) {
+	now := workContextTestTime
+	signer := workContextDelegationSigner(t, &now, &WorkContextDelegationPolicy{
+		AllowedPrincipalKinds:        map[string][]string{"warden.tools": {"tool"}},
+		DefaultAllowedPrincipalKinds: []string{"agent", "tool"},
+		MaxActorDepth:                map[string]int{"tenant-codefly": 2},
+		MaxTTL:                       map[string]time.Duration{"tool": time.Minute},
+	})
+	parent, _, err := signer.StartTask(workContextTestInput())
+	require.NoError(t, err)
+
+	cases := []struct {
+		name     string
+		input    StartChildSessionInput
+		expected WorkContextDelegationReason
+	}{
+		{
+			name:     "kind not allowed for audience",
+			input:    StartChildSessionInput{Audience: "warden.tools", Actor: workContextDelegationActor("agent-2", "agent")},
+			expected: WorkContextDelegationPrincipalKind,
+		},
+		{
+			name:     "kind not allowed by default",
+			input:    StartChildSessionInput{Actor: workContextDelegationActor("human-2", "human")},
+			expected: WorkContextDelegationPrincipalKind,
+		},
+		{
+			name:     "actor already in chain",
+			input:    StartChildSessionInput{Actor: workContextDelegationActor("agent-claude-code", "agent")},
+			expected: WorkContextDelegationCycle,
+		},
+		{
+			name:     "owner as actor",
+			input:    StartChildSessionInput{Actor: workContextDelegationActor("principal-antoine", "agent")},
+			expected: WorkContextDelegationCycle,
+		},
+		{
+			name:     "TTL above kind cap",
+			input:    StartChildSessionInput{Audience: "warden.tools", Actor: workContextDelegationActor("tool-1", "tool"), TTL: 2 * time.Minute},
+			expected: WorkContextDelegationTTL,
+		},
+		{
+			name:     "TTL beyond parent",
+			input:    StartChildSessionInput{Actor: workContextDelegationActor("agent-2", "agent"), TTL: 6 * time.Minute},
+			expected: WorkContextDelegationTTL,
+		},
+	}
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			testCase.input.SessionID = "session-child"
+			_, _, err := signer.StartChildSession(parent, testCase.input)
+			var denied *WorkContextDelegationError
+			require.True(t, errors.As(err, &denied), "%v", err)
+			require.Equal(t, testCase.expected, denied.Reason)
+			require.Equal(t, "tenant-codefly", denied.TenantID)
+			require.ErrorIs(t, err, ErrWorkContextDelegationDenied)
+			require.NotErrorIs(t, err, ErrWorkContextInvalid)
+			require.Equal(t, WorkContextReasonDelegationDenied, WorkContextErrorReasonOf(err))
+			require.False(t, WorkContextErrorReasonOf(err).Retryable())
+		})
+	}
+
+	child, claims, err := signer.StartChildSession(parent, StartChildSessionInput{
+		SessionID: "session-child", Audience: "warden.tools", Actor: workContextDelegationActor("tool-1", "tool"),
+	})
+	require.NoError(t, err)
+	require.Equal(t, workContextTestTime.Add(time.Minute).Unix(), claims.ExpiresAtUnix, "a default TTL is clamped to the kind cap")
+	_, _, err = signer.StartChildSession(child, StartChildSessionInput{
+		SessionID: "session-grandchild", Audience: "warden.tools", Actor: workContextDelegationActor("tool-2", "tool"),
+	})
+	var denied *WorkContextDelegationError
+	require.ErrorAs(t, err, &denied)
+	require.Equal(t, WorkContextDelegationDepth, denied.Reason)
+}
+
+func TestWorkContextDelegationPolicyKeepsChildrenWithinParentLifetime(t *testing.T) {
+	now := workContextTestTime
+	signer := workContextDelegationSigner(t, &now, &WorkContextDelegationPolicy{})
+	parent, parentClaims, err := signer.StartTask(workContextTestInput())
+	require.NoError(t, err)
+
+	now = now.Add(4 * time.Minute)
+	_, child, err := signer.StartChildSession(parent, StartChildSessionInput{
+		SessionID: "session-child", Actor: workContextDelegationActor("agent-2", "agent"),
+	})
+	require.NoError(t, err)
+	require.Equal(t, parentClaims.ExpiresAtUnix, child.ExpiresAtUnix)
+
+	unrestricted := workContextDelegationSigner(t, &now, nil)
+	_, child, err = unrestricted.StartChildSession(parent, StartChildSessionInput{
+		SessionID: "session-child", Actor: workContextDelegationActor("principal-antoine", "human"),
+	})
+	require.NoError(t, err, "signers without a policy keep the structural checks only")
+	require.Greater(t, child.ExpiresAtUnix, parentClaims.ExpiresAtUnix)
+}
+
+func TestNewWorkContextSignerValidatesDelegationPolicy(t *testing.T) {
+	_, privateKey := workContextTestKeys()
+	for _, policy := range []*WorkContextDelegationPolicy{
+		{DefaultMaxActorDepth: WorkContextMaxActorDepth + 1},
+		{MaxActorDepth: map[string]int{"tenant-codefly": -1}},
+		{MaxActorDepth: map[string]int{"": 2}},
+		{MaxTTL: map[string]time.Duration{"tool": WorkContextMaxTTL + time.Second}},
+		{AllowedPrincipalKinds: map[string][]string{"": {"tool"}}},
+	} {
+		_, err := NewWorkContextSigner(WorkContextSignerOptions{
+			Issuer:           "https://accounts.codefly.dev/work-context",
+			KeyID:            "work-context-test-2026-07",
+			PrivateKey:       privateKey,
+			DelegationPolicy: policy,
+		})
+		require.ErrorIs(t, err, ErrWorkContextInvalid)
+	}
+}
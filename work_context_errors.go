@@ -0,0 +1,118 @@
+package codefly
+
+import "errors"
+
+// WorkContextErrorReason is a stable, machine-readable code for a Work Context
+// failure. Codes are part of the API: new ones may be added, existing ones are
+// never renamed.
+type WorkContextErrorReason string
+
+const (
+	// WorkContextReasonMissing: no Work Context was presented. Re-authenticate.
+	WorkContextReasonMissing WorkContextErrorReason = "missing"
+	// WorkContextReasonMalformed: the token or its carrier is not well formed.
+	// Retrying the same value cannot succeed.
+	WorkContextReasonMalformed WorkContextErrorReason = "malformed"
+	// WorkContextReasonUnknownKey: no trusted key has the token's key ID, even
+	// after a key refresh. Obtain a token from a trusted issuer.
+	WorkContextReasonUnknownKey WorkContextErrorReason = "unknown_key"
+	// WorkContextReasonBadSignature: the signature does not verify.
+	WorkContextReasonBadSignature WorkContextErrorReason = "bad_signature"
+	// WorkContextReasonExpired: the token is past its expiry. Exchange or
+	// re-authenticate for a new one.
+	WorkContextReasonExpired WorkContextErrorReason = "expired"
+	// WorkContextReasonNotYetValid: the token is not active yet, usually
+	// clock skew between issuer and verifier.
+	WorkContextReasonNotYetValid WorkContextErrorReason = "not_yet_valid"
+	// WorkContextReasonAudienceMismatch: the token was minted for another
+	// audience. Exchange it for this audience.
+	WorkContextReasonAudienceMismatch WorkContextErrorReason = "audience_mismatch"
+	// WorkContextReasonClaimsMismatch: an issuer, tenant, owner, task,
+	// session, workspace, project, team, or revision expectation failed.
+	WorkContextReasonClaimsMismatch WorkContextErrorReason = "claims_mismatch"
+	// WorkContextReasonCertificateBinding: the token's certificate binding
+	// does not match the presenting peer.
+	WorkContextReasonCertificateBinding WorkContextErrorReason = "certificate_binding"
+	// WorkContextReasonScopeDenied: the token is valid but does not grant the
+	// required scope. Matches ErrWorkContextDenied.
+	WorkContextReasonScopeDenied WorkContextErrorReason = "scope_denied"
+	// WorkContextReasonDelegationDenied: the signer's delegation policy
+	// refused a child session. Matches ErrWorkContextDelegationDenied.
+	WorkContextReasonDelegationDenied WorkContextErrorReason = "delegation_denied"
+	// WorkContextReasonRevoked: the token was revoked. Matches
+	// ErrWorkContextRevoked.
+	WorkContextReasonRevoked WorkContextErrorReason = "revoked"
+	// WorkContextReasonKeysUnavailable: verification keys could not be
+	// loaded. The token was not judged; retry later.
+	WorkContextReasonKeysUnavailable WorkContextErrorReason = "keys_unavailable"
+	// WorkContextReasonRevocationUnavailable: the revocation list could not
+	// be consulted. The token was not judged; retry later.
+	WorkContextReasonRevocationUnavailable WorkContextErrorReason = "revocation_unavailable"
+	// WorkContextReasonInvalidArgument: the caller passed a nil or invalid
+	// argument. This is a programming error, not a token problem.
+	WorkContextReasonInvalidArgument WorkContextErrorReason = "invalid_argument"
+)
+
+// Retryable reports whether the same token may verify on a later attempt
+// without the client changing anything.
+func (r WorkContextErrorReason) Retryable() bool {
+	return r == WorkContextReasonKeysUnavailable || r == WorkContextReasonRevocationUnavailable
+}
+
+// WorkContextError attaches a reason code to a Work Context failure. It keeps
+// the original message and unwraps to the original error, so errors.Is still
+// matches ErrWorkContextInvalid, ErrWorkContextDenied, and the other sentinels.
+type WorkContextError struct {
+	Reason WorkContextErrorReason
+	err    error
+}
+
+func (e *WorkContextError) Error() string {
+	return e.err.Error()
+}
+
+func (e *WorkContextError) Unwrap() error {
+	return e.err
+}
+
+// workContextFailure attaches reason to err unless err already carries one,
+// so the reason assigned closest to the failure wins.
+func workContextFailure(reason WorkContextErrorReason, err error) error {
+	if err == nil {
+		return nil
+	}
+	var existing *WorkContextError
+	if errors.As(err, &existing) {
+		return err
+	}
+	return &WorkContextError{Reason: reason, err: err}
+}
+
+// WorkContextErrorReasonOf returns the reason code of err. Errors from this
+// package without an explicit reason are classified by sentinel; any other
+// error returns the empty reason.
+func WorkContextErrorReasonOf(err error) WorkContextErrorReason {
+	var typed *WorkContextError
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &typed):
+		return typed.Reason
+	case errors.Is(err, ErrWorkContextDenied):
+		return WorkContextReasonScopeDenied
+	case errors.Is(err, ErrWorkContextDelegationDenied):
+		return WorkContextReasonDelegationDenied
+	case errors.Is(err, ErrWorkContextRevoked):
+		return WorkContextReasonRevoked
+	case errors.Is(err, ErrWorkContextRevocationUnavailable):
+		return WorkContextReasonRevocationUnavailable
+	case errors.Is(err, ErrWorkContextJWKSUnavailable), errors.Is(err, ErrWorkContextJWKSStale):
+		return WorkContextReasonKeysUnavailable
+	case errors.Is(err, ErrWorkContextExpiresBeforeDeadline):
+		return WorkContextReasonExpired
+	case errors.Is(err, ErrWorkContextInvalid):
+		return WorkContextReasonMalformed
+	default:
+		return ""
+	}
+}
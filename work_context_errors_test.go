@@ -0,0 +1,201 @@
+package codefly
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+)
+
+func requireWorkContextReason(t *testing.T, err error, reason WorkContextErrorReason, sentinel error) {
+	t.Helper()
+	var typed *WorkContextError
+	require.True(t, errors.As(err, &typed), "%v", err)
+	require.Equal(t, reason, typed.Reason, "%v", err)
+	require.Equal(t, reason, WorkContextErrorReasonOf(err))
+	require.ErrorIs(t, err, sentinel)
+}
+
+func TestWorkContextVerifyReportsReasonCodes(t *testing.T) {
+	signer := workContextTestSigner(t, workContextTestTime)
+	token, _, err := signer.StartTask(workContextTestInput())
+	require.NoError(t, err)
+	payload, signature, _ := strings.Cut(token.Encoded(), ".")
+	tampered, err := ParseWorkContextToken(payload + "." + strings.Repeat("A", len(signature)))
+	require.NoError(t, err)
+	otherPublic, _ := workContextJWKSKey(9)
+	otherKeys, err := NewWorkContextVerifier(WorkContextVerifierOptions{
+		PublicKeys: map[string]ed25519.PublicKey{"another-key": otherPublic},
+		Now:        func() time.Time { return workContextTestTime },
+	})
+	require.NoError(t, err)
+
+	cases := []struct {
+		name     string
+		verifier *WorkContextVerifier
+		token    WorkContextToken
+		expected WorkContextExpectations
+		reason   WorkContextErrorReason
+	}{
+		{"malformed", workContextTestVerifier(t, workContextTestTime), opaqueTestWorkContext(t), WorkContextExpectations{}, WorkContextReasonMalformed},
+		{"unknown key", otherKeys, token, WorkContextExpectations{}, WorkContextReasonUnknownKey},
+		{"bad signature", workContextTestVerifier(t, workContextTestTime), tampered, WorkContextExpectations{}, WorkContextReasonBadSignature},
+		{"expired", workContextTestVerifier(t, workContextTestTime.Add(time.Hour)), token, WorkContextExpectations{}, WorkContextReasonExpired},
+		{"not yet valid", workContextTestVerifier(t, workContextTestTime.Add(-time.Hour)), token, WorkContextExpectations{}, WorkContextReasonNotYetValid},
+		{
+			"audience", workContextTestVerifier(t, workContextTestTime), token,
+			WorkContextExpectations{Audience: "warden.tools"}, WorkContextReasonAudienceMismatch,
+		},
+		{
+			"audiences", workContextTestVerifier(t, workContextTestTime), token,
+			WorkContextExpectations{Audiences: []string{"warden.tools"}}, WorkContextReasonAudienceMismatch,
+		},
+		{
+			"tenant", workContextTestVerifier(t, workContextTestTime), token,
+			WorkContextExpectations{TenantID: "tenant-other"}, WorkContextReasonClaimsMismatch,
+		},
+		{
+			"binding required", workContextTestVerifier(t, workContextTestTime), token,
+			WorkContextExpectations{RequireCertificateBinding: true}, WorkContextReasonCertificateBinding,
+		},
+	}
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			_, err := testCase.verifier.Verify(testCase.token, testCase.expected)
+			requireWorkContextReason(t, err, testCase.reason, ErrWorkContextInvalid)
+			require.False(t, testCase.reason.Retryable())
+		})
+	}
+
+	var nilVerifier *WorkContextVerifier
+	_, err = nilVerifier.Verify(token, WorkContextExpectations{})
+	requireWorkContextReason(t, err, WorkContextReasonInvalidArgument, ErrWorkContextInvalid)
+
+	revocations, err := NewWorkContextRevocationList(WorkContextRevocationListOptions{
+		Revocations: WorkContextRevocations{Tasks: revokedWorkContextTasks("task-roadmap")},
+	})
+	require.NoError(t, err)
+	publicKey, _ := workContextTestKeys()
+	revoking, err := NewWorkContextVerifier(WorkContextVerifierOptions{
+		PublicKeys:  map[string]ed25519.PublicKey{"work-context-test-2026-07": publicKey},
+		Now:         func() time.Time { return workContextTestTime },
+		Revocations: revocations,
+	})
+	require.NoError(t, err)
+	_, err = revoking.Verify(token, WorkContextExpectations{})
+	requireWorkContextReason(t, err, WorkContextReasonRevoked, ErrWorkContextRevoked)
+
+	feed, err := NewWorkContextRevocationFeed(WorkContextRevocationFeedOptions{
+		File: filepath.Join(t.TempDir(), "missing.json"),
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, feed.Close()) })
+	unavailable, err := NewWorkContextVerifier(WorkContextVerifierOptions{
+		PublicKeys:  map[string]ed25519.PublicKey{"work-context-test-2026-07": publicKey},
+		Now:         func() time.Time { return workContextTestTime },
+		Revocations: feed,
+	})
+	require.NoError(t, err)
+	_, err = unavailable.Verify(token, WorkContextExpectations{})
+	requireWorkContextReason(t, err, WorkContextReasonRevocationUnavailable, ErrWorkContextRevocationUnavailable)
+	require.True(t, WorkContextErrorReasonOf(err).Retryable())
+}
+
+func TestRequireWorkContextScopeReportsReasonCodes(t *testing.T) {
+	_, claims, err := workContextTestSigner(t, workContextTestTime).StartTask(workContextTestInput())
+	require.NoError(t, err)
+
+	err = RequireWorkContextScope(claims, WorkContextScopeRequirement{
+		ResourceKind: "repository", Action: "delete", ResourceID: "repo-warden",
+	})
+	requireWorkContextReason(t, err, WorkContextReasonScopeDenied, ErrWorkContextDenied)
+	require.Equal(t, "Codefly Work Context scope denied: repository:delete:repo-warden", err.Error())
+
+	err = RequireWorkContextScope(claims, WorkContextScopeRequirement{ResourceKind: "repository"})
+	requireWorkContextReason(t, err, WorkContextReasonInvalidArgument, ErrWorkContextInvalid)
+
+	claims.Typ = "jwt"
+	err = RequireWorkContextScope(claims, WorkContextScopeRequirement{ResourceKind: "repository", Action: "read"})
+	requireWorkContextReason(t, err, WorkContextReasonMalformed, ErrWorkContextInvalid)
+}
+
+func TestWorkContextJWKSVerifierReportsReasonCodes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		writer.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+	verifier, err := NewWorkContextJWKSVerifier(WorkContextJWKSVerifierOptions{
+		URL: server.URL, HTTPClient: server.Client(), Now: func() time.Time { return workContextTestTime },
+	})
+	require.NoError(t, err)
+	_, privateKey := workContextJWKSKey(1)
+	_, err = verifier.Verify(t.Context(), workContextJWKSToken(t, "key-1", privateKey), WorkContextExpectations{})
+	requireWorkContextReason(t, err, WorkContextReasonKeysUnavailable, ErrWorkContextInvalid)
+	require.True(t, WorkContextErrorReasonOf(err).Retryable())
+	var jwksError *workContextJWKSError
+	require.ErrorAs(t, err, &jwksError, "the JWKS error class is preserved underneath")
+	require.Equal(t, WorkContextJWKSErrorHTTPStatus, jwksError.class)
+
+	_, err = verifier.Verify(t.Context(), opaqueTestWorkContext(t), WorkContextExpectations{})
+	requireWorkContextReason(t, err, WorkContextReasonMalformed, ErrWorkContextInvalid)
+	var missing context.Context
+	_, err = verifier.Verify(missing, opaqueTestWorkContext(t), WorkContextExpectations{})
+	requireWorkContextReason(t, err, WorkContextReasonInvalidArgument, ErrWorkContextInvalid)
+}
+
+func TestGRPCExecutionContextReportsReasonCodes(t *testing.T) {
+	encoded := opaqueTestWorkContext(t).Encoded()
+	cases := []struct {
+		name   string
+		ctx    context.Context
+		reason WorkContextErrorReason
+	}{
+		{"no metadata", context.Background(), WorkContextReasonMissing},
+		{
+			"no Work Context",
+			metadata.NewIncomingContext(context.Background(), metadata.Pairs(operationIDGRPCMetadataName, "operation-1")),
+			WorkContextReasonMissing,
+		},
+		{
+			"duplicate Work Context",
+			metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+				workContextGRPCMetadataName, encoded, workContextGRPCMetadataName, encoded,
+				operationIDGRPCMetadataName, "operation-1",
+			)),
+			WorkContextReasonMalformed,
+		},
+		{
+			"bad operation ID",
+			metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+				workContextGRPCMetadataName, encoded, operationIDGRPCMetadataName, " operation-1",
+			)),
+			WorkContextReasonMalformed,
+		},
+		{
+			"bad token",
+			metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+				workContextGRPCMetadataName, "not-a-token", operationIDGRPCMetadataName, "operation-1",
+			)),
+			WorkContextReasonMalformed,
+		},
+	}
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			_, err := GRPCExecutionContextFromIncoming(testCase.ctx)
+			requireWorkContextReason(t, err, testCase.reason, ErrWorkContextInvalid)
+		})
+	}
+
+	_, err := WorkContextFromHeaders(http.Header{})
+	requireWorkContextReason(t, err, WorkContextReasonMissing, ErrWorkContextInvalid)
+	require.Equal(t, WorkContextErrorReason(""), WorkContextErrorReasonOf(errors.New("unrelated")))
+	require.Equal(t, WorkContextReasonMalformed, WorkContextErrorReasonOf(ErrWorkContextInvalid))
+}
@@ -6,10 +6,10 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"io"
-	"mime"
 	"net/http"
 	"net/url"
+	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -24,18 +24,60 @@ const (
 	maxWorkContextJWKSRequestTimeout     = 30 * time.Second
 	maxWorkContextJWKSBytes              = 256 * 1024
 	maxWorkContextJWKSKeys               = 64
+	maxWorkContextJWKSStaleness          = 24 * time.Hour
+	defaultWorkContextJWKSMinCacheTTL    = 30 * time.Second
+	maxWorkContextJWKSURLs               = 8
 )
 
 // WorkContextJWKSVerifierOptions configures rotation-aware public Work Context
 // verification. The verifier caches only public keys and never stores bearer
-// tokens or private material.
+// tokens or private material. Exactly one key source is set: URL, URLs, or
+// File. Generation tracking, unknown-key refreshes, staleness, and background
+// refresh behave the same for every source.
 type WorkContextJWKSVerifierOptions struct {
-	URL            string
-	HTTPClient     *http.Client
+	URL string
+	// URLs lists equivalent JWKS endpoints in order of preference. Every
+	// refresh starts at the first URL and fails over to the next one when a
+	// request fails; RequestTimeout applies to each attempt.
+	URLs []string
+	// File loads the JWKS from a local file for air-gapped and local
+	// environments. The file is polled every CacheTTL and reloaded only when
+	// its inode, modification time, or size changes. It must be a regular,
+	// non-symlinked file that is not writable by group or world.
+	File       string
+	HTTPClient *http.Client
+	// CacheTTL applies when the authority sends no usable Cache-Control
+	// max-age. A max-age is honoured within [MinCacheTTL, MaxCacheTTL], which
+	// default to min(CacheTTL, 30s) and CacheTTL, so by default the authority
+	// can only shorten caching.
 	CacheTTL       time.Duration
+	MinCacheTTL    time.Duration
+	MaxCacheTTL    time.Duration
 	RequestTimeout time.Duration
 	Now            func() time.Time
 	ClockSkew      time.Duration
+	// ExpectRuntimeWorkspace has the same meaning as on
+	// WorkContextVerifierOptions and is resolved once at construction.
+	ExpectRuntimeWorkspace bool
+	// CacheSize enables the verification cache of WorkContextVerifierOptions.
+	// It survives refreshes that return an identical key set and is dropped
+	// whenever the keys change.
+	CacheSize int
+	// BackgroundRefresh starts a goroutine that loads the JWKS immediately and
+	// refreshes it ahead of CacheTTL at jittered intervals, so Verify does not
+	// wait on the authority. Call Close to stop it.
+	BackgroundRefresh bool
+	// MaxStaleness keeps the previous key set serving for this long past its
+	// CacheTTL while refreshes fail. Zero fails verification as soon as an
+	// expired key set cannot be refreshed.
+	MaxStaleness time.Duration
+	// RootKeys pins offline root keys by key ID. When set, every JWKS document
+	// must carry a codefly_signature from one of them, directly or through a
+	// rotation chain (see SignWorkContextJWKS), so whoever can tamper with the
+	// transport or file still cannot inject signing keys.
+	RootKeys map[string]ed25519.PublicKey
+	// Revocations has the same meaning as on WorkContextVerifierOptions.
+	Revocations WorkContextRevocationChecker
 }
 
 // WorkContextJWKSVerifier verifies signed Work Contexts against a bounded,
@@ -43,28 +85,39 @@ type WorkContextJWKSVerifierOptions struct {
 // so rotation is picked up immediately without a request stampede.
 type WorkContextJWKSVerifier struct {
 	mu                       sync.Mutex
-	url                      string
-	httpClient               *http.Client
+	source                   workContextJWKSSource
 	cacheTTL                 time.Duration
-	requestTimeout           time.Duration
+	minCacheTTL              time.Duration
+	maxCacheTTL              time.Duration
 	now                      func() time.Time
 	clockSkew                time.Duration
+	workspace                string
+	cacheSize                int
+	maxStaleness             time.Duration
+	revocations              WorkContextRevocationChecker
 	verifier                 *WorkContextVerifier
 	keyIDs                   map[string]struct{}
+	validators               workContextJWKSValidators
 	expiresAt                time.Time
+	signedUntil              time.Time
+	acceptedSequence         uint64
 	generation               uint64
 	unknownRefreshGeneration uint64
+	lastRefreshError         error
+	lastRefreshSuccess       time.Time
+	lastRefreshFailure       time.Time
+	retryAt                  time.Time
+	stopRefresh              context.CancelFunc
+	refreshDone              chan struct{}
+	closeOnce                sync.Once
 }
 
 // NewWorkContextJWKSVerifier validates configuration without performing
-// network I/O. The first Verify call fetches the JWKS.
+// network I/O. The first Verify call fetches the JWKS unless BackgroundRefresh
+// is set, in which case the refresher goroutine fetches it immediately.
 func NewWorkContextJWKSVerifier(
 	options WorkContextJWKSVerifierOptions,
 ) (*WorkContextJWKSVerifier, error) {
-	endpoint, err := validateWorkContextJWKSURL(options.URL)
-	if err != nil {
-		return nil, err
-	}
 	cacheTTL := options.CacheTTL
 	if cacheTTL == 0 {
 		cacheTTL = defaultWorkContextJWKSCacheTTL
@@ -76,6 +129,22 @@ func NewWorkContextJWKSVerifier(
 			maxWorkContextJWKSCacheTTL,
 		)
 	}
+	maxCacheTTL := options.MaxCacheTTL
+	if maxCacheTTL == 0 {
+		maxCacheTTL = cacheTTL
+	}
+	minCacheTTL := options.MinCacheTTL
+	if minCacheTTL == 0 {
+		minCacheTTL = min(cacheTTL, defaultWorkContextJWKSMinCacheTTL)
+	}
+	if minCacheTTL < time.Second || maxCacheTTL > maxWorkContextJWKSCacheTTL ||
+		minCacheTTL > cacheTTL || cacheTTL > maxCacheTTL {
+		return nil, fmt.Errorf(
+			"%w: JWKS cache TTL bounds must satisfy 1s <= min <= TTL <= max <= %s",
+			ErrWorkContextInvalid,
+			maxWorkContextJWKSCacheTTL,
+		)
+	}
 	requestTimeout := options.RequestTimeout
 	if requestTimeout == 0 {
 		requestTimeout = defaultWorkContextJWKSRequestTimeout
@@ -91,18 +160,45 @@ func NewWorkContextJWKSVerifier(
 	if now == nil {
 		now = time.Now
 	}
-	client := options.HTTPClient
-	if client == nil {
-		client = &http.Client{
-			CheckRedirect: func(_ *http.Request, _ []*http.Request) error {
-				return http.ErrUseLastResponse
-			},
+	if options.CacheSize < 0 || options.CacheSize > maxWorkContextVerificationCacheEntries {
+		return nil, fmt.Errorf(
+			"%w: verification cache size must be between 0 and %d",
+			ErrWorkContextInvalid,
+			maxWorkContextVerificationCacheEntries,
+		)
+	}
+	if options.MaxStaleness < 0 || options.MaxStaleness > maxWorkContextJWKSStaleness {
+		return nil, fmt.Errorf(
+			"%w: JWKS max staleness must be between zero and %s",
+			ErrWorkContextInvalid,
+			maxWorkContextJWKSStaleness,
+		)
+	}
+	roots, err := validateWorkContextJWKSRootKeys(options.RootKeys)
+	if err != nil {
+		return nil, err
+	}
+	source, err := newWorkContextJWKSSource(options, requestTimeout, roots)
+	if err != nil {
+		return nil, err
+	}
+	workspace := ""
+	if options.ExpectRuntimeWorkspace {
+		workspace, err = runtimeWorkContextWorkspace()
+		if err != nil {
+			return nil, err
 		}
 	}
-	return &WorkContextJWKSVerifier{
-		url: endpoint, httpClient: client, cacheTTL: cacheTTL,
-		requestTimeout: requestTimeout, now: now, clockSkew: options.ClockSkew,
-	}, nil
+	verifier := &WorkContextJWKSVerifier{
+		source: source, cacheTTL: cacheTTL, minCacheTTL: minCacheTTL, maxCacheTTL: maxCacheTTL,
+		now: now, clockSkew: options.ClockSkew,
+		workspace: workspace, cacheSize: options.CacheSize, maxStaleness: options.MaxStaleness,
+		revocations: options.Revocations,
+	}
+	if options.BackgroundRefresh {
+		verifier.startBackgroundRefresh()
+	}
+	return verifier, nil
 }
 
 // Verify establishes Work Context trust using the current cached JWKS.
@@ -112,18 +208,24 @@ func (v *WorkContextJWKSVerifier) Verify(
 	expected WorkContextExpectations,
 ) (*basev0.WorkContextV1, error) {
 	if v == nil {
-		return nil, fmt.Errorf("%w: nil JWKS verifier", ErrWorkContextInvalid)
+		return nil, workContextFailure(
+			WorkContextReasonInvalidArgument,
+			fmt.Errorf("%w: nil JWKS verifier", ErrWorkContextInvalid),
+		)
 	}
 	if ctx == nil {
-		return nil, fmt.Errorf("%w: nil context", ErrWorkContextInvalid)
+		return nil, workContextFailure(
+			WorkContextReasonInvalidArgument,
+			fmt.Errorf("%w: nil context", ErrWorkContextInvalid),
+		)
 	}
 	keyID, err := workContextTokenKeyID(token)
 	if err != nil {
-		return nil, err
+		return nil, workContextFailure(WorkContextReasonMalformed, err)
 	}
 	verifier, keyIDs, generation, err := v.current(ctx)
 	if err != nil {
-		return nil, err
+		return nil, workContextFailure(WorkContextReasonKeysUnavailable, err)
 	}
 	if _, known := keyIDs[keyID]; known {
 		return verifier.Verify(token, expected)
@@ -133,7 +235,7 @@ func (v *WorkContextJWKSVerifier) Verify(
 	// the observed generation lets that refresh satisfy every waiter.
 	verifier, _, _, err = v.refreshUnknown(ctx, generation)
 	if err != nil {
-		return nil, err
+		return nil, workContextFailure(WorkContextReasonKeysUnavailable, err)
 	}
 	return verifier.Verify(token, expected)
 }
@@ -143,7 +245,14 @@ func (v *WorkContextJWKSVerifier) current(
 ) (*WorkContextVerifier, map[string]struct{}, uint64, error) {
 	v.mu.Lock()
 	defer v.mu.Unlock()
-	if v.verifier != nil && v.now().UTC().Before(v.expiresAt) {
+	now := v.now().UTC()
+	if v.verifier != nil && now.Before(v.expiresAt) {
+		return v.verifier, cloneKeyIDs(v.keyIDs), v.generation, nil
+	}
+	if v.servingStaleLocked(now) && now.Before(v.retryAt) {
+		// Failed refreshes are retried at a bounded rate; in between, the
+		// previous keys keep serving instead of every caller hitting the
+		// unavailable authority.
 		return v.verifier, cloneKeyIDs(v.keyIDs), v.generation, nil
 	}
 	verifier, keyIDs, generation, err := v.refreshLocked(ctx)
@@ -151,8 +260,13 @@ func (v *WorkContextJWKSVerifier) current(
 		// A scheduled cache refresh starts a fresh generation in which one
 		// unknown key may force an early refresh for normal key rotation.
 		v.unknownRefreshGeneration = 0
+		return verifier, keyIDs, generation, nil
+	}
+	v.recordRefreshFailureLocked(now, err)
+	if v.servingStaleLocked(now) {
+		return v.verifier, cloneKeyIDs(v.keyIDs), v.generation, nil
 	}
-	return verifier, keyIDs, generation, err
+	return nil, nil, generation, err
 }
 
 func (v *WorkContextJWKSVerifier) refreshUnknown(
@@ -174,6 +288,7 @@ func (v *WorkContextJWKSVerifier) refreshUnknown(
 	v.unknownRefreshGeneration = v.generation
 	verifier, keyIDs, generation, err := v.refreshLocked(ctx)
 	if err != nil {
+		v.recordRefreshFailureLocked(v.now().UTC(), err)
 		return nil, nil, generation, err
 	}
 	v.unknownRefreshGeneration = generation
@@ -183,79 +298,192 @@ func (v *WorkContextJWKSVerifier) refreshUnknown(
 func (v *WorkContextJWKSVerifier) refreshLocked(
 	ctx context.Context,
 ) (*WorkContextVerifier, map[string]struct{}, uint64, error) {
-	keys, err := v.fetch(ctx)
+	response, err := v.source.fetch(ctx, v.currentValidatorsLocked())
 	if err != nil {
 		return nil, nil, v.generation, err
 	}
-	verifier, err := NewWorkContextVerifier(WorkContextVerifierOptions{
-		PublicKeys: keys,
-		Now:        v.now,
-		ClockSkew:  v.clockSkew,
-	})
-	if err != nil {
-		return nil, nil, v.generation, err
+	return v.applyLocked(response)
+}
+
+// applyLocked installs a fetched JWKS response. Fetching is separate so the
+// background refresher can perform network I/O without holding the lock that
+// Verify needs.
+func (v *WorkContextJWKSVerifier) applyLocked(
+	response workContextJWKSResponse,
+) (*WorkContextVerifier, map[string]struct{}, uint64, error) {
+	if !response.notModified {
+		return v.installLocked(response)
+	}
+	if v.verifier == nil {
+		return nil, nil, v.generation, workContextJWKSFailure(WorkContextJWKSErrorProtocol, fmt.Errorf(
+			"%w: Work Context JWKS returned 304 without a cached key set",
+			ErrWorkContextInvalid,
+		))
+	}
+	// 304 Not Modified revalidates the current generation: the verifier, its
+	// cache, and the unknown-key refresh window are kept; only freshness moves,
+	// and never past the expiry the document was signed with.
+	now := v.now().UTC()
+	if !v.signedUntil.IsZero() && !now.Before(v.signedUntil) {
+		return nil, nil, v.generation, workContextJWKSFailure(WorkContextJWKSErrorSignature, fmt.Errorf(
+			"%w: Work Context JWKS signature expired and the authority did not send a newer document",
+			ErrWorkContextInvalid,
+		))
+	}
+	v.expiresAt = v.signedFreshnessLocked(now, response.maxAge)
+	v.lastRefreshSuccess = now
+	v.lastRefreshError = nil
+	v.retryAt = time.Time{}
+	return v.verifier, cloneKeyIDs(v.keyIDs), v.generation, nil
+}
+
+// installLocked replaces the key set with a freshly fetched one and opens a new
+// generation.
+func (v *WorkContextJWKSVerifier) installLocked(
+	response workContextJWKSResponse,
+) (*WorkContextVerifier, map[string]struct{}, uint64, error) {
+	keys := response.keys
+	now := v.now().UTC()
+	if validity := response.validity; validity != nil {
+		if !now.Before(validity.expiresAt) {
+			return nil, nil, v.generation, workContextJWKSFailure(WorkContextJWKSErrorSignature, fmt.Errorf(
+				"%w: Work Context JWKS signature expired at %s",
+				ErrWorkContextInvalid,
+				validity.expiresAt.Format(time.RFC3339),
+			))
+		}
+		if validity.sequence < v.acceptedSequence {
+			return nil, nil, v.generation, workContextJWKSFailure(WorkContextJWKSErrorSignature, fmt.Errorf(
+				"%w: Work Context JWKS sequence %d is older than the accepted %d",
+				ErrWorkContextInvalid,
+				validity.sequence,
+				v.acceptedSequence,
+			))
+		}
+	}
+	var verifier *WorkContextVerifier
+	var err error
+	if v.verifier != nil && v.verifier.cache != nil && samePublicKeys(v.verifier.publicKeys, keys) {
+		// Unchanged keys: keep the verifier and its authenticated entries
+		// instead of paying for a cold cache after every scheduled refresh.
+		verifier = v.verifier
+	} else {
+		verifier, err = newWorkContextVerifier(WorkContextVerifierOptions{
+			PublicKeys:  keys,
+			Now:         v.now,
+			ClockSkew:   v.clockSkew,
+			CacheSize:   v.cacheSize,
+			Revocations: v.revocations,
+		}, v.workspace)
+		if err != nil {
+			return nil, nil, v.generation, err
+		}
 	}
 	keyIDs := make(map[string]struct{}, len(keys))
 	for keyID := range keys {
 		keyIDs[keyID] = struct{}{}
 	}
 	if v.generation == ^uint64(0) {
-		return nil, nil, v.generation, fmt.Errorf("%w: JWKS generation exhausted", ErrWorkContextInvalid)
+		return nil, nil, v.generation, workContextJWKSFailure(
+			WorkContextJWKSErrorInternal,
+			fmt.Errorf("%w: JWKS generation exhausted", ErrWorkContextInvalid),
+		)
 	}
 	v.verifier = verifier
 	v.keyIDs = keyIDs
-	v.expiresAt = v.now().UTC().Add(v.cacheTTL)
+	v.validators = response.validators
+	v.signedUntil = time.Time{}
+	if response.validity != nil {
+		v.signedUntil = response.validity.expiresAt
+		v.acceptedSequence = response.validity.sequence
+	}
+	v.expiresAt = v.signedFreshnessLocked(now, response.maxAge)
 	v.generation++
+	v.lastRefreshSuccess = now
+	v.lastRefreshError = nil
+	v.retryAt = time.Time{}
 	return verifier, cloneKeyIDs(keyIDs), v.generation, nil
 }
 
-func (v *WorkContextJWKSVerifier) fetch(ctx context.Context) (map[string]ed25519.PublicKey, error) {
-	requestContext, cancel := context.WithTimeout(ctx, v.requestTimeout)
-	defer cancel()
-	request, err := http.NewRequestWithContext(requestContext, http.MethodGet, v.url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("%w: create JWKS request: %v", ErrWorkContextInvalid, err)
-	}
-	request.Header.Set("Accept", "application/json")
-	response, err := v.httpClient.Do(request)
-	if err != nil {
-		return nil, fmt.Errorf("%w: fetch Work Context JWKS: %v", ErrWorkContextInvalid, err)
-	}
-	defer response.Body.Close()
-	if response.Request != nil && response.Request.URL != nil &&
-		response.Request.URL.String() != v.url {
-		return nil, fmt.Errorf("%w: Work Context JWKS redirected", ErrWorkContextInvalid)
+// workContextJWKSValidators identify the installed key set at its source so
+// the next refresh can skip an unchanged document: HTTP cache validators
+// replayed as conditional request headers, or the stat of a JWKS file.
+type workContextJWKSValidators struct {
+	origin       string
+	etag         string
+	lastModified string
+	file         os.FileInfo
+}
+
+type workContextJWKSResponse struct {
+	keys map[string]ed25519.PublicKey
+	// validity is the signed period of the document, nil without RootKeys.
+	validity    *workContextJWKSValidity
+	notModified bool
+	validators  workContextJWKSValidators
+	maxAge      *time.Duration
+}
+
+func (v *WorkContextJWKSVerifier) currentValidatorsLocked() workContextJWKSValidators {
+	if v.verifier == nil {
+		return workContextJWKSValidators{}
 	}
-	if response.StatusCode != http.StatusOK {
-		_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 4*1024))
-		return nil, fmt.Errorf(
-			"%w: Work Context JWKS returned HTTP %d",
-			ErrWorkContextInvalid,
-			response.StatusCode,
-		)
+	return v.validators
+}
+
+// signedFreshnessLocked is when a key set validated at now must be refreshed:
+// after its freshness lifetime, and no later than its signed expiry.
+func (v *WorkContextJWKSVerifier) signedFreshnessLocked(now time.Time, maxAge *time.Duration) time.Time {
+	expiresAt := now.Add(v.freshness(maxAge))
+	if !v.signedUntil.IsZero() && v.signedUntil.Before(expiresAt) {
+		return v.signedUntil
+	}
+	return expiresAt
+}
+
+// freshness clamps an authority-provided max-age to the configured bounds.
+func (v *WorkContextJWKSVerifier) freshness(maxAge *time.Duration) time.Duration {
+	if maxAge == nil {
+		return v.cacheTTL
 	}
-	if contentType := response.Header.Get("Content-Type"); contentType != "" {
-		mediaType, _, parseErr := mime.ParseMediaType(contentType)
-		if parseErr != nil || mediaType != "application/json" {
-			return nil, fmt.Errorf("%w: Work Context JWKS is not application/json", ErrWorkContextInvalid)
+	return min(max(*maxAge, v.minCacheTTL), v.maxCacheTTL)
+}
+
+// parseWorkContextJWKSMaxAge returns the remaining freshness lifetime from
+// Cache-Control and Age, or nil when the response does not state one.
+// no-cache and no-store yield zero, which freshness raises to MinCacheTTL.
+func parseWorkContextJWKSMaxAge(header http.Header) *time.Duration {
+	var maxAge *time.Duration
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			name, argument, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			switch strings.ToLower(name) {
+			case "no-cache", "no-store":
+				zero := time.Duration(0)
+				return &zero
+			case "max-age":
+				seconds, err := strconv.ParseInt(strings.Trim(argument, `"`), 10, 64)
+				if err != nil || seconds < 0 {
+					continue
+				}
+				ttl := time.Duration(min(seconds, int64(maxWorkContextJWKSCacheTTL/time.Second))) * time.Second
+				maxAge = &ttl
+			}
 		}
 	}
-	payload, err := io.ReadAll(io.LimitReader(response.Body, maxWorkContextJWKSBytes+1))
-	if err != nil {
-		return nil, fmt.Errorf("%w: read Work Context JWKS: %v", ErrWorkContextInvalid, err)
+	if maxAge == nil {
+		return nil
 	}
-	if len(payload) > maxWorkContextJWKSBytes {
-		return nil, fmt.Errorf(
-			"%w: Work Context JWKS exceeds %d bytes",
-			ErrWorkContextInvalid,
-			maxWorkContextJWKSBytes,
-		)
+	if age, err := strconv.ParseInt(header.Get("Age"), 10, 64); err == nil && age > 0 {
+		remaining := max(*maxAge-time.Duration(min(age, int64(maxWorkContextJWKSCacheTTL/time.Second)))*time.Second, 0)
+		maxAge = &remaining
 	}
-	return parseWorkContextJWKS(payload)
+	return maxAge
 }
 
 type workContextJWKS struct {
-	Keys []workContextJWK `json:"keys"`
+	Keys      []workContextJWK          `json:"keys"`
+	Signature *workContextJWKSSignature `json:"codefly_signature,omitempty"`
 }
 
 type workContextJWK struct {
@@ -267,13 +495,27 @@ type workContextJWK struct {
 	X       string `json:"x"`
 }
 
-func parseWorkContextJWKS(payload []byte) (map[string]ed25519.PublicKey, error) {
+// parseWorkContextJWKS decodes a JWKS document. With pinned roots, the root
+// signature is verified before any key is considered and the signed period is
+// returned; without them it is nil.
+func parseWorkContextJWKS(
+	payload []byte,
+	roots map[string]ed25519.PublicKey,
+) (map[string]ed25519.PublicKey, *workContextJWKSValidity, error) {
 	var document workContextJWKS
 	if err := json.Unmarshal(payload, &document); err != nil {
-		return nil, fmt.Errorf("%w: decode Work Context JWKS: %v", ErrWorkContextInvalid, err)
+		return nil, nil, fmt.Errorf("%w: decode Work Context JWKS: %v", ErrWorkContextInvalid, err)
+	}
+	var validity *workContextJWKSValidity
+	if len(roots) > 0 {
+		var err error
+		validity, err = verifyWorkContextJWKSSignature(document, roots)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 	if len(document.Keys) == 0 || len(document.Keys) > maxWorkContextJWKSKeys {
-		return nil, fmt.Errorf(
+		return nil, nil, fmt.Errorf(
 			"%w: Work Context JWKS must contain between 1 and %d keys",
 			ErrWorkContextInvalid,
 			maxWorkContextJWKSKeys,
@@ -284,30 +526,30 @@ func parseWorkContextJWKS(payload []byte) (map[string]ed25519.PublicKey, error)
 		if key.KeyType != "OKP" || key.Curve != "Ed25519" ||
 			key.Alg != "" && key.Alg != "EdDSA" ||
 			key.Use != "" && key.Use != "sig" {
-			return nil, fmt.Errorf("%w: JWKS contains a non-Ed25519 signing key", ErrWorkContextInvalid)
+			return nil, nil, fmt.Errorf("%w: JWKS contains a non-Ed25519 signing key", ErrWorkContextInvalid)
 		}
 		if err := validateBounded("key_id", key.KeyID, workContextMaxKindBytes, true); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		decoded, err := base64.RawURLEncoding.DecodeString(key.X)
 		if err != nil || len(decoded) != ed25519.PublicKeySize {
-			return nil, fmt.Errorf(
+			return nil, nil, fmt.Errorf(
 				"%w: JWKS key %q has an invalid Ed25519 public key",
 				ErrWorkContextInvalid,
 				key.KeyID,
 			)
 		}
 		if _, duplicate := keys[key.KeyID]; duplicate {
-			return nil, fmt.Errorf("%w: JWKS has duplicate key ID %q", ErrWorkContextInvalid, key.KeyID)
+			return nil, nil, fmt.Errorf("%w: JWKS has duplicate key ID %q", ErrWorkContextInvalid, key.KeyID)
 		}
 		keys[key.KeyID] = append(ed25519.PublicKey(nil), decoded...)
 	}
-	return keys, nil
+	return keys, validity, nil
 }
 
 func workContextTokenKeyID(token WorkContextToken) (string, error) {
 	if token.empty() {
-		return "", fmt.Errorf("%w: empty token", ErrWorkContextInvalid)
+		return "", workContextFailure(WorkContextReasonMissing, fmt.Errorf("%w: empty token", ErrWorkContextInvalid))
 	}
 	payloadSegment, _, found := strings.Cut(token.encoded, ".")
 	if !found {
@@ -0,0 +1,153 @@
+package codefly
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+const defaultWorkContextJWKSRetryInterval = 5 * time.Second
+
+var (
+	// ErrWorkContextJWKSStale reports that the previous key set is serving
+	// past its refresh time because the authority cannot be reached.
+	ErrWorkContextJWKSStale = errors.New("Codefly Work Context JWKS is stale")
+	// ErrWorkContextJWKSUnavailable reports that no trustworthy key set can
+	// currently be served.
+	ErrWorkContextJWKSUnavailable = errors.New("Codefly Work Context JWKS is unavailable")
+)
+
+// Health reports whether the verifier can serve a current key set. It returns
+// nil while keys are fresh, ErrWorkContextJWKSStale while MaxStaleness keeps a
+// previous key set serving through an authority outage, and
+// ErrWorkContextJWKSUnavailable when no key set is loaded or the last one can
+// no longer be trusted. Both errors carry the last refresh failure.
+func (v *WorkContextJWKSVerifier) Health() error {
+	if v == nil {
+		return fmt.Errorf("%w: nil JWKS verifier", ErrWorkContextJWKSUnavailable)
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	now := v.now().UTC()
+	switch {
+	case v.verifier == nil && v.lastRefreshError != nil:
+		return fmt.Errorf("%w: %v", ErrWorkContextJWKSUnavailable, v.lastRefreshError)
+	case v.verifier == nil:
+		return fmt.Errorf("%w: no key set loaded", ErrWorkContextJWKSUnavailable)
+	case now.Before(v.expiresAt) || v.lastRefreshError == nil:
+		// An expired key set that nobody has tried to refresh yet is not a
+		// failure; the next Verify refreshes it.
+		return nil
+	case v.servingStaleLocked(now):
+		return fmt.Errorf(
+			"%w: serving keys %s past refresh: %v",
+			ErrWorkContextJWKSStale,
+			now.Sub(v.expiresAt).Truncate(time.Second),
+			v.lastRefreshError,
+		)
+	default:
+		return fmt.Errorf("%w: %v", ErrWorkContextJWKSUnavailable, v.lastRefreshError)
+	}
+}
+
+// Close stops the background refresher and waits for it to exit. It is safe to
+// call more than once and on verifiers without BackgroundRefresh. Verify keeps
+// working after Close, refreshing synchronously.
+func (v *WorkContextJWKSVerifier) Close() error {
+	if v == nil {
+		return nil
+	}
+	v.closeOnce.Do(func() {
+		if v.stopRefresh == nil {
+			return
+		}
+		v.stopRefresh()
+		<-v.refreshDone
+	})
+	return nil
+}
+
+func (v *WorkContextJWKSVerifier) startBackgroundRefresh() {
+	ctx, cancel := context.WithCancel(context.Background())
+	v.stopRefresh = cancel
+	v.refreshDone = make(chan struct{})
+	go v.refreshLoop(ctx)
+}
+
+func (v *WorkContextJWKSVerifier) refreshLoop(ctx context.Context) {
+	defer close(v.refreshDone)
+	delay := time.Duration(0)
+	for {
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		delay = v.backgroundRefresh(ctx)
+	}
+}
+
+// backgroundRefresh performs one refresh and returns the delay until the next.
+// Successful refreshes are scheduled in the last quarter of the key set's
+// freshness lifetime and failures are retried after a short interval; both are
+// jittered so a fleet of pods does not synchronize on the authority.
+func (v *WorkContextJWKSVerifier) backgroundRefresh(ctx context.Context) time.Duration {
+	v.mu.Lock()
+	validators := v.currentValidatorsLocked()
+	generation := v.generation
+	v.mu.Unlock()
+	response, err := v.source.fetch(ctx, validators)
+	if ctx.Err() != nil {
+		return 0
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	// A 304 revalidates the generation whose validators were sent. If Verify
+	// installed another while the request was in flight, the 304 says nothing
+	// about it, so keep the new generation's freshness as it was.
+	stale := err == nil && response.notModified && v.generation != generation
+	if err == nil && !stale {
+		_, _, _, err = v.applyLocked(response)
+	}
+	if err != nil {
+		v.recordRefreshFailureLocked(v.now().UTC(), err)
+		return jitterWorkContextJWKSDelay(v.retryInterval())
+	}
+	if !stale {
+		v.unknownRefreshGeneration = 0
+	}
+	ttl := v.expiresAt.Sub(v.now().UTC())
+	return max(ttl-jitterWorkContextJWKSDelay(ttl/4), v.minCacheTTL/2)
+}
+
+// servingStaleLocked reports whether an expired key set may still serve
+// because it is within MaxStaleness. A signed key set never serves past its
+// signed expiry.
+func (v *WorkContextJWKSVerifier) servingStaleLocked(now time.Time) bool {
+	return v.verifier != nil && v.maxStaleness > 0 &&
+		!now.Before(v.expiresAt) && now.Before(v.expiresAt.Add(v.maxStaleness)) &&
+		(v.signedUntil.IsZero() || now.Before(v.signedUntil))
+}
+
+func (v *WorkContextJWKSVerifier) recordRefreshFailureLocked(now time.Time, err error) {
+	v.lastRefreshError = err
+	v.lastRefreshFailure = now
+	v.retryAt = now.Add(v.retryInterval())
+}
+
+func (v *WorkContextJWKSVerifier) retryInterval() time.Duration {
+	return min(v.cacheTTL, defaultWorkContextJWKSRetryInterval)
+}
+
+// jitterWorkContextJWKSDelay returns a uniformly random duration in [d/2, d].
+func jitterWorkContextJWKSDelay(d time.Duration) time.Duration {
+	if d <= 1 {
+		return d
+	}
+	half := d / 2
+	return half + rand.N(d-half+1)
+}
@@ -0,0 +1,166 @@
+package codefly
+
+import (
+	"crypto/ed25519"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkContextJWKSVerifierServesStaleKeysDuringOutage(t *testing.T) {
+	publicKey, privateKey := workContextJWKSKey(1)
+	var requests atomic.Int32
+	var failing atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		if failing.Load() {
+			writer.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		_, _ = writer.Write(workContextJWKSJSON(t, map[string]ed25519.PublicKey{"key-1": publicKey}))
+	}))
+	t.Cleanup(server.Close)
+	var mu sync.RWMutex
+	now := workContextTestTime
+	advance := func(by time.Duration) {
+		mu.Lock()
+		now = now.Add(by)
+		mu.Unlock()
+	}
+	verifier, err := NewWorkContextJWKSVerifier(WorkContextJWKSVerifierOptions{
+		URL: server.URL,
+		Now: func() time.Time {
+			mu.RLock()
+			defer mu.RUnlock()
+			return now
+		},
+		CacheTTL:     10 * time.Second,
+		MaxStaleness: time.Minute,
+	})
+	require.NoError(t, err)
+	token := workContextJWKSToken(t, "key-1", privateKey)
+	require.ErrorIs(t, verifier.Health(), ErrWorkContextJWKSUnavailable, "nothing is loaded before the first fetch")
+
+	_, err = verifier.Verify(t.Context(), token, WorkContextExpectations{})
+	require.NoError(t, err)
+	require.NoError(t, verifier.Health())
+
+	failing.Store(true)
+	advance(11 * time.Second)
+	for range 10 {
+		_, err = verifier.Verify(t.Context(), token, WorkContextExpectations{})
+		require.NoError(t, err, "the previous key set keeps serving within MaxStaleness")
+	}
+	require.EqualValues(t, 2, requests.Load(), "failed refreshes are retried at a bounded rate")
+	require.ErrorIs(t, verifier.Health(), ErrWorkContextJWKSStale)
+
+	advance(defaultWorkContextJWKSRetryInterval)
+	_, err = verifier.Verify(t.Context(), token, WorkContextExpectations{})
+	require.NoError(t, err)
+	require.EqualValues(t, 3, requests.Load())
+
+	advance(time.Minute)
+	_, err = verifier.Verify(t.Context(), token, WorkContextExpectations{})
+	require.ErrorIs(t, err, ErrWorkContextInvalid, "keys past MaxStaleness are no longer trusted")
+	require.ErrorIs(t, verifier.Health(), ErrWorkContextJWKSUnavailable)
+
+	failing.Store(false)
+	_, err = verifier.Verify(t.Context(), token, WorkContextExpectations{})
+	require.NoError(t, err)
+	require.NoError(t, verifier.Health())
+}
+
+func TestWorkContextJWKSVerifierBackgroundRefresh(t *testing.T) {
+	publicKey, privateKey := workContextJWKSKey(1)
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		writer.Header().Set("Content-Type", "application/json")
+		_, _ = writer.Write(workContextJWKSJSON(t, map[string]ed25519.PublicKey{"key-1": publicKey}))
+	}))
+	t.Cleanup(server.Close)
+	verifier, err := NewWorkContextJWKSVerifier(WorkContextJWKSVerifierOptions{
+		URL: server.URL, Now: func() time.Time { return workContextTestTime }, BackgroundRefresh: true,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, verifier.Close()) })
+
+	require.Eventually(t, func() bool { return verifier.Health() == nil }, 5*time.Second, 10*time.Millisecond)
+	_, err = verifier.Verify(t.Context(), workContextJWKSToken(t, "key-1", privateKey), WorkContextExpectations{})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, requests.Load(), "Verify uses the key set loaded in the background")
+
+	require.NoError(t, verifier.Close())
+	require.NoError(t, verifier.Close())
+	_, err = verifier.Verify(t.Context(), workContextJWKSToken(t, "key-1", privateKey), WorkContextExpectations{})
+	require.NoError(t, err, "Verify keeps working after Close")
+}
+
+func TestWorkContextJWKSBackgroundRefreshIgnoresNotModifiedForNewerGeneration(t *testing.T) {
+	publicKey, privateKey := workContextJWKSKey(1)
+	rotatedKey, rotatedPrivateKey := workContextJWKSKey(2)
+	var mu sync.RWMutex
+	now := workContextTestTime
+	var verifier *WorkContextJWKSVerifier
+	var rotating atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Header.Get("If-None-Match") == `"keys-v1"` && rotating.CompareAndSwap(false, true) {
+			// Rotate while the background request is in flight, then answer
+			// it for the key set it asked about.
+			_, err := verifier.Verify(request.Context(), workContextJWKSToken(t, "key-2", rotatedPrivateKey), WorkContextExpectations{})
+			require.NoError(t, err)
+			mu.Lock()
+			now = now.Add(5 * time.Second)
+			mu.Unlock()
+			writer.WriteHeader(http.StatusNotModified)
+			return
+		}
+		keys := map[string]ed25519.PublicKey{"key-1": publicKey}
+		writer.Header().Set("ETag", `"keys-v1"`)
+		if rotating.Load() {
+			keys["key-2"] = rotatedKey
+			writer.Header().Set("ETag", `"keys-v2"`)
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		_, _ = writer.Write(workContextJWKSJSON(t, keys))
+	}))
+	t.Cleanup(server.Close)
+	verifier, err := NewWorkContextJWKSVerifier(WorkContextJWKSVerifierOptions{
+		URL: server.URL,
+		Now: func() time.Time {
+			mu.RLock()
+			defer mu.RUnlock()
+			return now
+		},
+		CacheTTL: 10 * time.Second,
+	})
+	require.NoError(t, err)
+	_, err = verifier.Verify(t.Context(), workContextJWKSToken(t, "key-1", privateKey), WorkContextExpectations{})
+	require.NoError(t, err)
+
+	verifier.backgroundRefresh(t.Context())
+	verifier.mu.Lock()
+	defer verifier.mu.Unlock()
+	require.EqualValues(t, 2, verifier.generation)
+	require.Equal(t, workContextTestTime.Add(10*time.Second), verifier.expiresAt,
+		"a 304 for the previous generation does not extend the rotated key set")
+	require.Equal(t, verifier.generation, verifier.unknownRefreshGeneration)
+}
+
+func TestWorkContextJWKSRefreshJitterStaysInBounds(t *testing.T) {
+	for range 1000 {
+		delay := jitterWorkContextJWKSDelay(time.Minute)
+		require.GreaterOrEqual(t, delay, 30*time.Second)
+		require.LessOrEqual(t, delay, time.Minute)
+	}
+	_, err := NewWorkContextJWKSVerifier(WorkContextJWKSVerifierOptions{
+		URL: "https://accounts.example.test/keys", MaxStaleness: maxWorkContextJWKSStaleness + time.Second,
+	})
+	require.ErrorIs(t, err, ErrWorkContextInvalid)
+}
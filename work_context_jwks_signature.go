@@ -0,0 +1,321 @@
+package codefly
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	maxWorkContextJWKSRootKeys          = 16
+	maxWorkContextJWKSRootChain         = 8
+	maxWorkContextJWKSSignatureValidity = 90 * 24 * time.Hour
+
+	workContextJWKSSignatureDomain   = "codefly.work-context.jwks.v2\n"
+	workContextJWKSEndorsementDomain = "codefly.work-context.jwks-root.v1\n"
+)
+
+// WorkContextJWKSRootEndorsement is one link of a root key rotation chain:
+// SignerKeyID vouches for PublicKey under KeyID. A chain starts at a pinned
+// root and each link is signed by the key endorsed in the link before it, so
+// verifiers pinned to an old root accept documents signed by its successors.
+type WorkContextJWKSRootEndorsement struct {
+	KeyID       string
+	PublicKey   ed25519.PublicKey
+	SignerKeyID string
+	Signature   []byte
+}
+
+// EndorseWorkContextJWKSRootKey signs a successor root key with the current
+// root. Run it offline where the current root private key lives.
+func EndorseWorkContextJWKSRootKey(
+	signerKeyID string,
+	signer ed25519.PrivateKey,
+	keyID string,
+	publicKey ed25519.PublicKey,
+) (WorkContextJWKSRootEndorsement, error) {
+	if len(signer) != ed25519.PrivateKeySize || len(publicKey) != ed25519.PublicKeySize {
+		return WorkContextJWKSRootEndorsement{}, fmt.Errorf("%w: invalid Ed25519 root key", ErrWorkContextInvalid)
+	}
+	for _, id := range []string{signerKeyID, keyID} {
+		if err := validateBounded("root_key_id", id, workContextMaxKindBytes, true); err != nil {
+			return WorkContextJWKSRootEndorsement{}, err
+		}
+	}
+	if signerKeyID == keyID {
+		return WorkContextJWKSRootEndorsement{}, fmt.Errorf("%w: a root key cannot endorse itself", ErrWorkContextInvalid)
+	}
+	link := workContextJWKSEndorsement{
+		KeyID:       keyID,
+		X:           base64.RawURLEncoding.EncodeToString(publicKey),
+		SignerKeyID: signerKeyID,
+	}
+	return WorkContextJWKSRootEndorsement{
+		KeyID:       keyID,
+		PublicKey:   append(ed25519.PublicKey(nil), publicKey...),
+		SignerKeyID: signerKeyID,
+		Signature:   ed25519.Sign(signer, link.signingInput()),
+	}, nil
+}
+
+// WorkContextJWKSSigningOptions identifies the root key that signs a JWKS
+// document and the period the signature vouches for it. Chain is empty when
+// KeyID is itself pinned by verifiers; otherwise it must lead from a pinned
+// root to KeyID.
+type WorkContextJWKSSigningOptions struct {
+	KeyID      string
+	PrivateKey ed25519.PrivateKey
+	Chain      []WorkContextJWKSRootEndorsement
+	// IssuedAt defaults to now.
+	IssuedAt time.Time
+	// ExpiresAt is required and at most 90 days after IssuedAt. Verifiers stop
+	// trusting the keys once it passes, so whoever withholds newer documents
+	// cannot keep a retired key trusted indefinitely. Re-sign well before it.
+	ExpiresAt time.Time
+	// Sequence orders the documents of one authority and defaults to IssuedAt
+	// in Unix seconds. Verifiers refuse a document with a lower sequence than
+	// one they accepted, so a replayed document cannot roll back a rotation.
+	Sequence uint64
+}
+
+// SignWorkContextJWKS returns document with a detached root signature in its
+// codefly_signature member. The signature covers the canonical encoding of the
+// keys array together with the issue time, expiry, and sequence, so members
+// the verifier ignores are dropped from the output. Verifiers without RootKeys
+// ignore the signature, so signed documents can be rolled out before
+// verifiers start requiring them.
+func SignWorkContextJWKS(document []byte, options WorkContextJWKSSigningOptions) ([]byte, error) {
+	if len(options.PrivateKey) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("%w: invalid Ed25519 root key", ErrWorkContextInvalid)
+	}
+	if err := validateBounded("root_key_id", options.KeyID, workContextMaxKindBytes, true); err != nil {
+		return nil, err
+	}
+	issuedAt := options.IssuedAt
+	if issuedAt.IsZero() {
+		issuedAt = time.Now()
+	}
+	if issuedAt.Unix() <= 0 {
+		return nil, fmt.Errorf("%w: JWKS signature issue time must be after the Unix epoch", ErrWorkContextInvalid)
+	}
+	if !options.ExpiresAt.After(issuedAt) || options.ExpiresAt.Sub(issuedAt) > maxWorkContextJWKSSignatureValidity {
+		return nil, fmt.Errorf(
+			"%w: JWKS signature expiry must be within %s after its issue time",
+			ErrWorkContextInvalid,
+			maxWorkContextJWKSSignatureValidity,
+		)
+	}
+	sequence := options.Sequence
+	if sequence == 0 {
+		sequence = uint64(issuedAt.Unix())
+	}
+	if len(options.Chain) > maxWorkContextJWKSRootChain {
+		return nil, fmt.Errorf("%w: root chain exceeds %d links", ErrWorkContextInvalid, maxWorkContextJWKSRootChain)
+	}
+	if _, _, err := parseWorkContextJWKS(document, nil); err != nil {
+		return nil, err
+	}
+	var decoded workContextJWKS
+	if err := json.Unmarshal(document, &decoded); err != nil {
+		return nil, fmt.Errorf("%w: decode Work Context JWKS: %v", ErrWorkContextInvalid, err)
+	}
+	signature := &workContextJWKSSignature{
+		KeyID:     options.KeyID,
+		IssuedAt:  issuedAt.Unix(),
+		ExpiresAt: options.ExpiresAt.Unix(),
+		Sequence:  sequence,
+	}
+	previous := ""
+	for index, endorsement := range options.Chain {
+		if index > 0 && endorsement.SignerKeyID != previous {
+			return nil, fmt.Errorf("%w: root chain link %d is not signed by its predecessor", ErrWorkContextInvalid, index)
+		}
+		previous = endorsement.KeyID
+		signature.Chain = append(signature.Chain, workContextJWKSEndorsement{
+			KeyID:       endorsement.KeyID,
+			X:           base64.RawURLEncoding.EncodeToString(endorsement.PublicKey),
+			SignerKeyID: endorsement.SignerKeyID,
+			Signature:   base64.RawURLEncoding.EncodeToString(endorsement.Signature),
+		})
+	}
+	if len(options.Chain) > 0 {
+		last := options.Chain[len(options.Chain)-1]
+		if last.KeyID != options.KeyID || !last.PublicKey.Equal(options.PrivateKey.Public()) {
+			return nil, fmt.Errorf("%w: root chain does not end at the signing key", ErrWorkContextInvalid)
+		}
+	}
+	input, err := workContextJWKSSigningInput(decoded.Keys, signature)
+	if err != nil {
+		return nil, err
+	}
+	signature.Signature = base64.RawURLEncoding.EncodeToString(ed25519.Sign(options.PrivateKey, input))
+	signed, err := json.Marshal(workContextJWKS{Keys: decoded.Keys, Signature: signature})
+	if err != nil {
+		return nil, fmt.Errorf("%w: encode Work Context JWKS: %v", ErrWorkContextInvalid, err)
+	}
+	return signed, nil
+}
+
+type workContextJWKSSignature struct {
+	KeyID     string                       `json:"kid"`
+	IssuedAt  int64                        `json:"iat"`
+	ExpiresAt int64                        `json:"exp"`
+	Sequence  uint64                       `json:"seq"`
+	Signature string                       `json:"sig"`
+	Chain     []workContextJWKSEndorsement `json:"chain,omitempty"`
+}
+
+// workContextJWKSValidity is the signed period of a verified JWKS document.
+type workContextJWKSValidity struct {
+	expiresAt time.Time
+	sequence  uint64
+}
+
+type workContextJWKSEndorsement struct {
+	KeyID       string `json:"kid"`
+	X           string `json:"x"`
+	SignerKeyID string `json:"signer"`
+	Signature   string `json:"sig"`
+}
+
+func (e workContextJWKSEndorsement) signingInput() []byte {
+	// Marshalling a struct of strings cannot fail.
+	encoded, _ := json.Marshal(struct {
+		KeyID       string `json:"kid"`
+		X           string `json:"x"`
+		SignerKeyID string `json:"signer"`
+	}{e.KeyID, e.X, e.SignerKeyID})
+	return append([]byte(workContextJWKSEndorsementDomain), encoded...)
+}
+
+func workContextJWKSSigningInput(keys []workContextJWK, signature *workContextJWKSSignature) ([]byte, error) {
+	encoded, err := json.Marshal(struct {
+		Keys      []workContextJWK `json:"keys"`
+		IssuedAt  int64            `json:"iat"`
+		ExpiresAt int64            `json:"exp"`
+		Sequence  uint64           `json:"seq"`
+	}{keys, signature.IssuedAt, signature.ExpiresAt, signature.Sequence})
+	if err != nil {
+		return nil, fmt.Errorf("%w: encode Work Context JWKS: %v", ErrWorkContextInvalid, err)
+	}
+	return append([]byte(workContextJWKSSignatureDomain), encoded...), nil
+}
+
+// verifyWorkContextJWKSSignature authenticates a decoded document against the
+// pinned roots before any of its keys are trusted and returns its signed
+// period. Expiry and sequence are judged by the verifier, which knows the time
+// and the documents it accepted before.
+func verifyWorkContextJWKSSignature(
+	document workContextJWKS,
+	roots map[string]ed25519.PublicKey,
+) (*workContextJWKSValidity, error) {
+	signature := document.Signature
+	if signature == nil {
+		return nil, workContextJWKSFailure(
+			WorkContextJWKSErrorSignature,
+			fmt.Errorf("%w: Work Context JWKS is not signed by a root key", ErrWorkContextInvalid),
+		)
+	}
+	if len(signature.Chain) > maxWorkContextJWKSRootChain {
+		return nil, workContextJWKSFailure(
+			WorkContextJWKSErrorSignature,
+			fmt.Errorf("%w: JWKS root chain exceeds %d links", ErrWorkContextInvalid, maxWorkContextJWKSRootChain),
+		)
+	}
+	if signature.IssuedAt <= 0 || signature.ExpiresAt <= signature.IssuedAt || signature.Sequence == 0 {
+		return nil, workContextJWKSFailure(
+			WorkContextJWKSErrorSignature,
+			fmt.Errorf("%w: Work Context JWKS signature has no valid period or sequence", ErrWorkContextInvalid),
+		)
+	}
+	signer, err := resolveWorkContextJWKSRoot(signature, roots)
+	if err != nil {
+		return nil, workContextJWKSFailure(WorkContextJWKSErrorSignature, err)
+	}
+	input, err := workContextJWKSSigningInput(document.Keys, signature)
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(signature.Signature)
+	if err != nil || len(decoded) != ed25519.SignatureSize || !ed25519.Verify(signer, input, decoded) {
+		return nil, workContextJWKSFailure(
+			WorkContextJWKSErrorSignature,
+			fmt.Errorf("%w: Work Context JWKS root signature is invalid", ErrWorkContextInvalid),
+		)
+	}
+	return &workContextJWKSValidity{
+		expiresAt: time.Unix(signature.ExpiresAt, 0).UTC(),
+		sequence:  signature.Sequence,
+	}, nil
+}
+
+// resolveWorkContextJWKSRoot walks the rotation chain from a pinned root to the
+// key that signed the document.
+func resolveWorkContextJWKSRoot(
+	signature *workContextJWKSSignature,
+	roots map[string]ed25519.PublicKey,
+) (ed25519.PublicKey, error) {
+	if len(signature.Chain) == 0 {
+		root, ok := roots[signature.KeyID]
+		if !ok {
+			return nil, fmt.Errorf("%w: JWKS signed by unpinned root %q", ErrWorkContextInvalid, signature.KeyID)
+		}
+		return root, nil
+	}
+	current, ok := roots[signature.Chain[0].SignerKeyID]
+	if !ok {
+		return nil, fmt.Errorf(
+			"%w: JWKS root chain starts at unpinned root %q",
+			ErrWorkContextInvalid,
+			signature.Chain[0].SignerKeyID,
+		)
+	}
+	currentKeyID := signature.Chain[0].SignerKeyID
+	for index, link := range signature.Chain {
+		if link.SignerKeyID != currentKeyID {
+			return nil, fmt.Errorf("%w: JWKS root chain link %d is not signed by its predecessor", ErrWorkContextInvalid, index)
+		}
+		if err := validateBounded("root_key_id", link.KeyID, workContextMaxKindBytes, true); err != nil {
+			return nil, err
+		}
+		endorsed, err := base64.RawURLEncoding.DecodeString(link.X)
+		if err != nil || len(endorsed) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: JWKS root chain link %d has an invalid key", ErrWorkContextInvalid, index)
+		}
+		if pinned, isPinned := roots[link.KeyID]; isPinned && !pinned.Equal(ed25519.PublicKey(endorsed)) {
+			return nil, fmt.Errorf("%w: JWKS root chain redefines pinned root %q", ErrWorkContextInvalid, link.KeyID)
+		}
+		linkSignature, err := base64.RawURLEncoding.DecodeString(link.Signature)
+		if err != nil || len(linkSignature) != ed25519.SignatureSize ||
+			!ed25519.Verify(current, link.signingInput(), linkSignature) {
+			return nil, fmt.Errorf("%w: JWKS root chain link %d signature is invalid", ErrWorkContextInvalid, index)
+		}
+		current, currentKeyID = endorsed, link.KeyID
+	}
+	if currentKeyID != signature.KeyID {
+		return nil, fmt.Errorf("%w: JWKS root chain does not end at signer %q", ErrWorkContextInvalid, signature.KeyID)
+	}
+	return current, nil
+}
+
+func validateWorkContextJWKSRootKeys(roots map[string]ed25519.PublicKey) (map[string]ed25519.PublicKey, error) {
+	if len(roots) == 0 {
+		return nil, nil
+	}
+	if len(roots) > maxWorkContextJWKSRootKeys {
+		return nil, fmt.Errorf("%w: at most %d JWKS root keys may be pinned", ErrWorkContextInvalid, maxWorkContextJWKSRootKeys)
+	}
+	pinned := make(map[string]ed25519.PublicKey, len(roots))
+	for keyID, key := range roots {
+		if err := validateBounded("root_key_id", keyID, workContextMaxKindBytes, true); err != nil {
+			return nil, err
+		}
+		if len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: JWKS root key %q is not an Ed25519 public key", ErrWorkContextInvalid, keyID)
+		}
+		pinned[keyID] = append(ed25519.PublicKey(nil), key...)
+	}
+	return pinned, nil
+}
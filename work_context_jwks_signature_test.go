@@ -0,0 +1,211 @@
+package codefly
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkContextJWKSVerifierRequiresPinnedRootSignature(t *testing.T) {
+	signingPublic, signingPrivate := workContextJWKSKey(1)
+	rootPublic, rootPrivate := workContextJWKSKey(10)
+	_, strangerPrivate := workContextJWKSKey(11)
+	document := workContextJWKSJSON(t, map[string]ed25519.PublicKey{"key-1": signingPublic})
+	signed, err := SignWorkContextJWKS(document, workContextJWKSTestSigning(WorkContextJWKSSigningOptions{KeyID: "root-2026", PrivateKey: rootPrivate}))
+	require.NoError(t, err)
+	unpinned, err := SignWorkContextJWKS(document, workContextJWKSTestSigning(WorkContextJWKSSigningOptions{KeyID: "root-stranger", PrivateKey: strangerPrivate}))
+	require.NoError(t, err)
+	forged, err := SignWorkContextJWKS(document, workContextJWKSTestSigning(WorkContextJWKSSigningOptions{KeyID: "root-2026", PrivateKey: strangerPrivate}))
+	require.NoError(t, err)
+	injectedPublic, _ := workContextJWKSKey(2)
+	injected := workContextJWKSWithKey(t, signed, "key-2", injectedPublic)
+
+	roots := map[string]ed25519.PublicKey{"root-2026": rootPublic}
+	token := workContextJWKSToken(t, "key-1", signingPrivate)
+	require.NoError(t, verifyWorkContextJWKSDocument(t, signed, roots, token))
+	require.NoError(t, verifyWorkContextJWKSDocument(t, signed, nil, token), "verifiers without roots ignore the signature")
+	for name, candidate := range map[string][]byte{
+		"unsigned": document, "unpinned root": unpinned, "forged": forged, "injected key": injected,
+	} {
+		err := verifyWorkContextJWKSDocument(t, candidate, roots, token)
+		require.ErrorIs(t, err, ErrWorkContextInvalid, name)
+		require.Equal(t, WorkContextJWKSErrorSignature, classifyWorkContextJWKSError(err), name)
+	}
+}
+
+func TestWorkContextJWKSVerifierFollowsRootRotationChain(t *testing.T) {
+	signingPublic, signingPrivate := workContextJWKSKey(1)
+	firstRootPublic, firstRootPrivate := workContextJWKSKey(10)
+	secondRootPublic, secondRootPrivate := workContextJWKSKey(11)
+	thirdRootPublic, thirdRootPrivate := workContextJWKSKey(12)
+	_, strangerPrivate := workContextJWKSKey(13)
+	document := workContextJWKSJSON(t, map[string]ed25519.PublicKey{"key-1": signingPublic})
+	roots := map[string]ed25519.PublicKey{"root-2026": firstRootPublic}
+	token := workContextJWKSToken(t, "key-1", signingPrivate)
+
+	second, err := EndorseWorkContextJWKSRootKey("root-2026", firstRootPrivate, "root-2027", secondRootPublic)
+	require.NoError(t, err)
+	third, err := EndorseWorkContextJWKSRootKey("root-2027", secondRootPrivate, "root-2028", thirdRootPublic)
+	require.NoError(t, err)
+	signed, err := SignWorkContextJWKS(document, workContextJWKSTestSigning(WorkContextJWKSSigningOptions{
+		KeyID: "root-2028", PrivateKey: thirdRootPrivate, Chain: []WorkContextJWKSRootEndorsement{second, third},
+	}))
+	require.NoError(t, err)
+	require.NoError(t, verifyWorkContextJWKSDocument(t, signed, roots, token))
+
+	selfEndorsed, err := EndorseWorkContextJWKSRootKey("root-2026", strangerPrivate, "root-2027", secondRootPublic)
+	require.NoError(t, err)
+	untrusted, err := SignWorkContextJWKS(document, workContextJWKSTestSigning(WorkContextJWKSSigningOptions{
+		KeyID: "root-2027", PrivateKey: secondRootPrivate, Chain: []WorkContextJWKSRootEndorsement{selfEndorsed},
+	}))
+	require.NoError(t, err)
+	require.ErrorContains(t, verifyWorkContextJWKSDocument(t, untrusted, roots, token), "chain link 0 signature is invalid")
+
+	redefined, err := EndorseWorkContextJWKSRootKey("root-2027", secondRootPrivate, "root-2026", thirdRootPublic)
+	require.NoError(t, err)
+	hijacked, err := SignWorkContextJWKS(document, workContextJWKSTestSigning(WorkContextJWKSSigningOptions{
+		KeyID: "root-2026", PrivateKey: thirdRootPrivate, Chain: []WorkContextJWKSRootEndorsement{second, redefined},
+	}))
+	require.NoError(t, err)
+	require.ErrorContains(t, verifyWorkContextJWKSDocument(t, hijacked, roots, token), "redefines pinned root")
+
+	_, err = SignWorkContextJWKS(document, workContextJWKSTestSigning(WorkContextJWKSSigningOptions{
+		KeyID: "root-2028", PrivateKey: thirdRootPrivate, Chain: []WorkContextJWKSRootEndorsement{second},
+	}))
+	require.ErrorIs(t, err, ErrWorkContextInvalid, "the chain must end at the signing key")
+	_, err = SignWorkContextJWKS(document, workContextJWKSTestSigning(WorkContextJWKSSigningOptions{
+		KeyID: "root-2028", PrivateKey: thirdRootPrivate, Chain: []WorkContextJWKSRootEndorsement{third},
+	}))
+	require.NoError(t, err, "a chain that does not start at a pinned root is only rejected by verifiers")
+	_, err = SignWorkContextJWKS(document, workContextJWKSTestSigning(WorkContextJWKSSigningOptions{
+		KeyID: "root-2028", PrivateKey: thirdRootPrivate, Chain: []WorkContextJWKSRootEndorsement{third, second},
+	}))
+	require.ErrorIs(t, err, ErrWorkContextInvalid, "links must be signed by their predecessor")
+}
+
+func TestWorkContextJWKSVerifierRejectsReplayedAndExpiredDocuments(t *testing.T) {
+	retiredPublic, retiredPrivate := workContextJWKSKey(1)
+	currentPublic, currentPrivate := workContextJWKSKey(2)
+	rootPublic, rootPrivate := workContextJWKSKey(10)
+	roots := map[string]ed25519.PublicKey{"root-2026": rootPublic}
+	sign := func(keys map[string]ed25519.PublicKey, issuedAt time.Time, validFor time.Duration) []byte {
+		signed, err := SignWorkContextJWKS(workContextJWKSJSON(t, keys), WorkContextJWKSSigningOptions{
+			KeyID: "root-2026", PrivateKey: rootPrivate, IssuedAt: issuedAt, ExpiresAt: issuedAt.Add(validFor),
+		})
+		require.NoError(t, err)
+		return signed
+	}
+	before := sign(map[string]ed25519.PublicKey{"key-1": retiredPublic}, workContextTestTime.Add(-time.Hour), 24*time.Hour)
+	rotated := sign(map[string]ed25519.PublicKey{"key-2": currentPublic}, workContextTestTime, time.Hour)
+
+	now := workContextTestTime
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	replace := func(document []byte) {
+		staged := path + ".staged"
+		require.NoError(t, os.WriteFile(staged, document, 0o600))
+		require.NoError(t, os.Rename(staged, path))
+	}
+	replace(rotated)
+	verifier, err := NewWorkContextJWKSVerifier(WorkContextJWKSVerifierOptions{
+		File: path, RootKeys: roots, MaxStaleness: time.Hour, Now: func() time.Time { return now },
+	})
+	require.NoError(t, err)
+	retired := workContextJWKSToken(t, "key-1", retiredPrivate)
+	current := workContextJWKSToken(t, "key-2", currentPrivate)
+	_, err = verifier.Verify(t.Context(), current, WorkContextExpectations{})
+	require.NoError(t, err)
+
+	// Whoever controls the file serves the validly signed document from before
+	// the rotation to bring the retired key back.
+	replace(before)
+	now = now.Add(10 * time.Minute)
+	_, err = verifier.Verify(t.Context(), retired, WorkContextExpectations{})
+	require.ErrorIs(t, err, ErrWorkContextInvalid)
+	require.ErrorContains(t, verifier.Health(), "older than the accepted")
+	require.Equal(t, WorkContextJWKSErrorSignature, verifier.Status().LastErrorClass)
+
+	// Withholding newer documents only works until the signed expiry, even
+	// within MaxStaleness.
+	replace(rotated)
+	now = workContextTestTime.Add(59 * time.Minute)
+	_, _, _, err = verifier.current(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, workContextTestTime.Add(time.Hour), verifier.Status().ExpiresAt)
+	now = workContextTestTime.Add(time.Hour)
+	_, _, _, err = verifier.current(t.Context())
+	require.ErrorIs(t, err, ErrWorkContextInvalid)
+	require.ErrorContains(t, verifier.Health(), "signature expired")
+
+	expired := sign(map[string]ed25519.PublicKey{"key-2": currentPublic}, workContextTestTime.Add(-2*time.Hour), time.Hour)
+	require.ErrorContains(t, verifyWorkContextJWKSDocument(t, expired, roots, current), "signature expired")
+
+	_, err = SignWorkContextJWKS(workContextJWKSJSON(t, map[string]ed25519.PublicKey{"key-2": currentPublic}),
+		WorkContextJWKSSigningOptions{KeyID: "root-2026", PrivateKey: rootPrivate})
+	require.ErrorIs(t, err, ErrWorkContextInvalid, "an expiry is required")
+}
+
+func TestNewWorkContextJWKSVerifierValidatesRootKeys(t *testing.T) {
+	rootPublic, _ := workContextJWKSKey(10)
+	for _, roots := range []map[string]ed25519.PublicKey{
+		{"root-2026": rootPublic[:16]},
+		{"": rootPublic},
+	} {
+		_, err := NewWorkContextJWKSVerifier(WorkContextJWKSVerifierOptions{
+			URL: "https://accounts.example.test/keys", RootKeys: roots,
+		})
+		require.ErrorIs(t, err, ErrWorkContextInvalid)
+	}
+}
+
+// workContextJWKSTestSigning signs for a day from workContextTestTime.
+func workContextJWKSTestSigning(options WorkContextJWKSSigningOptions) WorkContextJWKSSigningOptions {
+	if options.IssuedAt.IsZero() {
+		options.IssuedAt = workContextTestTime
+	}
+	if options.ExpiresAt.IsZero() {
+		options.ExpiresAt = options.IssuedAt.Add(24 * time.Hour)
+	}
+	return options
+}
+
+func verifyWorkContextJWKSDocument(
+	t *testing.T,
+	document []byte,
+	roots map[string]ed25519.PublicKey,
+	token WorkContextToken,
+) error {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, document, 0o600))
+	verifier, err := NewWorkContextJWKSVerifier(WorkContextJWKSVerifierOptions{
+		File: path, RootKeys: roots, Now: func() time.Time { return workContextTestTime },
+	})
+	require.NoError(t, err)
+	_, err = verifier.Verify(t.Context(), token, WorkContextExpectations{})
+	return err
+}
+
+// workContextJWKSWithKey appends a key to a signed document without
+// re-signing it, as an attacker on the transport would.
+func workContextJWKSWithKey(t *testing.T, signed []byte, keyID string, publicKey ed25519.PublicKey) []byte {
+	t.Helper()
+	var document map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(signed, &document))
+	var keys []json.RawMessage
+	require.NoError(t, json.Unmarshal(document["keys"], &keys))
+	var extra struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	require.NoError(t, json.Unmarshal(workContextJWKSJSON(t, map[string]ed25519.PublicKey{keyID: publicKey}), &extra))
+	encoded, err := json.Marshal(append(keys, extra.Keys...))
+	require.NoError(t, err)
+	document["keys"] = encoded
+	tampered, err := json.Marshal(document)
+	require.NoError(t, err)
+	return tampered
+}
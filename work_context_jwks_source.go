@@ -0,0 +1,264 @@
+package codefly
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// workContextJWKSSource loads a key set for WorkContextJWKSVerifier. Sources
+// only fetch; generations, unknown-key refresh suppression, and staleness are
+// owned by the verifier so they behave identically for every source. A source
+// may report notModified only for validators it issued itself.
+type workContextJWKSSource interface {
+	fetch(ctx context.Context, validators workContextJWKSValidators) (workContextJWKSResponse, error)
+}
+
+func newWorkContextJWKSSource(
+	options WorkContextJWKSVerifierOptions,
+	requestTimeout time.Duration,
+	roots map[string]ed25519.PublicKey,
+) (workContextJWKSSource, error) {
+	sources := 0
+	for _, set := range []bool{options.URL != "", len(options.URLs) > 0, options.File != ""} {
+		if set {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return nil, fmt.Errorf("%w: exactly one of JWKS URL, URLs, or File is required", ErrWorkContextInvalid)
+	}
+	if options.File != "" {
+		return newWorkContextJWKSFileSource(options.File, roots)
+	}
+	raw := options.URLs
+	if options.URL != "" {
+		raw = []string{options.URL}
+	}
+	if len(raw) > maxWorkContextJWKSURLs {
+		return nil, fmt.Errorf("%w: at most %d JWKS URLs are supported", ErrWorkContextInvalid, maxWorkContextJWKSURLs)
+	}
+	endpoints := make([]string, 0, len(raw))
+	for _, candidate := range raw {
+		endpoint, err := validateWorkContextJWKSURL(candidate)
+		if err != nil {
+			return nil, err
+		}
+		if slices.Contains(endpoints, endpoint) {
+			return nil, fmt.Errorf("%w: duplicate JWKS URL %q", ErrWorkContextInvalid, endpoint)
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	client := options.HTTPClient
+	if client == nil {
+		client = &http.Client{
+			CheckRedirect: func(_ *http.Request, _ []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+	}
+	return &workContextJWKSHTTPSource{
+		urls: endpoints, client: client, requestTimeout: requestTimeout, roots: roots,
+	}, nil
+}
+
+// workContextJWKSHTTPSource fetches the JWKS from an ordered list of
+// equivalent endpoints, failing over on any error.
+type workContextJWKSHTTPSource struct {
+	urls           []string
+	client         *http.Client
+	requestTimeout time.Duration
+	roots          map[string]ed25519.PublicKey
+}
+
+func (s *workContextJWKSHTTPSource) fetch(
+	ctx context.Context,
+	validators workContextJWKSValidators,
+) (workContextJWKSResponse, error) {
+	if len(s.urls) == 1 {
+		return s.fetchURL(ctx, s.urls[0], validators)
+	}
+	failures := make([]error, 0, len(s.urls))
+	for _, endpoint := range s.urls {
+		response, err := s.fetchURL(ctx, endpoint, validators)
+		if err == nil {
+			return response, nil
+		}
+		failures = append(failures, fmt.Errorf("%s: %w", endpoint, err))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return workContextJWKSResponse{}, errors.Join(failures...)
+}
+
+func (s *workContextJWKSHTTPSource) fetchURL(
+	ctx context.Context,
+	endpoint string,
+	validators workContextJWKSValidators,
+) (workContextJWKSResponse, error) {
+	requestContext, cancel := context.WithTimeout(ctx, s.requestTimeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(requestContext, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return workContextJWKSResponse{}, workContextJWKSFailure(
+			WorkContextJWKSErrorProtocol,
+			fmt.Errorf("%w: create JWKS request: %v", ErrWorkContextInvalid, err),
+		)
+	}
+	request.Header.Set("Accept", "application/json")
+	// Entity tags are only meaningful to the endpoint that issued them.
+	if validators.origin != endpoint {
+		validators = workContextJWKSValidators{}
+	}
+	if validators.etag != "" {
+		request.Header.Set("If-None-Match", validators.etag)
+	}
+	if validators.lastModified != "" {
+		request.Header.Set("If-Modified-Since", validators.lastModified)
+	}
+	response, err := s.client.Do(request)
+	if err != nil {
+		class := WorkContextJWKSErrorTransport
+		if errors.Is(requestContext.Err(), context.DeadlineExceeded) {
+			class = WorkContextJWKSErrorTimeout
+		}
+		return workContextJWKSResponse{}, workContextJWKSFailure(
+			class,
+			fmt.Errorf("%w: fetch Work Context JWKS: %v", ErrWorkContextInvalid, err),
+		)
+	}
+	defer response.Body.Close()
+	if response.Request != nil && response.Request.URL != nil &&
+		response.Request.URL.String() != endpoint {
+		return workContextJWKSResponse{}, workContextJWKSFailure(
+			WorkContextJWKSErrorProtocol,
+			fmt.Errorf("%w: Work Context JWKS redirected", ErrWorkContextInvalid),
+		)
+	}
+	maxAge := parseWorkContextJWKSMaxAge(response.Header)
+	if response.StatusCode == http.StatusNotModified &&
+		(validators.etag != "" || validators.lastModified != "") {
+		return workContextJWKSResponse{notModified: true, validators: validators, maxAge: maxAge}, nil
+	}
+	if response.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 4*1024))
+		return workContextJWKSResponse{}, workContextJWKSFailure(WorkContextJWKSErrorHTTPStatus, fmt.Errorf(
+			"%w: Work Context JWKS returned HTTP %d",
+			ErrWorkContextInvalid,
+			response.StatusCode,
+		))
+	}
+	if contentType := response.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, parseErr := mime.ParseMediaType(contentType)
+		if parseErr != nil || mediaType != "application/json" {
+			return workContextJWKSResponse{}, workContextJWKSFailure(
+				WorkContextJWKSErrorInvalidDocument,
+				fmt.Errorf("%w: Work Context JWKS is not application/json", ErrWorkContextInvalid),
+			)
+		}
+	}
+	payload, err := io.ReadAll(io.LimitReader(response.Body, maxWorkContextJWKSBytes+1))
+	if err != nil {
+		return workContextJWKSResponse{}, workContextJWKSFailure(
+			WorkContextJWKSErrorTransport,
+			fmt.Errorf("%w: read Work Context JWKS: %v", ErrWorkContextInvalid, err),
+		)
+	}
+	if len(payload) > maxWorkContextJWKSBytes {
+		return workContextJWKSResponse{}, workContextJWKSFailure(WorkContextJWKSErrorInvalidDocument, fmt.Errorf(
+			"%w: Work Context JWKS exceeds %d bytes",
+			ErrWorkContextInvalid,
+			maxWorkContextJWKSBytes,
+		))
+	}
+	keys, validity, err := parseWorkContextJWKS(payload, s.roots)
+	if err != nil {
+		return workContextJWKSResponse{}, workContextJWKSFailure(WorkContextJWKSErrorInvalidDocument, err)
+	}
+	return workContextJWKSResponse{
+		keys:     keys,
+		validity: validity,
+		validators: workContextJWKSValidators{
+			origin:       endpoint,
+			etag:         response.Header.Get("ETag"),
+			lastModified: response.Header.Get("Last-Modified"),
+		},
+		maxAge: maxAge,
+	}, nil
+}
+
+// workContextJWKSFileSource loads the JWKS from a local file. Public keys may
+// be world-readable, but a file others can write would let them inject keys,
+// so group and world write permissions are rejected.
+type workContextJWKSFileSource struct {
+	path  string
+	roots map[string]ed25519.PublicKey
+}
+
+func newWorkContextJWKSFileSource(
+	path string,
+	roots map[string]ed25519.PublicKey,
+) (*workContextJWKSFileSource, error) {
+	path = strings.TrimSpace(path)
+	if path == "" || strings.ContainsRune(path, 0) {
+		return nil, fmt.Errorf("%w: Work Context JWKS file path is invalid", ErrWorkContextInvalid)
+	}
+	return &workContextJWKSFileSource{path: filepath.Clean(path), roots: roots}, nil
+}
+
+func (s *workContextJWKSFileSource) fetch(
+	ctx context.Context,
+	validators workContextJWKSValidators,
+) (workContextJWKSResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return workContextJWKSResponse{}, workContextJWKSFailure(
+			WorkContextJWKSErrorFile,
+			fmt.Errorf("%w: load Work Context JWKS: %v", ErrWorkContextInvalid, err),
+		)
+	}
+	info, err := statSafeFile(s.path, "Work Context JWKS file", maxWorkContextJWKSBytes, 0o022)
+	if err != nil {
+		return workContextJWKSResponse{}, workContextJWKSFailure(
+			WorkContextJWKSErrorFile,
+			fmt.Errorf("%w: %v", ErrWorkContextInvalid, err),
+		)
+	}
+	if validators.origin == s.path && validators.file != nil && sameWorkContextJWKSFile(validators.file, info) {
+		return workContextJWKSResponse{notModified: true, validators: validators}, nil
+	}
+	payload, opened, err := readSafeFile(s.path, "Work Context JWKS file", maxWorkContextJWKSBytes, 0o022)
+	if err != nil {
+		return workContextJWKSResponse{}, workContextJWKSFailure(
+			WorkContextJWKSErrorFile,
+			fmt.Errorf("%w: %v", ErrWorkContextInvalid, err),
+		)
+	}
+	keys, validity, err := parseWorkContextJWKS(payload, s.roots)
+	if err != nil {
+		return workContextJWKSResponse{}, workContextJWKSFailure(WorkContextJWKSErrorInvalidDocument, err)
+	}
+	return workContextJWKSResponse{
+		keys:       keys,
+		validity:   validity,
+		validators: workContextJWKSValidators{origin: s.path, file: opened},
+	}, nil
+}
+
+// sameWorkContextJWKSFile reports whether a polled file is unchanged. Atomic
+// replacement changes the inode; an in-place rewrite changes the modification
+// time or size.
+func sameWorkContextJWKSFile(previous, current os.FileInfo) bool {
+	return os.SameFile(previous, current) &&
+		previous.ModTime().Equal(current.ModTime()) &&
+		previous.Size() == current.Size()
+}
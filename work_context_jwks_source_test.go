@@ -0,0 +1,163 @@
+package codefly
+
+import (
+	"crypto/ed25519"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkContextJWKSVerifierWatchesFile(t *testing.T) {
+	firstPublic, firstPrivate := workContextJWKSKey(1)
+	secondPublic, secondPrivate := workContextJWKSKey(2)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeWorkContextJWKSFile(t, path, map[string]ed25519.PublicKey{"key-1": firstPublic})
+	var mu sync.RWMutex
+	now := workContextTestTime
+	verifier, err := NewWorkContextJWKSVerifier(WorkContextJWKSVerifierOptions{
+		File: path,
+		Now: func() time.Time {
+			mu.RLock()
+			defer mu.RUnlock()
+			return now
+		},
+		CacheTTL: time.Second,
+	})
+	require.NoError(t, err)
+	_, err = verifier.Verify(t.Context(), workContextJWKSToken(t, "key-1", firstPrivate), WorkContextExpectations{})
+	require.NoError(t, err)
+	generation := verifier.generation
+
+	mu.Lock()
+	now = now.Add(2 * time.Second)
+	mu.Unlock()
+	_, err = verifier.Verify(t.Context(), workContextJWKSToken(t, "key-1", firstPrivate), WorkContextExpectations{})
+	require.NoError(t, err)
+	require.Equal(t, generation, verifier.generation, "an unchanged file keeps the generation")
+
+	writeWorkContextJWKSFile(t, path, map[string]ed25519.PublicKey{"key-1": firstPublic, "key-2": secondPublic})
+	_, err = verifier.Verify(t.Context(), workContextJWKSToken(t, "key-2", secondPrivate), WorkContextExpectations{})
+	require.NoError(t, err, "an unknown key reloads a replaced file immediately")
+	require.Equal(t, generation+1, verifier.generation)
+
+	_, err = verifier.Verify(t.Context(), workContextJWKSToken(t, "key-3", secondPrivate), WorkContextExpectations{})
+	require.ErrorIs(t, err, ErrWorkContextInvalid)
+	require.Equal(t, generation+1, verifier.generation, "one unknown-key reload per generation")
+}
+
+func TestWorkContextJWKSFileSourceAppliesSafeFileRules(t *testing.T) {
+	publicKey, privateKey := workContextJWKSKey(1)
+	directory := t.TempDir()
+	path := filepath.Join(directory, "jwks.json")
+	writeWorkContextJWKSFile(t, path, map[string]ed25519.PublicKey{"key-1": publicKey})
+	fixedNow := func() time.Time { return workContextTestTime }
+	link := filepath.Join(directory, "link.json")
+	require.NoError(t, os.Symlink(path, link))
+	writable := filepath.Join(directory, "writable.json")
+	require.NoError(t, os.WriteFile(writable, workContextJWKSJSON(t, map[string]ed25519.PublicKey{"key-1": publicKey}), 0o600))
+	require.NoError(t, os.Chmod(writable, 0o666))
+
+	for _, candidate := range []string{link, writable, directory, filepath.Join(directory, "missing.json")} {
+		verifier, err := NewWorkContextJWKSVerifier(WorkContextJWKSVerifierOptions{File: candidate, Now: fixedNow})
+		require.NoError(t, err)
+		_, err = verifier.Verify(t.Context(), workContextJWKSToken(t, "key-1", privateKey), WorkContextExpectations{})
+		require.ErrorIs(t, err, ErrWorkContextInvalid, candidate)
+		require.ErrorContains(t, err, "Work Context JWKS file", candidate)
+	}
+
+	require.NoError(t, os.Chmod(path, 0o644))
+	verifier, err := NewWorkContextJWKSVerifier(WorkContextJWKSVerifierOptions{File: path, Now: fixedNow})
+	require.NoError(t, err)
+	_, err = verifier.Verify(t.Context(), workContextJWKSToken(t, "key-1", privateKey), WorkContextExpectations{})
+	require.NoError(t, err, "public keys may be world-readable")
+}
+
+func TestWorkContextJWKSVerifierFailsOverAcrossURLs(t *testing.T) {
+	publicKey, privateKey := workContextJWKSKey(1)
+	var primaryDown atomic.Bool
+	var primaryRequests, secondaryConditional atomic.Int32
+	primary := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		primaryRequests.Add(1)
+		if primaryDown.Load() {
+			writer.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		writer.Header().Set("ETag", `"primary"`)
+		_, _ = writer.Write(workContextJWKSJSON(t, map[string]ed25519.PublicKey{"key-1": publicKey}))
+	}))
+	t.Cleanup(primary.Close)
+	secondary := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Header.Get("If-None-Match") != "" {
+			secondaryConditional.Add(1)
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		_, _ = writer.Write(workContextJWKSJSON(t, map[string]ed25519.PublicKey{"key-1": publicKey}))
+	}))
+	t.Cleanup(secondary.Close)
+	var mu sync.RWMutex
+	now := workContextTestTime
+	verifier, err := NewWorkContextJWKSVerifier(WorkContextJWKSVerifierOptions{
+		URLs: []string{primary.URL, secondary.URL},
+		Now: func() time.Time {
+			mu.RLock()
+			defer mu.RUnlock()
+			return now
+		},
+		CacheTTL: time.Second,
+	})
+	require.NoError(t, err)
+	token := workContextJWKSToken(t, "key-1", privateKey)
+	_, err = verifier.Verify(t.Context(), token, WorkContextExpectations{})
+	require.NoError(t, err)
+
+	primaryDown.Store(true)
+	mu.Lock()
+	now = now.Add(2 * time.Second)
+	mu.Unlock()
+	_, err = verifier.Verify(t.Context(), token, WorkContextExpectations{})
+	require.NoError(t, err, "the secondary serves while the primary is down")
+	require.EqualValues(t, 2, primaryRequests.Load(), "every refresh starts at the primary")
+	require.Zero(t, secondaryConditional.Load(), "entity tags are not replayed to other endpoints")
+
+	secondary.Close()
+	mu.Lock()
+	now = now.Add(2 * time.Second)
+	mu.Unlock()
+	_, err = verifier.Verify(t.Context(), token, WorkContextExpectations{})
+	require.ErrorIs(t, err, ErrWorkContextInvalid)
+	require.ErrorContains(t, err, primary.URL)
+	require.ErrorContains(t, err, secondary.URL)
+}
+
+func TestWorkContextJWKSVerifierRequiresExactlyOneSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	for _, options := range []WorkContextJWKSVerifierOptions{
+		{},
+		{URL: "https://accounts.example.test/keys", File: path},
+		{URL: "https://accounts.example.test/keys", URLs: []string{"https://backup.example.test/keys"}},
+		{URLs: []string{"https://accounts.example.test/keys", "https://accounts.example.test/keys"}},
+		{URLs: []string{"https://accounts.example.test/keys", "file:///etc/jwks.json"}},
+		{URLs: make([]string, maxWorkContextJWKSURLs+1)},
+		{File: " "},
+	} {
+		_, err := NewWorkContextJWKSVerifier(options)
+		require.ErrorIs(t, err, ErrWorkContextInvalid)
+	}
+}
+
+// writeWorkContextJWKSFile replaces the file atomically, as key distribution
+// tooling does.
+func writeWorkContextJWKSFile(t *testing.T, path string, keys map[string]ed25519.PublicKey) {
+	t.Helper()
+	temporary := path + ".tmp"
+	require.NoError(t, os.WriteFile(temporary, workContextJWKSJSON(t, keys), 0o600))
+	require.NoError(t, os.Rename(temporary, path))
+}
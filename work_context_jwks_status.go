@@ -0,0 +1,143 @@
+package codefly
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// WorkContextJWKSErrorClass groups refresh failures for readiness probes and
+// metrics without exposing error text, which may name internal endpoints.
+type WorkContextJWKSErrorClass string
+
+const (
+	// WorkContextJWKSErrorTransport covers connection and read failures.
+	WorkContextJWKSErrorTransport WorkContextJWKSErrorClass = "transport"
+	// WorkContextJWKSErrorTimeout reports that a request exceeded
+	// RequestTimeout.
+	WorkContextJWKSErrorTimeout WorkContextJWKSErrorClass = "timeout"
+	// WorkContextJWKSErrorHTTPStatus reports a non-200 response.
+	WorkContextJWKSErrorHTTPStatus WorkContextJWKSErrorClass = "http_status"
+	// WorkContextJWKSErrorProtocol reports redirects and cache validation the
+	// verifier cannot accept.
+	WorkContextJWKSErrorProtocol WorkContextJWKSErrorClass = "protocol"
+	// WorkContextJWKSErrorInvalidDocument reports a JWKS that is oversized,
+	// not JSON, or holds keys the verifier refuses.
+	WorkContextJWKSErrorInvalidDocument WorkContextJWKSErrorClass = "invalid_document"
+	// WorkContextJWKSErrorSignature reports a JWKS without a valid signature
+	// from a pinned root key.
+	WorkContextJWKSErrorSignature WorkContextJWKSErrorClass = "signature"
+	// WorkContextJWKSErrorFile reports a JWKS file that is missing, unsafe, or
+	// unreadable.
+	WorkContextJWKSErrorFile WorkContextJWKSErrorClass = "file"
+	// WorkContextJWKSErrorInternal covers failures outside the source, such as
+	// an exhausted generation counter.
+	WorkContextJWKSErrorInternal WorkContextJWKSErrorClass = "internal"
+)
+
+// WorkContextJWKSStatus is a point-in-time snapshot of a JWKS verifier. Zero
+// times mean the event has not happened yet.
+type WorkContextJWKSStatus struct {
+	// Loaded reports whether a key set has ever been installed.
+	Loaded bool
+	// Generation increments each time a fetched key set is installed, even
+	// one with the same keys; a 304 revalidation keeps it.
+	Generation uint64
+	// KeyIDs lists the loaded key IDs in sorted order.
+	KeyIDs []string
+	// ExpiresAt is when the loaded key set must be refreshed.
+	ExpiresAt time.Time
+	// LastSuccess is when a refresh last installed or revalidated keys.
+	LastSuccess time.Time
+	// LastFailure is when a refresh last failed, and LastErrorClass says why.
+	// LastErrorClass is empty once a later refresh succeeds.
+	LastFailure    time.Time
+	LastErrorClass WorkContextJWKSErrorClass
+	// UnknownKeyRefreshReserved reports that this generation already spent
+	// its unknown-key refresh, so further unknown key IDs fail until the next
+	// scheduled refresh.
+	UnknownKeyRefreshReserved bool
+}
+
+// Status returns a snapshot of the verifier for readiness and diagnostics. It
+// never performs I/O.
+func (v *WorkContextJWKSVerifier) Status() WorkContextJWKSStatus {
+	if v == nil {
+		return WorkContextJWKSStatus{}
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	status := WorkContextJWKSStatus{
+		Loaded:                    v.verifier != nil,
+		Generation:                v.generation,
+		ExpiresAt:                 v.expiresAt,
+		LastSuccess:               v.lastRefreshSuccess,
+		LastFailure:               v.lastRefreshFailure,
+		UnknownKeyRefreshReserved: v.verifier != nil && v.unknownRefreshGeneration == v.generation,
+	}
+	if v.lastRefreshError != nil {
+		status.LastErrorClass = classifyWorkContextJWKSError(v.lastRefreshError)
+	}
+	status.KeyIDs = make([]string, 0, len(v.keyIDs))
+	for keyID := range v.keyIDs {
+		status.KeyIDs = append(status.KeyIDs, keyID)
+	}
+	slices.Sort(status.KeyIDs)
+	return status
+}
+
+// Prefetch loads the key set ahead of the first Verify so a readiness gate can
+// block on it. Failed attempts are retried at a jittered interval until a key
+// set is usable or ctx ends; bound ctx with a deadline to give up.
+func (v *WorkContextJWKSVerifier) Prefetch(ctx context.Context) error {
+	if v == nil {
+		return fmt.Errorf("%w: nil JWKS verifier", ErrWorkContextJWKSUnavailable)
+	}
+	if ctx == nil {
+		return fmt.Errorf("%w: nil context", ErrWorkContextInvalid)
+	}
+	for {
+		_, _, _, err := v.current(ctx)
+		if err == nil {
+			return nil
+		}
+		timer := time.NewTimer(jitterWorkContextJWKSDelay(v.retryInterval()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w: %v", ErrWorkContextJWKSUnavailable, err)
+		case <-timer.C:
+		}
+	}
+}
+
+// workContextJWKSError attaches a WorkContextJWKSErrorClass to a refresh
+// failure without changing its message or sentinel.
+type workContextJWKSError struct {
+	class WorkContextJWKSErrorClass
+	err   error
+}
+
+// workContextJWKSFailure classifies err unless a more specific class was
+// already attached closer to the failure.
+func workContextJWKSFailure(class WorkContextJWKSErrorClass, err error) error {
+	var classified *workContextJWKSError
+	if errors.As(err, &classified) {
+		return err
+	}
+	return &workContextJWKSError{class: class, err: err}
+}
+
+func (e *workContextJWKSError) Error() string { return e.err.Error() }
+
+func (e *workContextJWKSError) Unwrap() error { return e.err }
+
+func classifyWorkContextJWKSError(err error) WorkContextJWKSErrorClass {
+	var classified *workContextJWKSError
+	if errors.As(err, &classified) {
+		return classified.class
+	}
+	return WorkContextJWKSErrorInternal
+}
@@ -0,0 +1,110 @@
+package codefly
+
+import (
+	"context"
+	"crypto/ed25519"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkContextJWKSVerifierPrefetchRetriesUntilLoaded(t *testing.T) {
+	firstPublic, firstPrivate := workContextJWKSKey(1)
+	secondPublic, _ := workContextJWKSKey(2)
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		if requests.Add(1) <= 2 {
+			writer.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		_, _ = writer.Write(workContextJWKSJSON(t, map[string]ed25519.PublicKey{"key-b": secondPublic, "key-a": firstPublic}))
+	}))
+	t.Cleanup(server.Close)
+	verifier, err := NewWorkContextJWKSVerifier(WorkContextJWKSVerifierOptions{
+		URL: server.URL, Now: func() time.Time { return workContextTestTime }, CacheTTL: time.Second,
+	})
+	require.NoError(t, err)
+	require.Equal(t, WorkContextJWKSStatus{KeyIDs: []string{}}, verifier.Status())
+
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, verifier.Prefetch(ctx))
+	require.EqualValues(t, 3, requests.Load())
+	status := verifier.Status()
+	require.True(t, status.Loaded)
+	require.EqualValues(t, 1, status.Generation)
+	require.Equal(t, []string{"key-a", "key-b"}, status.KeyIDs)
+	require.Equal(t, workContextTestTime.Add(time.Second), status.ExpiresAt)
+	require.Equal(t, workContextTestTime, status.LastSuccess)
+	require.Equal(t, workContextTestTime, status.LastFailure)
+	require.Empty(t, status.LastErrorClass, "a later success clears the error class")
+	require.False(t, status.UnknownKeyRefreshReserved)
+
+	_, err = verifier.Verify(t.Context(), workContextJWKSToken(t, "key-a", firstPrivate), WorkContextExpectations{})
+	require.NoError(t, err)
+	require.EqualValues(t, 3, requests.Load(), "Verify uses the prefetched key set")
+	_, err = verifier.Verify(t.Context(), workContextJWKSToken(t, "key-z", firstPrivate), WorkContextExpectations{})
+	require.ErrorIs(t, err, ErrWorkContextInvalid)
+	require.True(t, verifier.Status().UnknownKeyRefreshReserved)
+}
+
+func TestWorkContextJWKSVerifierPrefetchHonoursContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		writer.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+	verifier, err := NewWorkContextJWKSVerifier(WorkContextJWKSVerifierOptions{
+		URL: server.URL, Now: func() time.Time { return workContextTestTime }, CacheTTL: time.Second,
+	})
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+	defer cancel()
+	err = verifier.Prefetch(ctx)
+	require.ErrorIs(t, err, ErrWorkContextJWKSUnavailable)
+	require.ErrorContains(t, err, "HTTP 503")
+	status := verifier.Status()
+	require.False(t, status.Loaded)
+	require.Equal(t, WorkContextJWKSErrorHTTPStatus, status.LastErrorClass)
+	require.Equal(t, workContextTestTime, status.LastFailure)
+	require.True(t, status.LastSuccess.IsZero())
+}
+
+func TestWorkContextJWKSStatusClassifiesFailures(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, request *http.Request) {
+		<-request.Context().Done()
+	}))
+	t.Cleanup(slow.Close)
+	malformed := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+		writer.Header().Set("Content-Type", "application/json")
+		_, _ = writer.Write([]byte(`{"keys":[]}`))
+	}))
+	t.Cleanup(malformed.Close)
+	redirect := httptest.NewServer(http.RedirectHandler("https://elsewhere.example.test/keys", http.StatusFound))
+	t.Cleanup(redirect.Close)
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	for _, testCase := range []struct {
+		options  WorkContextJWKSVerifierOptions
+		expected WorkContextJWKSErrorClass
+	}{
+		{WorkContextJWKSVerifierOptions{URL: slow.URL, RequestTimeout: 50 * time.Millisecond}, WorkContextJWKSErrorTimeout},
+		{WorkContextJWKSVerifierOptions{URL: malformed.URL}, WorkContextJWKSErrorInvalidDocument},
+		{WorkContextJWKSVerifierOptions{URL: redirect.URL}, WorkContextJWKSErrorHTTPStatus},
+		{WorkContextJWKSVerifierOptions{URL: closed.URL}, WorkContextJWKSErrorTransport},
+		{WorkContextJWKSVerifierOptions{File: filepath.Join(t.TempDir(), "missing.json")}, WorkContextJWKSErrorFile},
+	} {
+		testCase.options.Now = func() time.Time { return workContextTestTime }
+		verifier, err := NewWorkContextJWKSVerifier(testCase.options)
+		require.NoError(t, err)
+		_, _, _, err = verifier.current(t.Context())
+		require.Error(t, err)
+		require.Equal(t, testCase.expected, verifier.Status().LastErrorClass, err.Error())
+	}
+}
@@ -98,6 +98,17 @@ func TestWorkContextJWKSVerifierRejectsRedirects(t *testing.T) {
 	require.Zero(t, destinationRequests.Load())
 }
 
+func TestWorkContextJWKSVerifierClassifiesTokenShape(t *testing.T) {
+	verifier, err := NewWorkContextJWKSVerifier(WorkContextJWKSVerifierOptions{
+		URL: "https://authority.invalid/jwks", Now: func() time.Time { return workContextTestTime },
+	})
+	require.NoError(t, err)
+	_, err = verifier.Verify(t.Context(), WorkContextToken{}, WorkContextExpectations{})
+	require.Equal(t, WorkContextReasonMissing, WorkContextErrorReasonOf(err), "an empty token is missing, as ParseWorkContextToken reports")
+	_, err = verifier.Verify(t.Context(), WorkContextToken{encoded: "not-a-token"}, WorkContextExpectations{})
+	require.Equal(t, WorkContextReasonMalformed, WorkContextErrorReasonOf(err))
+}
+
 func TestWorkContextJWKSVerifierSuppressesConcurrentRotationRefresh(t *testing.T) {
 	firstPublic, _ := workContextJWKSKey(1)
 	secondPublic, secondPrivate := workContextJWKSKey(2)
@@ -201,6 +212,112 @@ func TestWorkContextJWKSVerifierRefreshesExpiredCacheWithoutRefreshingBadSignatu
 	require.EqualValues(t, 2, requests.Load())
 }
 
+func TestWorkContextJWKSVerifierRevalidatesWithConditionalRequests(t *testing.T) {
+	publicKey, privateKey := workContextJWKSKey(1)
+	var requests, revalidations atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		requests.Add(1)
+		writer.Header().Set("Cache-Control", "public, max-age=60")
+		if request.Header.Get("If-None-Match") == `"keys-v1"` &&
+			request.Header.Get("If-Modified-Since") == "Thu, 23 Jul 2026 12:00:00 GMT" {
+			revalidations.Add(1)
+			writer.WriteHeader(http.StatusNotModified)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		writer.Header().Set("ETag", `"keys-v1"`)
+		writer.Header().Set("Last-Modified", "Thu, 23 Jul 2026 12:00:00 GMT")
+		_, _ = writer.Write(workContextJWKSJSON(t, map[string]ed25519.PublicKey{"key-1": publicKey}))
+	}))
+	t.Cleanup(server.Close)
+	var mu sync.RWMutex
+	now := workContextTestTime
+	advance := func(by time.Duration) {
+		mu.Lock()
+		now = now.Add(by)
+		mu.Unlock()
+	}
+	verifier, err := NewWorkContextJWKSVerifier(WorkContextJWKSVerifierOptions{
+		URL: server.URL,
+		Now: func() time.Time {
+			mu.RLock()
+			defer mu.RUnlock()
+			return now
+		},
+		CacheTTL: 5 * time.Minute,
+	})
+	require.NoError(t, err)
+	token := workContextJWKSToken(t, "key-1", privateKey)
+	_, err = verifier.Verify(t.Context(), token, WorkContextExpectations{})
+	require.NoError(t, err)
+	first, generation := verifier.verifier, verifier.generation
+
+	advance(30 * time.Second)
+	_, err = verifier.Verify(t.Context(), token, WorkContextExpectations{})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, requests.Load())
+
+	advance(31 * time.Second)
+	_, err = verifier.Verify(t.Context(), token, WorkContextExpectations{})
+	require.NoError(t, err)
+	require.EqualValues(t, 2, requests.Load(), "max-age shortens the configured TTL")
+	require.EqualValues(t, 1, revalidations.Load())
+	require.Same(t, first, verifier.verifier, "304 keeps the verifier")
+	require.Equal(t, generation, verifier.generation, "304 keeps the generation")
+
+	advance(59 * time.Second)
+	_, err = verifier.Verify(t.Context(), token, WorkContextExpectations{})
+	require.NoError(t, err)
+	require.EqualValues(t, 2, requests.Load(), "304 extends freshness")
+}
+
+func TestWorkContextJWKSFreshnessClampsCacheControl(t *testing.T) {
+	verifier, err := NewWorkContextJWKSVerifier(WorkContextJWKSVerifierOptions{
+		URL:         "https://accounts.example.test/keys",
+		CacheTTL:    5 * time.Minute,
+		MinCacheTTL: time.Minute,
+		MaxCacheTTL: time.Hour,
+	})
+	require.NoError(t, err)
+	for _, testCase := range []struct {
+		cacheControl string
+		age          string
+		expected     time.Duration
+	}{
+		{expected: 5 * time.Minute},
+		{cacheControl: "public", expected: 5 * time.Minute},
+		{cacheControl: "max-age=600", expected: 10 * time.Minute},
+		{cacheControl: "max-age=600", age: "300", expected: 5 * time.Minute},
+		{cacheControl: "max-age=5", expected: time.Minute},
+		{cacheControl: "max-age=86400", expected: time.Hour},
+		{cacheControl: "no-store, max-age=600", expected: time.Minute},
+		{cacheControl: "max-age=invalid", expected: 5 * time.Minute},
+	} {
+		header := http.Header{}
+		if testCase.cacheControl != "" {
+			header.Set("Cache-Control", testCase.cacheControl)
+		}
+		if testCase.age != "" {
+			header.Set("Age", testCase.age)
+		}
+		require.Equal(
+			t,
+			testCase.expected,
+			verifier.freshness(parseWorkContextJWKSMaxAge(header)),
+			"%s age=%s", testCase.cacheControl, testCase.age,
+		)
+	}
+
+	for _, options := range []WorkContextJWKSVerifierOptions{
+		{URL: "https://accounts.example.test/keys", CacheTTL: time.Minute, MinCacheTTL: 2 * time.Minute},
+		{URL: "https://accounts.example.test/keys", CacheTTL: time.Hour, MaxCacheTTL: time.Minute},
+		{URL: "https://accounts.example.test/keys", MaxCacheTTL: maxWorkContextJWKSCacheTTL + time.Second},
+	} {
+		_, err = NewWorkContextJWKSVerifier(options)
+		require.ErrorIs(t, err, ErrWorkContextInvalid)
+	}
+}
+
 func TestWorkContextJWKSVerifierBoundsAndValidatesRemoteKeys(t *testing.T) {
 	cases := []struct {
 		name        string
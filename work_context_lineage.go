@@ -0,0 +1,296 @@
+package codefly
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	basev0 "github.com/codefly-dev/core/generated/go/codefly/base/v0"
+)
+
+const (
+	maxWorkContextLineageFileBytes   = 1 << 30
+	maxWorkContextLineageRecordBytes = 64 * 1024
+)
+
+// ErrWorkContextLineageNotFound reports a lineage query for a session the
+// store has never recorded.
+var ErrWorkContextLineageNotFound = errors.New("Codefly Work Context lineage not found")
+
+// WorkContextLineageRecord is one node of a Task's session tree, written each
+// time the signer mints a Work Context. It holds identifiers only; tokens and
+// scopes are never recorded.
+type WorkContextLineageRecord struct {
+	TenantID         string                   `json:"tenant_id"`
+	OwnerPrincipalID string                   `json:"owner_principal_id"`
+	TaskID           string                   `json:"task_id"`
+	SessionID        string                   `json:"session_id"`
+	ParentSessionID  string                   `json:"parent_session_id,omitempty"`
+	Actor            *WorkContextLineageActor `json:"actor,omitempty"`
+	Audience         string                   `json:"audience"`
+	IssuedAt         time.Time                `json:"issued_at"`
+	ExpiresAt        time.Time                `json:"expires_at"`
+	Nonce            string                   `json:"nonce"`
+}
+
+// WorkContextLineageActor identifies the acting principal of a session: the
+// last entry of its actor chain.
+type WorkContextLineageActor struct {
+	PrincipalID   string `json:"principal_id"`
+	PrincipalKind string `json:"principal_kind"`
+	DelegationID  string `json:"delegation_id"`
+}
+
+// WorkContextLineageRecorder receives a record for every Work Context a signer
+// mints. A recorder error fails the mint, so no capability exists that the
+// lineage does not know about.
+type WorkContextLineageRecorder interface {
+	RecordWorkContext(record WorkContextLineageRecord) error
+}
+
+func newWorkContextLineageRecord(context *basev0.WorkContextV1) WorkContextLineageRecord {
+	record := WorkContextLineageRecord{
+		TenantID:         context.GetTenantId(),
+		OwnerPrincipalID: context.GetOwnerPrincipalId(),
+		TaskID:           context.GetTaskId(),
+		SessionID:        context.GetSessionId(),
+		ParentSessionID:  context.GetParentSessionId(),
+		Audience:         context.GetAudience(),
+		IssuedAt:         time.Unix(context.GetIssuedAtUnix(), 0).UTC(),
+		ExpiresAt:        time.Unix(context.GetExpiresAtUnix(), 0).UTC(),
+		Nonce:            context.GetNonce(),
+	}
+	if actors := context.GetActorChain(); len(actors) > 0 {
+		actor := actors[len(actors)-1]
+		record.Actor = &WorkContextLineageActor{
+			PrincipalID:   actor.GetPrincipalId(),
+			PrincipalKind: actor.GetPrincipalKind(),
+			DelegationID:  actor.GetDelegationId(),
+		}
+	}
+	return record
+}
+
+// workContextLineageTask identifies a Task. Task and session IDs are chosen by
+// callers, so they are only unique within a tenant and a task.
+type workContextLineageTask struct {
+	tenantID string
+	taskID   string
+}
+
+type workContextLineageSession struct {
+	workContextLineageTask
+	sessionID string
+}
+
+// WorkContextLineageFile is an append-only JSON-lines lineage store. The whole
+// file is indexed in memory on open, so queries never read the file. A file
+// must have a single writer process at a time.
+type WorkContextLineageFile struct {
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	sessions map[workContextLineageSession]WorkContextLineageRecord
+	tasks    map[workContextLineageTask][]string
+}
+
+// OpenWorkContextLineageFile opens or creates a lineage file. An existing file
+// must follow the same rules as LoadRuntimeEnvironmentFile: a regular,
+// non-symlinked file that is not accessible by group or world. A partial final
+// line left by a crash is discarded; any other malformed line is an error.
+func OpenWorkContextLineageFile(path string) (*WorkContextLineageFile, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return nil, errors.New("Codefly Work Context lineage file path is required")
+	}
+	if _, err := statSafeFile(path, "Codefly Work Context lineage file", maxWorkContextLineageFileBytes, 0o077); err != nil &&
+		!errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open Codefly Work Context lineage file: %w", err)
+	}
+	// Re-check after open: the file may have been created just now, or the
+	// path swapped since the first check.
+	info, err := statSafeFile(path, "Codefly Work Context lineage file", maxWorkContextLineageFileBytes, 0o077)
+	if err == nil {
+		var opened os.FileInfo
+		if opened, err = file.Stat(); err == nil && !os.SameFile(info, opened) {
+			err = errors.New("Codefly Work Context lineage file changed while it was opened")
+		}
+	}
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	store := &WorkContextLineageFile{
+		file:     file,
+		sessions: make(map[workContextLineageSession]WorkContextLineageRecord),
+		tasks:    make(map[workContextLineageTask][]string),
+	}
+	if err := store.load(); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+func (s *WorkContextLineageFile) load() error {
+	reader := bufio.NewReaderSize(s.file, 64*1024)
+	var offset int64
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := reader.ReadSlice('\n')
+		if errors.Is(err, bufio.ErrBufferFull) {
+			return fmt.Errorf("Codefly Work Context lineage line %d exceeds %d bytes", lineNumber, reader.Size())
+		}
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				// A record is only complete once its newline is written.
+				if truncateErr := s.file.Truncate(offset); truncateErr != nil {
+					return fmt.Errorf("discard partial Codefly Work Context lineage record: %w", truncateErr)
+				}
+			}
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read Codefly Work Context lineage file: %w", err)
+		}
+		offset += int64(len(line))
+		var record WorkContextLineageRecord
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&record); err != nil {
+			return fmt.Errorf("invalid Codefly Work Context lineage record at line %d: %w", lineNumber, err)
+		}
+		s.index(record)
+	}
+	if _, err := s.file.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("seek Codefly Work Context lineage file: %w", err)
+	}
+	s.size = offset
+	return nil
+}
+
+// RecordWorkContext appends one record and indexes it.
+func (s *WorkContextLineageFile) RecordWorkContext(record WorkContextLineageRecord) error {
+	if record.TaskID == "" || record.SessionID == "" {
+		return fmt.Errorf("%w: lineage record requires task and session IDs", ErrWorkContextInvalid)
+	}
+	encoded, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("encode Codefly Work Context lineage record: %w", err)
+	}
+	encoded = append(encoded, '\n')
+	if len(encoded) > maxWorkContextLineageRecordBytes {
+		return fmt.Errorf("Codefly Work Context lineage record exceeds %d bytes", maxWorkContextLineageRecordBytes)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return errors.New("Codefly Work Context lineage file is closed")
+	}
+	if s.size+int64(len(encoded)) > maxWorkContextLineageFileBytes {
+		return fmt.Errorf("Codefly Work Context lineage file exceeds %d bytes", maxWorkContextLineageFileBytes)
+	}
+	if _, err := s.file.Write(encoded); err != nil {
+		// Roll back a short write so the next record starts on a clean line.
+		if truncateErr := s.file.Truncate(s.size); truncateErr == nil {
+			_, _ = s.file.Seek(s.size, io.SeekStart)
+		}
+		return fmt.Errorf("append Codefly Work Context lineage record: %w", err)
+	}
+	s.size += int64(len(encoded))
+	s.index(record)
+	return nil
+}
+
+// index keeps the latest record per session; a session re-minted with a
+// later expiry extends its lifetime.
+func (s *WorkContextLineageFile) index(record WorkContextLineageRecord) {
+	task := workContextLineageTask{tenantID: record.TenantID, taskID: record.TaskID}
+	key := workContextLineageSession{workContextLineageTask: task, sessionID: record.SessionID}
+	previous, known := s.sessions[key]
+	if !known {
+		s.tasks[task] = append(s.tasks[task], record.SessionID)
+	}
+	if !known || !record.ExpiresAt.Before(previous.ExpiresAt) {
+		s.sessions[key] = record
+	}
+}
+
+// Session returns the latest record of one session of a tenant's task.
+func (s *WorkContextLineageFile) Session(tenantID, taskID, sessionID string) (WorkContextLineageRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.sessions[workContextLineageSession{workContextLineageTask{tenantID, taskID}, sessionID}]
+	if !ok {
+		return WorkContextLineageRecord{}, fmt.Errorf("%w: session %q", ErrWorkContextLineageNotFound, sessionID)
+	}
+	return record, nil
+}
+
+// LiveSessions returns the sessions of a tenant's task that have not expired
+// at now, in the order they were first recorded.
+func (s *WorkContextLineageFile) LiveSessions(tenantID, taskID string, now time.Time) []WorkContextLineageRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	task := workContextLineageTask{tenantID, taskID}
+	var live []WorkContextLineageRecord
+	for _, sessionID := range s.tasks[task] {
+		if record := s.sessions[workContextLineageSession{task, sessionID}]; now.Before(record.ExpiresAt) {
+			live = append(live, record)
+		}
+	}
+	return live
+}
+
+// DelegationPath returns the chain of sessions of a tenant's task from its
+// root session down to sessionID. A parent missing from the store ends the
+// path early, so the first element is not necessarily a root.
+func (s *WorkContextLineageFile) DelegationPath(tenantID, taskID, sessionID string) ([]WorkContextLineageRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	task := workContextLineageTask{tenantID, taskID}
+	record, ok := s.sessions[workContextLineageSession{task, sessionID}]
+	if !ok {
+		return nil, fmt.Errorf("%w: session %q", ErrWorkContextLineageNotFound, sessionID)
+	}
+	path := []WorkContextLineageRecord{record}
+	visited := map[string]struct{}{sessionID: {}}
+	for record.ParentSessionID != "" {
+		if _, cycle := visited[record.ParentSessionID]; cycle {
+			return nil, fmt.Errorf("%w: lineage of session %q contains a cycle", ErrWorkContextInvalid, sessionID)
+		}
+		parent, ok := s.sessions[workContextLineageSession{task, record.ParentSessionID}]
+		if !ok {
+			break
+		}
+		visited[record.ParentSessionID] = struct{}{}
+		path = append(path, parent)
+		record = parent
+	}
+	slices.Reverse(path)
+	return path, nil
+}
+
+// Close flushes the file to stable storage and closes it.
+func (s *WorkContextLineageFile) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	syncErr := s.file.Sync()
+	closeErr := s.file.Close()
+	s.file = nil
+	return errors.Join(syncErr, closeErr)
+}
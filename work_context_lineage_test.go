@@ -0,0 +1,176 @@
+package codefly
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type failingWorkContextLineageRecorder struct{}
+
+func (failingWorkContextLineageRecorder) RecordWorkContext(WorkContextLineageRecord) error {
+	return errors.New("lineage unavailable")
+}
+
+func TestWorkContextLineageFileRecordsSessionTree(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lineage.jsonl")
+	store, err := OpenWorkContextLineageFile(path)
+	require.NoError(t, err)
+	now := workContextTestTime
+	_, privateKey := workContextTestKeys()
+	signer, err := NewWorkContextSigner(WorkContextSignerOptions{
+		Issuer:          "https://accounts.codefly.dev/work-context",
+		KeyID:           "work-context-test-2026-07",
+		PrivateKey:      privateKey,
+		Now:             func() time.Time { return now },
+		LineageRecorder: store,
+	})
+	require.NoError(t, err)
+
+	root, rootClaims, err := signer.StartTask(workContextTestInput())
+	require.NoError(t, err)
+	child, _, err := signer.StartChildSession(root, StartChildSessionInput{
+		SessionID: "session-child", Audience: "warden.tools", TTL: time.Minute,
+		Actor: workContextDelegationActor("tool-1", "tool"),
+	})
+	require.NoError(t, err)
+	_, _, err = signer.StartChildSession(child, StartChildSessionInput{
+		SessionID: "session-grandchild", Audience: "warden.tools", TTL: 2 * time.Minute,
+		Actor: workContextDelegationActor("tool-2", "tool"),
+	})
+	require.NoError(t, err)
+
+	rootRecord, err := store.Session("tenant-codefly", "task-roadmap", "session-root")
+	require.NoError(t, err)
+	require.Equal(t, WorkContextLineageRecord{
+		TenantID:         "tenant-codefly",
+		OwnerPrincipalID: "principal-antoine",
+		TaskID:           "task-roadmap",
+		SessionID:        "session-root",
+		Actor: &WorkContextLineageActor{
+			PrincipalID: "agent-claude-code", PrincipalKind: "agent", DelegationID: "delegation-1",
+		},
+		Audience:  "warden.evidence",
+		IssuedAt:  workContextTestTime,
+		ExpiresAt: workContextTestTime.Add(5 * time.Minute),
+		Nonce:     rootClaims.Nonce,
+	}, rootRecord)
+	require.NoError(t, store.Close())
+	require.NoError(t, store.Close())
+	require.Error(t, store.RecordWorkContext(rootRecord), "a closed store refuses records")
+
+	reopened, err := OpenWorkContextLineageFile(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, reopened.Close()) })
+	delegation, err := reopened.DelegationPath("tenant-codefly", "task-roadmap", "session-grandchild")
+	require.NoError(t, err)
+	require.Len(t, delegation, 3)
+	require.Equal(t, []string{"session-root", "session-child", "session-grandchild"}, []string{
+		delegation[0].SessionID, delegation[1].SessionID, delegation[2].SessionID,
+	})
+	require.Equal(t, "tool-2", delegation[2].Actor.PrincipalID)
+
+	live := reopened.LiveSessions("tenant-codefly", "task-roadmap", workContextTestTime.Add(90*time.Second))
+	require.Len(t, live, 2, "the one-minute child has expired")
+	require.Equal(t, "session-root", live[0].SessionID)
+	require.Equal(t, "session-grandchild", live[1].SessionID)
+	require.Empty(t, reopened.LiveSessions("tenant-codefly", "task-other", workContextTestTime))
+
+	_, err = reopened.DelegationPath("tenant-codefly", "task-roadmap", "session-unknown")
+	require.ErrorIs(t, err, ErrWorkContextLineageNotFound)
+}
+
+func TestWorkContextLineageFileSeparatesTenantsAndTasks(t *testing.T) {
+	store, err := OpenWorkContextLineageFile(filepath.Join(t.TempDir(), "lineage.jsonl"))
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, store.Close()) })
+	expiresAt := workContextTestTime.Add(time.Minute)
+	// Every tenant and task picks its own IDs, so the same session IDs recur.
+	for _, record := range []WorkContextLineageRecord{
+		{TenantID: "tenant-a", TaskID: "task-1", SessionID: "session-root", ExpiresAt: expiresAt},
+		{TenantID: "tenant-a", TaskID: "task-1", SessionID: "session-child", ParentSessionID: "session-root", ExpiresAt: expiresAt},
+		{TenantID: "tenant-a", TaskID: "task-2", SessionID: "session-root", ExpiresAt: expiresAt},
+		{TenantID: "tenant-b", TaskID: "task-1", SessionID: "session-root", Audience: "tenant-b", ExpiresAt: expiresAt},
+	} {
+		require.NoError(t, store.RecordWorkContext(record))
+	}
+
+	require.Len(t, store.LiveSessions("tenant-a", "task-1", workContextTestTime), 2)
+	require.Len(t, store.LiveSessions("tenant-a", "task-2", workContextTestTime), 1)
+	require.Len(t, store.LiveSessions("tenant-b", "task-1", workContextTestTime), 1)
+	record, err := store.Session("tenant-b", "task-1", "session-root")
+	require.NoError(t, err)
+	require.Equal(t, "tenant-b", record.Audience)
+	_, err = store.Session("tenant-b", "task-1", "session-child")
+	require.ErrorIs(t, err, ErrWorkContextLineageNotFound)
+
+	path, err := store.DelegationPath("tenant-a", "task-1", "session-child")
+	require.NoError(t, err)
+	require.Len(t, path, 2)
+	require.Equal(t, "tenant-a", path[0].TenantID)
+	require.Equal(t, "task-1", path[0].TaskID)
+}
+
+func TestWorkContextLineageFileRecoversFromPartialRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lineage.jsonl")
+	store, err := OpenWorkContextLineageFile(path)
+	require.NoError(t, err)
+	record := WorkContextLineageRecord{
+		TaskID: "task-roadmap", SessionID: "session-root", ExpiresAt: workContextTestTime.Add(time.Minute),
+	}
+	require.NoError(t, store.RecordWorkContext(record))
+	require.NoError(t, store.Close())
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"task_id":"task-roadmap","sess`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	store, err = OpenWorkContextLineageFile(path)
+	require.NoError(t, err)
+	record.SessionID = "session-second"
+	require.NoError(t, store.RecordWorkContext(record))
+	require.NoError(t, store.Close())
+
+	store, err = OpenWorkContextLineageFile(path)
+	require.NoError(t, err, "the partial record was discarded, not left mid-file")
+	require.Len(t, store.LiveSessions("", "task-roadmap", workContextTestTime), 2)
+	require.NoError(t, store.Close())
+}
+
+func TestWorkContextLineageFileRejectsUnsafeOrCorruptFiles(t *testing.T) {
+	directory := t.TempDir()
+	readable := filepath.Join(directory, "readable.jsonl")
+	require.NoError(t, os.WriteFile(readable, nil, 0o600))
+	require.NoError(t, os.Chmod(readable, 0o640))
+	_, err := OpenWorkContextLineageFile(readable)
+	require.ErrorContains(t, err, "must not be accessible by group or world")
+
+	link := filepath.Join(directory, "link.jsonl")
+	require.NoError(t, os.Symlink(readable, link))
+	_, err = OpenWorkContextLineageFile(link)
+	require.ErrorContains(t, err, "symbolic link")
+
+	corrupt := filepath.Join(directory, "corrupt.jsonl")
+	require.NoError(t, os.WriteFile(corrupt, []byte("not json\n{}\n"), 0o600))
+	_, err = OpenWorkContextLineageFile(corrupt)
+	require.ErrorContains(t, err, "line 1")
+
+	_, privateKey := workContextTestKeys()
+	signer, err := NewWorkContextSigner(WorkContextSignerOptions{
+		Issuer:          "https://accounts.codefly.dev/work-context",
+		KeyID:           "work-context-test-2026-07",
+		PrivateKey:      privateKey,
+		Now:             func() time.Time { return workContextTestTime },
+		LineageRecorder: failingWorkContextLineageRecorder{},
+	})
+	require.NoError(t, err)
+	token, _, err := signer.StartTask(workContextTestInput())
+	require.ErrorContains(t, err, "lineage unavailable")
+	require.Empty(t, token.Encoded(), "no capability is minted without its lineage")
+}
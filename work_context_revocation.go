@@ -0,0 +1,581 @@
+package codefly
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	basev0 "github.com/codefly-dev/core/generated/go/codefly/base/v0"
+)
+
+const (
+	defaultWorkContextRevocationPollInterval = 30 * time.Second
+	defaultWorkContextRevocationMaxStaleness = 5 * time.Minute
+	maxWorkContextRevocationStaleness        = 24 * time.Hour
+	maxWorkContextRevocationBytes            = 4 * 1024 * 1024
+	maxWorkContextRevocationEntries          = 100_000
+)
+
+var (
+	// ErrWorkContextRevoked reports a correctly signed, unexpired Work Context
+	// that a revocation list has cut off. It does not match
+	// ErrWorkContextInvalid, so callers can tell revocation from expiry.
+	ErrWorkContextRevoked = errors.New("Codefly Work Context revoked")
+	// ErrWorkContextRevocationUnavailable reports that a revocation feed has
+	// no current list. Verification fails closed rather than skip the check.
+	ErrWorkContextRevocationUnavailable = errors.New("Codefly Work Context revocation list is unavailable")
+)
+
+// WorkContextRevocationChecker is consulted by WorkContextVerifier and
+// WorkContextJWKSVerifier after signature, time, and expectation checks have
+// passed. It returns nil for a token that may be used.
+type WorkContextRevocationChecker interface {
+	CheckWorkContextRevocation(claims *basev0.WorkContextV1) error
+}
+
+// WorkContextRevocations is the revocation document. Task, session, and
+// delegation IDs are chosen by callers and are only unique within a tenant, so
+// each entry names its tenant; nonces are random and need no scope.
+//
+// Revoking a session also rejects the sessions descending from it. Without a
+// lineage, only its direct children are known from their claims; revoke the
+// task or a delegation ID to cut off a whole subtree, since every descendant
+// carries both.
+type WorkContextRevocations struct {
+	Sessions    []WorkContextRevokedSession    `json:"sessions,omitempty"`
+	Tasks       []WorkContextRevokedTask       `json:"tasks,omitempty"`
+	Nonces      []string                       `json:"nonces,omitempty"`
+	Delegations []WorkContextRevokedDelegation `json:"delegations,omitempty"`
+}
+
+// WorkContextRevokedTask names a revoked task of a tenant.
+type WorkContextRevokedTask struct {
+	TenantID string `json:"tenant_id"`
+	TaskID   string `json:"task_id"`
+}
+
+// WorkContextRevokedSession names a revoked session of a tenant's task.
+type WorkContextRevokedSession struct {
+	TenantID  string `json:"tenant_id"`
+	TaskID    string `json:"task_id"`
+	SessionID string `json:"session_id"`
+}
+
+// WorkContextRevokedDelegation names a revoked delegation of a tenant.
+type WorkContextRevokedDelegation struct {
+	TenantID     string `json:"tenant_id"`
+	DelegationID string `json:"delegation_id"`
+}
+
+// WorkContextLineageReader resolves the ancestors of a session, as
+// WorkContextLineageFile does.
+type WorkContextLineageReader interface {
+	DelegationPath(tenantID, taskID, sessionID string) ([]WorkContextLineageRecord, error)
+}
+
+type workContextRevocationSet struct {
+	sessions    map[WorkContextRevokedSession]struct{}
+	tasks       map[WorkContextRevokedTask]struct{}
+	nonces      map[string]struct{}
+	delegations map[WorkContextRevokedDelegation]struct{}
+	// sessionTasks counts the revoked sessions of each task, so that only
+	// tokens of those tasks pay for a lineage walk.
+	sessionTasks map[WorkContextRevokedTask]int
+}
+
+func newWorkContextRevocationSet(revocations WorkContextRevocations) (workContextRevocationSet, error) {
+	set := workContextRevocationSet{
+		sessions:     map[WorkContextRevokedSession]struct{}{},
+		tasks:        map[WorkContextRevokedTask]struct{}{},
+		nonces:       map[string]struct{}{},
+		delegations:  map[WorkContextRevokedDelegation]struct{}{},
+		sessionTasks: map[WorkContextRevokedTask]int{},
+	}
+	return set, set.add(revocations)
+}
+
+func (s workContextRevocationSet) add(revocations WorkContextRevocations) error {
+	total := len(s.sessions) + len(s.tasks) + len(s.nonces) + len(s.delegations) +
+		len(revocations.Sessions) + len(revocations.Tasks) +
+		len(revocations.Nonces) + len(revocations.Delegations)
+	if total > maxWorkContextRevocationEntries {
+		return fmt.Errorf("%w: revocation list exceeds %d entries", ErrWorkContextInvalid, maxWorkContextRevocationEntries)
+	}
+	type field struct{ name, value string }
+	var fields []field
+	for _, session := range revocations.Sessions {
+		fields = append(fields,
+			field{"revoked session tenant_id", session.TenantID},
+			field{"revoked session task_id", session.TaskID},
+			field{"revoked session_id", session.SessionID})
+	}
+	for _, task := range revocations.Tasks {
+		fields = append(fields, field{"revoked task tenant_id", task.TenantID}, field{"revoked task_id", task.TaskID})
+	}
+	for _, nonce := range revocations.Nonces {
+		fields = append(fields, field{"revoked nonce", nonce})
+	}
+	for _, delegation := range revocations.Delegations {
+		fields = append(fields,
+			field{"revoked delegation tenant_id", delegation.TenantID},
+			field{"revoked delegation_id", delegation.DelegationID})
+	}
+	for _, field := range fields {
+		if err := validateBounded(field.name, field.value, workContextMaxIDBytes, true); err != nil {
+			return err
+		}
+	}
+	for _, session := range revocations.Sessions {
+		if _, known := s.sessions[session]; !known {
+			s.sessions[session] = struct{}{}
+			s.sessionTasks[WorkContextRevokedTask{TenantID: session.TenantID, TaskID: session.TaskID}]++
+		}
+	}
+	for _, task := range revocations.Tasks {
+		s.tasks[task] = struct{}{}
+	}
+	for _, nonce := range revocations.Nonces {
+		s.nonces[nonce] = struct{}{}
+	}
+	for _, delegation := range revocations.Delegations {
+		s.delegations[delegation] = struct{}{}
+	}
+	return nil
+}
+
+// check rejects revoked claims. With a lineage, a revoked session rejects all
+// its descendants; without one, only its direct children.
+func (s workContextRevocationSet) check(claims *basev0.WorkContextV1, lineage WorkContextLineageReader) error {
+	if claims == nil {
+		return fmt.Errorf("%w: nil claims", ErrWorkContextInvalid)
+	}
+	task := WorkContextRevokedTask{TenantID: claims.GetTenantId(), TaskID: claims.GetTaskId()}
+	if _, revoked := s.tasks[task]; revoked {
+		return fmt.Errorf("%w: task %q", ErrWorkContextRevoked, task.TaskID)
+	}
+	if s.sessionTasks[task] > 0 {
+		if err := s.checkSessions(task, claims, lineage); err != nil {
+			return err
+		}
+	}
+	if _, revoked := s.nonces[claims.GetNonce()]; revoked {
+		return fmt.Errorf("%w: nonce", ErrWorkContextRevoked)
+	}
+	for _, actor := range claims.GetActorChain() {
+		delegation := WorkContextRevokedDelegation{TenantID: task.TenantID, DelegationID: actor.GetDelegationId()}
+		if _, revoked := s.delegations[delegation]; revoked {
+			return fmt.Errorf("%w: delegation %q", ErrWorkContextRevoked, delegation.DelegationID)
+		}
+	}
+	return nil
+}
+
+func (s workContextRevocationSet) checkSessions(
+	task WorkContextRevokedTask,
+	claims *basev0.WorkContextV1,
+	lineage WorkContextLineageReader,
+) error {
+	session := WorkContextRevokedSession{TenantID: task.TenantID, TaskID: task.TaskID, SessionID: claims.GetSessionId()}
+	if _, revoked := s.sessions[session]; revoked {
+		return fmt.Errorf("%w: session %q", ErrWorkContextRevoked, session.SessionID)
+	}
+	ancestors := []string{claims.GetParentSessionId()}
+	if lineage != nil {
+		path, err := lineage.DelegationPath(task.TenantID, task.TaskID, session.SessionID)
+		if err != nil && !errors.Is(err, ErrWorkContextLineageNotFound) {
+			// A lineage that cannot be read could hide a revoked ancestor.
+			return fmt.Errorf("%w: read lineage: %v", ErrWorkContextRevocationUnavailable, err)
+		}
+		for _, record := range path {
+			ancestors = append(ancestors, record.SessionID)
+		}
+	}
+	for _, ancestor := range ancestors {
+		if ancestor == "" || ancestor == claims.GetSessionId() {
+			continue
+		}
+		session.SessionID = ancestor
+		if _, revoked := s.sessions[session]; revoked {
+			return fmt.Errorf("%w: ancestor session %q", ErrWorkContextRevoked, ancestor)
+		}
+	}
+	return nil
+}
+
+// WorkContextRevocationListOptions configures NewWorkContextRevocationList.
+type WorkContextRevocationListOptions struct {
+	Revocations WorkContextRevocations
+	// Lineage lets a revoked session reject every descendant, not only its
+	// direct children. It is typically the authority's lineage file.
+	Lineage WorkContextLineageReader
+}
+
+// WorkContextRevocationList is an in-memory revocation list for authorities
+// that push revocations to verifiers directly, and for tests.
+type WorkContextRevocationList struct {
+	lineage WorkContextLineageReader
+
+	mu  sync.RWMutex
+	set workContextRevocationSet
+}
+
+func NewWorkContextRevocationList(options WorkContextRevocationListOptions) (*WorkContextRevocationList, error) {
+	set, err := newWorkContextRevocationSet(options.Revocations)
+	if err != nil {
+		return nil, err
+	}
+	return &WorkContextRevocationList{lineage: options.Lineage, set: set}, nil
+}
+
+// Revoke adds entries to the list. Nothing is added if any entry is invalid.
+func (l *WorkContextRevocationList) Revoke(revocations WorkContextRevocations) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	next, err := newWorkContextRevocationSet(l.snapshotLocked())
+	if err != nil {
+		return err
+	}
+	if err := next.add(revocations); err != nil {
+		return err
+	}
+	l.set = next
+	return nil
+}
+
+// Replace swaps the whole list.
+func (l *WorkContextRevocationList) Replace(revocations WorkContextRevocations) error {
+	next, err := newWorkContextRevocationSet(revocations)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.set = next
+	return nil
+}
+
+func (l *WorkContextRevocationList) CheckWorkContextRevocation(claims *basev0.WorkContextV1) error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.set.check(claims, l.lineage)
+}
+
+func (l *WorkContextRevocationList) snapshotLocked() WorkContextRevocations {
+	return WorkContextRevocations{
+		Sessions:    slices.Collect(maps.Keys(l.set.sessions)),
+		Tasks:       slices.Collect(maps.Keys(l.set.tasks)),
+		Nonces:      slices.Collect(maps.Keys(l.set.nonces)),
+		Delegations: slices.Collect(maps.Keys(l.set.delegations)),
+	}
+}
+
+// WorkContextRevocationFeedOptions configures a polled revocation list.
+// Exactly one of URL and File is set; both serve a WorkContextRevocations JSON
+// document.
+type WorkContextRevocationFeedOptions struct {
+	URL string
+	// File must be a regular, non-symlinked file that is not writable by
+	// group or world. It is reloaded when its inode, modification time, or
+	// size changes.
+	File           string
+	HTTPClient     *http.Client
+	RequestTimeout time.Duration
+	PollInterval   time.Duration
+	// MaxStaleness bounds how long the last loaded list keeps serving while
+	// polls fail. After it, and before the first successful load, checks
+	// fail with ErrWorkContextRevocationUnavailable.
+	MaxStaleness time.Duration
+	// Lineage has the same meaning as on WorkContextRevocationListOptions.
+	Lineage WorkContextLineageReader
+	Now     func() time.Time
+}
+
+// WorkContextRevocationFeed polls a revocation document in the background.
+// Call Close to stop polling.
+type WorkContextRevocationFeed struct {
+	mu           sync.RWMutex
+	fetch        func(ctx context.Context) (*workContextRevocationSet, error)
+	pollInterval time.Duration
+	maxStaleness time.Duration
+	lineage      WorkContextLineageReader
+	now          func() time.Time
+	set          *workContextRevocationSet
+	loadedAt     time.Time
+	lastError    error
+	stop         context.CancelFunc
+	done         chan struct{}
+	closeOnce    sync.Once
+	// Only the polling goroutine and Prefetch touch the source validators.
+	fetchMu    sync.Mutex
+	etag       string
+	fileInfo   os.FileInfo
+	unmodified *workContextRevocationSet
+}
+
+// NewWorkContextRevocationFeed validates configuration and starts polling.
+func NewWorkContextRevocationFeed(options WorkContextRevocationFeedOptions) (*WorkContextRevocationFeed, error) {
+	if (options.URL == "") == (options.File == "") {
+		return nil, fmt.Errorf("%w: exactly one of revocation URL or File is required", ErrWorkContextInvalid)
+	}
+	pollInterval := options.PollInterval
+	if pollInterval == 0 {
+		pollInterval = defaultWorkContextRevocationPollInterval
+	}
+	if pollInterval < 100*time.Millisecond || pollInterval > time.Hour {
+		return nil, fmt.Errorf("%w: revocation poll interval must be between 100ms and 1h", ErrWorkContextInvalid)
+	}
+	maxStaleness := options.MaxStaleness
+	if maxStaleness == 0 {
+		maxStaleness = max(defaultWorkContextRevocationMaxStaleness, 2*pollInterval)
+	}
+	if maxStaleness < pollInterval || maxStaleness > maxWorkContextRevocationStaleness {
+		return nil, fmt.Errorf(
+			"%w: revocation max staleness must be between the poll interval and %s",
+			ErrWorkContextInvalid,
+			maxWorkContextRevocationStaleness,
+		)
+	}
+	requestTimeout := options.RequestTimeout
+	if requestTimeout == 0 {
+		requestTimeout = defaultWorkContextJWKSRequestTimeout
+	}
+	if requestTimeout < time.Millisecond || requestTimeout > maxWorkContextJWKSRequestTimeout {
+		return nil, fmt.Errorf(
+			"%w: revocation request timeout must be between 1ms and %s",
+			ErrWorkContextInvalid,
+			maxWorkContextJWKSRequestTimeout,
+		)
+	}
+	now := options.Now
+	if now == nil {
+		now = time.Now
+	}
+	feed := &WorkContextRevocationFeed{
+		pollInterval: pollInterval,
+		maxStaleness: maxStaleness,
+		lineage:      options.Lineage,
+		now:          now,
+	}
+	if options.File != "" {
+		path := strings.TrimSpace(options.File)
+		if path == "" || strings.ContainsRune(path, 0) {
+			return nil, fmt.Errorf("%w: revocation file path is invalid", ErrWorkContextInvalid)
+		}
+		path = filepath.Clean(path)
+		feed.fetch = func(context.Context) (*workContextRevocationSet, error) { return feed.fetchFile(path) }
+	} else {
+		endpoint, err := validateWorkContextJWKSURL(options.URL)
+		if err != nil {
+			return nil, err
+		}
+		client := options.HTTPClient
+		if client == nil {
+			client = &http.Client{
+				CheckRedirect: func(_ *http.Request, _ []*http.Request) error {
+					return http.ErrUseLastResponse
+				},
+			}
+		}
+		feed.fetch = func(ctx context.Context) (*workContextRevocationSet, error) {
+			return feed.fetchURL(ctx, client, endpoint, requestTimeout)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	feed.stop = cancel
+	feed.done = make(chan struct{})
+	go feed.pollLoop(ctx)
+	return feed, nil
+}
+
+// CheckWorkContextRevocation consults the last loaded list.
+func (f *WorkContextRevocationFeed) CheckWorkContextRevocation(claims *basev0.WorkContextV1) error {
+	if f == nil {
+		return fmt.Errorf("%w: nil revocation feed", ErrWorkContextRevocationUnavailable)
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.set == nil {
+		if f.lastError != nil {
+			return fmt.Errorf("%w: %v", ErrWorkContextRevocationUnavailable, f.lastError)
+		}
+		return fmt.Errorf("%w: not loaded yet", ErrWorkContextRevocationUnavailable)
+	}
+	if age := f.now().Sub(f.loadedAt); age > f.maxStaleness {
+		return fmt.Errorf("%w: last loaded %s ago: %v", ErrWorkContextRevocationUnavailable, age.Truncate(time.Second), f.lastError)
+	}
+	return f.set.check(claims, f.lineage)
+}
+
+// Prefetch loads the list ahead of the first verification, retrying until it
+// succeeds or ctx ends.
+func (f *WorkContextRevocationFeed) Prefetch(ctx context.Context) error {
+	if f == nil {
+		return fmt.Errorf("%w: nil revocation feed", ErrWorkContextRevocationUnavailable)
+	}
+	if ctx == nil {
+		return fmt.Errorf("%w: nil context", ErrWorkContextInvalid)
+	}
+	for {
+		err := f.refresh(ctx)
+		if err == nil {
+			return nil
+		}
+		timer := time.NewTimer(jitterWorkContextJWKSDelay(min(f.pollInterval, defaultWorkContextJWKSRetryInterval)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w: %v", ErrWorkContextRevocationUnavailable, err)
+		case <-timer.C:
+		}
+	}
+}
+
+// Close stops polling. The last loaded list keeps serving until it exceeds
+// MaxStaleness.
+func (f *WorkContextRevocationFeed) Close() error {
+	if f == nil {
+		return nil
+	}
+	f.closeOnce.Do(func() {
+		f.stop()
+		<-f.done
+	})
+	return nil
+}
+
+func (f *WorkContextRevocationFeed) pollLoop(ctx context.Context) {
+	defer close(f.done)
+	delay := time.Duration(0)
+	for {
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		delay = jitterWorkContextJWKSDelay(f.pollInterval)
+		if err := f.refresh(ctx); err != nil {
+			delay = jitterWorkContextJWKSDelay(min(f.pollInterval, defaultWorkContextJWKSRetryInterval))
+		}
+	}
+}
+
+func (f *WorkContextRevocationFeed) refresh(ctx context.Context) error {
+	f.fetchMu.Lock()
+	set, err := f.fetch(ctx)
+	f.fetchMu.Unlock()
+	if ctx.Err() != nil && err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err != nil {
+		f.lastError = err
+		return err
+	}
+	f.set = set
+	f.loadedAt = f.now()
+	f.lastError = nil
+	return nil
+}
+
+// fetchFile runs with fetchMu held.
+func (f *WorkContextRevocationFeed) fetchFile(path string) (*workContextRevocationSet, error) {
+	info, err := statSafeFile(path, "Work Context revocation file", maxWorkContextRevocationBytes, 0o022)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrWorkContextInvalid, err)
+	}
+	if f.unmodified != nil && f.fileInfo != nil && sameWorkContextJWKSFile(f.fileInfo, info) {
+		return f.unmodified, nil
+	}
+	payload, opened, err := readSafeFile(path, "Work Context revocation file", maxWorkContextRevocationBytes, 0o022)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrWorkContextInvalid, err)
+	}
+	set, err := parseWorkContextRevocations(payload)
+	if err != nil {
+		return nil, err
+	}
+	f.fileInfo, f.unmodified = opened, set
+	return set, nil
+}
+
+// fetchURL runs with fetchMu held.
+func (f *WorkContextRevocationFeed) fetchURL(
+	ctx context.Context,
+	client *http.Client,
+	endpoint string,
+	timeout time.Duration,
+) (*workContextRevocationSet, error) {
+	requestContext, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(requestContext, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: create revocation request: %v", ErrWorkContextInvalid, err)
+	}
+	request.Header.Set("Accept", "application/json")
+	if f.unmodified != nil && f.etag != "" {
+		request.Header.Set("If-None-Match", f.etag)
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("%w: fetch revocation list: %v", ErrWorkContextInvalid, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotModified && f.unmodified != nil && f.etag != "" {
+		return f.unmodified, nil
+	}
+	if response.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 4*1024))
+		return nil, fmt.Errorf("%w: revocation list returned HTTP %d", ErrWorkContextInvalid, response.StatusCode)
+	}
+	if contentType := response.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, parseErr := mime.ParseMediaType(contentType)
+		if parseErr != nil || mediaType != "application/json" {
+			return nil, fmt.Errorf("%w: revocation list is not application/json", ErrWorkContextInvalid)
+		}
+	}
+	payload, err := io.ReadAll(io.LimitReader(response.Body, maxWorkContextRevocationBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: read revocation list: %v", ErrWorkContextInvalid, err)
+	}
+	if len(payload) > maxWorkContextRevocationBytes {
+		return nil, fmt.Errorf("%w: revocation list exceeds %d bytes", ErrWorkContextInvalid, maxWorkContextRevocationBytes)
+	}
+	set, err := parseWorkContextRevocations(payload)
+	if err != nil {
+		return nil, err
+	}
+	f.etag, f.unmodified = response.Header.Get("ETag"), set
+	return set, nil
+}
+
+func parseWorkContextRevocations(payload []byte) (*workContextRevocationSet, error) {
+	var revocations WorkContextRevocations
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&revocations); err != nil {
+		return nil, fmt.Errorf("%w: decode revocation list: %v", ErrWorkContextInvalid, err)
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: trailing data after revocation list", ErrWorkContextInvalid)
+	}
+	set, err := newWorkContextRevocationSet(revocations)
+	if err != nil {
+		return nil, err
+	}
+	return &set, nil
+}
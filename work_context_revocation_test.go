@@ -0,0 +1,308 @@
+package codefly
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeWorkContextRevocationFile(t *testing.T, path string, revocations WorkContextRevocations) {
+	t.Helper()
+	payload, err := json.Marshal(revocations)
+	require.NoError(t, err)
+	temporary := path + ".tmp"
+	require.NoError(t, os.WriteFile(temporary, payload, 0o600))
+	require.NoError(t, os.Rename(temporary, path))
+}
+
+// revokedWorkContextTasks names tasks of the test tenant.
+func revokedWorkContextTasks(taskIDs ...string) []WorkContextRevokedTask {
+	var tasks []WorkContextRevokedTask
+	for _, taskID := range taskIDs {
+		tasks = append(tasks, WorkContextRevokedTask{TenantID: "tenant-codefly", TaskID: taskID})
+	}
+	return tasks
+}
+
+// revokedWorkContextSessions names sessions of the test task.
+func revokedWorkContextSessions(sessionIDs ...string) []WorkContextRevokedSession {
+	var sessions []WorkContextRevokedSession
+	for _, sessionID := range sessionIDs {
+		sessions = append(sessions, WorkContextRevokedSession{
+			TenantID: "tenant-codefly", TaskID: "task-roadmap", SessionID: sessionID,
+		})
+	}
+	return sessions
+}
+
+func TestWorkContextVerifierRejectsRevokedSessionsAndTasks(t *testing.T) {
+	now := workContextTestTime
+	signer := workContextDelegationSigner(t, &now, nil)
+	root, _, err := signer.StartTask(workContextTestInput())
+	require.NoError(t, err)
+	child, childClaims, err := signer.StartChildSession(root, StartChildSessionInput{
+		SessionID: "session-child", Actor: workContextDelegationActor("tool-1", "tool"),
+	})
+	require.NoError(t, err)
+
+	revocations, err := NewWorkContextRevocationList(WorkContextRevocationListOptions{})
+	require.NoError(t, err)
+	publicKey, _ := workContextTestKeys()
+	verifier, err := NewWorkContextVerifier(WorkContextVerifierOptions{
+		PublicKeys:  map[string]ed25519.PublicKey{"work-context-test-2026-07": publicKey},
+		Now:         func() time.Time { return now },
+		CacheSize:   8,
+		Revocations: revocations,
+	})
+	require.NoError(t, err)
+	_, err = verifier.Verify(root, WorkContextExpectations{})
+	require.NoError(t, err)
+	_, err = verifier.Verify(child, WorkContextExpectations{})
+	require.NoError(t, err)
+
+	cases := []struct {
+		name          string
+		revocations   WorkContextRevocations
+		rootRevoked   bool
+		childRevoked  bool
+		expectedError string
+	}{
+		{
+			name:        "session and its direct children",
+			revocations: WorkContextRevocations{Sessions: revokedWorkContextSessions("session-root")},
+			rootRevoked: true, childRevoked: true, expectedError: `session "session-root"`,
+		},
+		{
+			name:         "child session only",
+			revocations:  WorkContextRevocations{Sessions: revokedWorkContextSessions("session-child")},
+			childRevoked: true, expectedError: `session "session-child"`,
+		},
+		{
+			name:        "task",
+			revocations: WorkContextRevocations{Tasks: revokedWorkContextTasks("task-roadmap")},
+			rootRevoked: true, childRevoked: true, expectedError: `task "task-roadmap"`,
+		},
+		{
+			name:         "nonce",
+			revocations:  WorkContextRevocations{Nonces: []string{childClaims.Nonce}},
+			childRevoked: true, expectedError: "nonce",
+		},
+		{
+			name: "delegation",
+			revocations: WorkContextRevocations{Delegations: []WorkContextRevokedDelegation{
+				{TenantID: "tenant-codefly", DelegationID: "delegation-tool-1"},
+			}},
+			childRevoked: true, expectedError: `delegation "delegation-tool-1"`,
+		},
+	}
+	for _, testCase := range cases {
+		t.Run(testCase.name, func(t *testing.T) {
+			require.NoError(t, revocations.Replace(testCase.revocations))
+			for _, token := range []struct {
+				token   WorkContextToken
+				revoked bool
+			}{{root, testCase.rootRevoked}, {child, testCase.childRevoked}} {
+				_, err := verifier.Verify(token.token, WorkContextExpectations{})
+				if !token.revoked {
+					require.NoError(t, err)
+					continue
+				}
+				require.ErrorIs(t, err, ErrWorkContextRevoked, "cached authentication still consults the list")
+				require.NotErrorIs(t, err, ErrWorkContextInvalid)
+				require.ErrorContains(t, err, testCase.expectedError)
+			}
+		})
+	}
+
+	require.NoError(t, revocations.Replace(WorkContextRevocations{}))
+	require.NoError(t, revocations.Revoke(WorkContextRevocations{Tasks: revokedWorkContextTasks("task-other")}))
+	require.ErrorIs(t, revocations.Revoke(WorkContextRevocations{
+		Tasks: revokedWorkContextTasks("task-roadmap"), Sessions: revokedWorkContextSessions(""),
+	}), ErrWorkContextInvalid)
+	_, err = verifier.Verify(root, WorkContextExpectations{})
+	require.NoError(t, err, "a rejected Revoke adds nothing")
+
+	now = now.Add(time.Hour)
+	require.NoError(t, revocations.Revoke(WorkContextRevocations{Tasks: revokedWorkContextTasks("task-roadmap")}))
+	_, err = verifier.Verify(root, WorkContextExpectations{})
+	require.ErrorIs(t, err, ErrWorkContextInvalid, "expiry is reported before revocation")
+	require.NotErrorIs(t, err, ErrWorkContextRevoked)
+}
+
+func TestWorkContextRevocationListScopesEntriesByTenantAndWalksLineage(t *testing.T) {
+	now := workContextTestTime
+	lineage, err := OpenWorkContextLineageFile(filepath.Join(t.TempDir(), "lineage.jsonl"))
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, lineage.Close()) })
+	signer := workContextDelegationSigner(t, &now, nil)
+	signer.lineage = lineage
+	root, _, err := signer.StartTask(workContextTestInput())
+	require.NoError(t, err)
+	child, _, err := signer.StartChildSession(root, StartChildSessionInput{
+		SessionID: "session-child", Actor: workContextDelegationActor("tool-1", "tool"),
+	})
+	require.NoError(t, err)
+	_, grandchild, err := signer.StartChildSession(child, StartChildSessionInput{
+		SessionID: "session-grandchild", Actor: workContextDelegationActor("tool-2", "tool"),
+	})
+	require.NoError(t, err)
+
+	revokedRoot := WorkContextRevocations{Sessions: revokedWorkContextSessions("session-root")}
+	unscoped, err := NewWorkContextRevocationList(WorkContextRevocationListOptions{Revocations: revokedRoot})
+	require.NoError(t, err)
+	require.NoError(t, unscoped.CheckWorkContextRevocation(grandchild), "without a lineage only direct children are known")
+
+	walking, err := NewWorkContextRevocationList(WorkContextRevocationListOptions{Revocations: revokedRoot, Lineage: lineage})
+	require.NoError(t, err)
+	err = walking.CheckWorkContextRevocation(grandchild)
+	require.ErrorIs(t, err, ErrWorkContextRevoked)
+	require.ErrorContains(t, err, `ancestor session "session-root"`)
+
+	// The same IDs in another tenant are another task and delegation.
+	require.NoError(t, walking.Replace(WorkContextRevocations{
+		Sessions:    []WorkContextRevokedSession{{TenantID: "tenant-other", TaskID: "task-roadmap", SessionID: "session-root"}},
+		Tasks:       []WorkContextRevokedTask{{TenantID: "tenant-other", TaskID: "task-roadmap"}},
+		Delegations: []WorkContextRevokedDelegation{{TenantID: "tenant-other", DelegationID: "delegation-tool-1"}},
+	}))
+	require.NoError(t, walking.CheckWorkContextRevocation(grandchild))
+}
+
+func TestWorkContextJWKSVerifierConsultsRevocations(t *testing.T) {
+	publicKey, privateKey := workContextJWKSKey(1)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeWorkContextJWKSFile(t, path, map[string]ed25519.PublicKey{"key-1": publicKey})
+	revocations, err := NewWorkContextRevocationList(WorkContextRevocationListOptions{
+		Revocations: WorkContextRevocations{Tasks: revokedWorkContextTasks("task-roadmap")},
+	})
+	require.NoError(t, err)
+	verifier, err := NewWorkContextJWKSVerifier(WorkContextJWKSVerifierOptions{
+		File:        path,
+		Now:         func() time.Time { return workContextTestTime },
+		Revocations: revocations,
+	})
+	require.NoError(t, err)
+	_, err = verifier.Verify(t.Context(), workContextJWKSToken(t, "key-1", privateKey), WorkContextExpectations{})
+	require.ErrorIs(t, err, ErrWorkContextRevoked)
+
+	_, err = verifier.Verify(t.Context(), workContextJWKSToken(t, "key-1", workContextJWKSPrivateForSeed(2)), WorkContextExpectations{})
+	require.ErrorIs(t, err, ErrWorkContextInvalid, "a forged token never reaches the revocation list")
+	require.NotErrorIs(t, err, ErrWorkContextRevoked)
+}
+
+func TestWorkContextRevocationFeedReloadsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "revocations.json")
+	feed, err := NewWorkContextRevocationFeed(WorkContextRevocationFeedOptions{
+		File: path, PollInterval: 100 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, feed.Close()) })
+	_, rootClaims, err := workContextTestSigner(t, workContextTestTime).StartTask(workContextTestInput())
+	require.NoError(t, err)
+
+	require.ErrorIs(t, feed.CheckWorkContextRevocation(rootClaims), ErrWorkContextRevocationUnavailable)
+	require.ErrorIs(t, feed.Prefetch(nil), ErrWorkContextInvalid)
+	writeWorkContextRevocationFile(t, path, WorkContextRevocations{Tasks: revokedWorkContextTasks("task-other")})
+	require.NoError(t, feed.Prefetch(t.Context()))
+	require.NoError(t, feed.CheckWorkContextRevocation(rootClaims))
+
+	writeWorkContextRevocationFile(t, path, WorkContextRevocations{Sessions: revokedWorkContextSessions("session-root")})
+	require.Eventually(t, func() bool {
+		return feed.CheckWorkContextRevocation(rootClaims) != nil
+	}, 5*time.Second, 20*time.Millisecond)
+	require.ErrorIs(t, feed.CheckWorkContextRevocation(rootClaims), ErrWorkContextRevoked)
+
+	require.NoError(t, os.Chmod(path, 0o622))
+	unsafe, err := NewWorkContextRevocationFeed(WorkContextRevocationFeedOptions{File: path})
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, unsafe.Close()) })
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	err = unsafe.Prefetch(ctx)
+	require.ErrorIs(t, err, ErrWorkContextRevocationUnavailable)
+	require.ErrorContains(t, err, "must not be writable by group or world")
+}
+
+func TestWorkContextRevocationFeedPollsURLAndFailsClosedWhenStale(t *testing.T) {
+	var requests, notModified atomic.Int32
+	var failing atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		requests.Add(1)
+		if failing.Load() {
+			writer.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if request.Header.Get("If-None-Match") == `"v1"` {
+			notModified.Add(1)
+			writer.WriteHeader(http.StatusNotModified)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/json")
+		writer.Header().Set("ETag", `"v1"`)
+		_, _ = writer.Write([]byte(`{"nonces":["nonce-fixed-for-golden"]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	var elapsed atomic.Int64
+	feed, err := NewWorkContextRevocationFeed(WorkContextRevocationFeedOptions{
+		URL:          server.URL,
+		HTTPClient:   server.Client(),
+		PollInterval: 100 * time.Millisecond,
+		MaxStaleness: time.Minute,
+		Now:          func() time.Time { return workContextTestTime.Add(time.Duration(elapsed.Load())) },
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, feed.Close()) })
+	_, claims, err := workContextTestSigner(t, workContextTestTime).StartTask(workContextTestInput())
+	require.NoError(t, err)
+
+	require.NoError(t, feed.Prefetch(t.Context()))
+	require.ErrorIs(t, feed.CheckWorkContextRevocation(claims), ErrWorkContextRevoked)
+	require.Eventually(t, func() bool { return notModified.Load() > 0 }, 5*time.Second, 20*time.Millisecond)
+	require.ErrorIs(t, feed.CheckWorkContextRevocation(claims), ErrWorkContextRevoked, "304 keeps the list")
+
+	failing.Store(true)
+	failedAt := requests.Load()
+	require.Eventually(t, func() bool { return requests.Load() > failedAt }, 5*time.Second, 20*time.Millisecond)
+	require.ErrorIs(t, feed.CheckWorkContextRevocation(claims), ErrWorkContextRevoked, "a failed poll serves the last list")
+	elapsed.Store(int64(2 * time.Minute))
+	err = feed.CheckWorkContextRevocation(claims)
+	require.ErrorIs(t, err, ErrWorkContextRevocationUnavailable)
+	require.ErrorContains(t, err, "HTTP 503")
+}
+
+func TestNewWorkContextRevocationFeedValidatesOptions(t *testing.T) {
+	for _, options := range []WorkContextRevocationFeedOptions{
+		{},
+		{URL: "https://accounts.example.test/revocations", File: "/etc/revocations.json"},
+		{URL: "https://accounts.example.test/revocations?tenant=codefly"},
+		{File: "/etc/revocations.json", PollInterval: time.Millisecond},
+		{File: "/etc/revocations.json", PollInterval: time.Minute, MaxStaleness: time.Second},
+		{File: "/etc/revocations.json", MaxStaleness: maxWorkContextRevocationStaleness + time.Second},
+	} {
+		_, err := NewWorkContextRevocationFeed(options)
+		require.ErrorIs(t, err, ErrWorkContextInvalid, "%+v", options)
+	}
+	_, err := NewWorkContextRevocationList(WorkContextRevocationListOptions{Revocations: WorkContextRevocations{Nonces: []string{""}}})
+	require.ErrorIs(t, err, ErrWorkContextInvalid)
+}
+
+func TestParseWorkContextRevocationsRejectsTrailingData(t *testing.T) {
+	_, err := parseWorkContextRevocations([]byte(`{"nonces":["nonce-1"]}`))
+	require.NoError(t, err)
+	for _, payload := range []string{
+		`{"nonces":["nonce-1"]}{"nonces":[]}`,
+		`{"nonces":["nonce-1"]} trailing`,
+	} {
+		_, err := parseWorkContextRevocations([]byte(payload))
+		require.ErrorIs(t, err, ErrWorkContextInvalid, payload)
+	}
+}
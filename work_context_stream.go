@@ -0,0 +1,359 @@
+package codefly
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	basev0 "github.com/codefly-dev/core/generated/go/codefly/base/v0"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// WorkContextExpiresAtHeaderName is response header metadata announcing,
+	// in Unix seconds, when the stream's Work Context expires, so the client
+	// can refresh it in time.
+	WorkContextExpiresAtHeaderName = "x-codefly-work-context-expires-at"
+	// WorkContextReasonTrailerName is trailer metadata carrying the
+	// WorkContextErrorReason of a stream closed because its authority
+	// lapsed.
+	WorkContextReasonTrailerName = "x-codefly-work-context-reason"
+
+	defaultWorkContextStreamRecheckInterval = time.Minute
+	defaultWorkContextStreamHandlerGrace    = time.Second
+)
+
+// WorkContextStreamRefresh is implemented by stream request messages that can
+// carry a renewed Work Context. A protobuf field
+//
+//	string work_context_refresh = N;
+//
+// generates it. A message with the field set refreshes the stream's authority
+// before it reaches the handler; the renewal must continue the same tenant,
+// owner, task, and session.
+type WorkContextStreamRefresh interface {
+	GetWorkContextRefresh() string
+}
+
+// WorkContextStreamGuardOptions configures NewWorkContextStreamGuard.
+type WorkContextStreamGuardOptions struct {
+	// Verify establishes trust in a token, typically a
+	// WorkContextJWKSVerifier's Verify with the expectations of the service.
+	// Configure the verifier's revocations for the periodic re-check to find
+	// revoked tokens.
+	Verify func(ctx context.Context, token WorkContextToken) (*basev0.WorkContextV1, error)
+	// RecheckInterval is how often the current token is verified again. It
+	// defaults to one minute; a negative interval disables the re-check.
+	RecheckInterval time.Duration
+	// HandlerGrace bounds how long a lapsed stream waits for its handler to
+	// return after cancelling its context. A handler blocked receiving from an
+	// idle client cannot be interrupted; it is released once the stream
+	// closes. It defaults to one second.
+	HandlerGrace time.Duration
+	Now          func() time.Time
+}
+
+// WorkContextStreamGuard keeps a stream's authority current for as long as
+// the stream lives: it verifies the Work Context when the stream starts,
+// closes the stream with Unauthenticated when the token expires or a
+// periodic re-check fails, and accepts refreshed tokens in band through
+// WorkContextStreamRefresh messages.
+type WorkContextStreamGuard struct {
+	verify          func(ctx context.Context, token WorkContextToken) (*basev0.WorkContextV1, error)
+	recheckInterval time.Duration
+	handlerGrace    time.Duration
+	now             func() time.Time
+}
+
+// NewWorkContextStreamGuard validates options and returns the guard.
+func NewWorkContextStreamGuard(options WorkContextStreamGuardOptions) (*WorkContextStreamGuard, error) {
+	if options.Verify == nil {
+		return nil, errors.New("Codefly Work Context stream guard requires a verify function")
+	}
+	recheckInterval := options.RecheckInterval
+	if recheckInterval == 0 {
+		recheckInterval = defaultWorkContextStreamRecheckInterval
+	}
+	if options.HandlerGrace < 0 {
+		return nil, errors.New("Codefly Work Context stream guard handler grace must not be negative")
+	}
+	handlerGrace := options.HandlerGrace
+	if handlerGrace == 0 {
+		handlerGrace = defaultWorkContextStreamHandlerGrace
+	}
+	now := options.Now
+	if now == nil {
+		now = time.Now
+	}
+	return &WorkContextStreamGuard{
+		verify:          options.Verify,
+		recheckInterval: recheckInterval,
+		handlerGrace:    handlerGrace,
+		now:             now,
+	}, nil
+}
+
+// StreamWorkContextClaims returns the current verified claims of a stream
+// guarded by WorkContextStreamGuard, including any in-band refresh. ctx is the
+// stream's context.
+func StreamWorkContextClaims(ctx context.Context) (*basev0.WorkContextV1, bool) {
+	guarded, ok := ctx.Value(guardedWorkContextStreamKey{}).(*guardedWorkContextStream)
+	if !ok {
+		return nil, false
+	}
+	guarded.mu.Lock()
+	defer guarded.mu.Unlock()
+	return guarded.claims, true
+}
+
+// StreamServerInterceptor guards every stream. The handler runs in its own
+// goroutine so that a lapse closes the stream even while the handler is
+// blocked receiving. On a lapse the handler's context is cancelled, its
+// stream calls fail, and the stream closes once the handler returns or
+// HandlerGrace passes; whatever the handler returns is discarded.
+func (g *WorkContextStreamGuard) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(server any, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		execution, err := GRPCExecutionContextFromIncoming(stream.Context())
+		if err != nil {
+			return status.Error(grpcWorkContextCode(err), err.Error())
+		}
+		claims, err := g.verify(stream.Context(), execution.workContext)
+		if err != nil {
+			return status.Error(grpcWorkContextCode(err), err.Error())
+		}
+		guarded := &guardedWorkContextStream{
+			ServerStream: stream,
+			guard:        g,
+			token:        execution.workContext,
+			claims:       claims,
+			lapsed:       make(chan struct{}),
+		}
+		ctx, cancel := context.WithCancel(context.WithValue(stream.Context(), guardedWorkContextStreamKey{}, guarded))
+		defer cancel()
+		guarded.ctx = ctx
+		_ = stream.SetHeader(metadata.Pairs(
+			WorkContextExpiresAtHeaderName, strconv.FormatInt(claims.GetExpiresAtUnix(), 10),
+		))
+		guarded.mu.Lock()
+		guarded.scheduleExpiryLocked()
+		guarded.mu.Unlock()
+		defer guarded.stopExpiry()
+		if g.recheckInterval > 0 {
+			go guarded.recheck(ctx)
+		}
+
+		done := make(chan error, 1)
+		go func() {
+			done <- runWorkContextStreamHandler(handler, server, guarded)
+		}()
+		select {
+		case err = <-done:
+		case <-guarded.lapsed:
+			cancel()
+			g.awaitHandler(stream.Context(), done)
+		}
+		if lapse := guarded.lapse(); lapse != nil {
+			stream.SetTrailer(metadata.Pairs(WorkContextReasonTrailerName, string(guarded.lapseReason())))
+			return lapse
+		}
+		return err
+	}
+}
+
+// awaitHandler waits for a cancelled handler to return, so that it does not
+// outlive the stream, for at most the handler grace.
+func (g *WorkContextStreamGuard) awaitHandler(ctx context.Context, done <-chan error) {
+	grace := time.NewTimer(g.handlerGrace)
+	defer grace.Stop()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	case <-grace.C:
+	}
+}
+
+// runWorkContextStreamHandler turns a panic into an error: the handler runs
+// in its own goroutine, where a panic would escape the server's recovery.
+func runWorkContextStreamHandler(handler grpc.StreamHandler, server any, stream grpc.ServerStream) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = status.Errorf(codes.Internal, "stream handler panic: %v", recovered)
+		}
+	}()
+	return handler(server, stream)
+}
+
+type guardedWorkContextStreamKey struct{}
+
+type guardedWorkContextStream struct {
+	grpc.ServerStream
+	guard *WorkContextStreamGuard
+	ctx   context.Context
+
+	mu    sync.Mutex
+	token WorkContextToken
+	// generation counts refreshes. Expiry timers and re-checks carry the
+	// generation of the token they judge, so a lapse of a replaced token,
+	// racing with its refresh, does not close the stream.
+	generation uint64
+	claims     *basev0.WorkContextV1
+	expiry     *time.Timer
+	reason     WorkContextErrorReason
+	err        error
+	lapsed     chan struct{}
+}
+
+func (s *guardedWorkContextStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *guardedWorkContextStream) SendMsg(message any) error {
+	if lapse := s.lapse(); lapse != nil {
+		return lapse
+	}
+	return s.ServerStream.SendMsg(message)
+}
+
+func (s *guardedWorkContextStream) RecvMsg(message any) error {
+	if lapse := s.lapse(); lapse != nil {
+		return lapse
+	}
+	if err := s.ServerStream.RecvMsg(message); err != nil {
+		if lapse := s.lapse(); lapse != nil {
+			return lapse
+		}
+		return err
+	}
+	refresh, ok := message.(WorkContextStreamRefresh)
+	if !ok || refresh.GetWorkContextRefresh() == "" {
+		return s.lapse()
+	}
+	if err := s.refresh(refresh.GetWorkContextRefresh()); err != nil {
+		s.end(err)
+		return s.lapse()
+	}
+	return nil
+}
+
+// refresh verifies a renewed token and makes it the stream's authority.
+func (s *guardedWorkContextStream) refresh(encoded string) error {
+	token, err := ParseWorkContextToken(encoded)
+	if err != nil {
+		return err
+	}
+	claims, err := s.guard.verify(s.ctx, token)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	if claims.GetTenantId() != s.claims.GetTenantId() ||
+		claims.GetOwnerPrincipalId() != s.claims.GetOwnerPrincipalId() ||
+		claims.GetTaskId() != s.claims.GetTaskId() ||
+		claims.GetSessionId() != s.claims.GetSessionId() {
+		return workContextFailure(
+			WorkContextReasonClaimsMismatch,
+			fmt.Errorf("%w: refreshed Work Context belongs to another session", ErrWorkContextInvalid),
+		)
+	}
+	s.token, s.claims = token, claims
+	s.generation++
+	// The previous timer may already be firing; its generation is stale.
+	s.expiry.Stop()
+	s.scheduleExpiryLocked()
+	return nil
+}
+
+func (s *guardedWorkContextStream) scheduleExpiryLocked() {
+	expiresAt := time.Unix(s.claims.GetExpiresAtUnix(), 0)
+	generation := s.generation
+	s.expiry = time.AfterFunc(expiresAt.Sub(s.guard.now()), func() {
+		s.endGeneration(generation, workContextFailure(
+			WorkContextReasonExpired,
+			fmt.Errorf("%w: stream Work Context expired", ErrWorkContextInvalid),
+		))
+	})
+}
+
+func (s *guardedWorkContextStream) stopExpiry() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expiry.Stop()
+}
+
+// recheck verifies the current token every interval until the stream ends,
+// so a revocation or key withdrawal closes it without waiting for expiry. A
+// retryable failure, such as unreachable keys or revocations, leaves the
+// stream open until the next re-check; expiry still bounds it.
+func (s *guardedWorkContextStream) recheck(ctx context.Context) {
+	ticker := time.NewTicker(s.guard.recheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		s.mu.Lock()
+		token, generation := s.token, s.generation
+		s.mu.Unlock()
+		_, err := s.guard.verify(ctx, token)
+		if err == nil || WorkContextErrorReasonOf(err).Retryable() {
+			continue
+		}
+		if ctx.Err() == nil && s.endGeneration(generation, err) {
+			return
+		}
+	}
+}
+
+// end records the first lapse and closes the stream.
+func (s *guardedWorkContextStream) end(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.endLocked(err)
+}
+
+// endGeneration ends the stream for a lapse of the token of generation, and
+// reports whether it did; a lapse of a token already refreshed is ignored.
+func (s *guardedWorkContextStream) endGeneration(generation uint64, err error) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if generation != s.generation {
+		return false
+	}
+	s.endLocked(err)
+	return true
+}
+
+func (s *guardedWorkContextStream) endLocked(err error) {
+	if s.err != nil {
+		return
+	}
+	s.reason = WorkContextErrorReasonOf(err)
+	s.err = status.Error(codes.Unauthenticated, err.Error())
+	close(s.lapsed)
+}
+
+func (s *guardedWorkContextStream) lapseReason() WorkContextErrorReason {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reason
+}
+
+// lapse returns the status the stream closed with, or nil while its
+// authority holds.
+func (s *guardedWorkContextStream) lapse() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
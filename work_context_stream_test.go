@@ -0,0 +1,358 @@
+package codefly
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	basev0 "github.com/codefly-dev/core/generated/go/codefly/base/v0"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// streamTestMessage stands in for a generated request message with a
+// work_context_refresh field.
+type streamTestMessage struct {
+	Text               string
+	WorkContextRefresh string
+}
+
+func (m *streamTestMessage) GetWorkContextRefresh() string {
+	return m.WorkContextRefresh
+}
+
+// streamTestServerStream delivers queued messages and then blocks, like an
+// idle client, until the stream is finished.
+type streamTestServerStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	messages chan *streamTestMessage
+	finished chan struct{}
+
+	mu      sync.Mutex
+	header  metadata.MD
+	trailer metadata.MD
+	sent    []any
+}
+
+func newStreamTestServerStream(t *testing.T, execution ExecutionContext) *streamTestServerStream {
+	t.Helper()
+	return &streamTestServerStream{
+		ctx: metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+			workContextGRPCMetadataName, execution.WorkContext().Encoded(),
+			operationIDGRPCMetadataName, execution.OperationID(),
+		)),
+		messages: make(chan *streamTestMessage, 8),
+		finished: make(chan struct{}),
+	}
+}
+
+func (s *streamTestServerStream) Context() context.Context { return s.ctx }
+
+func (s *streamTestServerStream) SetHeader(values metadata.MD) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.header = metadata.Join(s.header, values)
+	return nil
+}
+
+func (s *streamTestServerStream) SetTrailer(values metadata.MD) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.trailer = metadata.Join(s.trailer, values)
+}
+
+func (s *streamTestServerStream) SendMsg(message any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, message)
+	return nil
+}
+
+func (s *streamTestServerStream) RecvMsg(message any) error {
+	select {
+	case next, ok := <-s.messages:
+		if !ok {
+			return io.EOF
+		}
+		*message.(*streamTestMessage) = *next
+		return nil
+	case <-s.finished:
+		return status.Error(codes.Canceled, "stream finished")
+	}
+}
+
+// serve runs interceptor the way gRPC does and then finishes the stream,
+// releasing a handler still blocked in RecvMsg.
+func (s *streamTestServerStream) serve(interceptor grpc.StreamServerInterceptor, handler grpc.StreamHandler) error {
+	err := interceptor(nil, s, &grpc.StreamServerInfo{FullMethod: "/warden.v1.Tasks/Follow"}, handler)
+	close(s.finished)
+	return err
+}
+
+// streamTestHandler echoes messages and the session of the current claims
+// until the client stops sending.
+func streamTestHandler(received chan<- string) grpc.StreamHandler {
+	return func(_ any, stream grpc.ServerStream) error {
+		for {
+			var message streamTestMessage
+			if err := stream.RecvMsg(&message); err != nil {
+				if errors.Is(err, io.EOF) {
+					return nil
+				}
+				return err
+			}
+			claims, ok := StreamWorkContextClaims(stream.Context())
+			if !ok {
+				return errors.New("no stream claims")
+			}
+			received <- message.Text + "@" + time.Unix(claims.GetExpiresAtUnix(), 0).UTC().Format(time.TimeOnly)
+		}
+	}
+}
+
+type streamTestAuthority struct {
+	t           *testing.T
+	start       time.Time
+	signer      *WorkContextSigner
+	root        WorkContextToken
+	revoked     atomic.Bool
+	unavailable atomic.Bool
+	verified    atomic.Int64
+}
+
+func newStreamTestAuthority(t *testing.T) *streamTestAuthority {
+	t.Helper()
+	signer := workContextTestSigner(t, workContextTestTime)
+	root, _, err := signer.StartTask(workContextTestInput())
+	require.NoError(t, err)
+	return &streamTestAuthority{t: t, start: time.Now(), signer: signer, root: root}
+}
+
+// now runs the clock from just before the root token's expiry, so the stream
+// guard schedules expiry within milliseconds.
+func (a *streamTestAuthority) now() time.Time {
+	return workContextTestTime.Add(WorkContextDefaultTTL - 150*time.Millisecond).Add(time.Since(a.start))
+}
+
+func (a *streamTestAuthority) verify(_ context.Context, token WorkContextToken) (*basev0.WorkContextV1, error) {
+	a.verified.Add(1)
+	if a.unavailable.Load() {
+		return nil, workContextFailure(
+			WorkContextReasonRevocationUnavailable,
+			fmt.Errorf("%w: revocation feed unreachable", ErrWorkContextInvalid),
+		)
+	}
+	if a.revoked.Load() {
+		return nil, fmt.Errorf("%w: task revoked", ErrWorkContextRevoked)
+	}
+	return workContextTestVerifier(a.t, workContextTestTime).Verify(token, WorkContextExpectations{Audience: "warden.evidence"})
+}
+
+func (a *streamTestAuthority) guard(recheckInterval time.Duration) grpc.StreamServerInterceptor {
+	guard, err := NewWorkContextStreamGuard(WorkContextStreamGuardOptions{
+		Verify:          a.verify,
+		RecheckInterval: recheckInterval,
+		HandlerGrace:    50 * time.Millisecond,
+		Now:             a.now,
+	})
+	require.NoError(a.t, err)
+	return guard.StreamServerInterceptor()
+}
+
+// renewed mints a longer-lived token for the same session.
+func (a *streamTestAuthority) renewed() (WorkContextToken, error) {
+	input := workContextTestInput()
+	input.TTL = WorkContextMaxTTL
+	token, _, err := a.signer.StartTask(input)
+	return token, err
+}
+
+func (a *streamTestAuthority) execution() ExecutionContext {
+	execution, err := NewExecutionContext(a.root, "operation-stream")
+	require.NoError(a.t, err)
+	return execution
+}
+
+func TestWorkContextStreamGuardClosesIdleStreamAtExpiry(t *testing.T) {
+	authority := newStreamTestAuthority(t)
+	stream := newStreamTestServerStream(t, authority.execution())
+	received := make(chan string, 8)
+	stream.messages <- &streamTestMessage{Text: "hello"}
+
+	err := stream.serve(authority.guard(-1), streamTestHandler(received))
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+	require.Contains(t, err.Error(), "stream Work Context expired")
+	require.Equal(t, "hello@12:39:56", <-received)
+	require.Equal(t, []string{"1784810396"}, stream.header.Get(WorkContextExpiresAtHeaderName))
+	require.Equal(t, []string{"expired"}, stream.trailer.Get(WorkContextReasonTrailerName))
+}
+
+func TestWorkContextStreamGuardWaitsForCancelledHandler(t *testing.T) {
+	authority := newStreamTestAuthority(t)
+	stream := newStreamTestServerStream(t, authority.execution())
+	var cleanedUp atomic.Bool
+	err := stream.serve(authority.guard(-1), func(_ any, stream grpc.ServerStream) error {
+		<-stream.Context().Done()
+		time.Sleep(20 * time.Millisecond)
+		cleanedUp.Store(true)
+		return nil
+	})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+	require.True(t, cleanedUp.Load(), "the handler returns before the stream closes")
+}
+
+func TestWorkContextStreamGuardAcceptsInBandRefresh(t *testing.T) {
+	authority := newStreamTestAuthority(t)
+	refreshed, err := authority.renewed()
+	require.NoError(t, err)
+	stream := newStreamTestServerStream(t, authority.execution())
+	received := make(chan string, 8)
+	stream.messages <- &streamTestMessage{Text: "before"}
+	stream.messages <- &streamTestMessage{Text: "refresh", WorkContextRefresh: refreshed.Encoded()}
+
+	done := make(chan error, 1)
+	go func() { done <- stream.serve(authority.guard(-1), streamTestHandler(received)) }()
+	require.Equal(t, "before@12:39:56", <-received)
+	require.Equal(t, "refresh@12:49:56", <-received)
+	// The stream outlives the first token's expiry.
+	time.Sleep(300 * time.Millisecond)
+	stream.messages <- &streamTestMessage{Text: "after"}
+	require.Equal(t, "after@12:49:56", <-received)
+	close(stream.messages)
+	require.NoError(t, <-done)
+	require.Empty(t, stream.trailer.Get(WorkContextReasonTrailerName))
+}
+
+func TestWorkContextStreamGuardRejectsRefreshForAnotherSession(t *testing.T) {
+	authority := newStreamTestAuthority(t)
+	other, _, err := authority.signer.StartSession(authority.root, StartRootSessionInput{SessionID: "session-other"})
+	require.NoError(t, err)
+	stream := newStreamTestServerStream(t, authority.execution())
+	stream.messages <- &streamTestMessage{Text: "refresh", WorkContextRefresh: other.Encoded()}
+
+	err = stream.serve(authority.guard(-1), streamTestHandler(make(chan string, 8)))
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+	require.Contains(t, err.Error(), "belongs to another session")
+	require.Equal(t, []string{"claims_mismatch"}, stream.trailer.Get(WorkContextReasonTrailerName))
+}
+
+func TestWorkContextStreamGuardClosesRevokedStream(t *testing.T) {
+	authority := newStreamTestAuthority(t)
+	refreshed, err := authority.renewed()
+	require.NoError(t, err)
+	execution, err := NewExecutionContext(refreshed, "operation-stream")
+	require.NoError(t, err)
+	stream := newStreamTestServerStream(t, execution)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- stream.serve(authority.guard(10*time.Millisecond), streamTestHandler(make(chan string, 8)))
+	}()
+	time.Sleep(30 * time.Millisecond)
+	select {
+	case err := <-done:
+		t.Fatalf("stream closed before revocation: %v", err)
+	default:
+	}
+	authority.revoked.Store(true)
+	err = <-done
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+	require.Equal(t, []string{"revoked"}, stream.trailer.Get(WorkContextReasonTrailerName))
+}
+
+func TestWorkContextStreamGuardKeepsStreamOpenWhileRecheckIsRetryable(t *testing.T) {
+	authority := newStreamTestAuthority(t)
+	refreshed, err := authority.renewed()
+	require.NoError(t, err)
+	execution, err := NewExecutionContext(refreshed, "operation-stream")
+	require.NoError(t, err)
+	stream := newStreamTestServerStream(t, execution)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- stream.serve(authority.guard(10*time.Millisecond), streamTestHandler(make(chan string, 8)))
+	}()
+	require.Eventually(t, func() bool { return authority.verified.Load() > 0 }, 5*time.Second, time.Millisecond)
+	authority.unavailable.Store(true)
+	require.Eventually(t, func() bool { return authority.verified.Load() > 3 }, 5*time.Second, time.Millisecond)
+	select {
+	case err := <-done:
+		t.Fatalf("stream closed on a retryable re-check failure: %v", err)
+	default:
+	}
+	authority.unavailable.Store(false)
+	authority.revoked.Store(true)
+	err = <-done
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+	require.Equal(t, []string{"revoked"}, stream.trailer.Get(WorkContextReasonTrailerName))
+}
+
+func TestWorkContextStreamGuardIgnoresLapsesOfRefreshedTokens(t *testing.T) {
+	authority := newStreamTestAuthority(t)
+	refreshed, err := authority.renewed()
+	require.NoError(t, err)
+	guard, err := NewWorkContextStreamGuard(WorkContextStreamGuardOptions{Verify: authority.verify, Now: authority.now})
+	require.NoError(t, err)
+	claims, err := authority.verify(context.Background(), authority.root)
+	require.NoError(t, err)
+	guarded := &guardedWorkContextStream{
+		guard:  guard,
+		ctx:    context.Background(),
+		token:  authority.root,
+		claims: claims,
+		lapsed: make(chan struct{}),
+	}
+	guarded.mu.Lock()
+	guarded.scheduleExpiryLocked()
+	guarded.mu.Unlock()
+	defer guarded.stopExpiry()
+
+	require.NoError(t, guarded.refresh(refreshed.Encoded()))
+	// The expiry timer or a re-check of the replaced token fires late.
+	lapse := workContextFailure(WorkContextReasonExpired, fmt.Errorf("%w: stream Work Context expired", ErrWorkContextInvalid))
+	require.False(t, guarded.endGeneration(0, lapse))
+	require.NoError(t, guarded.lapse())
+	require.True(t, guarded.endGeneration(1, lapse))
+	require.Equal(t, codes.Unauthenticated, status.Code(guarded.lapse()))
+}
+
+func TestWorkContextStreamGuardRejectsStreamsWithoutAuthority(t *testing.T) {
+	authority := newStreamTestAuthority(t)
+	called := false
+	handler := func(any, grpc.ServerStream) error {
+		called = true
+		return nil
+	}
+	stream := newStreamTestServerStream(t, authority.execution())
+	stream.ctx = context.Background()
+	require.Equal(t, codes.Unauthenticated, status.Code(stream.serve(authority.guard(-1), handler)))
+
+	authority.revoked.Store(true)
+	stream = newStreamTestServerStream(t, authority.execution())
+	require.Equal(t, codes.Unauthenticated, status.Code(stream.serve(authority.guard(-1), handler)))
+	require.False(t, called)
+
+	_, err := NewWorkContextStreamGuard(WorkContextStreamGuardOptions{})
+	require.ErrorContains(t, err, "requires a verify function")
+	_, err = NewWorkContextStreamGuard(WorkContextStreamGuardOptions{Verify: authority.verify, HandlerGrace: -time.Second})
+	require.ErrorContains(t, err, "must not be negative")
+}
+
+func TestWorkContextStreamGuardTurnsHandlerPanicsIntoErrors(t *testing.T) {
+	authority := newStreamTestAuthority(t)
+	stream := newStreamTestServerStream(t, authority.execution())
+	err := stream.serve(authority.guard(-1), func(any, grpc.ServerStream) error {
+		panic("handler bug")
+	})
+	require.Equal(t, codes.Internal, status.Code(err))
+	require.Contains(t, err.Error(), "handler bug")
+}
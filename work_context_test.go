@@ -9,6 +9,7 @@ import (
 	"time"
 
 	basev0 "github.com/codefly-dev/core/generated/go/codefly/base/v0"
+	"github.com/codefly-dev/core/resources"
 	"github.com/stretchr/testify/require"
 )
 
@@ -213,6 +214,67 @@ func TestWorkContextRejectsForgeryAndClaimSubstitution(t *testing.T) {
 	}
 }
 
+func TestWorkContextExpectationsConstrainAudienceWorkspaceProjectAndTeam(t *testing.T) {
+	token, _, err := workContextTestSigner(t, workContextTestTime).StartTask(workContextTestInput())
+	require.NoError(t, err)
+	verifier := workContextTestVerifier(t, workContextTestTime)
+
+	_, err = verifier.Verify(token, WorkContextExpectations{
+		Audiences:         []string{"warden.tools", "warden.evidence"},
+		WorkspaceID:       "workspace-deus",
+		ProjectIDs:        []string{"project-codefly", "project-warden"},
+		AttributionTeamID: "team-ai",
+	})
+	require.NoError(t, err)
+
+	for name, expected := range map[string]WorkContextExpectations{
+		"audience":         {Audiences: []string{"warden.tools", "other-service"}},
+		"workspace":        {WorkspaceID: "workspace-other"},
+		"project":          {ProjectIDs: []string{"project-codefly"}},
+		"attribution team": {AttributionTeamID: "team-security"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, verifyErr := verifier.Verify(token, expected)
+			require.ErrorIs(t, verifyErr, ErrWorkContextInvalid)
+			require.Contains(t, verifyErr.Error(), name+" mismatch")
+		})
+	}
+
+	unscopedInput := workContextTestInput()
+	unscopedInput.WorkspaceID = ""
+	unscopedInput.ProjectID = ""
+	unscoped, _, err := workContextTestSigner(t, workContextTestTime).StartTask(unscopedInput)
+	require.NoError(t, err)
+	_, err = verifier.Verify(unscoped, WorkContextExpectations{WorkspaceID: "workspace-deus"})
+	require.ErrorIs(t, err, ErrWorkContextInvalid, "a token without a workspace never satisfies a workspace constraint")
+	_, err = verifier.Verify(unscoped, WorkContextExpectations{ProjectIDs: []string{""}})
+	require.ErrorIs(t, err, ErrWorkContextInvalid)
+}
+
+func TestWorkContextVerifierPinsRuntimeWorkspace(t *testing.T) {
+	token, _, err := workContextTestSigner(t, workContextTestTime).StartTask(workContextTestInput())
+	require.NoError(t, err)
+	publicKey, _ := workContextTestKeys()
+	newVerifier := func() *WorkContextVerifier {
+		verifier, verifierErr := NewWorkContextVerifier(WorkContextVerifierOptions{
+			PublicKeys:             map[string]ed25519.PublicKey{"work-context-test-2026-07": publicKey},
+			Now:                    func() time.Time { return workContextTestTime },
+			ExpectRuntimeWorkspace: true,
+		})
+		require.NoError(t, verifierErr)
+		return verifier
+	}
+
+	t.Setenv(resources.WorkspacePrefix, "workspace-deus")
+	_, err = newVerifier().Verify(token, WorkContextExpectations{})
+	require.NoError(t, err)
+
+	t.Setenv(resources.WorkspacePrefix, "workspace-other")
+	_, err = newVerifier().Verify(token, WorkContextExpectations{})
+	require.ErrorIs(t, err, ErrWorkContextInvalid)
+	require.Contains(t, err.Error(), "runtime workspace mismatch")
+}
+
 func TestWorkContextRejectsExpiredFutureAndExcessiveLifetime(t *testing.T) {
 	token, _, err := workContextTestSigner(t, workContextTestTime).StartTask(workContextTestInput())
 	require.NoError(t, err)
@@ -0,0 +1,486 @@
+// Package workcontextvectors publishes a portable conformance corpus for
+// Codefly Work Context tokens. Every vector pairs a token, a fixed clock, and
+// verifier expectations with the outcome the Go verifier produces, so SDKs in
+// other languages can prove they accept and reject exactly the same tokens.
+//
+// The corpus is generated deterministically by Generate and checked in as
+// corpus_v1.json; regenerate it with go generate after an intentional wire
+// change and bump Version when the format itself changes.
+package workcontextvectors
+
+//go:generate go run ../cmd/workcontextvectors -o corpus_v1.json
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	basev0 "github.com/codefly-dev/core/generated/go/codefly/base/v0"
+	codefly "github.com/codefly-dev/sdk-go"
+)
+
+// Version identifies the corpus format. Readers must reject other versions.
+const Version = 1
+
+const (
+	issuer          = "https://accounts.codefly.dev/work-context"
+	trustedKeyID    = "vectors-key-1"
+	untrustedKeyID  = "vectors-key-untrusted"
+	corpusClockUnix = 1784810096 // 2026-07-23T12:34:56Z
+)
+
+//go:embed corpus_v1.json
+var embeddedCorpus []byte
+
+// Corpus is the versioned JSON document.
+type Corpus struct {
+	Version int `json:"version"`
+	// NowUnix is the verifier clock for every vector that does not set its
+	// own.
+	NowUnix          int64    `json:"now_unix"`
+	ClockSkewSeconds int64    `json:"clock_skew_seconds"`
+	Keys             []Key    `json:"keys"`
+	Vectors          []Vector `json:"vectors"`
+}
+
+// Key is a trusted Ed25519 verification key.
+type Key struct {
+	KeyID string `json:"kid"`
+	// PublicKey is the raw 32-byte key, unpadded base64url as in a JWKS.
+	PublicKey string `json:"x"`
+}
+
+// Vector is one conformance case.
+type Vector struct {
+	Name         string       `json:"name"`
+	Description  string       `json:"description"`
+	Token        string       `json:"token"`
+	NowUnix      int64        `json:"now_unix,omitempty"`
+	Expectations Expectations `json:"expectations"`
+	Expected     Outcome      `json:"expected"`
+}
+
+// Expectations mirrors codefly.WorkContextExpectations without the peer
+// certificate. AuthorizationRevision is a decimal string so every language
+// keeps the full uint64 range.
+type Expectations struct {
+	Issuer                string   `json:"issuer,omitempty"`
+	Audience              string   `json:"audience,omitempty"`
+	Audiences             []string `json:"audiences,omitempty"`
+	TenantID              string   `json:"tenant_id,omitempty"`
+	OwnerPrincipalID      string   `json:"owner_principal_id,omitempty"`
+	TaskID                string   `json:"task_id,omitempty"`
+	SessionID             string   `json:"session_id,omitempty"`
+	ParentSessionID       *string  `json:"parent_session_id,omitempty"`
+	AuthorizationRevision string   `json:"authorization_revision,omitempty"`
+	WorkspaceID           string   `json:"workspace_id,omitempty"`
+	ProjectIDs            []string `json:"project_ids,omitempty"`
+	AttributionTeamID     string   `json:"attribution_team_id,omitempty"`
+}
+
+// Outcome is the expected verification result. A rejected vector names the
+// stable codefly.WorkContextErrorReason; an accepted one carries the decoded
+// token payload so readers can also compare parsed claims.
+type Outcome struct {
+	Valid  bool            `json:"valid"`
+	Reason string          `json:"reason,omitempty"`
+	Claims json.RawMessage `json:"claims,omitempty"`
+}
+
+// WorkContextExpectations converts the vector expectations for the Go
+// verifier.
+func (e Expectations) WorkContextExpectations() (codefly.WorkContextExpectations, error) {
+	expected := codefly.WorkContextExpectations{
+		Issuer:            e.Issuer,
+		Audience:          e.Audience,
+		Audiences:         e.Audiences,
+		TenantID:          e.TenantID,
+		OwnerPrincipalID:  e.OwnerPrincipalID,
+		TaskID:            e.TaskID,
+		SessionID:         e.SessionID,
+		ParentSessionID:   e.ParentSessionID,
+		WorkspaceID:       e.WorkspaceID,
+		ProjectIDs:        e.ProjectIDs,
+		AttributionTeamID: e.AttributionTeamID,
+	}
+	if e.AuthorizationRevision != "" {
+		revision, err := strconv.ParseUint(e.AuthorizationRevision, 10, 64)
+		if err != nil {
+			return codefly.WorkContextExpectations{}, fmt.Errorf("authorization_revision: %w", err)
+		}
+		expected.AuthorizationRevision = &revision
+	}
+	return expected, nil
+}
+
+// Load returns the checked-in corpus.
+func Load() (*Corpus, error) {
+	var corpus Corpus
+	decoder := json.NewDecoder(bytes.NewReader(embeddedCorpus))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&corpus); err != nil {
+		return nil, fmt.Errorf("decode Work Context vectors: %w", err)
+	}
+	if corpus.Version != Version {
+		return nil, fmt.Errorf("Work Context vectors version %d, want %d", corpus.Version, Version)
+	}
+	return &corpus, nil
+}
+
+// Marshal encodes the corpus in its canonical checked-in form.
+func (c *Corpus) Marshal() ([]byte, error) {
+	encoded, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(encoded, '\n'), nil
+}
+
+// Verify runs every vector through codefly.WorkContextVerifier and reports
+// each vector whose outcome differs from the corpus.
+func Verify(corpus *Corpus) error {
+	if corpus == nil || corpus.Version != Version {
+		return fmt.Errorf("Work Context vectors version must be %d", Version)
+	}
+	keys := make(map[string]ed25519.PublicKey, len(corpus.Keys))
+	for _, key := range corpus.Keys {
+		decoded, err := base64.RawURLEncoding.DecodeString(key.PublicKey)
+		if err != nil {
+			return fmt.Errorf("key %q: %w", key.KeyID, err)
+		}
+		keys[key.KeyID] = decoded
+	}
+	var failures []error
+	for _, vector := range corpus.Vectors {
+		now := corpus.NowUnix
+		if vector.NowUnix != 0 {
+			now = vector.NowUnix
+		}
+		verifier, err := codefly.NewWorkContextVerifier(codefly.WorkContextVerifierOptions{
+			PublicKeys: keys,
+			Now:        func() time.Time { return time.Unix(now, 0).UTC() },
+			ClockSkew:  time.Duration(corpus.ClockSkewSeconds) * time.Second,
+		})
+		if err != nil {
+			return err
+		}
+		expected, err := vector.Expectations.WorkContextExpectations()
+		if err != nil {
+			failures = append(failures, fmt.Errorf("%s: %w", vector.Name, err))
+			continue
+		}
+		got := outcome(verifier, vector.Token, expected)
+		if got.Valid != vector.Expected.Valid || got.Reason != vector.Expected.Reason {
+			failures = append(failures, fmt.Errorf(
+				"%s: got valid=%t reason=%q, want valid=%t reason=%q",
+				vector.Name, got.Valid, got.Reason, vector.Expected.Valid, vector.Expected.Reason,
+			))
+		}
+	}
+	return errors.Join(failures...)
+}
+
+func outcome(verifier *codefly.WorkContextVerifier, encoded string, expected codefly.WorkContextExpectations) Outcome {
+	token, err := codefly.ParseWorkContextToken(encoded)
+	if err == nil {
+		_, err = verifier.Verify(token, expected)
+	}
+	if err != nil {
+		return Outcome{Reason: string(codefly.WorkContextErrorReasonOf(err))}
+	}
+	payload, _, _ := strings.Cut(encoded, ".")
+	claims, _ := base64.RawURLEncoding.DecodeString(payload)
+	return Outcome{Valid: true, Claims: claims}
+}
+
+// Generate builds the corpus from fixed keys, nonces, and clock. The result is
+// byte-for-byte reproducible.
+func Generate() (*Corpus, error) {
+	trusted := vectorKey(trustedKeyID)
+	untrusted := vectorKey(untrustedKeyID)
+	now := time.Unix(corpusClockUnix, 0).UTC()
+	skew := codefly.WorkContextClockSkew
+	nonces := 0
+	newSigner := func(keyID string, privateKey ed25519.PrivateKey) (*codefly.WorkContextSigner, error) {
+		return codefly.NewWorkContextSigner(codefly.WorkContextSignerOptions{
+			Issuer: issuer, KeyID: keyID, PrivateKey: privateKey,
+			Now: func() time.Time { return now },
+			Nonce: func() (string, error) {
+				nonces++
+				return fmt.Sprintf("vectors-nonce-%02d", nonces), nil
+			},
+		})
+	}
+	signer, err := newSigner(trustedKeyID, trusted)
+	if err != nil {
+		return nil, err
+	}
+	forger, err := newSigner(trustedKeyID, untrusted)
+	if err != nil {
+		return nil, err
+	}
+	stranger, err := newSigner(untrustedKeyID, untrusted)
+	if err != nil {
+		return nil, err
+	}
+
+	root, rootClaims, err := signer.StartTask(vectorTaskInput())
+	if err != nil {
+		return nil, err
+	}
+	child, _, err := signer.StartChildSession(root, codefly.StartChildSessionInput{
+		SessionID: "session-child",
+		Audience:  "warden.tools",
+		Actor: &basev0.WorkActorV1{
+			PrincipalId: "tool-indexer", PrincipalKind: "tool", DelegationId: "delegation-2",
+			GrantedScopes: []*basev0.WorkScopeV1{
+				{ResourceKind: "repository", Actions: []string{"read"}, ResourceIds: []string{"repo-warden"}},
+			},
+		},
+		TTL: 2 * time.Minute,
+	})
+	if err != nil {
+		return nil, err
+	}
+	forged, _, err := forger.StartTask(vectorTaskInput())
+	if err != nil {
+		return nil, err
+	}
+	unknown, _, err := stranger.StartTask(vectorTaskInput())
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := time.Unix(rootClaims.GetExpiresAtUnix(), 0)
+	notBefore := time.Unix(rootClaims.GetNotBeforeUnix(), 0)
+
+	rootPayload, rootSignature, _ := strings.Cut(root.Encoded(), ".")
+	mutate := func(mutation func(map[string]any)) (string, error) {
+		return mutatePayload(root.Encoded(), mutation)
+	}
+	resigned := func(mutation func(map[string]any)) (string, error) {
+		payload, err := mutate(mutation)
+		if err != nil {
+			return "", err
+		}
+		segment, _, _ := strings.Cut(payload, ".")
+		decoded, err := base64.RawURLEncoding.DecodeString(segment)
+		if err != nil {
+			return "", err
+		}
+		return signPayload(decoded, trusted), nil
+	}
+	substitutedTenant, err := mutate(func(claims map[string]any) { claims["tenant_id"] = "tenant-attacker" })
+	if err != nil {
+		return nil, err
+	}
+	substitutedAudience, err := mutate(func(claims map[string]any) { claims["audience"] = "warden.admin" })
+	if err != nil {
+		return nil, err
+	}
+	widenedActor, err := resigned(func(claims map[string]any) {
+		// Verify below rejects the corpus if this ever stops widening.
+		if actors, ok := claims["actor_chain"].([]any); ok && len(actors) > 0 {
+			if actor, ok := actors[0].(map[string]any); ok {
+				actor["granted_scopes"] = []any{map[string]any{
+					"resource_kind": "repository", "actions": []any{"admin"}, "resource_ids": []any{"repo-warden"},
+				}}
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	widenedLifetime, err := resigned(func(claims map[string]any) {
+		claims["expires_at_unix"] = json.Number(strconv.FormatInt(now.Add(time.Hour).Unix(), 10))
+	})
+	if err != nil {
+		return nil, err
+	}
+	unknownField, err := resigned(func(claims map[string]any) { claims["admin"] = true })
+	if err != nil {
+		return nil, err
+	}
+	numericRevision, err := resigned(func(claims map[string]any) { claims["authorization_revision"] = json.Number("7") })
+	if err != nil {
+		return nil, err
+	}
+	wrongType, err := resigned(func(claims map[string]any) { claims["typ"] = "JWT" })
+	if err != nil {
+		return nil, err
+	}
+	parentSession := "session-root"
+	otherParent := "session-other"
+
+	valid := func(name, description, token string, nowOverride time.Time, expectations Expectations) Vector {
+		vector := Vector{
+			Name: name, Description: description, Token: token, Expectations: expectations,
+			Expected: Outcome{Valid: true},
+		}
+		if !nowOverride.IsZero() {
+			vector.NowUnix = nowOverride.Unix()
+		}
+		return vector
+	}
+	rejected := func(name, description, token string, nowOverride time.Time, expectations Expectations, reason codefly.WorkContextErrorReason) Vector {
+		vector := valid(name, description, token, nowOverride, expectations)
+		vector.Expected = Outcome{Reason: string(reason)}
+		return vector
+	}
+	vectors := []Vector{
+		valid("valid/root-task", "A root task token with an actor and the maximum authorization revision.",
+			root.Encoded(), time.Time{}, Expectations{
+				Issuer: issuer, Audience: "warden.evidence", TenantID: "tenant-codefly", TaskID: "task-roadmap",
+				AuthorizationRevision: strconv.FormatUint(^uint64(0), 10),
+			}),
+		valid("valid/child-session", "A child session that appends an attenuated tool actor.",
+			child.Encoded(), time.Time{}, Expectations{
+				Issuer: issuer, Audiences: []string{"warden.evidence", "warden.tools"}, ParentSessionID: &parentSession,
+			}),
+		valid("valid/expiry-within-clock-skew", "Expiry is inclusive of the verifier clock skew.",
+			root.Encoded(), expiresAt.Add(skew), Expectations{}),
+		valid("valid/not-before-within-clock-skew", "Not-before is inclusive of the verifier clock skew.",
+			root.Encoded(), notBefore.Add(-skew), Expectations{}),
+
+		rejected("forgery/wrong-key", "Signed by an untrusted key under a trusted key ID.",
+			forged.Encoded(), time.Time{}, Expectations{}, codefly.WorkContextReasonBadSignature),
+		rejected("forgery/unknown-key", "Signed under a key ID the verifier does not trust.",
+			unknown.Encoded(), time.Time{}, Expectations{}, codefly.WorkContextReasonUnknownKey),
+		rejected("forgery/zero-signature", "A valid payload with an all-zero signature.",
+			rootPayload+"."+base64.RawURLEncoding.EncodeToString(make([]byte, ed25519.SignatureSize)),
+			time.Time{}, Expectations{}, codefly.WorkContextReasonBadSignature),
+		rejected("substitution/tenant", "tenant_id replaced after signing.",
+			substitutedTenant, time.Time{}, Expectations{}, codefly.WorkContextReasonBadSignature),
+		rejected("substitution/audience", "audience replaced after signing.",
+			substitutedAudience, time.Time{}, Expectations{}, codefly.WorkContextReasonBadSignature),
+		rejected("widening/actor-scope", "A signed actor grants an action its owner never had.",
+			widenedActor, time.Time{}, Expectations{}, codefly.WorkContextReasonMalformed),
+		rejected("widening/lifetime", "A signed lifetime longer than the fifteen-minute maximum.",
+			widenedLifetime, time.Time{}, Expectations{}, codefly.WorkContextReasonMalformed),
+		rejected("time/expired", "One second past expiry plus clock skew.",
+			root.Encoded(), expiresAt.Add(skew+time.Second), Expectations{}, codefly.WorkContextReasonExpired),
+		rejected("time/not-yet-valid", "One second before not-before minus clock skew.",
+			root.Encoded(), notBefore.Add(-skew-time.Second), Expectations{}, codefly.WorkContextReasonNotYetValid),
+		rejected("expectation/audience", "The verifier serves another audience.",
+			root.Encoded(), time.Time{}, Expectations{Audience: "warden.tools"}, codefly.WorkContextReasonAudienceMismatch),
+		rejected("expectation/tenant", "The verifier requires another tenant.",
+			root.Encoded(), time.Time{}, Expectations{TenantID: "tenant-other"}, codefly.WorkContextReasonClaimsMismatch),
+		rejected("expectation/parent-session", "The verifier requires another parent session.",
+			child.Encoded(), time.Time{}, Expectations{ParentSessionID: &otherParent}, codefly.WorkContextReasonClaimsMismatch),
+		rejected("expectation/authorization-revision", "The verifier requires another authorization revision.",
+			root.Encoded(), time.Time{}, Expectations{AuthorizationRevision: "7"}, codefly.WorkContextReasonClaimsMismatch),
+		rejected("shape/empty", "No token at all.",
+			"", time.Time{}, Expectations{}, codefly.WorkContextReasonMissing),
+		rejected("shape/one-segment", "A payload without a signature segment.",
+			rootPayload, time.Time{}, Expectations{}, codefly.WorkContextReasonMalformed),
+		rejected("shape/three-segments", "A JWS-style three-segment value.",
+			"e30."+rootPayload+"."+rootSignature, time.Time{}, Expectations{}, codefly.WorkContextReasonMalformed),
+		rejected("shape/padded-base64", "Padded base64url is not canonical.",
+			rootPayload+"."+rootSignature+"==", time.Time{}, Expectations{}, codefly.WorkContextReasonMalformed),
+		rejected("shape/short-signature", "A signature shorter than 64 bytes.",
+			rootPayload+"."+base64.RawURLEncoding.EncodeToString(make([]byte, ed25519.SignatureSize-1)),
+			time.Time{}, Expectations{}, codefly.WorkContextReasonMalformed),
+		rejected("shape/payload-not-json", "A correctly signed payload that is not JSON.",
+			signPayload([]byte("not json"), trusted), time.Time{}, Expectations{}, codefly.WorkContextReasonMalformed),
+		rejected("shape/unknown-field", "A correctly signed payload with an unknown member.",
+			unknownField, time.Time{}, Expectations{}, codefly.WorkContextReasonMalformed),
+		rejected("shape/numeric-revision", "authorization_revision must be a decimal string.",
+			numericRevision, time.Time{}, Expectations{}, codefly.WorkContextReasonMalformed),
+		rejected("shape/wrong-typ", "A correctly signed payload with another typ.",
+			wrongType, time.Time{}, Expectations{}, codefly.WorkContextReasonMalformed),
+	}
+
+	publicKey, _ := trusted.Public().(ed25519.PublicKey)
+	corpus := &Corpus{
+		Version:          Version,
+		NowUnix:          now.Unix(),
+		ClockSkewSeconds: int64(skew / time.Second),
+		Keys: []Key{{
+			KeyID:     trustedKeyID,
+			PublicKey: base64.RawURLEncoding.EncodeToString(publicKey),
+		}},
+		Vectors: vectors,
+	}
+	// The declared outcomes must match what the Go verifier does, so a
+	// behaviour change cannot slip into the corpus unnoticed.
+	if err := Verify(corpus); err != nil {
+		return nil, err
+	}
+	for index := range vectors {
+		if vectors[index].Expected.Valid {
+			payload, _, _ := strings.Cut(vectors[index].Token, ".")
+			claims, err := base64.RawURLEncoding.DecodeString(payload)
+			if err != nil {
+				return nil, err
+			}
+			vectors[index].Expected.Claims = claims
+		}
+	}
+	return corpus, nil
+}
+
+func vectorTaskInput() codefly.StartTaskInput {
+	return codefly.StartTaskInput{
+		Audience:              "warden.evidence",
+		TenantID:              "tenant-codefly",
+		OwnerPrincipalID:      "principal-owner",
+		TaskID:                "task-roadmap",
+		SessionID:             "session-root",
+		AuthorizationRevision: ^uint64(0),
+		ReplayPolicy:          codefly.WorkContextReplayIdempotent,
+		AuthorityScopes: []*basev0.WorkScopeV1{
+			{ResourceKind: "evidence", Actions: []string{"append"}},
+			{ResourceKind: "repository", Actions: []string{"read", "write"}, ResourceIds: []string{"repo-codefly", "repo-warden"}},
+		},
+		ActorChain: []*basev0.WorkActorV1{{
+			PrincipalId: "agent-planner", PrincipalKind: "agent", DelegationId: "delegation-1",
+			GrantedScopes: []*basev0.WorkScopeV1{
+				{ResourceKind: "repository", Actions: []string{"read", "write"}, ResourceIds: []string{"repo-warden"}},
+			},
+		}},
+		AttributionTeamIDs: []string{"team-platform"},
+		WorkspaceID:        "workspace-deus",
+		ProjectID:          "project-warden",
+		TTL:                5 * time.Minute,
+	}
+}
+
+// vectorKey derives a fixed key from its ID. The private keys are public by
+// construction; they exist only to make the corpus reproducible.
+func vectorKey(keyID string) ed25519.PrivateKey {
+	seed := sha256.Sum256([]byte("codefly.work-context.vectors.v1\n" + keyID))
+	return ed25519.NewKeyFromSeed(seed[:])
+}
+
+func signPayload(payload []byte, privateKey ed25519.PrivateKey) string {
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(ed25519.Sign(privateKey, payload))
+}
+
+// mutatePayload rewrites the payload of a token and keeps its signature.
+func mutatePayload(encoded string, mutation func(map[string]any)) (string, error) {
+	payloadSegment, signature, _ := strings.Cut(encoded, ".")
+	payload, err := base64.RawURLEncoding.DecodeString(payloadSegment)
+	if err != nil {
+		return "", err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	var claims map[string]any
+	if err := decoder.Decode(&claims); err != nil {
+		return "", err
+	}
+	mutation(claims)
+	rewritten, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(rewritten) + "." + signature, nil
+}
@@ -0,0 +1,82 @@
+package workcontextvectors
+
+import (
+	"testing"
+
+	codefly "github.com/codefly-dev/sdk-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCorpusIsUpToDate(t *testing.T) {
+	corpus, err := Generate()
+	require.NoError(t, err)
+	encoded, err := corpus.Marshal()
+	require.NoError(t, err)
+	require.Equal(t, string(embeddedCorpus), string(encoded), "run go generate ./workcontextvectors")
+
+	again, err := Generate()
+	require.NoError(t, err)
+	reencoded, err := again.Marshal()
+	require.NoError(t, err)
+	require.Equal(t, encoded, reencoded, "generation is deterministic")
+}
+
+func TestGoVerifierPassesCorpus(t *testing.T) {
+	corpus, err := Load()
+	require.NoError(t, err)
+	require.NoError(t, Verify(corpus))
+
+	names := make(map[string]struct{}, len(corpus.Vectors))
+	reasons := make(map[string]struct{})
+	for _, vector := range corpus.Vectors {
+		_, duplicate := names[vector.Name]
+		require.False(t, duplicate, vector.Name)
+		names[vector.Name] = struct{}{}
+		if vector.Expected.Valid {
+			require.NotEmpty(t, vector.Expected.Claims, vector.Name)
+			continue
+		}
+		reasons[vector.Expected.Reason] = struct{}{}
+	}
+	verification := []codefly.WorkContextErrorReason{
+		codefly.WorkContextReasonMissing,
+		codefly.WorkContextReasonMalformed,
+		codefly.WorkContextReasonUnknownKey,
+		codefly.WorkContextReasonBadSignature,
+		codefly.WorkContextReasonExpired,
+		codefly.WorkContextReasonNotYetValid,
+		codefly.WorkContextReasonAudienceMismatch,
+		codefly.WorkContextReasonClaimsMismatch,
+	}
+	for _, reason := range verification {
+		require.Contains(t, reasons, string(reason))
+	}
+	// The other stable reasons depend on state outside a token, such as
+	// revocation lists, scopes, or the signer's delegation policy, so no
+	// vector can produce them; the corpus must not invent new ones either.
+	stable := append(verification,
+		codefly.WorkContextReasonCertificateBinding,
+		codefly.WorkContextReasonScopeDenied,
+		codefly.WorkContextReasonDelegationDenied,
+		codefly.WorkContextReasonRevoked,
+		codefly.WorkContextReasonKeysUnavailable,
+		codefly.WorkContextReasonRevocationUnavailable,
+		codefly.WorkContextReasonInvalidArgument,
+	)
+	for reason := range reasons {
+		require.Contains(t, stable, codefly.WorkContextErrorReason(reason))
+	}
+}
+
+func TestVerifyReportsDivergingVectors(t *testing.T) {
+	corpus, err := Load()
+	require.NoError(t, err)
+	corpus.Vectors[0].Expectations.TenantID = "tenant-other"
+	corpus.Vectors[len(corpus.Vectors)-1].Expected.Reason = string(codefly.WorkContextReasonExpired)
+	err = Verify(corpus)
+	require.ErrorContains(t, err, corpus.Vectors[0].Name+": got valid=false reason=\"claims_mismatch\"")
+	require.ErrorContains(t, err, corpus.Vectors[len(corpus.Vectors)-1].Name)
+
+	corpus.Version = Version + 1
+	require.Error(t, Verify(corpus))
+}